@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/yowger/golang-api-study/internal/apperror"
+)
+
+/*
+	GET /items/export downloads the whole catalog as items.json, POST
+	/items/import puts such a file back
+
+		the import replaces every item with the uploaded array and keeps their
+		ids. Each entry goes through items.schema.json like a POST body and also
+		needs an id, which has to be unique in the file
+
+		one bad entry rejects the whole file with a 422 keyed by its index,
+		like "2.price", and the catalog is left as it was. A good file is
+		swapped in under one lock, readers see either the old catalog or the
+		new one
+
+		an import counts as a single change: the items version moves once and
+		the cache is emptied, but there are no history entries and no events
+		per item. image_url is kept as exported, the files in IMAGE_DIR are not
+		part of the backup
+*/
+
+const (
+	exportFilename = "items.json"
+	maxImportSize  = 10 << 20
+)
+
+type importResult struct {
+	Imported int `json:"imported"`
+}
+
+func exportItems(response http.ResponseWriter) {
+	itemsMu.RLock()
+	exported := make([]Item, len(items))
+	copy(exported, items)
+	version := itemsVersion
+	itemsMu.RUnlock()
+
+	response.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", exportFilename))
+	response.Header().Set("X-Items-Version", strconv.FormatUint(version, 10))
+	respondWithJSON(response, http.StatusOK, exported)
+}
+
+func importItems(response http.ResponseWriter, request *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(response, request.Body, maxImportSize))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			apperror.WriteError(response, apperror.New(http.StatusRequestEntityTooLarge, "payload_too_large",
+				fmt.Sprintf("import must be at most %d bytes", maxImportSize)))
+			return
+		}
+
+		apperror.WriteError(response, apperror.BadRequest("invalid request body"))
+		return
+	}
+
+	imported, err := parseImport(body)
+	if err != nil {
+		apperror.WriteError(response, err)
+		return
+	}
+
+	itemsMu.Lock()
+	items = imported
+	itemsCache.clear()
+	bumpItemsVersion()
+	itemsMu.Unlock()
+
+	respondWithJSON(response, http.StatusOK, importResult{Imported: len(imported)})
+}
+
+// parseImport validates every entry before returning any, so a file is
+// either taken whole or not at all.
+func parseImport(body []byte) ([]Item, error) {
+	var entries []json.RawMessage
+	if err := json.Unmarshal(body, &entries); err != nil || entries == nil {
+		return nil, apperror.BadRequest("request body must be a JSON array of items")
+	}
+
+	imported := make([]Item, 0, len(entries))
+	fields := map[string]string{}
+	seen := map[int]int{}
+
+	for index, entry := range entries {
+		item, err := parseItem(entry)
+		if err != nil {
+			appErr := apperror.From(err)
+			if appErr.Fields == nil {
+				fields[strconv.Itoa(index)] = appErr.Message
+				continue
+			}
+
+			for field, message := range appErr.Fields {
+				if field == "body" {
+					fields[strconv.Itoa(index)] = message
+				} else {
+					fields[fmt.Sprintf("%d.%s", index, field)] = message
+				}
+			}
+			continue
+		}
+
+		idField := fmt.Sprintf("%d.id", index)
+		switch first, duplicate := seen[item.ID]; {
+		case item.ID < 1:
+			fields[idField] = "must be a positive item id"
+		case duplicate:
+			fields[idField] = fmt.Sprintf("duplicates the id of entry %d", first)
+		default:
+			seen[item.ID] = index
+		}
+
+		imported = append(imported, item)
+	}
+
+	if len(fields) > 0 {
+		return nil, apperror.Validation(fields)
+	}
+
+	return imported, nil
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestExportImportRoundTrip(t *testing.T) {
+	original := items
+	t.Cleanup(func() { items = original })
+	items = []Item{
+		{ID: 1, Name: "Laptop", Price: 1000, Tags: []string{"electronics", "work"}},
+		{ID: 4, Name: "Desk", Price: 300, ImageURL: "/items/4/image"},
+		{ID: 9, Name: "Mug", Price: 10},
+	}
+	want := slices.Clone(items)
+	useItemHistory(t)
+
+	router := newRouter()
+
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/items/export", nil))
+	if response.Code != http.StatusOK {
+		t.Fatalf("export: expected status %d, got %d", http.StatusOK, response.Code)
+	}
+	if got := response.Header().Get("Content-Disposition"); got != `attachment; filename="items.json"` {
+		t.Errorf("expected an items.json attachment, got %q", got)
+	}
+	backup := response.Body.String()
+
+	importFile := func(body string) {
+		t.Helper()
+
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, newJSONRequest(http.MethodPost, "/items/import", body))
+		if response.Code != http.StatusOK {
+			t.Fatalf("import: expected status %d, got %d: %s", http.StatusOK, response.Code, response.Body)
+		}
+	}
+
+	importFile(`[]`)
+	if len(items) != 0 {
+		t.Fatalf("expected an empty catalog, got %+v", items)
+	}
+
+	version := currentItemsVersion()
+	importFile(backup)
+
+	if !reflect.DeepEqual(items, want) {
+		t.Errorf("expected the catalog back as %+v, got %+v", want, items)
+	}
+	if currentItemsVersion() != version+1 {
+		t.Error("expected an import to bump the items version once")
+	}
+
+	// ids survive the trip, new items carry on after the highest one
+	response = httptest.NewRecorder()
+	router.ServeHTTP(response, newJSONRequest(http.MethodPost, "/items", `{"name":"Lamp","price":40}`))
+	if location := response.Header().Get("Location"); location != "/items/10" {
+		t.Errorf("expected the next item at /items/10, got %q", location)
+	}
+}
+
+func TestImportReplacesCachedItems(t *testing.T) {
+	useItemCache(t, time.Minute)
+
+	if name := getItemName(t, "1"); name != "Laptop" {
+		t.Fatalf("expected Laptop, got %q", name)
+	}
+
+	response := httptest.NewRecorder()
+	newRouter().ServeHTTP(response, newJSONRequest(http.MethodPost, "/items/import", `[{"id":1,"name":"Gaming laptop","price":1500}]`))
+	if response.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, response.Code, response.Body)
+	}
+
+	var result importResult
+	if err := json.NewDecoder(response.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Imported != 1 {
+		t.Errorf("expected 1 item imported, got %d", result.Imported)
+	}
+
+	if name := getItemName(t, "1"); name != "Gaming laptop" {
+		t.Errorf("expected the cache to be emptied by the import, got %q", name)
+	}
+}
+
+func TestImportRejectsInvalidFile(t *testing.T) {
+	original := items
+	items = slices.Clone(items)
+	t.Cleanup(func() { items = original })
+	useItemHistory(t)
+
+	router := newRouter()
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		fields map[string]string
+	}{
+		{name: "not an array", body: `{"id":1,"name":"Laptop","price":1000}`, status: http.StatusBadRequest},
+		{name: "null", body: `null`, status: http.StatusBadRequest},
+		{name: "malformed", body: `[{"id":1,`, status: http.StatusBadRequest},
+		{
+			name:   "invalid entry",
+			body:   `[{"id":1,"name":"Laptop","price":1000},{"id":2,"name":"","price":-1}]`,
+			status: http.StatusUnprocessableEntity,
+			fields: map[string]string{"1.name": "", "1.price": ""},
+		},
+		{
+			name:   "missing id",
+			body:   `[{"name":"Laptop","price":1000}]`,
+			status: http.StatusUnprocessableEntity,
+			fields: map[string]string{"0.id": ""},
+		},
+		{
+			name:   "duplicate id",
+			body:   `[{"id":1,"name":"Laptop","price":1000},{"id":1,"name":"Phone","price":500}]`,
+			status: http.StatusUnprocessableEntity,
+			fields: map[string]string{"1.id": "duplicates the id of entry 0"},
+		},
+		{
+			name:   "entry that isn't an object",
+			body:   `[{"id":1,"name":"Laptop","price":1000},"Phone"]`,
+			status: http.StatusUnprocessableEntity,
+			fields: map[string]string{"1": ""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := slices.Clone(items)
+			version := currentItemsVersion()
+
+			response := httptest.NewRecorder()
+			router.ServeHTTP(response, newJSONRequest(http.MethodPost, "/items/import", tt.body))
+
+			if response.Code != tt.status {
+				t.Fatalf("expected status %d, got %d: %s", tt.status, response.Code, response.Body)
+			}
+
+			var body struct {
+				Fields map[string]string `json:"fields"`
+			}
+			if err := json.NewDecoder(response.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			for field, message := range tt.fields {
+				got, ok := body.Fields[field]
+				if !ok || (message != "" && got != message) {
+					t.Errorf("expected an error for %s, got %v", field, body.Fields)
+				}
+			}
+
+			if !reflect.DeepEqual(items, before) || currentItemsVersion() != version {
+				t.Errorf("expected a rejected import to leave the catalog alone, got %+v", items)
+			}
+		})
+	}
+}
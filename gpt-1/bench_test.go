@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// catalogSizes are the item counts every benchmark runs with, go test
+// -bench 'ListItems/items=1000' picks one.
+var catalogSizes = []int{10, 100, 1000, 10000}
+
+// useCatalog replaces the items with n generated ones until the benchmark ends.
+func useCatalog(b *testing.B, n int) {
+	b.Helper()
+
+	catalog := make([]Item, n)
+	for i := range catalog {
+		catalog[i] = Item{ID: i + 1, Name: fmt.Sprintf("Item %d", i+1), Price: 100 + i%1000}
+	}
+
+	original := items
+	items = catalog
+	b.Cleanup(func() { items = original })
+}
+
+// discardWriter is a ResponseWriter that throws the body away, so the
+// benchmarks measure the handlers rather than a growing recorder buffer.
+type discardWriter struct {
+	header http.Header
+}
+
+func (w *discardWriter) Header() http.Header         { return w.header }
+func (w *discardWriter) Write(p []byte) (int, error) { return io.Discard.Write(p) }
+func (w *discardWriter) WriteHeader(int)             {}
+
+func newDiscardWriter() *discardWriter {
+	return &discardWriter{header: make(http.Header)}
+}
+
+func BenchmarkListItems(b *testing.B) {
+	for _, n := range catalogSizes {
+		b.Run(fmt.Sprintf("items=%d", n), func(b *testing.B) {
+			useCatalog(b, n)
+			request := httptest.NewRequest(http.MethodGet, "/items", nil)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				getItems(newDiscardWriter(), request)
+			}
+		})
+	}
+}
+
+func BenchmarkCreateItem(b *testing.B) {
+	for _, n := range catalogSizes {
+		b.Run(fmt.Sprintf("items=%d", n), func(b *testing.B) {
+			useCatalog(b, n)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				request := httptest.NewRequest(http.MethodPost, "/items", strings.NewReader(`{"name":"Monitor","price":250}`))
+				createItem(newDiscardWriter(), request)
+
+				// drop the new item again so every create sees n items
+				b.StopTimer()
+				itemsMu.Lock()
+				items = items[:n]
+				itemsMu.Unlock()
+				b.StartTimer()
+			}
+		})
+	}
+}
+
+func BenchmarkRespondWithJSON(b *testing.B) {
+	for _, n := range catalogSizes {
+		b.Run(fmt.Sprintf("items=%d", n), func(b *testing.B) {
+			useCatalog(b, n)
+			payload := items
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				respondWithJSON(newDiscardWriter(), http.StatusOK, payload)
+			}
+		})
+	}
+}
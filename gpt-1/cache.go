@@ -0,0 +1,80 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+/*
+	getItem answers from itemsCache while an entry is fresh, so hot items don't
+	have to wait for itemsMu behind writers
+
+		ITEM_CACHE_TTL sets how long an entry lives, like 30s or 500ms, 0 turns
+		the cache off
+
+	entries are filled by findItemByID and dropped by every change to the item,
+	both while holding itemsMu, so a reader can never put back an item that a
+	writer has already replaced
+*/
+
+const defaultItemCacheTTL = 5 * time.Second
+
+type cachedItem struct {
+	item    Item
+	expires time.Time
+}
+
+type itemCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[int]cachedItem
+	// now is swapped by the tests to move past the ttl
+	now func() time.Time
+}
+
+func newItemCache(ttl time.Duration) *itemCache {
+	return &itemCache{ttl: ttl, entries: make(map[int]cachedItem), now: time.Now}
+}
+
+// itemsCache stays off until main configures it, tests turn it on as needed
+var itemsCache = newItemCache(0)
+
+func (cache *itemCache) get(id int) (Item, bool) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	entry, ok := cache.entries[id]
+	if !ok {
+		return Item{}, false
+	}
+
+	if !cache.now().Before(entry.expires) {
+		delete(cache.entries, id)
+		return Item{}, false
+	}
+
+	return entry.item, true
+}
+
+func (cache *itemCache) set(item Item) {
+	if cache.ttl <= 0 {
+		return
+	}
+
+	cache.mu.Lock()
+	cache.entries[item.ID] = cachedItem{item: item, expires: cache.now().Add(cache.ttl)}
+	cache.mu.Unlock()
+}
+
+func (cache *itemCache) invalidate(id int) {
+	cache.mu.Lock()
+	delete(cache.entries, id)
+	cache.mu.Unlock()
+}
+
+// clear drops every entry, for changes that replace the whole catalog
+func (cache *itemCache) clear() {
+	cache.mu.Lock()
+	clear(cache.entries)
+	cache.mu.Unlock()
+}
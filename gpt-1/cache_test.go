@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// useItemCache turns the cache on with a clock the test moves by hand.
+func useItemCache(t *testing.T, ttl time.Duration) *time.Time {
+	t.Helper()
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cache := newItemCache(ttl)
+	cache.now = func() time.Time { return now }
+
+	original, originalItems := itemsCache, items
+	itemsCache, items = cache, slices.Clone(items)
+	t.Cleanup(func() { itemsCache, items = original, originalItems })
+	useItemHistory(t)
+
+	return &now
+}
+
+func getItemName(t *testing.T, id string) string {
+	t.Helper()
+
+	response := httptest.NewRecorder()
+	getItem(response, httptest.NewRequest(http.MethodGet, "/items/"+id, nil))
+
+	if response.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, response.Code)
+	}
+
+	var item Item
+	if err := json.NewDecoder(response.Body).Decode(&item); err != nil {
+		t.Fatal(err)
+	}
+	return item.Name
+}
+
+// renameItemBehindCache changes the stored item without telling the cache, so
+// only a cache hit can still return the old name.
+func renameItemBehindCache(id int, name string) {
+	itemsMu.Lock()
+	items[indexOfItem(id)].Name = name
+	itemsMu.Unlock()
+}
+
+func TestItemCacheHitAndExpiry(t *testing.T) {
+	now := useItemCache(t, time.Minute)
+
+	if name := getItemName(t, "1"); name != "Laptop" {
+		t.Fatalf("expected Laptop, got %q", name)
+	}
+
+	renameItemBehindCache(1, "Notebook")
+
+	*now = now.Add(59 * time.Second)
+	if name := getItemName(t, "1"); name != "Laptop" {
+		t.Errorf("expected the cached Laptop, got %q", name)
+	}
+
+	*now = now.Add(time.Second)
+	if name := getItemName(t, "1"); name != "Notebook" {
+		t.Errorf("expected the entry to expire after the ttl, got %q", name)
+	}
+}
+
+func TestItemCacheInvalidatedOnChange(t *testing.T) {
+	useItemCache(t, time.Minute)
+
+	getItemName(t, "2")
+
+	request := httptest.NewRequest(http.MethodPut, "/items/2", strings.NewReader(`{"name":"Smartphone","price":450}`))
+	updateItem(httptest.NewRecorder(), request)
+
+	if name := getItemName(t, "2"); name != "Smartphone" {
+		t.Errorf("expected the update to drop the cached item, got %q", name)
+	}
+
+	deleteItem(httptest.NewRecorder(), httptest.NewRequest(http.MethodDelete, "/items/2", nil))
+
+	response := httptest.NewRecorder()
+	getItem(response, httptest.NewRequest(http.MethodGet, "/items/2", nil))
+	if response.Code != http.StatusNotFound {
+		t.Errorf("expected status %d after delete, got %d", http.StatusNotFound, response.Code)
+	}
+}
+
+func TestItemCacheDisabled(t *testing.T) {
+	useItemCache(t, 0)
+
+	getItemName(t, "3")
+	renameItemBehindCache(3, "Slate")
+
+	if name := getItemName(t, "3"); name != "Slate" {
+		t.Errorf("expected a zero ttl to skip the cache, got %q", name)
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"mime"
+	"net/http"
+
+	"github.com/yowger/golang-api-study/internal/apperror"
+)
+
+/*
+	the item routes only read JSON, a form or plain text body gets a clear 415
+	instead of a confusing decode error
+
+		parameters are fine, application/json; charset=utf-8 is accepted
+
+		the image upload is multipart and checks its own content, it is not
+		wrapped
+*/
+
+func requireJSON(next http.HandlerFunc) http.HandlerFunc {
+	return func(response http.ResponseWriter, request *http.Request) {
+		mediaType, _, err := mime.ParseMediaType(request.Header.Get("Content-Type"))
+		if err != nil || mediaType != "application/json" {
+			apperror.WriteError(response, apperror.New(http.StatusUnsupportedMediaType, "unsupported_media_type",
+				"Content-Type must be application/json"))
+			return
+		}
+
+		next(response, request)
+	}
+}
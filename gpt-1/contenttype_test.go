@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// newJSONRequest builds a request with a JSON body, as requireJSON expects.
+func newJSONRequest(method, target, body string) *http.Request {
+	request := httptest.NewRequest(method, target, strings.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+
+	return request
+}
+
+func TestRequireJSON(t *testing.T) {
+	original := items
+	items = slices.Clone(items)
+	t.Cleanup(func() { items = original })
+	useItemHistory(t)
+
+	router := newRouter()
+
+	tests := []struct {
+		method, target, contentType string
+		status                      int
+	}{
+		{http.MethodPost, "/items", "text/plain", http.StatusUnsupportedMediaType},
+		{http.MethodPost, "/items", "application/x-www-form-urlencoded", http.StatusUnsupportedMediaType},
+		{http.MethodPost, "/items", "", http.StatusUnsupportedMediaType},
+		{http.MethodPost, "/items", "application/json; charset=utf-8", http.StatusCreated},
+		{http.MethodPut, "/items/1", "text/plain", http.StatusUnsupportedMediaType},
+		{http.MethodPut, "/items/1", "Application/JSON", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.contentType, func(t *testing.T) {
+			request := httptest.NewRequest(tt.method, tt.target, strings.NewReader(`{"name":"Monitor","price":250}`))
+			if tt.contentType != "" {
+				request.Header.Set("Content-Type", tt.contentType)
+			}
+
+			response := httptest.NewRecorder()
+			router.ServeHTTP(response, request)
+
+			if response.Code != tt.status {
+				t.Fatalf("expected status %d, got %d", tt.status, response.Code)
+			}
+
+			if tt.status == http.StatusUnsupportedMediaType {
+				var body struct{ Code string }
+				if err := json.NewDecoder(response.Body).Decode(&body); err != nil {
+					t.Fatal(err)
+				}
+				if body.Code != "unsupported_media_type" {
+					t.Errorf("expected code unsupported_media_type, got %q", body.Code)
+				}
+			}
+		})
+	}
+
+	if len(items) != 4 {
+		t.Errorf("expected only the JSON create to add an item, got %d items", len(items))
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+	CORS_ALLOWED_ORIGINS lets a browser app on another origin call the API, it
+	is a comma separated list like https://app.example.com,http://localhost:5173
+
+		a listed Origin is echoed back in Access-Control-Allow-Origin together
+		with Access-Control-Allow-Credentials so cookies and auth headers work
+
+		"*" allows every origin but without credentials, browsers refuse to
+		combine the two anyway
+
+		an Origin that is not listed gets no CORS headers at all and the browser
+		blocks the response, requests without an Origin are not affected
+
+	preflights (OPTIONS with Access-Control-Request-Method) are answered here with
+	204 and never reach the routes
+*/
+
+const corsMaxAge = 10 * time.Minute
+
+var (
+	corsAllowedOrigins []string
+
+	corsAllowedMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete}
+	corsAllowedHeaders = []string{"Content-Type"}
+	// headers the browser hides from scripts unless they are listed
+	corsExposedHeaders = []string{"Location", "X-Items-Version", "X-Dry-Run", "Retry-After"}
+)
+
+func parseAllowedOrigins(value string) []string {
+	var origins []string
+	for _, origin := range strings.Split(value, ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins = append(origins, strings.TrimSuffix(origin, "/"))
+		}
+	}
+
+	return origins
+}
+
+func withCORS(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		origin := request.Header.Get("Origin")
+		preflight := request.Method == http.MethodOptions && request.Header.Get("Access-Control-Request-Method") != ""
+
+		if origin == "" || len(corsAllowedOrigins) == 0 {
+			next.ServeHTTP(response, request)
+			return
+		}
+
+		header := response.Header()
+		header.Add("Vary", "Origin")
+
+		wildcard := slices.Contains(corsAllowedOrigins, "*")
+		allowed := wildcard || slices.Contains(corsAllowedOrigins, origin)
+
+		if allowed {
+			if wildcard {
+				header.Set("Access-Control-Allow-Origin", "*")
+			} else {
+				header.Set("Access-Control-Allow-Origin", origin)
+				header.Set("Access-Control-Allow-Credentials", "true")
+			}
+			header.Set("Access-Control-Expose-Headers", strings.Join(corsExposedHeaders, ", "))
+		}
+
+		if !preflight {
+			next.ServeHTTP(response, request)
+			return
+		}
+
+		if allowed {
+			header.Set("Access-Control-Allow-Methods", strings.Join(corsAllowedMethods, ", "))
+			header.Set("Access-Control-Allow-Headers", strings.Join(corsAllowedHeaders, ", "))
+			header.Set("Access-Control-Max-Age", strconv.Itoa(int(corsMaxAge.Seconds())))
+		}
+
+		response.WriteHeader(http.StatusNoContent)
+	})
+}
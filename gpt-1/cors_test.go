@@ -0,0 +1,103 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func useAllowedOrigins(t *testing.T, origins ...string) {
+	t.Helper()
+
+	original := corsAllowedOrigins
+	corsAllowedOrigins = origins
+	t.Cleanup(func() { corsAllowedOrigins = original })
+}
+
+func TestParseAllowedOrigins(t *testing.T) {
+	got := parseAllowedOrigins(" https://a.example , ,http://localhost:5173/")
+	want := []string{"https://a.example", "http://localhost:5173"}
+
+	if !slices.Equal(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	if got := parseAllowedOrigins(""); len(got) != 0 {
+		t.Errorf("expected no origins, got %v", got)
+	}
+}
+
+func TestCORS(t *testing.T) {
+	useAllowedOrigins(t, "https://app.example")
+
+	tests := []struct {
+		name        string
+		method      string
+		origin      string
+		preflight   bool
+		status      int
+		allowOrigin string
+	}{
+		{name: "allowed origin", method: http.MethodGet, origin: "https://app.example", status: http.StatusOK, allowOrigin: "https://app.example"},
+		{name: "disallowed origin", method: http.MethodGet, origin: "https://evil.example", status: http.StatusOK},
+		{name: "no origin", method: http.MethodGet, status: http.StatusOK},
+		{name: "preflight", method: http.MethodOptions, origin: "https://app.example", preflight: true, status: http.StatusNoContent, allowOrigin: "https://app.example"},
+		{name: "disallowed preflight", method: http.MethodOptions, origin: "https://evil.example", preflight: true, status: http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(tt.method, "/items", nil)
+			if tt.origin != "" {
+				request.Header.Set("Origin", tt.origin)
+			}
+			if tt.preflight {
+				request.Header.Set("Access-Control-Request-Method", http.MethodPost)
+				request.Header.Set("Access-Control-Request-Headers", "Content-Type")
+			}
+
+			response := httptest.NewRecorder()
+			newRouter().ServeHTTP(response, request)
+
+			if response.Code != tt.status {
+				t.Fatalf("expected status %d, got %d", tt.status, response.Code)
+			}
+
+			header := response.Header()
+			if got := header.Get("Access-Control-Allow-Origin"); got != tt.allowOrigin {
+				t.Errorf("expected Access-Control-Allow-Origin %q, got %q", tt.allowOrigin, got)
+			}
+
+			allowed := tt.allowOrigin != ""
+			if got := header.Get("Access-Control-Allow-Credentials") == "true"; got != allowed {
+				t.Errorf("expected credentials allowed %v, got %v", allowed, got)
+			}
+			if got := header.Get("Access-Control-Allow-Methods") != ""; got != (allowed && tt.preflight) {
+				t.Errorf("unexpected Access-Control-Allow-Methods %q", header.Get("Access-Control-Allow-Methods"))
+			}
+			if tt.preflight && allowed && header.Get("Access-Control-Allow-Headers") != "Content-Type" {
+				t.Errorf("expected Content-Type to be an allowed header, got %q", header.Get("Access-Control-Allow-Headers"))
+			}
+			if tt.origin != "" && header.Get("Vary") != "Origin" {
+				t.Errorf("expected Vary: Origin, got %q", header.Get("Vary"))
+			}
+		})
+	}
+}
+
+func TestCORSWildcard(t *testing.T) {
+	useAllowedOrigins(t, "*")
+
+	request := httptest.NewRequest(http.MethodGet, "/items", nil)
+	request.Header.Set("Origin", "https://anywhere.example")
+
+	response := httptest.NewRecorder()
+	newRouter().ServeHTTP(response, request)
+
+	if got := response.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("expected Access-Control-Allow-Origin *, got %q", got)
+	}
+	if got := response.Header().Get("Access-Control-Allow-Credentials"); got != "" {
+		t.Errorf("a wildcard must not allow credentials, got %q", got)
+	}
+}
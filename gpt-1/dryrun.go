@@ -0,0 +1,38 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/yowger/golang-api-study/internal/apperror"
+)
+
+/*
+	?dry_run=true on POST /items, PUT /items/{id} and DELETE /items/{id} runs
+	the validation and works out the result without touching the catalog
+
+		the answer is always 200 with X-Dry-Run: true and the item as it would
+		be stored, or as it was for a delete
+
+		nothing else happens either: no new version, no history entry, no
+		event and no webhook
+*/
+
+func parseDryRun(request *http.Request) (bool, error) {
+	value := request.URL.Query().Get("dry_run")
+	if value == "" {
+		return false, nil
+	}
+
+	dryRun, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, apperror.BadRequest("dry_run must be true or false")
+	}
+
+	return dryRun, nil
+}
+
+func respondDryRun(response http.ResponseWriter, item Item) {
+	response.Header().Set("X-Dry-Run", "true")
+	respondWithJSON(response, http.StatusOK, item)
+}
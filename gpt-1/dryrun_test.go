@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestDryRun(t *testing.T) {
+	original := items
+	items = slices.Clone(items)
+	t.Cleanup(func() { items = original })
+	useItemHistory(t)
+
+	router := newRouter()
+	before := slices.Clone(items)
+	version := currentItemsVersion()
+
+	tests := []struct {
+		name, method, target, body string
+		want                       Item
+	}{
+		{name: "create", method: http.MethodPost, target: "/items?dry_run=true", body: `{"name":"Monitor","price":250}`, want: Item{ID: 4, Name: "Monitor", Price: 250}},
+		{name: "update", method: http.MethodPut, target: "/items/2?dry_run=true", body: `{"name":"Phone","price":450}`, want: Item{ID: 2, Name: "Phone", Price: 450}},
+		{name: "create with put", method: http.MethodPut, target: "/items/42?dry_run=true", body: `{"name":"Phone","price":450}`, want: Item{ID: 42, Name: "Phone", Price: 450}},
+		{name: "delete", method: http.MethodDelete, target: "/items/3?dry_run=1", want: Item{ID: 3, Name: "Tablet", Price: 300}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := httptest.NewRecorder()
+			router.ServeHTTP(response, newJSONRequest(tt.method, tt.target, tt.body))
+
+			if response.Code != http.StatusOK {
+				t.Fatalf("expected status %d, got %d: %s", http.StatusOK, response.Code, response.Body)
+			}
+			if response.Header().Get("X-Dry-Run") != "true" {
+				t.Errorf("expected X-Dry-Run: true, got %q", response.Header().Get("X-Dry-Run"))
+			}
+			if location := response.Header().Get("Location"); location != "" {
+				t.Errorf("expected no Location for a dry run, got %q", location)
+			}
+
+			var got Item
+			if err := json.NewDecoder(response.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %+v, got %+v", tt.want, got)
+			}
+
+			if !reflect.DeepEqual(items, before) {
+				t.Errorf("expected the catalog to stay %+v, got %+v", before, items)
+			}
+			if currentItemsVersion() != version {
+				t.Error("expected the items version to stay put")
+			}
+			if len(itemHistory) != 0 {
+				t.Errorf("expected no history, got %+v", itemHistory)
+			}
+		})
+	}
+}
+
+func TestDryRunErrors(t *testing.T) {
+	original := items
+	items = slices.Clone(items)
+	t.Cleanup(func() { items = original })
+
+	router := newRouter()
+
+	tests := []struct {
+		name, method, target, body string
+		status                     int
+	}{
+		{name: "invalid item", method: http.MethodPost, target: "/items?dry_run=true", body: `{"name":"","price":-1}`, status: http.StatusUnprocessableEntity},
+		{name: "invalid id", method: http.MethodPut, target: "/items/0?dry_run=true", body: `{"name":"Phone","price":450}`, status: http.StatusBadRequest},
+		{name: "unknown delete", method: http.MethodDelete, target: "/items/42?dry_run=true", status: http.StatusNotFound},
+		{name: "bad flag", method: http.MethodPost, target: "/items?dry_run=maybe", body: `{"name":"Monitor","price":250}`, status: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := httptest.NewRecorder()
+			router.ServeHTTP(response, newJSONRequest(tt.method, tt.target, tt.body))
+
+			if response.Code != tt.status {
+				t.Errorf("expected status %d, got %d", tt.status, response.Code)
+			}
+			if len(items) != 3 {
+				t.Errorf("expected the catalog to keep 3 items, got %d", len(items))
+			}
+		})
+	}
+}
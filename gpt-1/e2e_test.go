@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// startServer serves newRouter on a random port and returns its base URL.
+// The catalog is reset when the test ends.
+func startServer(t *testing.T) string {
+	t.Helper()
+
+	original := items
+	items = slices.Clone(items)
+
+	useItemHistory(t)
+
+	server := httptest.NewServer(newRouter())
+	t.Cleanup(func() {
+		server.Close()
+		items = original
+	})
+
+	return server.URL
+}
+
+func TestItemLifecycleE2E(t *testing.T) {
+	baseURL := startServer(t)
+
+	do := func(method, path, body string, wantStatus int, out any) *http.Response {
+		t.Helper()
+
+		var reader io.Reader
+		if body != "" {
+			reader = strings.NewReader(body)
+		}
+
+		request, err := http.NewRequest(method, baseURL+path, reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if body != "" {
+			request.Header.Set("Content-Type", "application/json")
+		}
+
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer response.Body.Close()
+
+		if response.StatusCode != wantStatus {
+			t.Fatalf("%s %s: expected status %d, got %d", method, path, wantStatus, response.StatusCode)
+		}
+
+		if out != nil {
+			if err := json.NewDecoder(response.Body).Decode(out); err != nil {
+				t.Fatalf("%s %s: %v", method, path, err)
+			}
+		}
+
+		return response
+	}
+
+	var created Item
+	response := do(http.MethodPost, "/items", `{"name":"Monitor","price":250}`, http.StatusCreated, &created)
+
+	location := response.Header.Get("Location")
+	if created.Name != "Monitor" || location != "/items/4" {
+		t.Fatalf("unexpected create %+v at %q", created, location)
+	}
+
+	var fetched Item
+	do(http.MethodGet, location, "", http.StatusOK, &fetched)
+	if !reflect.DeepEqual(fetched, created) {
+		t.Errorf("expected %+v, got %+v", created, fetched)
+	}
+
+	var updated Item
+	do(http.MethodPut, location, `{"name":"Monitor","price":199}`, http.StatusOK, &updated)
+	if updated.ID != created.ID || updated.Price != 199 {
+		t.Errorf("unexpected update %+v", updated)
+	}
+
+	do(http.MethodGet, location, "", http.StatusOK, &fetched)
+	if fetched.Price != 199 {
+		t.Errorf("expected the update to stick, got %+v", fetched)
+	}
+
+	do(http.MethodDelete, location, "", http.StatusNoContent, nil)
+	do(http.MethodGet, location, "", http.StatusNotFound, nil)
+
+	var listed []pricedItem
+	do(http.MethodGet, "/items", "", http.StatusOK, &listed)
+	if len(listed) != 3 {
+		t.Errorf("expected the 3 seed items after the cycle, got %d", len(listed))
+	}
+}
+
+func TestContentLengthE2E(t *testing.T) {
+	baseURL := startServer(t)
+
+	for _, path := range []string{"/items", "/items/1", "/items/stats", "/items/42"} {
+		response, err := http.Get(baseURL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		body, err := io.ReadAll(response.Body)
+		response.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// -1 would mean the server fell back to a chunked body
+		if response.ContentLength != int64(len(body)) {
+			t.Errorf("GET %s: expected Content-Length %d, got %d", path, len(body), response.ContentLength)
+		}
+		if got := response.Header.Get("Content-Type"); got != "application/json" {
+			t.Errorf("GET %s: expected Content-Type application/json, got %q", path, got)
+		}
+	}
+}
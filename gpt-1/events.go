@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/yowger/golang-api-study/internal/apperror"
+)
+
+/*
+	GET /items/events streams every item change as a server-sent event
+
+		event: item.created
+		data: {"type":"item.created","item":{...},"timestamp":"..."}
+
+	each connected client gets its own buffered channel in eventHub, a client
+	that falls too far behind misses events instead of blocking the handlers
+*/
+
+const subscriberBuffer = 16
+
+type eventHub struct {
+	mu          sync.Mutex
+	subscribers map[chan itemEvent]struct{}
+}
+
+func newEventHub() *eventHub {
+	return &eventHub{subscribers: make(map[chan itemEvent]struct{})}
+}
+
+var itemEvents = newEventHub()
+
+func (hub *eventHub) subscribe() chan itemEvent {
+	events := make(chan itemEvent, subscriberBuffer)
+
+	hub.mu.Lock()
+	hub.subscribers[events] = struct{}{}
+	hub.mu.Unlock()
+
+	return events
+}
+
+func (hub *eventHub) unsubscribe(events chan itemEvent) {
+	hub.mu.Lock()
+	delete(hub.subscribers, events)
+	hub.mu.Unlock()
+}
+
+func (hub *eventHub) publish(event itemEvent) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	for events := range hub.subscribers {
+		select {
+		case events <- event:
+		default:
+		}
+	}
+}
+
+// itemChanged fans a change out to the SSE subscribers and the webhook.
+func itemChanged(eventType string, item Item) {
+	event := itemEvent{Type: eventType, Item: item, Timestamp: time.Now().UTC()}
+
+	itemEvents.publish(event)
+	notifyWebhook(event)
+}
+
+func streamItemEvents(response http.ResponseWriter, request *http.Request) {
+	controller := http.NewResponseController(response)
+
+	// the stream outlives the server WriteTimeout, so lift it for this request
+	if err := controller.SetWriteDeadline(time.Time{}); err != nil {
+		apperror.WriteError(response, apperror.Internal(fmt.Errorf("streaming not supported: %w", err)))
+		return
+	}
+
+	events := itemEvents.subscribe()
+	defer itemEvents.unsubscribe(events)
+
+	response.Header().Set("Content-Type", "text/event-stream")
+	response.Header().Set("Cache-Control", "no-cache")
+	response.Header().Set("Connection", "keep-alive")
+	response.WriteHeader(http.StatusOK)
+
+	// a comment line lets clients know the subscription is live
+	fmt.Fprint(response, ": connected\n\n")
+	if err := controller.Flush(); err != nil {
+		return
+	}
+
+	for {
+		select {
+		case <-request.Context().Done():
+			return
+		case event := <-events:
+			data, err := json.Marshal(event)
+			if err != nil {
+				log.Printf("encoding %s event: %v", event.Type, err)
+				continue
+			}
+
+			fmt.Fprintf(response, "event: %s\ndata: %s\n\n", event.Type, data)
+			if err := controller.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
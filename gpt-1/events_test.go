@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStreamItemEvents(t *testing.T) {
+	original := items
+	t.Cleanup(func() { items = original })
+
+	server := httptest.NewServer(http.HandlerFunc(streamItemEvents))
+	t.Cleanup(server.Close)
+
+	response, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	if ct := response.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("expected text/event-stream, got %q", ct)
+	}
+
+	lines := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(response.Body)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+
+	next := func() string {
+		t.Helper()
+
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				t.Fatal("stream closed")
+			}
+			return line
+		case <-time.After(2 * time.Second):
+			t.Fatal("timed out waiting for the stream")
+		}
+		return ""
+	}
+
+	// wait until the handler has subscribed before triggering a change
+	if line := next(); line != ": connected" {
+		t.Fatalf("expected the connected comment, got %q", line)
+	}
+	next()
+
+	request := httptest.NewRequest(http.MethodPost, "/items", strings.NewReader(`{"name":"Monitor","price":250}`))
+	createItem(httptest.NewRecorder(), request)
+
+	if line := next(); line != "event: "+itemCreated {
+		t.Fatalf("expected the event name, got %q", line)
+	}
+
+	data, ok := strings.CutPrefix(next(), "data: ")
+	if !ok {
+		t.Fatal("expected a data line")
+	}
+
+	var event itemEvent
+	if err := json.Unmarshal([]byte(data), &event); err != nil {
+		t.Fatal(err)
+	}
+
+	if event.Type != itemCreated || event.Item.Name != "Monitor" {
+		t.Errorf("unexpected event %+v", event)
+	}
+}
+
+func TestStreamItemEventsDisconnect(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(streamItemEvents))
+	t.Cleanup(server.Close)
+
+	response, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bufio.NewReader(response.Body).ReadString('\n')
+	response.Body.Close()
+
+	// the handler must notice the disconnect and drop its subscription
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		itemEvents.mu.Lock()
+		subscribers := len(itemEvents.subscribers)
+		itemEvents.mu.Unlock()
+
+		if subscribers == 0 {
+			return
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("expected no subscribers after disconnect, got %d", subscribers)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
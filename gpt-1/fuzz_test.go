@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+// FuzzCreateItem feeds arbitrary bodies to createItem, any of them must end in
+// a 2xx or 4xx and never a panic or 5xx. Run it for longer with
+// go test -fuzz FuzzCreateItem ./gpt-1
+func FuzzCreateItem(f *testing.F) {
+	for _, seed := range []string{
+		`{"name":"Monitor","price":250}`,
+		`{"name":"","price":-1}`,
+		`{"name":"Mouse","price":9.99}`,
+		`{"name":"Mouse","price":1e400}`,
+		`{"name":"Mouse","price":99999999999999999999}`,
+		`{"name":"Mouse","price":10,"color":"red"}`,
+		`{"name":"Mouse","price":10}{"name":"Again","price":10}`,
+		`{"name":"\ud800","price":1}`,
+		`[{"name":"Mouse"}]`,
+		`{"name":{"nested":[[[[[]]]]]}}`,
+		`null`,
+		``,
+		"\x00\xff",
+	} {
+		f.Add([]byte(seed))
+	}
+
+	original := items
+	f.Cleanup(func() { items = original })
+
+	f.Fuzz(func(t *testing.T, body []byte) {
+		itemsMu.Lock()
+		items = slices.Clone(original)
+		itemsMu.Unlock()
+
+		response := httptest.NewRecorder()
+		createItem(response, httptest.NewRequest(http.MethodPost, "/items", bytes.NewReader(body)))
+
+		if response.Code >= http.StatusInternalServerError || response.Code < http.StatusOK {
+			t.Fatalf("body %q: got status %d: %s", body, response.Code, response.Body)
+		}
+	})
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yowger/golang-api-study/internal/apperror"
+)
+
+/*
+	every update and delete of an item is appended to its history, GET
+	/items/{id}/history lists the entries newest first
+
+		before and after are the item around the change, after is null for a
+		delete
+
+	the log is kept apart from items and is never trimmed, so the history of
+	a deleted item can still be read, which is also why createItem never hands
+	out the id of a deleted item again. a PUT that brings a deleted id back
+	carries on with its history
+*/
+
+type historyEntry struct {
+	Type      string    `json:"type"`
+	Before    Item      `json:"before"`
+	After     *Item     `json:"after"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// itemHistory is guarded by itemsMu, entries are appended in the same
+// critical section as the change they describe
+var itemHistory = map[int][]historyEntry{}
+
+// recordHistory must be called with itemsMu held. after is nil for a delete.
+func recordHistory(eventType string, before Item, after *Item) {
+	itemHistory[before.ID] = append(itemHistory[before.ID], historyEntry{
+		Type:      eventType,
+		Before:    before,
+		After:     after,
+		Timestamp: time.Now().UTC(),
+	})
+}
+
+func itemIDFromHistoryPath(request *http.Request) (int, error) {
+	idStr := strings.TrimPrefix(strings.TrimSuffix(request.URL.Path, "/history"), "/items/")
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		return 0, apperror.BadRequest("invalid item id")
+	}
+
+	return id, nil
+}
+
+func getItemHistory(response http.ResponseWriter, request *http.Request) {
+	id, err := itemIDFromHistoryPath(request)
+	if err != nil {
+		apperror.WriteError(response, err)
+		return
+	}
+
+	itemsMu.RLock()
+	entries, recorded := itemHistory[id]
+	entries = slices.Clone(entries)
+	exists := indexOfItem(id) >= 0
+	itemsMu.RUnlock()
+
+	if !recorded && !exists {
+		apperror.WriteError(response, apperror.NotFound(fmt.Sprintf("item %d not found", id)))
+		return
+	}
+
+	// an item that was never changed has an empty history, not a null one
+	if entries == nil {
+		entries = []historyEntry{}
+	}
+	slices.Reverse(entries)
+
+	respondWithJSON(response, http.StatusOK, entries)
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// useItemHistory gives the test an empty history, changes made by other
+// tests would otherwise show up in it and push up the next item id.
+func useItemHistory(t *testing.T) {
+	t.Helper()
+
+	original := itemHistory
+	itemHistory = map[int][]historyEntry{}
+	t.Cleanup(func() { itemHistory = original })
+}
+
+func getHistory(t *testing.T, router http.Handler, id string) (int, []historyEntry) {
+	t.Helper()
+
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/items/"+id+"/history", nil))
+
+	var entries []historyEntry
+	if response.Code == http.StatusOK {
+		if err := json.NewDecoder(response.Body).Decode(&entries); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return response.Code, entries
+}
+
+func TestItemHistory(t *testing.T) {
+	original := items
+	items = slices.Clone(items)
+	t.Cleanup(func() { items = original })
+	useItemHistory(t)
+
+	router := newRouter()
+
+	status, entries := getHistory(t, router, "2")
+	if status != http.StatusOK || entries == nil || len(entries) != 0 {
+		t.Fatalf("expected an empty history, got %d %v", status, entries)
+	}
+
+	for _, body := range []string{`{"name":"Phone","price":450}`, `{"name":"Smartphone","price":450}`} {
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, newJSONRequest(http.MethodPut, "/items/2", body))
+		if response.Code != http.StatusOK {
+			t.Fatalf("update: expected status %d, got %d", http.StatusOK, response.Code)
+		}
+	}
+
+	_, entries = getHistory(t, router, "2")
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(entries))
+	}
+
+	// newest first
+	latest, first := entries[0], entries[1]
+	if first.Before.Price != 500 || first.After == nil || first.After.Price != 450 {
+		t.Errorf("unexpected first change %+v", first)
+	}
+	if latest.Before.Name != "Phone" || latest.After == nil || latest.After.Name != "Smartphone" {
+		t.Errorf("unexpected latest change %+v", latest)
+	}
+	if latest.Type != itemUpdated || latest.Timestamp.Before(first.Timestamp) {
+		t.Errorf("expected the latest update to come first, got %+v then %+v", latest, first)
+	}
+
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, httptest.NewRequest(http.MethodDelete, "/items/2", nil))
+	if response.Code != http.StatusNoContent {
+		t.Fatalf("delete: expected status %d, got %d", http.StatusNoContent, response.Code)
+	}
+
+	status, entries = getHistory(t, router, "2")
+	if status != http.StatusOK || len(entries) != 3 {
+		t.Fatalf("expected the history to outlive the item, got %d with %d entries", status, len(entries))
+	}
+	if entries[0].Type != itemDeleted || entries[0].After != nil || entries[0].Before.Name != "Smartphone" {
+		t.Errorf("unexpected delete entry %+v", entries[0])
+	}
+
+	if status, _ := getHistory(t, router, "42"); status != http.StatusNotFound {
+		t.Errorf("expected status %d for an unknown item, got %d", http.StatusNotFound, status)
+	}
+	if status, _ := getHistory(t, router, "abc"); status != http.StatusBadRequest {
+		t.Errorf("expected status %d for a bad id, got %d", http.StatusBadRequest, status)
+	}
+}
+
+func TestCreateItemSkipsDeletedIDs(t *testing.T) {
+	original := items
+	items = slices.Clone(items)
+	t.Cleanup(func() { items = original })
+	useItemHistory(t)
+
+	deleteItem(httptest.NewRecorder(), httptest.NewRequest(http.MethodDelete, "/items/3", nil))
+
+	response := httptest.NewRecorder()
+	createItem(response, httptest.NewRequest(http.MethodPost, "/items", strings.NewReader(`{"name":"Monitor","price":250}`)))
+
+	if location := response.Header().Get("Location"); location != "/items/4" {
+		t.Errorf("expected the deleted id 3 to stay retired, got Location %q", location)
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/yowger/golang-api-study/internal/apperror"
+)
+
+/*
+	POST /items/{id}/image takes a multipart/form-data body with the file in
+	the "image" field, GET /items/{id}/image serves it back
+
+		the type is sniffed from the bytes rather than trusted from the client,
+		anything that isn't one of imageTypes is rejected with 415
+
+		files live in imageDir as item-{id}, a new upload replaces the old one
+*/
+
+const (
+	maxImageSize = 5 << 20
+	// multipart boundaries and part headers on top of the file itself
+	maxImageFormOverhead = 64 << 10
+)
+
+var imageDir = "uploads"
+
+var imageTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+func imagePath(id int) string {
+	return filepath.Join(imageDir, fmt.Sprintf("item-%d", id))
+}
+
+func itemIDFromImagePath(request *http.Request) (int, error) {
+	idStr := strings.TrimPrefix(strings.TrimSuffix(request.URL.Path, "/image"), "/items/")
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		return 0, apperror.BadRequest("invalid item id")
+	}
+
+	return id, nil
+}
+
+func uploadItemImage(response http.ResponseWriter, request *http.Request) {
+	id, err := itemIDFromImagePath(request)
+	if err != nil {
+		apperror.WriteError(response, err)
+		return
+	}
+
+	if _, err := findItemByID(id); err != nil {
+		apperror.WriteError(response, err)
+		return
+	}
+
+	request.Body = http.MaxBytesReader(response, request.Body, maxImageSize+maxImageFormOverhead)
+
+	file, header, err := request.FormFile("image")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			apperror.WriteError(response, imageTooLarge())
+			return
+		}
+
+		apperror.WriteError(response, apperror.BadRequest(`expected a multipart form with an "image" file`))
+		return
+	}
+	defer file.Close()
+
+	if header.Size > maxImageSize {
+		apperror.WriteError(response, imageTooLarge())
+		return
+	}
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		apperror.WriteError(response, apperror.Internal(err))
+		return
+	}
+
+	if contentType := http.DetectContentType(data); !imageTypes[contentType] {
+		apperror.WriteError(response, apperror.New(http.StatusUnsupportedMediaType, "unsupported_media_type",
+			fmt.Sprintf("expected a png, jpeg, gif or webp image, got %s", contentType)))
+		return
+	}
+
+	if err := saveItemImage(id, data); err != nil {
+		apperror.WriteError(response, apperror.Internal(err))
+		return
+	}
+
+	itemsMu.Lock()
+	index := indexOfItem(id)
+	var item Item
+	if index >= 0 {
+		item = items[index]
+		item.ImageURL = fmt.Sprintf("/items/%d/image", id)
+		recordHistory(itemUpdated, items[index], &item)
+		items[index] = item
+		itemsCache.invalidate(id)
+		bumpItemsVersion()
+	}
+	itemsMu.Unlock()
+
+	// the item may have been deleted while the file was being written
+	if index < 0 {
+		os.Remove(imagePath(id))
+		apperror.WriteError(response, apperror.NotFound(fmt.Sprintf("item %d not found", id)))
+		return
+	}
+
+	respondWithJSON(response, http.StatusOK, item)
+	itemChanged(itemUpdated, item)
+}
+
+func imageTooLarge() *apperror.Error {
+	return apperror.New(http.StatusRequestEntityTooLarge, "payload_too_large",
+		fmt.Sprintf("image must be at most %d bytes", maxImageSize))
+}
+
+// saveItemImage writes to a temp file first so a reader never sees half an
+// image.
+func saveItemImage(id int, data []byte) error {
+	if err := os.MkdirAll(imageDir, 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(imageDir, "upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), imagePath(id))
+}
+
+func getItemImage(response http.ResponseWriter, request *http.Request) {
+	id, err := itemIDFromImagePath(request)
+	if err != nil {
+		apperror.WriteError(response, err)
+		return
+	}
+
+	item, err := findItemByID(id)
+	if err != nil {
+		apperror.WriteError(response, err)
+		return
+	}
+
+	if item.ImageURL == "" {
+		apperror.WriteError(response, apperror.NotFound(fmt.Sprintf("item %d has no image", id)))
+		return
+	}
+
+	// ServeFile sniffs the content type since the file has no extension
+	http.ServeFile(response, request, imagePath(id))
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"testing"
+)
+
+// a png signature is all http.DetectContentType needs
+var pngImage = append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte{0}, 64)...)
+
+func imageUploadRequest(t *testing.T, target string, data []byte) *http.Request {
+	t.Helper()
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+
+	part, err := form.CreateFormFile("image", "photo.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(data)
+	form.Close()
+
+	request := httptest.NewRequest(http.MethodPost, target, &body)
+	request.Header.Set("Content-Type", form.FormDataContentType())
+
+	return request
+}
+
+func useTempImageDir(t *testing.T) {
+	t.Helper()
+
+	// uploads edit items in place, so work on a copy of the backing array
+	original, originalDir := items, imageDir
+	items, imageDir = slices.Clone(items), t.TempDir()
+	t.Cleanup(func() { items, imageDir = original, originalDir })
+	useItemHistory(t)
+}
+
+func TestUploadItemImage(t *testing.T) {
+	useTempImageDir(t)
+
+	response := httptest.NewRecorder()
+	uploadItemImage(response, imageUploadRequest(t, "/items/1/image", pngImage))
+
+	if response.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, response.Code, response.Body)
+	}
+
+	var item Item
+	if err := json.NewDecoder(response.Body).Decode(&item); err != nil {
+		t.Fatal(err)
+	}
+
+	if item.ImageURL != "/items/1/image" {
+		t.Errorf("expected image_url /items/1/image, got %q", item.ImageURL)
+	}
+
+	response = httptest.NewRecorder()
+	getItemImage(response, httptest.NewRequest(http.MethodGet, "/items/1/image", nil))
+
+	if response.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, response.Code)
+	}
+
+	if ct := response.Header().Get("Content-Type"); ct != "image/png" {
+		t.Errorf("expected image/png, got %q", ct)
+	}
+
+	if !bytes.Equal(response.Body.Bytes(), pngImage) {
+		t.Error("served image differs from the upload")
+	}
+
+	// the image survives a PUT that doesn't mention it
+	updateItem(httptest.NewRecorder(), httptest.NewRequest(http.MethodPut, "/items/1", bytes.NewBufferString(`{"name":"Laptop","price":900}`)))
+	if item, _ := findItemByID(1); item.ImageURL != "/items/1/image" {
+		t.Errorf("expected the update to keep the image, got %+v", item)
+	}
+
+	deleteItem(httptest.NewRecorder(), httptest.NewRequest(http.MethodDelete, "/items/1", nil))
+	if _, err := os.Stat(imagePath(1)); !os.IsNotExist(err) {
+		t.Errorf("expected the image file to be removed with the item, got %v", err)
+	}
+}
+
+func TestUploadItemImageRejected(t *testing.T) {
+	useTempImageDir(t)
+
+	tests := []struct {
+		name   string
+		target string
+		data   []byte
+		status int
+	}{
+		{name: "not an image", target: "/items/1/image", data: []byte("just some text"), status: http.StatusUnsupportedMediaType},
+		{name: "too large", target: "/items/1/image", data: append(pngImage, make([]byte, maxImageSize)...), status: http.StatusRequestEntityTooLarge},
+		{name: "unknown item", target: "/items/42/image", data: pngImage, status: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := httptest.NewRecorder()
+			uploadItemImage(response, imageUploadRequest(t, tt.target, tt.data))
+
+			if response.Code != tt.status {
+				t.Errorf("expected status %d, got %d: %s", tt.status, response.Code, response.Body)
+			}
+		})
+	}
+
+	if item, _ := findItemByID(1); item.ImageURL != "" {
+		t.Errorf("expected no image after rejected uploads, got %q", item.ImageURL)
+	}
+
+	response := httptest.NewRecorder()
+	getItemImage(response, httptest.NewRequest(http.MethodGet, "/items/1/image", nil))
+
+	if response.Code != http.StatusNotFound {
+		t.Errorf("expected status %d for an item without image, got %d", http.StatusNotFound, response.Code)
+	}
+}
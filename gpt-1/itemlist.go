@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/sync/singleflight"
+)
+
+/*
+	concurrent GET /items asking for the same list share one computation
+
+		the key is the currency, tag filter and ?pretty of the request plus
+		itemsVersion, every request arriving while such a list is being priced
+		and encoded waits for it and gets the same bytes
+
+		a create, update or delete bumps the version, so a request after it
+		starts a flight of its own rather than joining one reading the old
+		catalog
+
+		nothing is kept once the flight lands, it only collapses requests that
+		overlap
+*/
+
+var itemListFlight singleflight.Group
+
+type itemListQuery struct {
+	currency string
+	rate     float64
+	tags     []string
+	pretty   bool
+}
+
+// itemList is an encoded GET /items body and the version it was read at.
+type itemList struct {
+	body    []byte
+	version uint64
+}
+
+func loadItemList(query itemListQuery) (itemList, error) {
+	itemsMu.RLock()
+	version := itemsVersion
+	itemsMu.RUnlock()
+
+	key := fmt.Sprintf("%d|%s|%s|%t", version, query.currency, strings.Join(query.tags, ","), query.pretty)
+	list, err, _ := itemListFlight.Do(key, func() (any, error) {
+		return buildItemList(query)
+	})
+	if err != nil {
+		return itemList{}, err
+	}
+
+	return list.(itemList), nil
+}
+
+func buildItemList(query itemListQuery) (itemList, error) {
+	itemsMu.RLock()
+	priced := []pricedItem{}
+	for _, item := range items {
+		if hasAllTags(item, query.tags) {
+			priced = append(priced, pricedItem{ID: item.ID, Name: item.Name, Price: convertPrice(item.Price, query.rate), Currency: query.currency, Tags: item.Tags})
+		}
+	}
+	version := itemsVersion
+	itemsMu.RUnlock()
+
+	body, err := encodeJSON(priced, query.pretty)
+	if err != nil {
+		return itemList{}, err
+	}
+
+	return itemList{body: body, version: version}, nil
+}
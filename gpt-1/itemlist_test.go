@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// TestConcurrentItemLists is meant for go test -race: the readers share
+// flights, and a writer keeps bumping the version underneath them.
+func TestConcurrentItemLists(t *testing.T) {
+	original := items
+	items = slices.Clone(items)
+	t.Cleanup(func() { items = original })
+	useItemHistory(t)
+
+	router := newRouter()
+
+	get := func(target string) *httptest.ResponseRecorder {
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, target, nil))
+		return response
+	}
+
+	want := get("/items?currency=eur")
+
+	const readers = 50
+	responses := make([]*httptest.ResponseRecorder, readers)
+
+	var wg sync.WaitGroup
+	for i := range readers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			responses[i] = get("/items?currency=eur")
+		}()
+	}
+	wg.Wait()
+
+	for i, response := range responses {
+		if response.Code != http.StatusOK {
+			t.Fatalf("reader %d: expected status %d, got %d", i, http.StatusOK, response.Code)
+		}
+		if response.Body.String() != want.Body.String() || response.Header().Get("X-Items-Version") != want.Header().Get("X-Items-Version") {
+			t.Errorf("reader %d: expected the same list as before, got %s", i, response.Body)
+		}
+	}
+
+	// a write between reads must not be hidden by a shared result
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, newJSONRequest(http.MethodPost, "/items", `{"name":"Lamp","price":40}`))
+	if response.Code != http.StatusCreated {
+		t.Fatalf("create: expected status %d, got %d", http.StatusCreated, response.Code)
+	}
+
+	wg.Add(readers + 1)
+	go func() {
+		defer wg.Done()
+		router.ServeHTTP(httptest.NewRecorder(), newJSONRequest(http.MethodPost, "/items", `{"name":"Desk","price":300}`))
+	}()
+	for range readers {
+		go func() {
+			defer wg.Done()
+			if response := get("/items?currency=eur"); response.Code != http.StatusOK {
+				t.Errorf("expected status %d, got %d", http.StatusOK, response.Code)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := get("/items").Body.String(); !strings.Contains(got, "Lamp") || !strings.Contains(got, "Desk") {
+		t.Errorf("expected both new items in the list, got %s", got)
+	}
+}
@@ -1,15 +1,44 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"math"
+	"net"
 	"net/http"
+	"os"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
+
+	"github.com/yowger/golang-api-study/internal/apperror"
 )
 
 const port = ":8080"
 
+/*
+	server timeouts, a zero value means no timeout at all
+
+		readHeaderTimeout: time to send the request headers, the main guard
+			against slowloris clients that trickle headers forever
+		readTimeout: time to read the whole request, body included
+		writeTimeout: time from the end of the request headers until the
+			response is written
+		idleTimeout: how long a keep-alive connection may wait for the next
+			request
+*/
+
+const (
+	readHeaderTimeout = 5 * time.Second
+	readTimeout       = 10 * time.Second
+	writeTimeout      = 10 * time.Second
+	idleTimeout       = 60 * time.Second
+)
+
 /*
 without tags: ID int
 
@@ -41,16 +70,21 @@ with tags: ID int `json:"id"`
 */
 
 type Item struct {
-	ID    int    `json:"id"`
-	Name  string `json:"name"`
-	Price int    `json:"price"`
+	ID       int      `json:"id"`
+	Name     string   `json:"name"`
+	Price    int      `json:"price"`
+	ImageURL string   `json:"image_url,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
 }
 
-var items = []Item{
-	{ID: 1, Name: "Laptop", Price: 1000},
-	{ID: 2, Name: "Phone", Price: 500},
-	{ID: 3, Name: "Tablet", Price: 300},
-}
+var (
+	itemsMu sync.RWMutex
+	items   = []Item{
+		{ID: 1, Name: "Laptop", Price: 1000},
+		{ID: 2, Name: "Phone", Price: 500},
+		{ID: 3, Name: "Tablet", Price: 300},
+	}
+)
 
 /*
 	interface{} is equivalent to any in TS
@@ -68,37 +102,563 @@ var items = []Item{
 
 */
 
+/*
+	the payload is encoded into a buffer before anything is sent, so a value
+	that can't be encoded still gets a clean 500 instead of a 200 with half a
+	body, and the Content-Length is known up front
+*/
+
 func respondWithJSON[T any](response http.ResponseWriter, code int, payload T) {
-	response.Header().Set("Content-Type", "application.json")
+	body, err := encodeJSON(payload, wantsPrettyJSON(response))
+	if err != nil {
+		log.Printf("encoding %T response: %v", payload, err)
+		apperror.WriteError(response, apperror.Internal(err))
+		return
+	}
+
+	writeJSONBody(response, code, body)
+}
+
+func encodeJSON[T any](payload T, pretty bool) ([]byte, error) {
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	if pretty {
+		encoder.SetIndent("", prettyIndent)
+	}
+	if err := encoder.Encode(payload); err != nil {
+		return nil, err
+	}
+
+	return body.Bytes(), nil
+}
+
+// writeJSONBody sends an already encoded body, see encodeJSON.
+func writeJSONBody(response http.ResponseWriter, code int, body []byte) {
+	response.Header().Set("Content-Type", "application/json")
+	response.Header().Set("Content-Length", strconv.Itoa(len(body)))
 	response.WriteHeader(code)
-	json.NewEncoder(response).Encode(payload)
+
+	// the status is already sent, a failed write (a closed connection) can only be logged
+	if _, err := response.Write(body); err != nil {
+		log.Printf("writing response: %v", err)
+	}
 }
 
-func getItems(response http.ResponseWriter) {
-	respondWithJSON(response, http.StatusOK, items)
+/*
+	201 responses point at the new resource with the Location header
+*/
+
+func respondCreated[T any](response http.ResponseWriter, location string, payload T) {
+	response.Header().Set("Location", location)
+	respondWithJSON(response, http.StatusCreated, payload)
+}
+
+/*
+	prices are stored in the base currency, ?currency= converts them using a
+	static rate table: 1 unit of baseCurrency = rate units of the currency
+*/
+
+const baseCurrency = "USD"
+
+var exchangeRates = map[string]float64{
+	"USD": 1,
+	"EUR": 0.92,
+	"GBP": 0.79,
+	"JPY": 150,
+}
+
+type pricedItem struct {
+	ID       int      `json:"id"`
+	Name     string   `json:"name"`
+	Price    float64  `json:"price"`
+	Currency string   `json:"currency"`
+	Tags     []string `json:"tags,omitempty"`
+}
+
+func convertPrice(price int, rate float64) float64 {
+	// round to cents so conversions don't leak float noise like 91.99999
+	return math.Round(float64(price)*rate*100) / 100
+}
+
+func getItems(response http.ResponseWriter, request *http.Request) {
+	currency := strings.ToUpper(request.URL.Query().Get("currency"))
+	if currency == "" {
+		currency = baseCurrency
+	}
+
+	rate, ok := exchangeRates[currency]
+	if !ok {
+		apperror.WriteError(response, apperror.BadRequest(fmt.Sprintf("unsupported currency %q", currency)))
+		return
+	}
+
+	tags, err := parseTagFilter(request)
+	if err != nil {
+		apperror.WriteError(response, err)
+		return
+	}
+
+	if request.URL.Query().Has("wait") {
+		params, err := parsePollParams(request)
+		if err != nil {
+			apperror.WriteError(response, err)
+			return
+		}
+
+		// the poll may outlast the server WriteTimeout, so push it past the wait;
+		// writers that can't move deadlines (like test recorders) have none
+		controller := http.NewResponseController(response)
+		controller.SetWriteDeadline(time.Now().Add(params.wait + writeTimeout))
+
+		if !waitForItemsChange(request.Context(), params) {
+			if request.Context().Err() == nil {
+				response.WriteHeader(http.StatusNotModified)
+			}
+			return
+		}
+	}
+
+	list, err := loadItemList(itemListQuery{currency: currency, rate: rate, tags: tags, pretty: wantsPrettyJSON(response)})
+	if err != nil {
+		log.Printf("encoding items: %v", err)
+		apperror.WriteError(response, apperror.Internal(err))
+		return
+	}
+
+	response.Header().Set("X-Items-Version", strconv.FormatUint(list.version, 10))
+	writeJSONBody(response, http.StatusOK, list.body)
+}
+
+func findItemByID(id int) (Item, error) {
+	itemsMu.RLock()
+	defer itemsMu.RUnlock()
+
+	for _, item := range items {
+		if item.ID == id {
+			// filled under itemsMu, see itemsCache
+			itemsCache.set(item)
+			return item, nil
+		}
+	}
+
+	return Item{}, apperror.NotFound(fmt.Sprintf("item %d not found", id))
+}
+
+func itemIDFromPath(request *http.Request) (int, error) {
+	idStr := strings.TrimPrefix(request.URL.Path, "/items/")
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		return 0, apperror.BadRequest("invalid item id")
+	}
+
+	return id, nil
 }
 
 func getItem(response http.ResponseWriter, request *http.Request) {
-	idStr := strings.TrimPrefix(request.URL.Path, "/")
+	id, err := itemIDFromPath(request)
+	if err != nil {
+		apperror.WriteError(response, err)
+		return
+	}
 
-	fmt.Println("id: ", idStr)
-	respondWithJSON(response, http.StatusOK, "test item")
+	item, cached := itemsCache.get(id)
+	if !cached {
+		item, err = findItemByID(id)
+		if err != nil {
+			apperror.WriteError(response, err)
+			return
+		}
+	}
+
+	respondWithJSON(response, http.StatusOK, item)
 }
 
-func main() {
+type itemStats struct {
+	Count        int     `json:"count"`
+	TotalPrice   int     `json:"total_price"`
+	AveragePrice float64 `json:"average_price"`
+	MinPrice     int     `json:"min_price"`
+	MaxPrice     int     `json:"max_price"`
+}
+
+func computeItemStats(items []Item) itemStats {
+	var stats itemStats
+
+	for i, item := range items {
+		if i == 0 || item.Price < stats.MinPrice {
+			stats.MinPrice = item.Price
+		}
+		if i == 0 || item.Price > stats.MaxPrice {
+			stats.MaxPrice = item.Price
+		}
+
+		stats.TotalPrice += item.Price
+	}
+
+	stats.Count = len(items)
+	// an empty catalog keeps every stat at zero
+	if stats.Count > 0 {
+		stats.AveragePrice = float64(stats.TotalPrice) / float64(stats.Count)
+	}
+
+	return stats
+}
+
+func getItemStats(response http.ResponseWriter) {
+	itemsMu.RLock()
+	stats := computeItemStats(items)
+	itemsMu.RUnlock()
+
+	respondWithJSON(response, http.StatusOK, stats)
+}
+
+func createItem(response http.ResponseWriter, request *http.Request) {
+	dryRun, err := parseDryRun(request)
+	if err != nil {
+		apperror.WriteError(response, err)
+		return
+	}
+
+	item, err := decodeItem(request)
+	if err != nil {
+		apperror.WriteError(response, err)
+		return
+	}
+	// images are only set by uploading one
+	item.ImageURL = ""
+
+	itemsMu.Lock()
+	item.ID = nextItemID()
+	if !dryRun {
+		items = append(items, item)
+		bumpItemsVersion()
+	}
+	itemsMu.Unlock()
+
+	if dryRun {
+		respondDryRun(response, item)
+		return
+	}
+
+	respondCreated(response, fmt.Sprintf("/items/%d", item.ID), item)
+	itemChanged(itemCreated, item)
+}
+
+func updateItem(response http.ResponseWriter, request *http.Request) {
+	id, err := itemIDFromPath(request)
+	if err != nil {
+		apperror.WriteError(response, err)
+		return
+	}
+	// PUT creates unknown ids, ids start at 1 like the ones POST hands out
+	if id < 1 {
+		apperror.WriteError(response, apperror.BadRequest("item id must be positive"))
+		return
+	}
+
+	dryRun, err := parseDryRun(request)
+	if err != nil {
+		apperror.WriteError(response, err)
+		return
+	}
+
+	item, err := decodeItem(request)
+	if err != nil {
+		apperror.WriteError(response, err)
+		return
+	}
+	// the id in the path wins over one in the body
+	item.ID = id
+
+	// the lookup and the write share one critical section, so two PUTs to a
+	// new id can't both create it
+	itemsMu.Lock()
+	index := indexOfItem(id)
+	created := index < 0
+	if created {
+		// images are only set by uploading one
+		item.ImageURL = ""
+	} else {
+		item.ImageURL = items[index].ImageURL
+	}
+	if !dryRun {
+		if created {
+			items = append(items, item)
+		} else {
+			recordHistory(itemUpdated, items[index], &item)
+			items[index] = item
+			itemsCache.invalidate(id)
+		}
+		bumpItemsVersion()
+	}
+	itemsMu.Unlock()
+
+	if dryRun {
+		respondDryRun(response, item)
+		return
+	}
+
+	if created {
+		respondCreated(response, fmt.Sprintf("/items/%d", id), item)
+		itemChanged(itemCreated, item)
+		return
+	}
+
+	respondWithJSON(response, http.StatusOK, item)
+	itemChanged(itemUpdated, item)
+}
+
+func deleteItem(response http.ResponseWriter, request *http.Request) {
+	id, err := itemIDFromPath(request)
+	if err != nil {
+		apperror.WriteError(response, err)
+		return
+	}
+
+	dryRun, err := parseDryRun(request)
+	if err != nil {
+		apperror.WriteError(response, err)
+		return
+	}
+
+	var item Item
+
+	itemsMu.Lock()
+	index := indexOfItem(id)
+	if index >= 0 {
+		item = items[index]
+		if !dryRun {
+			recordHistory(itemDeleted, item, nil)
+			items = append(items[:index:index], items[index+1:]...)
+			itemsCache.invalidate(id)
+			bumpItemsVersion()
+		}
+	}
+	itemsMu.Unlock()
+
+	if index < 0 {
+		apperror.WriteError(response, apperror.NotFound(fmt.Sprintf("item %d not found", id)))
+		return
+	}
+
+	if dryRun {
+		respondDryRun(response, item)
+		return
+	}
+
+	if item.ImageURL != "" {
+		if err := os.Remove(imagePath(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Printf("removing image of item %d: %v", id, err)
+		}
+	}
+
+	response.WriteHeader(http.StatusNoContent)
+	itemChanged(itemDeleted, item)
+}
+
+// nextItemID is one past the highest id, so removed items never collide with
+// new ones; deleted items live on in itemHistory. It must be called with
+// itemsMu held.
+func nextItemID() int {
+	id := 1
+	for _, existing := range items {
+		id = max(id, existing.ID+1)
+	}
+	for deleted := range itemHistory {
+		id = max(id, deleted+1)
+	}
+
+	return id
+}
+
+// indexOfItem must be called with itemsMu held.
+func indexOfItem(id int) int {
+	for i, item := range items {
+		if item.ID == id {
+			return i
+		}
+	}
+
+	return -1
+}
+
+/*
+	newRouter wires every route, main only adds configuration and the server,
+	so tests can serve the exact same handler
+*/
+
+func newRouter() http.Handler {
 	mux := http.NewServeMux()
 
-	mux.HandleFunc("/", func(response http.ResponseWriter, request *http.Request) {
+	mux.HandleFunc("/items", func(response http.ResponseWriter, request *http.Request) {
 		switch request.Method {
 		case http.MethodGet:
-			getItems(response)
+			getItems(response, request)
+		case http.MethodPost:
+			requireJSON(withCreateLimit(createItem))(response, request)
 		default:
-			message := map[string]string{"error": "Method not allowed"}
-			respondWithJSON(response, http.StatusMethodNotAllowed, message)
+			apperror.WriteError(response, apperror.MethodNotAllowed("Method not allowed"))
 		}
 	})
 
-	if serverError := http.ListenAndServe(port, mux); serverError != nil {
+	mux.HandleFunc("/health", func(response http.ResponseWriter, request *http.Request) {
+		switch request.Method {
+		case http.MethodGet:
+			getHealth(response)
+		default:
+			apperror.WriteError(response, apperror.MethodNotAllowed("Method not allowed"))
+		}
+	})
+
+	mux.HandleFunc(maintenancePath, func(response http.ResponseWriter, request *http.Request) {
+		switch request.Method {
+		case http.MethodPost:
+			requireJSON(toggleMaintenance)(response, request)
+		default:
+			apperror.WriteError(response, apperror.MethodNotAllowed("Method not allowed"))
+		}
+	})
+
+	mux.HandleFunc("/items/events", func(response http.ResponseWriter, request *http.Request) {
+		switch request.Method {
+		case http.MethodGet:
+			streamItemEvents(response, request)
+		default:
+			apperror.WriteError(response, apperror.MethodNotAllowed("Method not allowed"))
+		}
+	})
+
+	mux.HandleFunc("/items/export", func(response http.ResponseWriter, request *http.Request) {
+		switch request.Method {
+		case http.MethodGet:
+			exportItems(response)
+		default:
+			apperror.WriteError(response, apperror.MethodNotAllowed("Method not allowed"))
+		}
+	})
+
+	mux.HandleFunc("/items/import", func(response http.ResponseWriter, request *http.Request) {
+		switch request.Method {
+		case http.MethodPost:
+			requireJSON(importItems)(response, request)
+		default:
+			apperror.WriteError(response, apperror.MethodNotAllowed("Method not allowed"))
+		}
+	})
+
+	mux.HandleFunc("/items/stats", func(response http.ResponseWriter, request *http.Request) {
+		switch request.Method {
+		case http.MethodGet:
+			getItemStats(response)
+		default:
+			apperror.WriteError(response, apperror.MethodNotAllowed("Method not allowed"))
+		}
+	})
+
+	mux.HandleFunc("/items/", func(response http.ResponseWriter, request *http.Request) {
+		if strings.HasSuffix(request.URL.Path, "/image") {
+			switch request.Method {
+			case http.MethodGet:
+				getItemImage(response, request)
+			case http.MethodPost:
+				uploadItemImage(response, request)
+			default:
+				apperror.WriteError(response, apperror.MethodNotAllowed("Method not allowed"))
+			}
+			return
+		}
+
+		if strings.HasSuffix(request.URL.Path, "/history") {
+			switch request.Method {
+			case http.MethodGet:
+				getItemHistory(response, request)
+			default:
+				apperror.WriteError(response, apperror.MethodNotAllowed("Method not allowed"))
+			}
+			return
+		}
+
+		switch request.Method {
+		case http.MethodGet:
+			getItem(response, request)
+		case http.MethodPut:
+			requireJSON(updateItem)(response, request)
+		case http.MethodDelete:
+			deleteItem(response, request)
+		default:
+			apperror.WriteError(response, apperror.MethodNotAllowed("Method not allowed"))
+		}
+	})
+
+	return withCORS(withPrettyJSON(withMaintenance(mux)))
+}
+
+func main() {
+	webhookURL = os.Getenv("WEBHOOK_URL")
+	corsAllowedOrigins = parseAllowedOrigins(os.Getenv("CORS_ALLOWED_ORIGINS"))
+	if dir := os.Getenv("IMAGE_DIR"); dir != "" {
+		imageDir = dir
+	}
+
+	cacheTTL := defaultItemCacheTTL
+	if value := os.Getenv("ITEM_CACHE_TTL"); value != "" {
+		ttl, err := time.ParseDuration(value)
+		if err != nil {
+			log.Fatalf("invalid ITEM_CACHE_TTL: %v", err)
+		}
+		cacheTTL = ttl
+	}
+	itemsCache = newItemCache(cacheTTL)
+
+	createRate := float64(defaultCreateRate)
+	if value := os.Getenv("CREATE_RATE_LIMIT"); value != "" {
+		perSecond, err := strconv.ParseFloat(value, 64)
+		if err != nil || perSecond < 0 {
+			log.Fatalf("invalid CREATE_RATE_LIMIT: %q", value)
+		}
+		createRate = perSecond
+	}
+	createBurst := defaultCreateBurst
+	if value := os.Getenv("CREATE_RATE_BURST"); value != "" {
+		burst, err := strconv.Atoi(value)
+		if err != nil || burst < 1 {
+			log.Fatalf("invalid CREATE_RATE_BURST: %q", value)
+		}
+		createBurst = burst
+	}
+	createLimiter = newIPRateLimiter(createRate, createBurst)
+	trustedProxies = parseTrustedProxies(os.Getenv("TRUSTED_PROXIES"))
+
+	adminToken = os.Getenv("ADMIN_TOKEN")
+	if value := os.Getenv("MAINTENANCE"); value != "" {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			log.Fatalf("invalid MAINTENANCE: %q", value)
+		}
+		maintenance.Store(enabled)
+	}
+
+	settings, err := tlsSettingsFromEnv()
+	if err != nil {
+		log.Fatalf("invalid TLS configuration: %v", err)
+	}
+
+	server := &http.Server{
+		Addr:              settings.addr(),
+		Handler:           newRouter(),
+		ReadHeaderTimeout: readHeaderTimeout,
+		ReadTimeout:       readTimeout,
+		WriteTimeout:      writeTimeout,
+		IdleTimeout:       idleTimeout,
+	}
+
+	listener, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		log.Fatalf("server error: %v", err)
+	}
+
+	if serverError := serve(server, listener, settings); serverError != nil {
 		log.Fatalf("server error: %v", serverError)
 	}
 
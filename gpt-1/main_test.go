@@ -0,0 +1,352 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestCreateItemLocation(t *testing.T) {
+	original := items
+	t.Cleanup(func() { items = original })
+
+	request := httptest.NewRequest(http.MethodPost, "/items", strings.NewReader(`{"name":"Monitor","price":250}`))
+	response := httptest.NewRecorder()
+	createItem(response, request)
+
+	if response.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d", http.StatusCreated, response.Code)
+	}
+
+	var created Item
+	if err := json.NewDecoder(response.Body).Decode(&created); err != nil {
+		t.Fatal(err)
+	}
+
+	if created.ID != 4 {
+		t.Errorf("expected id 4, got %d", created.ID)
+	}
+
+	if got, want := response.Header().Get("Location"), fmt.Sprintf("/items/%d", created.ID); got != want {
+		t.Errorf("expected Location %q, got %q", want, got)
+	}
+}
+
+func TestGetItemStats(t *testing.T) {
+	original := items
+	t.Cleanup(func() { items = original })
+
+	tests := []struct {
+		name  string
+		items []Item
+		want  itemStats
+	}{
+		{
+			name:  "known prices",
+			items: []Item{{ID: 1, Price: 100}, {ID: 2, Price: 25}, {ID: 3, Price: 400}, {ID: 4, Price: 75}},
+			want:  itemStats{Count: 4, TotalPrice: 600, AveragePrice: 150, MinPrice: 25, MaxPrice: 400},
+		},
+		{
+			name:  "fractional average",
+			items: []Item{{ID: 1, Price: 1}, {ID: 2, Price: 2}},
+			want:  itemStats{Count: 2, TotalPrice: 3, AveragePrice: 1.5, MinPrice: 1, MaxPrice: 2},
+		},
+		{
+			name:  "empty store",
+			items: []Item{},
+			want:  itemStats{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items = tt.items
+
+			response := httptest.NewRecorder()
+			getItemStats(response)
+
+			if response.Code != http.StatusOK {
+				t.Fatalf("expected status %d, got %d", http.StatusOK, response.Code)
+			}
+
+			var got itemStats
+			if err := json.NewDecoder(response.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+
+			if got != tt.want {
+				t.Errorf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestGetItemsCurrency(t *testing.T) {
+	original := items
+	t.Cleanup(func() { items = original })
+	items = []Item{{ID: 1, Name: "Laptop", Price: 1000}}
+
+	tests := []struct {
+		query    string
+		price    float64
+		currency string
+	}{
+		{query: "", price: 1000, currency: "USD"},
+		{query: "?currency=EUR", price: 920, currency: "EUR"},
+		{query: "?currency=jpy", price: 150000, currency: "JPY"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			response := httptest.NewRecorder()
+			getItems(response, httptest.NewRequest(http.MethodGet, "/items"+tt.query, nil))
+
+			if response.Code != http.StatusOK {
+				t.Fatalf("expected status %d, got %d", http.StatusOK, response.Code)
+			}
+
+			var got []pricedItem
+			if err := json.NewDecoder(response.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(got) != 1 || got[0].Price != tt.price || got[0].Currency != tt.currency {
+				t.Errorf("expected %v %s, got %+v", tt.price, tt.currency, got)
+			}
+		})
+	}
+
+	response := httptest.NewRecorder()
+	getItems(response, httptest.NewRequest(http.MethodGet, "/items?currency=XYZ", nil))
+
+	if response.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, response.Code)
+	}
+}
+
+func TestUpdateAndDeleteItem(t *testing.T) {
+	original := items
+	items = slices.Clone(items)
+	t.Cleanup(func() { items = original })
+	useItemHistory(t)
+
+	request := httptest.NewRequest(http.MethodPut, "/items/2", strings.NewReader(`{"id":99,"name":"Phone","price":450}`))
+	response := httptest.NewRecorder()
+	updateItem(response, request)
+
+	if response.Code != http.StatusOK {
+		t.Fatalf("update: expected status %d, got %d", http.StatusOK, response.Code)
+	}
+
+	if item, err := findItemByID(2); err != nil || item.Price != 450 {
+		t.Errorf("expected item 2 to cost 450, got %+v (%v)", item, err)
+	}
+
+	response = httptest.NewRecorder()
+	deleteItem(response, httptest.NewRequest(http.MethodDelete, "/items/2", nil))
+
+	if response.Code != http.StatusNoContent {
+		t.Fatalf("delete: expected status %d, got %d", http.StatusNoContent, response.Code)
+	}
+
+	response = httptest.NewRecorder()
+	deleteItem(response, httptest.NewRequest(http.MethodDelete, "/items/2", nil))
+
+	if response.Code != http.StatusNotFound {
+		t.Errorf("delete after delete: expected status %d, got %d", http.StatusNotFound, response.Code)
+	}
+}
+
+func TestUpsertItem(t *testing.T) {
+	original := items
+	items = slices.Clone(items)
+	t.Cleanup(func() { items = original })
+	useItemHistory(t)
+
+	put := func(target, body string) *httptest.ResponseRecorder {
+		t.Helper()
+
+		response := httptest.NewRecorder()
+		updateItem(response, httptest.NewRequest(http.MethodPut, target, strings.NewReader(body)))
+		return response
+	}
+
+	version := currentItemsVersion()
+
+	response := put("/items/10", `{"name":"Monitor","price":250,"image_url":"/elsewhere.png"}`)
+	if response.Code != http.StatusCreated {
+		t.Fatalf("create: expected status %d, got %d", http.StatusCreated, response.Code)
+	}
+	if location := response.Header().Get("Location"); location != "/items/10" {
+		t.Errorf("expected Location /items/10, got %q", location)
+	}
+
+	want := Item{ID: 10, Name: "Monitor", Price: 250}
+	if item, err := findItemByID(10); err != nil || !reflect.DeepEqual(item, want) {
+		t.Errorf("expected %+v, got %+v (%v)", want, item, err)
+	}
+	if currentItemsVersion() == version {
+		t.Error("expected a create to bump the items version")
+	}
+	if len(itemHistory[10]) != 0 {
+		t.Errorf("expected a create to leave no history, got %+v", itemHistory[10])
+	}
+
+	response = put("/items/10", `{"name":"Monitor","price":199}`)
+	if response.Code != http.StatusOK {
+		t.Fatalf("replace: expected status %d, got %d", http.StatusOK, response.Code)
+	}
+	if location := response.Header().Get("Location"); location != "" {
+		t.Errorf("expected no Location on a replace, got %q", location)
+	}
+	if item, _ := findItemByID(10); item.Price != 199 {
+		t.Errorf("expected item 10 to cost 199, got %+v", item)
+	}
+	if len(items) != 4 {
+		t.Errorf("expected the replace to keep 4 items, got %d", len(items))
+	}
+
+	// POST carries on after the highest id
+	response = httptest.NewRecorder()
+	createItem(response, httptest.NewRequest(http.MethodPost, "/items", strings.NewReader(`{"name":"Mouse","price":20}`)))
+	if location := response.Header().Get("Location"); location != "/items/11" {
+		t.Errorf("expected POST to create /items/11, got %q", location)
+	}
+
+	for _, target := range []string{"/items/0", "/items/-3"} {
+		if response := put(target, `{"name":"Monitor","price":250}`); response.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d, got %d", target, http.StatusBadRequest, response.Code)
+		}
+	}
+	if response := put("/items/12", `{"name":"","price":250}`); response.Code != http.StatusUnprocessableEntity {
+		t.Errorf("invalid item: expected status %d, got %d", http.StatusUnprocessableEntity, response.Code)
+	}
+	if _, err := findItemByID(12); err == nil {
+		t.Error("expected an invalid item not to be created")
+	}
+}
+
+func TestUpsertItemConcurrently(t *testing.T) {
+	original := items
+	items = slices.Clone(items)
+	t.Cleanup(func() { items = original })
+	useItemHistory(t)
+
+	const writers = 20
+
+	var wg sync.WaitGroup
+	codes := make(chan int, writers)
+	for i := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			response := httptest.NewRecorder()
+			body := fmt.Sprintf(`{"name":"Monitor","price":%d}`, 100+i)
+			updateItem(response, httptest.NewRequest(http.MethodPut, "/items/10", strings.NewReader(body)))
+			codes <- response.Code
+		}()
+	}
+	wg.Wait()
+	close(codes)
+
+	created := 0
+	for code := range codes {
+		if code == http.StatusCreated {
+			created++
+		}
+	}
+	if created != 1 {
+		t.Errorf("expected exactly one PUT to create item 10, got %d", created)
+	}
+
+	count := 0
+	for _, item := range items {
+		if item.ID == 10 {
+			count++
+		}
+	}
+	if count != 1 {
+		t.Errorf("expected one item 10, got %d", count)
+	}
+}
+
+func TestRouter(t *testing.T) {
+	router := newRouter()
+
+	tests := []struct {
+		method string
+		target string
+		status int
+	}{
+		{http.MethodGet, "/items", http.StatusOK},
+		{http.MethodGet, "/items/1", http.StatusOK},
+		{http.MethodGet, "/items/stats", http.StatusOK},
+		{http.MethodGet, "/items/42", http.StatusNotFound},
+		{http.MethodGet, "/items/1/image", http.StatusNotFound},
+		{http.MethodPatch, "/items", http.StatusMethodNotAllowed},
+		{http.MethodPost, "/items/1", http.StatusMethodNotAllowed},
+		{http.MethodDelete, "/items/1/image", http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.target, func(t *testing.T) {
+			response := httptest.NewRecorder()
+			router.ServeHTTP(response, httptest.NewRequest(tt.method, tt.target, nil))
+
+			if response.Code != tt.status {
+				t.Errorf("expected status %d, got %d", tt.status, response.Code)
+			}
+		})
+	}
+}
+
+func TestRespondWithJSONEncodeFailure(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	response := httptest.NewRecorder()
+	// channels can't be encoded as JSON
+	respondWithJSON(response, http.StatusOK, map[string]any{"items": items, "updates": make(chan Item)})
+
+	if response.Code != http.StatusInternalServerError {
+		t.Fatalf("expected status %d, got %d", http.StatusInternalServerError, response.Code)
+	}
+
+	var body struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&body); err != nil {
+		t.Fatalf("expected a clean JSON error body: %v", err)
+	}
+
+	if body.Code != "internal" {
+		t.Errorf("expected code internal, got %q", body.Code)
+	}
+
+	if !strings.Contains(logs.String(), "encoding map[string]interface {} response: json: unsupported type: chan main.Item") {
+		t.Errorf("expected the failure to be logged, got %q", logs.String())
+	}
+}
+
+func TestRespondWithJSONContentLength(t *testing.T) {
+	response := httptest.NewRecorder()
+	respondWithJSON(response, http.StatusOK, Item{ID: 1, Name: "Laptop", Price: 1000})
+
+	if got, want := response.Header().Get("Content-Length"), fmt.Sprint(response.Body.Len()); got != want {
+		t.Errorf("expected Content-Length %s, got %s", want, got)
+	}
+	if got := response.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("expected Content-Type application/json, got %q", got)
+	}
+}
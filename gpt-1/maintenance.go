@@ -0,0 +1,111 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/yowger/golang-api-study/internal/apperror"
+)
+
+/*
+	maintenance mode makes the API read-only, for deploys
+
+		MAINTENANCE=true starts the server in it, POST /admin/maintenance with
+		{"enabled": true} or {"enabled": false} switches it at runtime and
+		answers with the new state
+
+		while it is on POST, PUT, PATCH and DELETE get a 503 with Retry-After,
+		GET, HEAD and OPTIONS go through as usual. The toggle itself is never
+		blocked, or there would be no way out
+
+		GET /health answers 200 either way, the server is up and serves reads,
+		its status says "maintenance" instead of "ok"
+
+		the toggle takes ADMIN_TOKEN as a bearer token. Without ADMIN_TOKEN set
+		it is switched off, maintenance can then only come from the env
+*/
+
+const (
+	maintenancePath = "/admin/maintenance"
+	// maintenanceRetryAfter is a guess at how long a deploy takes
+	maintenanceRetryAfter = 120
+)
+
+var (
+	maintenance atomic.Bool
+	adminToken  string
+)
+
+type maintenanceState struct {
+	Enabled bool `json:"enabled"`
+}
+
+type healthStatus struct {
+	Status      string `json:"status"`
+	Maintenance bool   `json:"maintenance"`
+}
+
+func withMaintenance(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		if !maintenance.Load() || request.URL.Path == maintenancePath {
+			next.ServeHTTP(response, request)
+			return
+		}
+
+		switch request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(response, request)
+		default:
+			response.Header().Set("Retry-After", strconv.Itoa(maintenanceRetryAfter))
+			apperror.WriteError(response, apperror.New(http.StatusServiceUnavailable, "maintenance",
+				"the API is read-only during maintenance, try again later"))
+		}
+	})
+}
+
+func toggleMaintenance(response http.ResponseWriter, request *http.Request) {
+	if !isAdmin(request) {
+		apperror.WriteError(response, apperror.New(http.StatusUnauthorized, "unauthorized", "a valid admin token is required"))
+		return
+	}
+
+	var payload struct {
+		Enabled *bool `json:"enabled"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(response, request.Body, 1<<10)).Decode(&payload); err != nil {
+		apperror.WriteError(response, apperror.BadRequest("invalid request body"))
+		return
+	}
+	if payload.Enabled == nil {
+		apperror.WriteError(response, apperror.Validation(map[string]string{"enabled": "is required"}))
+		return
+	}
+
+	maintenance.Store(*payload.Enabled)
+
+	respondWithJSON(response, http.StatusOK, maintenanceState{Enabled: *payload.Enabled})
+}
+
+// isAdmin compares the bearer token in constant time, so its prefix can't be
+// guessed from response timings.
+func isAdmin(request *http.Request) bool {
+	token, ok := strings.CutPrefix(request.Header.Get("Authorization"), "Bearer ")
+	if adminToken == "" || !ok {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) == 1
+}
+
+func getHealth(response http.ResponseWriter) {
+	status := healthStatus{Status: "ok", Maintenance: maintenance.Load()}
+	if status.Maintenance {
+		status.Status = "maintenance"
+	}
+
+	respondWithJSON(response, http.StatusOK, status)
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func useMaintenance(t *testing.T, enabled bool) {
+	t.Helper()
+
+	original := maintenance.Load()
+	maintenance.Store(enabled)
+	t.Cleanup(func() { maintenance.Store(original) })
+
+	originalItems := items
+	items = slices.Clone(items)
+	t.Cleanup(func() { items = originalItems })
+	useItemHistory(t)
+}
+
+func getHealthStatus(t *testing.T, router http.Handler) healthStatus {
+	t.Helper()
+
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if response.Code != http.StatusOK {
+		t.Fatalf("health: expected status %d, got %d", http.StatusOK, response.Code)
+	}
+
+	var status healthStatus
+	if err := json.NewDecoder(response.Body).Decode(&status); err != nil {
+		t.Fatal(err)
+	}
+	return status
+}
+
+func TestMaintenanceBlocksWrites(t *testing.T) {
+	useMaintenance(t, true)
+	before := slices.Clone(items)
+
+	router := newRouter()
+
+	writes := []*http.Request{
+		newJSONRequest(http.MethodPost, "/items", `{"name":"Lamp","price":40}`),
+		newJSONRequest(http.MethodPut, "/items/1", `{"name":"Gaming laptop","price":1500}`),
+		httptest.NewRequest(http.MethodDelete, "/items/1", nil),
+	}
+	for _, request := range writes {
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, request)
+
+		if response.Code != http.StatusServiceUnavailable {
+			t.Errorf("%s %s: expected status %d, got %d", request.Method, request.URL.Path, http.StatusServiceUnavailable, response.Code)
+		}
+		if response.Header().Get("Retry-After") == "" {
+			t.Errorf("%s %s: expected a Retry-After header", request.Method, request.URL.Path)
+		}
+	}
+
+	if len(items) != len(before) || items[0].Name != before[0].Name {
+		t.Errorf("expected the items untouched, got %+v", items)
+	}
+
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/items", nil))
+	if response.Code != http.StatusOK {
+		t.Errorf("GET /items: expected status %d, got %d", http.StatusOK, response.Code)
+	}
+
+	if got := getHealthStatus(t, router); got.Status != "maintenance" || !got.Maintenance {
+		t.Errorf("expected health to report maintenance, got %+v", got)
+	}
+}
+
+func TestToggleMaintenance(t *testing.T) {
+	useMaintenance(t, false)
+	original := adminToken
+	adminToken = "s3cret"
+	t.Cleanup(func() { adminToken = original })
+
+	router := newRouter()
+
+	toggle := func(token, body string) int {
+		t.Helper()
+
+		request := newJSONRequest(http.MethodPost, "/admin/maintenance", body)
+		if token != "" {
+			request.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, request)
+		return response.Code
+	}
+
+	createItem := func() int {
+		t.Helper()
+
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, newJSONRequest(http.MethodPost, "/items", `{"name":"Lamp","price":40}`))
+		return response.Code
+	}
+
+	if code := toggle("", `{"enabled":true}`); code != http.StatusUnauthorized {
+		t.Errorf("without a token: expected status %d, got %d", http.StatusUnauthorized, code)
+	}
+	if code := toggle("guess", `{"enabled":true}`); code != http.StatusUnauthorized {
+		t.Errorf("with a wrong token: expected status %d, got %d", http.StatusUnauthorized, code)
+	}
+	if code := toggle("s3cret", `{}`); code != http.StatusUnprocessableEntity {
+		t.Errorf("without enabled: expected status %d, got %d", http.StatusUnprocessableEntity, code)
+	}
+	if maintenance.Load() {
+		t.Fatal("expected rejected toggles to leave maintenance off")
+	}
+
+	if code := toggle("s3cret", `{"enabled":true}`); code != http.StatusOK {
+		t.Fatalf("enable: expected status %d, got %d", http.StatusOK, code)
+	}
+	if code := createItem(); code != http.StatusServiceUnavailable {
+		t.Errorf("expected writes blocked, got %d", code)
+	}
+
+	// the toggle is a POST too, but it has to get through to switch back
+	if code := toggle("s3cret", `{"enabled":false}`); code != http.StatusOK {
+		t.Fatalf("disable: expected status %d, got %d", http.StatusOK, code)
+	}
+	if code := createItem(); code != http.StatusCreated {
+		t.Errorf("expected writes back, got %d", code)
+	}
+	if got := getHealthStatus(t, router); got.Status != "ok" || got.Maintenance {
+		t.Errorf("expected a healthy status, got %+v", got)
+	}
+}
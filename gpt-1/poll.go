@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/yowger/golang-api-study/internal/apperror"
+)
+
+/*
+	GET /items?wait=30s&since=<version> long-polls the catalog, a lighter
+	alternative to the SSE stream
+
+		every create, update and delete bumps itemsVersion, GET /items reports
+		it in the X-Items-Version header so clients know what to send as since
+
+		the request blocks until the version moves past since and then answers
+		with the fresh list, or gives up after wait with 304 Not Modified
+
+	waiters park on itemsChanged, a channel that is closed and replaced on every
+	change so all of them wake up at once
+*/
+
+const maxPollWait = time.Minute
+
+var (
+	itemsVersion uint64
+	itemsChanged = make(chan struct{})
+)
+
+// bumpItemsVersion must be called with itemsMu held for writing.
+func bumpItemsVersion() {
+	itemsVersion++
+	close(itemsChanged)
+	itemsChanged = make(chan struct{})
+}
+
+type pollParams struct {
+	wait  time.Duration
+	since uint64
+}
+
+func parsePollParams(request *http.Request) (pollParams, error) {
+	query := request.URL.Query()
+
+	wait, err := time.ParseDuration(query.Get("wait"))
+	if err != nil || wait <= 0 || wait > maxPollWait {
+		return pollParams{}, apperror.BadRequest("wait must be a duration between 0s and 1m, like 30s")
+	}
+
+	since, err := strconv.ParseUint(query.Get("since"), 10, 64)
+	if err != nil {
+		return pollParams{}, apperror.BadRequest("since must be an items version")
+	}
+
+	return pollParams{wait: wait, since: since}, nil
+}
+
+// waitForItemsChange blocks until itemsVersion is past since, the wait runs
+// out or the client goes away. It reports whether the version moved.
+func waitForItemsChange(ctx context.Context, params pollParams) bool {
+	timer := time.NewTimer(params.wait)
+	defer timer.Stop()
+
+	for {
+		itemsMu.RLock()
+		version, changed := itemsVersion, itemsChanged
+		itemsMu.RUnlock()
+
+		if version > params.since {
+			return true
+		}
+
+		select {
+		case <-changed:
+		case <-timer.C:
+			return false
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
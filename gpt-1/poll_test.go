@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func currentItemsVersion() uint64 {
+	itemsMu.RLock()
+	defer itemsMu.RUnlock()
+
+	return itemsVersion
+}
+
+func TestGetItemsLongPoll(t *testing.T) {
+	original := items
+	t.Cleanup(func() { items = original })
+
+	since := currentItemsVersion()
+	done := make(chan *httptest.ResponseRecorder)
+
+	go func() {
+		rr := httptest.NewRecorder()
+		getItems(rr, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/items?wait=5s&since=%d", since), nil))
+		done <- rr
+	}()
+
+	select {
+	case rr := <-done:
+		t.Fatalf("poll returned before any change with status %d", rr.Code)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	createItem(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/items", strings.NewReader(`{"name":"Monitor","price":250}`)))
+
+	var rr *httptest.ResponseRecorder
+	select {
+	case rr = <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("poll was not woken up by the create")
+	}
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	if got := rr.Header().Get("X-Items-Version"); got != strconv.FormatUint(since+1, 10) {
+		t.Errorf("expected version %d, got %q", since+1, got)
+	}
+
+	var got []pricedItem
+	if err := json.NewDecoder(rr.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != len(original)+1 || got[len(got)-1].Name != "Monitor" {
+		t.Errorf("expected the new item in the list, got %+v", got)
+	}
+}
+
+func TestGetItemsLongPollTimeout(t *testing.T) {
+	rr := httptest.NewRecorder()
+	target := fmt.Sprintf("/items?wait=20ms&since=%d", currentItemsVersion())
+	getItems(rr, httptest.NewRequest(http.MethodGet, target, nil))
+
+	if rr.Code != http.StatusNotModified {
+		t.Errorf("expected status %d, got %d", http.StatusNotModified, rr.Code)
+	}
+}
+
+func TestGetItemsLongPollStaleVersion(t *testing.T) {
+	original := items
+	t.Cleanup(func() { items = original })
+	useItemHistory(t)
+
+	since := currentItemsVersion()
+	deleteItem(httptest.NewRecorder(), httptest.NewRequest(http.MethodDelete, "/items/1", nil))
+
+	// the version already moved, so the poll answers right away
+	rr := httptest.NewRecorder()
+	getItems(rr, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/items?wait=5s&since=%d", since), nil))
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+}
+
+func TestGetItemsLongPollCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	target := fmt.Sprintf("/items?wait=5s&since=%d", currentItemsVersion())
+	request := httptest.NewRequest(http.MethodGet, target, nil).WithContext(ctx)
+
+	done := make(chan struct{})
+	go func() {
+		getItems(httptest.NewRecorder(), request)
+		close(done)
+	}()
+
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("poll kept waiting after the request was canceled")
+	}
+}
+
+func TestGetItemsLongPollParams(t *testing.T) {
+	for _, query := range []string{"wait=soon&since=0", "wait=2m&since=0", "wait=-1s&since=0", "wait=1s", "wait=1s&since=x"} {
+		t.Run(query, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			getItems(rr, httptest.NewRequest(http.MethodGet, "/items?"+query, nil))
+
+			if rr.Code != http.StatusBadRequest {
+				t.Errorf("expected status %d, got %d", http.StatusBadRequest, rr.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+)
+
+/*
+	?pretty=true on any route makes respondWithJSON indent its output by two
+	spaces, which is easier to read from curl
+
+		anything else, including a value that isn't a bool, keeps the compact
+		output, a debugging aid shouldn't be able to fail a request
+
+		errors written by apperror stay compact, they are a single line anyway
+*/
+
+const prettyIndent = "  "
+
+// prettyResponseWriter marks a response that asked for ?pretty=true,
+// respondWithJSON looks for it
+type prettyResponseWriter struct {
+	http.ResponseWriter
+}
+
+// Unwrap lets http.ResponseController reach the Flusher underneath, the
+// event stream needs it
+func (writer *prettyResponseWriter) Unwrap() http.ResponseWriter {
+	return writer.ResponseWriter
+}
+
+func withPrettyJSON(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		if pretty, _ := strconv.ParseBool(request.URL.Query().Get("pretty")); pretty {
+			response = &prettyResponseWriter{ResponseWriter: response}
+		}
+
+		next.ServeHTTP(response, request)
+	})
+}
+
+func wantsPrettyJSON(response http.ResponseWriter) bool {
+	_, ok := response.(*prettyResponseWriter)
+	return ok
+}
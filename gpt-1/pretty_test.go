@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPrettyJSON(t *testing.T) {
+	router := newRouter()
+
+	tests := []struct {
+		target string
+		pretty bool
+	}{
+		{"/items", false},
+		{"/items?pretty=true", true},
+		{"/items?pretty=1", true},
+		{"/items?pretty=false", false},
+		{"/items?pretty=maybe", false},
+		{"/items/1?pretty=true", true},
+		{"/items/stats?pretty=true", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			response := httptest.NewRecorder()
+			router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+			if response.Code != http.StatusOK {
+				t.Fatalf("expected status %d, got %d", http.StatusOK, response.Code)
+			}
+
+			body := response.Body.String()
+			if !json.Valid([]byte(body)) {
+				t.Fatalf("expected valid JSON, got %q", body)
+			}
+
+			// the encoder always ends with a newline, so look inside the body
+			inner := strings.TrimSuffix(body, "\n")
+			indented := strings.Contains(inner, "\n"+prettyIndent+`"`) || strings.Contains(inner, "\n"+prettyIndent+"{")
+			if tt.pretty && !indented {
+				t.Errorf("expected indented output, got %q", body)
+			}
+			if !tt.pretty && strings.Contains(inner, "\n") {
+				t.Errorf("expected compact output, got %q", body)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/yowger/golang-api-study/internal/apperror"
+	"github.com/yowger/golang-api-study/internal/realip"
+)
+
+/*
+	POST /items is throttled per client IP with a token bucket, reads are not
+
+		CREATE_RATE_LIMIT is how many creates per second an IP gets back, like
+		0.5 for one every two seconds, 0 turns the limit off
+
+		CREATE_RATE_BURST is how many creates an IP can make back to back before
+		the rate kicks in
+
+		TRUSTED_PROXIES lists the proxies, as IPs or CIDR ranges, whose
+		X-Forwarded-For is believed when working out the client IP
+
+	a client over the limit gets 429 with Retry-After set to the seconds until
+	its next token
+*/
+
+const (
+	defaultCreateRate  = 1
+	defaultCreateBurst = 5
+
+	// buckets that have been idle this long are full again and can be dropped
+	createLimiterIdle = 10 * time.Minute
+)
+
+var trustedProxies []string
+
+func parseTrustedProxies(value string) []string {
+	var proxies []string
+	for _, proxy := range strings.Split(value, ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+
+	return proxies
+}
+
+type visitor struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+type ipRateLimiter struct {
+	mu        sync.Mutex
+	limit     rate.Limit
+	burst     int
+	visitors  map[string]*visitor
+	lastPrune time.Time
+	// now is swapped by the tests to refill the buckets
+	now func() time.Time
+}
+
+func newIPRateLimiter(perSecond float64, burst int) *ipRateLimiter {
+	return &ipRateLimiter{
+		limit:    rate.Limit(perSecond),
+		burst:    burst,
+		visitors: make(map[string]*visitor),
+		now:      time.Now,
+	}
+}
+
+// createLimiter stays off until main configures it, tests turn it on as needed
+var createLimiter = newIPRateLimiter(0, 0)
+
+// allow takes a token from ip's bucket. When there is none it reports how
+// long until there will be.
+func (limiter *ipRateLimiter) allow(ip string) (bool, time.Duration) {
+	if limiter.limit <= 0 {
+		return true, 0
+	}
+
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+
+	now := limiter.now()
+	limiter.prune(now)
+
+	v, ok := limiter.visitors[ip]
+	if !ok {
+		v = &visitor{limiter: rate.NewLimiter(limiter.limit, limiter.burst)}
+		limiter.visitors[ip] = v
+	}
+	v.lastSeen = now
+
+	reservation := v.limiter.ReserveN(now, 1)
+	if !reservation.OK() {
+		return false, time.Duration(float64(time.Second) / float64(limiter.limit))
+	}
+
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		reservation.CancelAt(now)
+		return false, delay
+	}
+
+	return true, 0
+}
+
+// prune forgets idle visitors, at most once per createLimiterIdle so a busy
+// server doesn't walk the map on every request. Must be called with mu held.
+func (limiter *ipRateLimiter) prune(now time.Time) {
+	if now.Sub(limiter.lastPrune) < createLimiterIdle {
+		return
+	}
+	limiter.lastPrune = now
+
+	for ip, v := range limiter.visitors {
+		if now.Sub(v.lastSeen) >= createLimiterIdle {
+			delete(limiter.visitors, ip)
+		}
+	}
+}
+
+func withCreateLimit(next http.HandlerFunc) http.HandlerFunc {
+	return func(response http.ResponseWriter, request *http.Request) {
+		ok, retryAfter := createLimiter.allow(realip.ClientIP(request, trustedProxies))
+		if !ok {
+			seconds := int(math.Ceil(retryAfter.Seconds()))
+			response.Header().Set("Retry-After", strconv.Itoa(max(seconds, 1)))
+			apperror.WriteError(response, apperror.New(http.StatusTooManyRequests, "rate_limited", "too many items created, try again later"))
+			return
+		}
+
+		next(response, request)
+	}
+}
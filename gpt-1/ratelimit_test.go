@@ -0,0 +1,108 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// useCreateLimiter turns the create limit on with a clock the test moves by
+// hand.
+func useCreateLimiter(t *testing.T, perSecond float64, burst int) *time.Time {
+	t.Helper()
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter := newIPRateLimiter(perSecond, burst)
+	limiter.now = func() time.Time { return now }
+
+	original, originalItems := createLimiter, items
+	createLimiter, items = limiter, slices.Clone(items)
+	t.Cleanup(func() { createLimiter, items = original, originalItems })
+
+	return &now
+}
+
+func TestCreateRateLimit(t *testing.T) {
+	now := useCreateLimiter(t, 0.5, 3)
+	router := newRouter()
+
+	do := func(method, remoteAddr string) *httptest.ResponseRecorder {
+		t.Helper()
+
+		body := ""
+		if method == http.MethodPost {
+			body = `{"name":"Monitor","price":250}`
+		}
+
+		request := newJSONRequest(method, "/items", body)
+		request.RemoteAddr = remoteAddr
+
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, request)
+
+		return response
+	}
+
+	for i := range 3 {
+		if response := do(http.MethodPost, "203.0.113.7:4000"); response.Code != http.StatusCreated {
+			t.Fatalf("create %d: expected status %d, got %d", i+1, http.StatusCreated, response.Code)
+		}
+	}
+
+	response := do(http.MethodPost, "203.0.113.7:4001")
+	if response.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected status %d after the burst, got %d", http.StatusTooManyRequests, response.Code)
+	}
+	if retryAfter := response.Header().Get("Retry-After"); retryAfter != "2" {
+		t.Errorf("expected Retry-After 2, got %q", retryAfter)
+	}
+	if !strings.Contains(response.Body.String(), `"code":"rate_limited"`) {
+		t.Errorf("expected a rate_limited error, got %s", response.Body)
+	}
+
+	if response := do(http.MethodGet, "203.0.113.7:4000"); response.Code != http.StatusOK {
+		t.Errorf("expected reads to stay unthrottled, got status %d", response.Code)
+	}
+	if response := do(http.MethodPost, "198.51.100.2:4000"); response.Code != http.StatusCreated {
+		t.Errorf("expected another IP to have its own bucket, got status %d", response.Code)
+	}
+
+	*now = now.Add(2 * time.Second)
+	if response := do(http.MethodPost, "203.0.113.7:4000"); response.Code != http.StatusCreated {
+		t.Errorf("expected a new token after Retry-After, got status %d", response.Code)
+	}
+	if response := do(http.MethodPost, "203.0.113.7:4000"); response.Code != http.StatusTooManyRequests {
+		t.Errorf("expected the bucket to be empty again, got status %d", response.Code)
+	}
+}
+
+func TestCreateRateLimitOff(t *testing.T) {
+	useCreateLimiter(t, 0, 0)
+
+	for i := range 20 {
+		response := httptest.NewRecorder()
+		withCreateLimit(createItem)(response, httptest.NewRequest(http.MethodPost, "/items", strings.NewReader(`{"name":"Monitor","price":250}`)))
+
+		if response.Code != http.StatusCreated {
+			t.Fatalf("create %d: expected status %d, got %d", i+1, http.StatusCreated, response.Code)
+		}
+	}
+}
+
+func TestCreateLimiterPrunesIdleVisitors(t *testing.T) {
+	now := useCreateLimiter(t, 1, 1)
+
+	createLimiter.allow("203.0.113.7")
+	*now = now.Add(createLimiterIdle)
+	createLimiter.allow("198.51.100.2")
+
+	if _, ok := createLimiter.visitors["203.0.113.7"]; ok {
+		t.Error("expected the idle visitor to be dropped")
+	}
+	if len(createLimiter.visitors) != 1 {
+		t.Errorf("expected 1 visitor, got %d", len(createLimiter.visitors))
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"github.com/yowger/golang-api-study/internal/apperror"
+)
+
+/*
+	item payloads are checked against items.schema.json before they are
+	decoded, so the constraints live in one declarative place instead of
+	being spread over the handlers
+
+		{"name":"","price":-1}
+
+		422 {"error":"validation failed","code":"validation_failed",
+			"fields":{"name":"minLength: got 0, want 1","price":"minimum: got -1, want 0"}}
+*/
+
+//go:embed items.schema.json
+var itemSchemaJSON []byte
+
+var itemSchema = compileItemSchema()
+
+func compileItemSchema() *jsonschema.Schema {
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(itemSchemaJSON))
+	if err != nil {
+		panic("items.schema.json: " + err.Error())
+	}
+
+	compiler := jsonschema.NewCompiler()
+	if err := compiler.AddResource("items.schema.json", doc); err != nil {
+		panic("items.schema.json: " + err.Error())
+	}
+
+	return compiler.MustCompile("items.schema.json")
+}
+
+// decodeItem validates the request body against itemSchema and decodes it.
+func decodeItem(request *http.Request) (Item, error) {
+	body, err := io.ReadAll(request.Body)
+	if err != nil {
+		return Item{}, apperror.BadRequest("invalid request body")
+	}
+
+	return parseItem(body)
+}
+
+// parseItem is decodeItem for a payload that has already been read.
+func parseItem(body []byte) (Item, error) {
+	instance, err := jsonschema.UnmarshalJSON(bytes.NewReader(body))
+	if err != nil {
+		return Item{}, apperror.BadRequest("invalid request body")
+	}
+
+	if err := itemSchema.Validate(instance); err != nil {
+		var validationErr *jsonschema.ValidationError
+		if !errors.As(err, &validationErr) {
+			return Item{}, apperror.Internal(err)
+		}
+
+		return Item{}, apperror.Validation(schemaFields(validationErr))
+	}
+
+	var item Item
+	if err := json.Unmarshal(body, &item); err != nil {
+		return Item{}, apperror.BadRequest("invalid request body")
+	}
+
+	return item, nil
+}
+
+// schemaFields keys every schema error by the field it is about, errors
+// about the body as a whole go under "body".
+func schemaFields(err *jsonschema.ValidationError) map[string]string {
+	fields := map[string]string{}
+
+	for _, unit := range err.BasicOutput().Errors {
+		if unit.Error == nil {
+			continue
+		}
+
+		field := strings.ReplaceAll(strings.TrimPrefix(unit.InstanceLocation, "/"), "/", ".")
+		if field == "" {
+			field = "body"
+		}
+
+		if message, ok := fields[field]; ok {
+			fields[field] = message + "; " + unit.Error.String()
+		} else {
+			fields[field] = unit.Error.String()
+		}
+	}
+
+	return fields
+}
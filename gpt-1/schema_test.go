@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestItemSchemaValidation(t *testing.T) {
+	original := items
+	t.Cleanup(func() { items = original })
+	useItemHistory(t)
+
+	tests := []struct {
+		name   string
+		body   string
+		fields []string
+	}{
+		{name: "empty name and negative price", body: `{"name":"","price":-1}`, fields: []string{"name", "price"}},
+		{name: "fractional price", body: `{"name":"Mouse","price":9.99}`, fields: []string{"price"}},
+		{name: "missing fields", body: `{}`, fields: []string{"body"}},
+		{name: "unknown field", body: `{"name":"Mouse","price":10,"color":"red"}`, fields: []string{"body"}},
+		{name: "not an object", body: `["Mouse"]`, fields: []string{"body"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := httptest.NewRecorder()
+			createItem(response, httptest.NewRequest(http.MethodPost, "/items", strings.NewReader(tt.body)))
+
+			if response.Code != http.StatusUnprocessableEntity {
+				t.Fatalf("expected status %d, got %d", http.StatusUnprocessableEntity, response.Code)
+			}
+
+			var body struct {
+				Code   string            `json:"code"`
+				Fields map[string]string `json:"fields"`
+			}
+			if err := json.NewDecoder(response.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+
+			if body.Code != "validation_failed" || len(body.Fields) != len(tt.fields) {
+				t.Fatalf("expected errors for %v, got %+v", tt.fields, body)
+			}
+
+			for _, field := range tt.fields {
+				if body.Fields[field] == "" {
+					t.Errorf("expected an error for %q, got %v", field, body.Fields)
+				}
+			}
+		})
+	}
+
+	if len(items) != len(original) {
+		t.Errorf("expected invalid items not to be stored, got %d items", len(items))
+	}
+
+	response := httptest.NewRecorder()
+	updateItem(response, httptest.NewRequest(http.MethodPut, "/items/1", strings.NewReader(`{"name":"Laptop"}`)))
+
+	if response.Code != http.StatusUnprocessableEntity {
+		t.Errorf("update: expected status %d, got %d", http.StatusUnprocessableEntity, response.Code)
+	}
+
+	response = httptest.NewRecorder()
+	createItem(response, httptest.NewRequest(http.MethodPost, "/items", strings.NewReader(`{"name":`)))
+
+	if response.Code != http.StatusBadRequest {
+		t.Errorf("malformed body: expected status %d, got %d", http.StatusBadRequest, response.Code)
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/yowger/golang-api-study/internal/apperror"
+)
+
+/*
+	items carry a list of category tags, set like any other field on POST and
+	PUT, GET /items?tag= keeps the items that have it
+
+		GET /items?tag=electronics
+		GET /items?tag=electronics&tag=sale
+			repeated tags AND together, an item needs every one of them
+
+	tags match exactly, case included, and without ?tag= every item is listed
+*/
+
+func parseTagFilter(request *http.Request) ([]string, error) {
+	tags := request.URL.Query()["tag"]
+	if slices.Contains(tags, "") {
+		return nil, apperror.BadRequest("tag must not be empty")
+	}
+
+	return tags, nil
+}
+
+func hasAllTags(item Item, tags []string) bool {
+	for _, tag := range tags {
+		if !slices.Contains(item.Tags, tag) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestFilterItemsByTag(t *testing.T) {
+	original := items
+	t.Cleanup(func() { items = original })
+	items = []Item{}
+	useItemHistory(t)
+
+	router := newRouter()
+
+	for _, body := range []string{
+		`{"name":"Laptop","price":1000,"tags":["electronics","work"]}`,
+		`{"name":"Phone","price":500,"tags":["electronics","sale"]}`,
+		`{"name":"Desk","price":300,"tags":["furniture","work"]}`,
+		`{"name":"Mug","price":10}`,
+	} {
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, newJSONRequest(http.MethodPost, "/items", body))
+		if response.Code != http.StatusCreated {
+			t.Fatalf("create %s: expected status %d, got %d: %s", body, http.StatusCreated, response.Code, response.Body)
+		}
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{query: "", want: []string{"Laptop", "Phone", "Desk", "Mug"}},
+		{query: "?tag=electronics", want: []string{"Laptop", "Phone"}},
+		{query: "?tag=work", want: []string{"Laptop", "Desk"}},
+		{query: "?tag=electronics&tag=work", want: []string{"Laptop"}},
+		{query: "?tag=electronics&tag=furniture", want: []string{}},
+		{query: "?tag=Electronics", want: []string{}},
+		{query: "?tag=sale&currency=EUR", want: []string{"Phone"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			response := httptest.NewRecorder()
+			router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/items"+tt.query, nil))
+
+			if response.Code != http.StatusOK {
+				t.Fatalf("expected status %d, got %d", http.StatusOK, response.Code)
+			}
+
+			var got []pricedItem
+			if err := json.NewDecoder(response.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+
+			names := []string{}
+			for _, item := range got {
+				names = append(names, item.Name)
+			}
+			if !slices.Equal(names, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, names)
+			}
+		})
+	}
+
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/items?tag=", nil))
+	if response.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d for an empty tag, got %d", http.StatusBadRequest, response.Code)
+	}
+}
+
+func TestItemTags(t *testing.T) {
+	original := items
+	items = slices.Clone(items)
+	t.Cleanup(func() { items = original })
+	useItemHistory(t)
+
+	router := newRouter()
+
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, newJSONRequest(http.MethodPut, "/items/1", `{"name":"Laptop","price":1000,"tags":["electronics"]}`))
+	if response.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, response.Code, response.Body)
+	}
+
+	var updated Item
+	if err := json.NewDecoder(response.Body).Decode(&updated); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(updated.Tags, []string{"electronics"}) {
+		t.Errorf("expected the tags to be saved, got %+v", updated)
+	}
+
+	for _, body := range []string{
+		`{"name":"Laptop","price":1000,"tags":[""]}`,
+		`{"name":"Laptop","price":1000,"tags":["a","a"]}`,
+		`{"name":"Laptop","price":1000,"tags":"electronics"}`,
+	} {
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, newJSONRequest(http.MethodPut, "/items/1", body))
+		if response.Code != http.StatusUnprocessableEntity {
+			t.Errorf("%s: expected status %d, got %d", body, http.StatusUnprocessableEntity, response.Code)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+/*
+	HTTPS is off by default, local dev keeps plain HTTP on :8080
+
+		TLS_CERT and TLS_KEY are the paths of a PEM certificate and its key, the
+		server then speaks HTTPS on the same port, HTTP/2 included
+
+		DOMAIN gets the certificates from Let's Encrypt instead, a comma
+		separated list covers several names. ACME has to reach the standard
+		ports, so the API moves to :443 while :80 answers the challenges and
+		redirects everything else to https. AUTOCERT_CACHE is where certificates
+		are kept between restarts, ./autocert-cache by default
+
+		setting both is a mistake and stops the server from starting
+*/
+
+const (
+	httpsPort               = ":443"
+	acmeChallengePort       = ":80"
+	defaultAutocertCacheDir = "autocert-cache"
+)
+
+type tlsSettings struct {
+	certFile string
+	keyFile  string
+	// domains turns on autocert
+	domains  []string
+	cacheDir string
+}
+
+func tlsSettingsFromEnv() (tlsSettings, error) {
+	settings := tlsSettings{
+		certFile: os.Getenv("TLS_CERT"),
+		keyFile:  os.Getenv("TLS_KEY"),
+		cacheDir: os.Getenv("AUTOCERT_CACHE"),
+	}
+	for _, domain := range strings.Split(os.Getenv("DOMAIN"), ",") {
+		if domain = strings.TrimSpace(domain); domain != "" {
+			settings.domains = append(settings.domains, domain)
+		}
+	}
+	if settings.cacheDir == "" {
+		settings.cacheDir = defaultAutocertCacheDir
+	}
+
+	if (settings.certFile == "") != (settings.keyFile == "") {
+		return tlsSettings{}, errors.New("TLS_CERT and TLS_KEY must be set together")
+	}
+	if settings.certFile != "" && len(settings.domains) > 0 {
+		return tlsSettings{}, errors.New("set either TLS_CERT and TLS_KEY or DOMAIN, not both")
+	}
+
+	return settings, nil
+}
+
+// addr is where the API listens, autocert needs the standard https port
+func (settings tlsSettings) addr() string {
+	if len(settings.domains) > 0 {
+		return httpsPort
+	}
+
+	return port
+}
+
+// serve runs server on listener with whichever TLS mode settings asks for,
+// like http.Server.Serve it only returns with an error
+func serve(server *http.Server, listener net.Listener, settings tlsSettings) error {
+	switch {
+	case len(settings.domains) > 0:
+		manager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(settings.domains...),
+			Cache:      autocert.DirCache(settings.cacheDir),
+		}
+		server.TLSConfig = manager.TLSConfig()
+
+		challenges := &http.Server{
+			Addr:              acmeChallengePort,
+			Handler:           manager.HTTPHandler(nil),
+			ReadHeaderTimeout: readHeaderTimeout,
+		}
+		go func() {
+			if err := challenges.ListenAndServe(); err != nil {
+				log.Fatalf("acme challenge server error: %v", err)
+			}
+		}()
+
+		// the certificates come from the manager
+		return server.ServeTLS(listener, "", "")
+	case settings.certFile != "":
+		return server.ServeTLS(listener, settings.certFile, settings.keyFile)
+	default:
+		return server.Serve(listener)
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// writeSelfSignedCert writes a certificate for 127.0.0.1 and its key as PEM
+// files and returns their paths along with the certificate.
+func writeSelfSignedCert(t *testing.T) (certFile, keyFile string, cert *x509.Certificate) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "gpt-1 test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err = x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return certFile, keyFile, cert
+}
+
+func TestServeTLS(t *testing.T) {
+	original := items
+	items = slices.Clone(items)
+	t.Cleanup(func() { items = original })
+
+	certFile, keyFile, cert := writeSelfSignedCert(t)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := &http.Server{Handler: newRouter(), ReadHeaderTimeout: readHeaderTimeout}
+	served := make(chan error, 1)
+	go func() {
+		served <- serve(server, listener, tlsSettings{certFile: certFile, keyFile: keyFile})
+	}()
+	t.Cleanup(func() {
+		server.Close()
+		if err := <-served; !errors.Is(err, http.ErrServerClosed) {
+			t.Errorf("expected the server to stop with ErrServerClosed, got %v", err)
+		}
+	})
+
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+	client := &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig:   &tls.Config{RootCAs: roots},
+			ForceAttemptHTTP2: true,
+		},
+		Timeout: 5 * time.Second,
+	}
+	defer client.CloseIdleConnections()
+
+	response, err := client.Get("https://" + listener.Addr().String() + "/items")
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, response.StatusCode)
+	}
+	if response.TLS == nil {
+		t.Error("expected a TLS connection")
+	}
+	if response.ProtoMajor != 2 {
+		t.Errorf("expected HTTP/2, got %s", response.Proto)
+	}
+
+	// plain HTTP on the TLS port doesn't reach the routes
+	plain, err := http.Get("http://" + listener.Addr().String() + "/items")
+	if err == nil {
+		plain.Body.Close()
+		if plain.StatusCode == http.StatusOK {
+			t.Error("expected plain HTTP to be refused")
+		}
+	}
+}
+
+func TestTLSSettingsFromEnv(t *testing.T) {
+	tests := []struct {
+		name              string
+		cert, key, domain string
+		wantErr           bool
+		wantAddr          string
+		wantDomains       []string
+	}{
+		{name: "plain http", wantAddr: port},
+		{name: "certificate", cert: "cert.pem", key: "key.pem", wantAddr: port},
+		{name: "autocert", domain: "api.example.com, www.example.com", wantAddr: httpsPort, wantDomains: []string{"api.example.com", "www.example.com"}},
+		{name: "cert without key", cert: "cert.pem", wantErr: true},
+		{name: "key without cert", key: "key.pem", wantErr: true},
+		{name: "both modes", cert: "cert.pem", key: "key.pem", domain: "api.example.com", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TLS_CERT", tt.cert)
+			t.Setenv("TLS_KEY", tt.key)
+			t.Setenv("DOMAIN", tt.domain)
+			t.Setenv("AUTOCERT_CACHE", "")
+
+			settings, err := tlsSettingsFromEnv()
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected an error, got %+v", settings)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if settings.addr() != tt.wantAddr {
+				t.Errorf("expected to listen on %s, got %s", tt.wantAddr, settings.addr())
+			}
+			if !slices.Equal(settings.domains, tt.wantDomains) {
+				t.Errorf("expected domains %v, got %v", tt.wantDomains, settings.domains)
+			}
+			if settings.cacheDir != defaultAutocertCacheDir {
+				t.Errorf("expected the default cache dir, got %q", settings.cacheDir)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+/*
+	when WEBHOOK_URL is set every change to the items is POSTed there as an
+	itemEvent, from a goroutine so a slow receiver never delays the client
+*/
+
+const webhookTimeout = 5 * time.Second
+
+const (
+	itemCreated = "item.created"
+	itemUpdated = "item.updated"
+	itemDeleted = "item.deleted"
+)
+
+var webhookURL string
+
+type itemEvent struct {
+	Type      string    `json:"type"`
+	Item      Item      `json:"item"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+func notifyWebhook(event itemEvent) {
+	// read once so the goroutine isn't affected by later config changes
+	url := webhookURL
+	if url == "" {
+		return
+	}
+
+	go func() {
+		if err := sendWebhook(url, event); err != nil {
+			log.Printf("webhook %s for item %d failed: %v", event.Type, event.Item.ID, err)
+		}
+	}()
+}
+
+func sendWebhook(url string, event itemEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+	defer cancel()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", response.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWebhookOnCreate(t *testing.T) {
+	original := items
+	t.Cleanup(func() { items = original })
+
+	events := make(chan itemEvent, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		var event itemEvent
+		if err := json.NewDecoder(request.Body).Decode(&event); err != nil {
+			t.Errorf("decoding webhook body: %v", err)
+		}
+		events <- event
+	}))
+	t.Cleanup(receiver.Close)
+
+	webhookURL = receiver.URL
+	t.Cleanup(func() { webhookURL = "" })
+
+	request := httptest.NewRequest(http.MethodPost, "/items", strings.NewReader(`{"name":"Monitor","price":250}`))
+	response := httptest.NewRecorder()
+	createItem(response, request)
+
+	if response.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d", http.StatusCreated, response.Code)
+	}
+
+	select {
+	case event := <-events:
+		if event.Type != itemCreated || event.Item.Name != "Monitor" || event.Timestamp.IsZero() {
+			t.Errorf("unexpected event %+v", event)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("webhook was not called")
+	}
+}
@@ -0,0 +1,102 @@
+// Package apperror defines an error type that carries the HTTP status and
+// machine readable code it should be rendered with, so handlers can return
+// errors instead of picking status codes ad hoc.
+package apperror
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// Error is an error meant to be shown to the client. Err optionally holds the
+// underlying cause, which is never rendered.
+type Error struct {
+	Code       string
+	Message    string
+	HTTPStatus int
+	// Fields holds per-field messages for validation failures.
+	Fields map[string]string
+	Err    error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func New(status int, code, message string) *Error {
+	return &Error{Code: code, Message: message, HTTPStatus: status}
+}
+
+func BadRequest(message string) *Error {
+	return New(http.StatusBadRequest, "bad_request", message)
+}
+
+func Forbidden(message string) *Error {
+	return New(http.StatusForbidden, "forbidden", message)
+}
+
+func NotFound(message string) *Error {
+	return New(http.StatusNotFound, "not_found", message)
+}
+
+func MethodNotAllowed(message string) *Error {
+	return New(http.StatusMethodNotAllowed, "method_not_allowed", message)
+}
+
+func Conflict(message string) *Error {
+	return New(http.StatusConflict, "conflict", message)
+}
+
+func Validation(fields map[string]string) *Error {
+	e := New(http.StatusUnprocessableEntity, "validation_failed", "validation failed")
+	e.Fields = fields
+
+	return e
+}
+
+// Internal wraps err behind a generic message so internals don't leak.
+func Internal(err error) *Error {
+	e := New(http.StatusInternalServerError, "internal", "the server encountered a problem")
+	e.Err = err
+
+	return e
+}
+
+// From returns the *Error in err's chain, treating anything else as Internal.
+func From(err error) *Error {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+
+	return Internal(err)
+}
+
+// WriteError renders err as {"error": ..., "code": ...} with its status.
+func WriteError(w http.ResponseWriter, err error) error {
+	appErr := From(err)
+
+	type envelope struct {
+		Error  string            `json:"error"`
+		Code   string            `json:"code"`
+		Fields map[string]string `json:"fields,omitempty"`
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(appErr.HTTPStatus)
+
+	return json.NewEncoder(w).Encode(&envelope{
+		Error:  appErr.Message,
+		Code:   appErr.Code,
+		Fields: appErr.Fields,
+	})
+}
@@ -0,0 +1,68 @@
+package apperror
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWriteError(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		status  int
+		code    string
+		message string
+	}{
+		{name: "bad request", err: BadRequest("id must be a number"), status: http.StatusBadRequest, code: "bad_request", message: "id must be a number"},
+		{name: "forbidden", err: Forbidden("forbidden"), status: http.StatusForbidden, code: "forbidden", message: "forbidden"},
+		{name: "not found", err: NotFound("item not found"), status: http.StatusNotFound, code: "not_found", message: "item not found"},
+		{name: "method not allowed", err: MethodNotAllowed("nope"), status: http.StatusMethodNotAllowed, code: "method_not_allowed", message: "nope"},
+		{name: "conflict", err: Conflict("taken"), status: http.StatusConflict, code: "conflict", message: "taken"},
+		{name: "validation", err: Validation(map[string]string{"name": "required"}), status: http.StatusUnprocessableEntity, code: "validation_failed", message: "validation failed"},
+		{name: "custom", err: New(http.StatusTeapot, "teapot", "short and stout"), status: http.StatusTeapot, code: "teapot", message: "short and stout"},
+		{name: "wrapped", err: fmt.Errorf("loading item: %w", NotFound("item not found")), status: http.StatusNotFound, code: "not_found", message: "item not found"},
+		{name: "plain error", err: errors.New("connection refused"), status: http.StatusInternalServerError, code: "internal", message: "the server encountered a problem"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			if err := WriteError(rr, tt.err); err != nil {
+				t.Fatal(err)
+			}
+
+			if rr.Code != tt.status {
+				t.Errorf("expected status %d, got %d", tt.status, rr.Code)
+			}
+
+			if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("expected application/json, got %q", ct)
+			}
+
+			var body struct {
+				Error string `json:"error"`
+				Code  string `json:"code"`
+			}
+			if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+
+			if body.Code != tt.code || body.Error != tt.message {
+				t.Errorf("expected %s %q, got %s %q", tt.code, tt.message, body.Code, body.Error)
+			}
+		})
+	}
+}
+
+func TestInternalKeepsCause(t *testing.T) {
+	cause := errors.New("disk full")
+	err := Internal(cause)
+
+	if !errors.Is(err, cause) {
+		t.Error("expected the cause to be reachable through Unwrap")
+	}
+}
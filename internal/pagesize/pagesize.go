@@ -0,0 +1,49 @@
+// Package pagesize holds the page size limits of the list endpoints, so
+// operators can tune them with DEFAULT_PAGE_SIZE and MAX_PAGE_SIZE instead of
+// each handler hardcoding its own.
+package pagesize
+
+import (
+	"os"
+	"strconv"
+)
+
+const (
+	// FallbackDefault and FallbackMax are used when the environment sets
+	// nothing usable.
+	FallbackDefault = 20
+	FallbackMax     = 100
+)
+
+// Config bounds a list: Default is the page size of a request that doesn't
+// ask for one, Max the largest one it may ask for.
+type Config struct {
+	Default int
+	Max     int
+}
+
+// Fallback is the Config of an unset environment.
+func Fallback() Config {
+	return Config{Default: FallbackDefault, Max: FallbackMax}
+}
+
+// FromEnv reads DEFAULT_PAGE_SIZE and MAX_PAGE_SIZE. Missing, malformed and
+// non-positive values fall back, and a default above the max is lowered to it.
+func FromEnv() Config {
+	cfg := Config{
+		Default: positiveEnv("DEFAULT_PAGE_SIZE", FallbackDefault),
+		Max:     positiveEnv("MAX_PAGE_SIZE", FallbackMax),
+	}
+	cfg.Default = min(cfg.Default, cfg.Max)
+
+	return cfg
+}
+
+func positiveEnv(key string, fallback int) int {
+	n, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || n < 1 {
+		return fallback
+	}
+
+	return n
+}
@@ -0,0 +1,30 @@
+package pagesize
+
+import "testing"
+
+func TestFromEnv(t *testing.T) {
+	tests := []struct {
+		name        string
+		defaultSize string
+		maxSize     string
+		want        Config
+	}{
+		{name: "unset", want: Config{Default: FallbackDefault, Max: FallbackMax}},
+		{name: "both set", defaultSize: "50", maxSize: "500", want: Config{Default: 50, Max: 500}},
+		{name: "default only", defaultSize: "5", want: Config{Default: 5, Max: FallbackMax}},
+		{name: "default above max", defaultSize: "80", maxSize: "40", want: Config{Default: 40, Max: 40}},
+		{name: "max below fallback default", maxSize: "10", want: Config{Default: 10, Max: 10}},
+		{name: "malformed", defaultSize: "ten", maxSize: "-1", want: Config{Default: FallbackDefault, Max: FallbackMax}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DEFAULT_PAGE_SIZE", tt.defaultSize)
+			t.Setenv("MAX_PAGE_SIZE", tt.maxSize)
+
+			if got := FromEnv(); got != tt.want {
+				t.Errorf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+// Package realip works out the address of the client that sent a request,
+// looking through X-Forwarded-For only when it was set by a trusted proxy.
+package realip
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// ClientIP returns the client address for r. trustedProxies holds IPs or CIDR
+// ranges; invalid entries are ignored.
+//
+// X-Forwarded-For is only read when the immediate peer is trusted, and is
+// then walked from the right, skipping trusted hops, so a client can't spoof
+// its address by sending its own header. When the peer isn't trusted the
+// result is simply the host part of r.RemoteAddr.
+func ClientIP(r *http.Request, trustedProxies []string) string {
+	trusted := parsePrefixes(trustedProxies)
+
+	peer, err := parseAddr(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	ip := peer
+	if !contains(trusted, ip) {
+		return ip.String()
+	}
+
+	hops := forwardedFor(r)
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := parseAddr(hops[i])
+		if err != nil {
+			// the hop we trusted sent garbage, so it is the best we know
+			break
+		}
+
+		ip = hop
+		if !contains(trusted, ip) {
+			break
+		}
+	}
+
+	return ip.String()
+}
+
+func forwardedFor(r *http.Request) []string {
+	var hops []string
+	for _, value := range r.Header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(value, ",") {
+			hops = append(hops, strings.TrimSpace(hop))
+		}
+	}
+
+	return hops
+}
+
+// parseAddr accepts a bare IP or a host:port pair.
+func parseAddr(s string) (netip.Addr, error) {
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	}
+
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+
+	return addr.Unmap(), nil
+}
+
+func parsePrefixes(values []string) []netip.Prefix {
+	prefixes := make([]netip.Prefix, 0, len(values))
+
+	for _, value := range values {
+		if prefix, err := netip.ParsePrefix(value); err == nil {
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+
+		if addr, err := netip.ParseAddr(value); err == nil {
+			addr = addr.Unmap()
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+		}
+	}
+
+	return prefixes
+}
+
+func contains(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,44 @@
+package realip
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	trusted := []string{"10.0.0.0/8", "192.168.1.5", "not-an-ip"}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		xff        []string
+		trusted    []string
+		want       string
+	}{
+		{name: "no proxies configured", remoteAddr: "203.0.113.7:5123", want: "203.0.113.7"},
+		{name: "untrusted peer ignores header", remoteAddr: "203.0.113.7:5123", xff: []string{"1.2.3.4"}, trusted: trusted, want: "203.0.113.7"},
+		{name: "trusted peer", remoteAddr: "10.1.2.3:80", xff: []string{"198.51.100.9"}, trusted: trusted, want: "198.51.100.9"},
+		{name: "trusted single ip", remoteAddr: "192.168.1.5:80", xff: []string{"198.51.100.9"}, trusted: trusted, want: "198.51.100.9"},
+		{name: "spoofed leftmost entry", remoteAddr: "10.1.2.3:80", xff: []string{"6.6.6.6, 198.51.100.9"}, trusted: trusted, want: "198.51.100.9"},
+		{name: "chain of trusted proxies", remoteAddr: "10.1.2.3:80", xff: []string{"198.51.100.9, 10.9.9.9", "192.168.1.5"}, trusted: trusted, want: "198.51.100.9"},
+		{name: "trusted peer without header", remoteAddr: "10.1.2.3:80", trusted: trusted, want: "10.1.2.3"},
+		{name: "garbage hop", remoteAddr: "10.1.2.3:80", xff: []string{"198.51.100.9, bogus"}, trusted: trusted, want: "10.1.2.3"},
+		{name: "all hops trusted", remoteAddr: "10.1.2.3:80", xff: []string{"10.4.4.4"}, trusted: trusted, want: "10.4.4.4"},
+		{name: "ipv6 peer", remoteAddr: "[2001:db8::1]:443", xff: []string{"198.51.100.9"}, trusted: []string{"2001:db8::/32"}, want: "198.51.100.9"},
+		{name: "ipv4 mapped peer", remoteAddr: "[::ffff:10.1.2.3]:80", xff: []string{"198.51.100.9"}, trusted: trusted, want: "198.51.100.9"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for _, value := range tt.xff {
+				r.Header.Add("X-Forwarded-For", value)
+			}
+
+			if got := ClientIP(r, tt.trusted); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
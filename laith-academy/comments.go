@@ -0,0 +1,287 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yowger/golang-api-study/internal/pagesize"
+)
+
+var errCommentNotFound = errors.New("comment not found")
+
+type Comment struct {
+	ID        int       `json:"id"`
+	Author    string    `json:"author"`
+	Body      string    `json:"body"`
+	Likes     int       `json:"likes"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type commentStore struct {
+	mu       sync.RWMutex
+	nextID   int
+	comments []Comment
+}
+
+func newCommentStore() *commentStore {
+	return &commentStore{nextID: 1}
+}
+
+func (s *commentStore) create(c *Comment) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c.ID = s.nextID
+	c.CreatedAt = time.Now().UTC()
+	s.nextID++
+
+	s.comments = append(s.comments, *c)
+}
+
+func (s *commentStore) get(id int) (Comment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, c := range s.comments {
+		if c.ID == id {
+			return c, nil
+		}
+	}
+
+	return Comment{}, errCommentNotFound
+}
+
+// like adjusts the like counter by delta, never letting it drop below zero,
+// and returns the updated comment.
+func (s *commentStore) like(id, delta int) (Comment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.comments {
+		if s.comments[i].ID == id {
+			s.comments[i].Likes = max(s.comments[i].Likes+delta, 0)
+			return s.comments[i], nil
+		}
+	}
+
+	return Comment{}, errCommentNotFound
+}
+
+// list returns a copy of the comments created after since in the
+// [offset, offset+limit) window and the total number of such comments. A zero
+// since matches every comment.
+func (s *commentStore) list(since time.Time, offset, limit int) ([]Comment, int) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	matched := make([]Comment, 0, len(s.comments))
+	for _, c := range s.comments {
+		if since.IsZero() || c.CreatedAt.After(since) {
+			matched = append(matched, c)
+		}
+	}
+
+	total := len(matched)
+	offset = min(offset, total)
+	end := min(offset+limit, total)
+
+	return matched[offset:end], total
+}
+
+// update replaces the body of an existing comment.
+func (s *commentStore) update(id int, body string) (Comment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.comments {
+		if s.comments[i].ID == id {
+			s.comments[i].Body = body
+			return s.comments[i], nil
+		}
+	}
+
+	return Comment{}, errCommentNotFound
+}
+
+type api struct {
+	comments *commentStore
+	rules    commentRules
+	pages    pagesize.Config
+}
+
+type commentList struct {
+	Comments []Comment `json:"comments"`
+	Total    int       `json:"total"`
+	Limit    int       `json:"limit"`
+	Offset   int       `json:"offset"`
+}
+
+func (a *api) listComments(w http.ResponseWriter, r *http.Request) {
+	limit, err := queryInt(r, "limit", a.pages.Default)
+	if err != nil || limit < 1 || limit > a.pages.Max {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", a.pages.Max))
+		return
+	}
+
+	offset, err := queryInt(r, "offset", 0)
+	if err != nil || offset < 0 {
+		writeError(w, http.StatusBadRequest, "offset must be a non-negative integer")
+		return
+	}
+
+	var since time.Time
+	if value := r.URL.Query().Get("since"); value != "" {
+		since, err = time.Parse(time.RFC3339, value)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "since must be an RFC 3339 timestamp")
+			return
+		}
+	}
+
+	comments, total := a.comments.list(since, offset, limit)
+
+	writeJSON(w, http.StatusOK, commentList{
+		Comments: comments,
+		Total:    total,
+		Limit:    limit,
+		Offset:   offset,
+	})
+}
+
+func (a *api) getComment(w http.ResponseWriter, r *http.Request) {
+	id, ok := commentID(w, r)
+	if !ok {
+		return
+	}
+
+	comment, err := a.comments.get(id)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, comment)
+}
+
+type commentPayload struct {
+	Author string `json:"author"`
+	Body   string `json:"body"`
+}
+
+func (a *api) createComment(w http.ResponseWriter, r *http.Request) {
+	var payload commentPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	// the X-Author header wins over the body so proxies can attribute
+	// comments on behalf of their users
+	author := strings.TrimSpace(r.Header.Get("X-Author"))
+	if author == "" {
+		author = strings.TrimSpace(payload.Author)
+	}
+
+	if author == "" {
+		writeError(w, http.StatusBadRequest, "author is required, set the X-Author header or the author field")
+		return
+	}
+
+	comment := Comment{Author: author, Body: payload.Body}
+
+	if err := comment.Validate(a.rules); err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	a.comments.create(&comment)
+
+	writeJSON(w, http.StatusCreated, comment)
+}
+
+func (a *api) updateComment(w http.ResponseWriter, r *http.Request) {
+	id, ok := commentID(w, r)
+	if !ok {
+		return
+	}
+
+	var payload commentPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	candidate := Comment{Body: payload.Body}
+
+	if err := candidate.Validate(a.rules); err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	comment, err := a.comments.update(id, candidate.Body)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, comment)
+}
+
+func (a *api) likeComment(w http.ResponseWriter, r *http.Request) {
+	a.adjustLikes(w, r, 1)
+}
+
+func (a *api) unlikeComment(w http.ResponseWriter, r *http.Request) {
+	a.adjustLikes(w, r, -1)
+}
+
+func (a *api) adjustLikes(w http.ResponseWriter, r *http.Request, delta int) {
+	id, ok := commentID(w, r)
+	if !ok {
+		return
+	}
+
+	comment, err := a.comments.like(id, delta)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, comment)
+}
+
+// commentID parses the {id} path value, writing a 400 when it is invalid.
+func commentID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid comment id")
+		return 0, false
+	}
+
+	return id, true
+}
+
+func queryInt(r *http.Request, key string, fallback int) (int, error) {
+	value := r.URL.Query().Get(key)
+	if value == "" {
+		return fallback, nil
+	}
+
+	return strconv.Atoi(value)
+}
+
+func writeJSON(w http.ResponseWriter, status int, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yowger/golang-api-study/internal/pagesize"
+)
+
+func newTestAPI(t *testing.T) (*api, http.Handler) {
+	t.Helper()
+
+	a := &api{
+		comments: newCommentStore(),
+		rules:    newCommentRules(defaultCommentMaxLength, nil),
+		pages:    pagesize.Fallback(),
+	}
+
+	return a, newMux(a)
+}
+
+func TestListCommentsPagination(t *testing.T) {
+	a, mux := newTestAPI(t)
+
+	for i := 0; i < 30; i++ {
+		a.comments.create(&Comment{Body: fmt.Sprintf("comment %d", i)})
+	}
+
+	t.Run("default window", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/comment", nil))
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		var resp commentList
+		if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(resp.Comments) != pagesize.FallbackDefault || resp.Total != 30 {
+			t.Errorf("expected %d of 30 comments, got %d of %d", pagesize.FallbackDefault, len(resp.Comments), resp.Total)
+		}
+
+		if resp.Comments[0].ID != 1 || resp.Comments[19].ID != 20 {
+			t.Errorf("unexpected window %d..%d", resp.Comments[0].ID, resp.Comments[19].ID)
+		}
+	})
+
+	t.Run("explicit window", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/comment?limit=5&offset=28", nil))
+
+		var resp commentList
+		if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(resp.Comments) != 2 || resp.Comments[0].ID != 29 {
+			t.Errorf("unexpected window %+v", resp.Comments)
+		}
+	})
+
+	for _, query := range []string{"limit=0", "limit=101", "limit=abc", "offset=-1"} {
+		t.Run(query, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/comment?"+query, nil))
+
+			if rr.Code != http.StatusBadRequest {
+				t.Errorf("expected status %d, got %d", http.StatusBadRequest, rr.Code)
+			}
+		})
+	}
+}
+
+func TestListCommentsPageSizeFromEnv(t *testing.T) {
+	t.Setenv("DEFAULT_PAGE_SIZE", "5")
+	t.Setenv("MAX_PAGE_SIZE", "10")
+
+	a := &api{
+		comments: newCommentStore(),
+		rules:    newCommentRules(defaultCommentMaxLength, nil),
+		pages:    pagesize.FromEnv(),
+	}
+	mux := newMux(a)
+
+	for i := 0; i < 30; i++ {
+		a.comments.create(&Comment{Body: fmt.Sprintf("comment %d", i)})
+	}
+
+	tests := []struct {
+		query  string
+		status int
+		count  int
+	}{
+		{query: "", status: http.StatusOK, count: 5},
+		{query: "limit=10", status: http.StatusOK, count: 10},
+		{query: "limit=11", status: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run("?"+tt.query, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/comment?"+tt.query, nil))
+
+			if rr.Code != tt.status {
+				t.Fatalf("expected status %d, got %d", tt.status, rr.Code)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+
+			var resp commentList
+			if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+			if len(resp.Comments) != tt.count || resp.Limit != tt.count {
+				t.Errorf("expected %d comments, got %d with limit %d", tt.count, len(resp.Comments), resp.Limit)
+			}
+		})
+	}
+}
+
+func TestListCommentsSince(t *testing.T) {
+	a, mux := newTestAPI(t)
+
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 4; i++ {
+		a.comments.create(&Comment{Body: fmt.Sprintf("comment %d", i)})
+		a.comments.comments[i].CreatedAt = base.Add(time.Duration(i) * time.Hour)
+	}
+
+	tests := []struct {
+		since string
+		want  []int
+	}{
+		{since: "", want: []int{1, 2, 3, 4}},
+		{since: "2024-05-01T12:30:00Z", want: []int{2, 3, 4}},
+		// the cutoff itself is excluded
+		{since: "2024-05-01T14:00:00Z", want: []int{4}},
+		{since: "2024-05-01T17:00:00+02:00", want: []int{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.since, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/comment?since="+url.QueryEscape(tt.since), nil))
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("expected status %d, got %d", http.StatusOK, rr.Code)
+			}
+
+			var resp commentList
+			if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+
+			got := []int{}
+			for _, c := range resp.Comments {
+				got = append(got, c.ID)
+			}
+
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) || resp.Total != len(tt.want) {
+				t.Errorf("expected ids %v, got %v (total %d)", tt.want, got, resp.Total)
+			}
+		})
+	}
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/comment?since=yesterday", nil))
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
+func TestCommentLikes(t *testing.T) {
+	a, mux := newTestAPI(t)
+	a.comments.create(&Comment{Body: "nice post"})
+
+	send := func(method, path string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(method, path, nil))
+		return rr
+	}
+
+	send(http.MethodPost, "/comment/1/like")
+	send(http.MethodPost, "/comment/1/like")
+	rr := send(http.MethodDelete, "/comment/1/like")
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	var comment Comment
+	if err := json.NewDecoder(rr.Body).Decode(&comment); err != nil {
+		t.Fatal(err)
+	}
+
+	if comment.Likes != 1 {
+		t.Errorf("expected 1 like, got %d", comment.Likes)
+	}
+
+	t.Run("never below zero", func(t *testing.T) {
+		send(http.MethodDelete, "/comment/1/like")
+		send(http.MethodDelete, "/comment/1/like")
+
+		if c, _ := a.comments.get(1); c.Likes != 0 {
+			t.Errorf("expected 0 likes, got %d", c.Likes)
+		}
+	})
+
+	t.Run("unknown comment", func(t *testing.T) {
+		if rr := send(http.MethodPost, "/comment/99/like"); rr.Code != http.StatusNotFound {
+			t.Errorf("expected status %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+}
+
+func TestCreateCommentAuthor(t *testing.T) {
+	a, mux := newTestAPI(t)
+
+	tests := []struct {
+		name   string
+		header string
+		body   string
+		status int
+		author string
+	}{
+		{name: "from header", header: "laith", body: `{"body":"hi"}`, status: http.StatusCreated, author: "laith"},
+		{name: "from body", body: `{"author":"sara","body":"hi"}`, status: http.StatusCreated, author: "sara"},
+		{name: "header wins", header: "laith", body: `{"author":"sara","body":"hi"}`, status: http.StatusCreated, author: "laith"},
+		{name: "missing", body: `{"author":"  ","body":"hi"}`, status: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/comment", strings.NewReader(tt.body))
+			if tt.header != "" {
+				req.Header.Set("X-Author", tt.header)
+			}
+
+			rr := httptest.NewRecorder()
+			mux.ServeHTTP(rr, req)
+
+			if rr.Code != tt.status {
+				t.Fatalf("expected status %d, got %d", tt.status, rr.Code)
+			}
+
+			if tt.status != http.StatusCreated {
+				return
+			}
+
+			var created Comment
+			if err := json.NewDecoder(rr.Body).Decode(&created); err != nil {
+				t.Fatal(err)
+			}
+
+			stored, err := a.comments.get(created.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if created.Author != tt.author || stored.Author != tt.author {
+				t.Errorf("expected author %q, got response %q and stored %q", tt.author, created.Author, stored.Author)
+			}
+		})
+	}
+}
@@ -3,29 +3,35 @@ package main
 import (
 	"fmt"
 	"net/http"
-)
 
-func main() {
-	fmt.Println("API")
+	"github.com/yowger/golang-api-study/internal/pagesize"
+)
 
+func newMux(a *api) *http.ServeMux {
 	mux := http.NewServeMux()
 
 	// routes
-	mux.HandleFunc("GET /comment", func(w http.ResponseWriter, r *http.Request) {
-		fmt.Fprint(w, "get comment")
-	})
+	mux.HandleFunc("GET /comment", a.listComments)
+	mux.HandleFunc("GET /comment/{id}", a.getComment)
+	mux.HandleFunc("POST /comment", a.createComment)
+	mux.HandleFunc("PUT /comment/{id}", a.updateComment)
+	mux.HandleFunc("POST /comment/{id}/like", a.likeComment)
+	mux.HandleFunc("DELETE /comment/{id}/like", a.unlikeComment)
 
-	mux.HandleFunc("GET /comment/{id}", func(w http.ResponseWriter, r *http.Request) {
-		id := r.PathValue(("id"))
-		fmt.Fprintf(w, "get comment by id: %s", id)
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "Hello World")
 	})
 
-	mux.HandleFunc("POST /comment", func(w http.ResponseWriter, r *http.Request) {
-		fmt.Fprint(w, "post comment")
-	})
+	return mux
+}
 
-	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		fmt.Fprint(w, "Hello World")
+func main() {
+	fmt.Println("API")
+
+	mux := newMux(&api{
+		comments: newCommentStore(),
+		rules:    commentRulesFromEnv(),
+		pages:    pagesize.FromEnv(),
 	})
 
 	// server
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const defaultCommentMaxLength = 1000
+
+type commentRules struct {
+	maxLength int
+	// blocklist holds lowercased words that may not appear in a comment.
+	blocklist map[string]bool
+}
+
+func newCommentRules(maxLength int, blocked []string) commentRules {
+	rules := commentRules{
+		maxLength: maxLength,
+		blocklist: make(map[string]bool, len(blocked)),
+	}
+
+	for _, word := range blocked {
+		if word = strings.ToLower(strings.TrimSpace(word)); word != "" {
+			rules.blocklist[word] = true
+		}
+	}
+
+	return rules
+}
+
+// commentRulesFromEnv reads COMMENT_MAX_LENGTH and the comma separated
+// COMMENT_BLOCKLIST.
+func commentRulesFromEnv() commentRules {
+	maxLength := defaultCommentMaxLength
+	if n, err := strconv.Atoi(os.Getenv("COMMENT_MAX_LENGTH")); err == nil && n > 0 {
+		maxLength = n
+	}
+
+	var blocked []string
+	if list := os.Getenv("COMMENT_BLOCKLIST"); list != "" {
+		blocked = strings.Split(list, ",")
+	}
+
+	return newCommentRules(maxLength, blocked)
+}
+
+func (c *Comment) Validate(rules commentRules) error {
+	body := strings.TrimSpace(c.Body)
+
+	if body == "" {
+		return errors.New("body must not be empty")
+	}
+
+	if utf8.RuneCountInString(body) > rules.maxLength {
+		return fmt.Errorf("body must be at most %d characters", rules.maxLength)
+	}
+
+	words := strings.FieldsFunc(strings.ToLower(body), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	for _, word := range words {
+		if rules.blocklist[word] {
+			return fmt.Errorf("body contains a blocked word: %q", word)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCommentValidation(t *testing.T) {
+	a, mux := newTestAPI(t)
+	a.rules = newCommentRules(20, []string{"Spam", "scam"})
+	a.comments.create(&Comment{Body: "original"})
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{name: "valid", method: http.MethodPost, path: "/comment", body: `{"body":"great talk"}`, status: http.StatusCreated},
+		{name: "empty", method: http.MethodPost, path: "/comment", body: `{"body":"   "}`, status: http.StatusUnprocessableEntity},
+		{name: "too long", method: http.MethodPost, path: "/comment", body: `{"body":"` + strings.Repeat("a", 21) + `"}`, status: http.StatusUnprocessableEntity},
+		{name: "blocked word", method: http.MethodPost, path: "/comment", body: `{"body":"buy SPAM now"}`, status: http.StatusUnprocessableEntity},
+		{name: "blocked word on update", method: http.MethodPut, path: "/comment/1", body: `{"body":"total scam!"}`, status: http.StatusUnprocessableEntity},
+		{name: "blocked word inside another word", method: http.MethodPut, path: "/comment/1", body: `{"body":"scampi recipe"}`, status: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("X-Author", "laith")
+
+			rr := httptest.NewRecorder()
+			mux.ServeHTTP(rr, req)
+
+			if rr.Code != tt.status {
+				t.Errorf("expected status %d, got %d: %s", tt.status, rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// RequireRole lets through only callers whose token lists role in its roles
+// claim and answers everyone else with 403, as in
+// r.With(RequireRole("admin")).Get(...). It reads the claims AuthMiddleware
+// stored, so it has to run after it; a request that never went through it is
+// answered with 401.
+func RequireRole(role string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := subjectFromContext(r.Context()); !ok {
+				unauthorized(w, r, "missing bearer token")
+				return
+			}
+
+			if !hasRole(r.Context(), role) {
+				respondError(w, r, http.StatusForbidden, codeForbidden, "requires the "+role+" role")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// adminRoutes are mounted at /admin behind AuthMiddleware. There is no
+// scopeMiddleware here, so /admin/todos lists every user's todos.
+func (h *todoHandler) adminRoutes() chi.Router {
+	r := chi.NewRouter()
+	r.Use(RequireRole(roleAdmin))
+	r.Use(timeoutMiddleware(h.requestTimeout()))
+
+	r.Method(http.MethodGet, "/profile", documented(routeDoc{Summary: "Show the admin's token claims", Response: adminProfile{}}, getAdminProfileHandler))
+	r.Method(http.MethodGet, "/todos", documented(routeDoc{Summary: "List every user's todos", Response: []Todo{}}, h.listTodos))
+
+	return r
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestAdminRoutes(t *testing.T) {
+	r := newAPIRouter(t, &todoHandler{store: newMemoryTodoStore()}, nil, nil)
+
+	ana, bob, admin := testToken(t, "ana"), testToken(t, "bob"), testToken(t, "root", roleAdmin)
+
+	do := func(token, method, target, body string) *httptest.ResponseRecorder {
+		t.Helper()
+
+		req := newJSONRequest(method, target, body)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+
+	for _, create := range []struct{ token, title string }{{ana, "Ana's todo"}, {bob, "Bob's todo"}} {
+		if rr := do(create.token, http.MethodPost, "/v1/todo", `{"title":"`+create.title+`"}`); rr.Code != http.StatusCreated {
+			t.Fatalf("create %q: expected status %d, got %d", create.title, http.StatusCreated, rr.Code)
+		}
+	}
+
+	t.Run("admin", func(t *testing.T) {
+		rr := do(admin, http.MethodGet, "/v1/admin/profile", "")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		var profile adminProfile
+		if err := json.NewDecoder(rr.Body).Decode(&profile); err != nil {
+			t.Fatal(err)
+		}
+		if profile.Subject != "root" || !slices.Equal(profile.Roles, []string{roleAdmin}) {
+			t.Errorf("unexpected profile %+v", profile)
+		}
+
+		rr = do(admin, http.MethodGet, "/v1/admin/todos", "")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		var todos []Todo
+		if err := json.NewDecoder(rr.Body).Decode(&todos); err != nil {
+			t.Fatal(err)
+		}
+		if len(todos) != 2 {
+			t.Errorf("expected every user's todos, got %d", len(todos))
+		}
+	})
+
+	t.Run("user", func(t *testing.T) {
+		for _, target := range []string{"/v1/admin/profile", "/v1/admin/todos"} {
+			rr := do(ana, http.MethodGet, target, "")
+			if rr.Code != http.StatusForbidden {
+				t.Fatalf("GET %s: expected status %d, got %d", target, http.StatusForbidden, rr.Code)
+			}
+
+			var body errorResponse
+			if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			if body.Code != codeForbidden {
+				t.Errorf("GET %s: expected code %q, got %q", target, codeForbidden, body.Code)
+			}
+		}
+	})
+
+	t.Run("anonymous", func(t *testing.T) {
+		for _, target := range []string{"/v1/admin/profile", "/v1/admin/todos"} {
+			rr := do("", http.MethodGet, target, "")
+			if rr.Code != http.StatusUnauthorized {
+				t.Errorf("GET %s: expected status %d, got %d", target, http.StatusUnauthorized, rr.Code)
+			}
+		}
+	})
+}
+
+func TestRequireRole(t *testing.T) {
+	withTestSecret(t)
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	r := chi.NewRouter()
+	useJSONRouteErrors(r)
+	r.With(AuthMiddleware, RequireRole("editor")).Get("/drafts", ok)
+	// without AuthMiddleware there are no claims to check
+	r.With(RequireRole("editor")).Get("/unguarded", ok)
+
+	tests := []struct {
+		name   string
+		path   string
+		token  string
+		status int
+		code   string
+	}{
+		{name: "has the role", path: "/drafts", token: testToken(t, "ana", "viewer", "editor"), status: http.StatusNoContent},
+		{name: "other roles", path: "/drafts", token: testToken(t, "ana", "viewer", roleAdmin), status: http.StatusForbidden, code: codeForbidden},
+		{name: "no roles", path: "/drafts", token: testToken(t, "ana"), status: http.StatusForbidden, code: codeForbidden},
+		{name: "unauthenticated", path: "/drafts", status: http.StatusUnauthorized, code: codeUnauthorized},
+		{name: "no auth middleware", path: "/unguarded", token: testToken(t, "ana", "editor"), status: http.StatusUnauthorized, code: codeUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			if rr.Code != tt.status {
+				t.Fatalf("expected status %d, got %d", tt.status, rr.Code)
+			}
+			if tt.code == "" {
+				return
+			}
+
+			var body errorResponse
+			if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			if body.Code != tt.code {
+				t.Errorf("expected code %q, got %q", tt.code, body.Code)
+			}
+		})
+	}
+}
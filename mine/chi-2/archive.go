@@ -0,0 +1,25 @@
+package main
+
+import "net/http"
+
+// archiveTodo and unarchiveTodo are idempotent like completeTodo, repeating
+// them leaves ArchivedAt untouched.
+func (h *todoHandler) archiveTodo(w http.ResponseWriter, r *http.Request) {
+	h.setArchived(w, r, true)
+}
+
+func (h *todoHandler) unarchiveTodo(w http.ResponseWriter, r *http.Request) {
+	h.setArchived(w, r, false)
+}
+
+func (h *todoHandler) setArchived(w http.ResponseWriter, r *http.Request, archived bool) {
+	id := TodoIDFromContext(r.Context())
+
+	todo, err := h.storeFor(r).SetArchived(r.Context(), id, archived)
+	if err != nil {
+		storeError(w, r, "archive", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, todo)
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestArchiveTodo(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store TodoStore) {
+		for _, title := range []string{"a", "b", "c"} {
+			if err := store.Create(context.Background(), &Todo{Title: title}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		r := newTodoRouter(t, store)
+
+		post := func(target string) (int, Todo) {
+			t.Helper()
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, target, nil))
+
+			var todo Todo
+			if rr.Code == http.StatusOK {
+				if err := json.NewDecoder(rr.Body).Decode(&todo); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			return rr.Code, todo
+		}
+
+		list := func(query string) []int64 {
+			t.Helper()
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/todo"+query, nil))
+			if rr.Code != http.StatusOK {
+				t.Fatalf("GET /todo%s: expected status %d, got %d", query, http.StatusOK, rr.Code)
+			}
+
+			var todos []Todo
+			if err := json.NewDecoder(rr.Body).Decode(&todos); err != nil {
+				t.Fatal(err)
+			}
+
+			return todoIDs(todos)
+		}
+
+		status, archived := post("/todo/2/archive")
+		if status != http.StatusOK || !archived.Archived || archived.ArchivedAt == nil {
+			t.Fatalf("expected an archived todo, got %d %+v", status, archived)
+		}
+
+		_, again := post("/todo/2/archive")
+		if !again.ArchivedAt.Equal(*archived.ArchivedAt) {
+			t.Errorf("expected archiving twice to keep archived_at %v, got %v", archived.ArchivedAt, again.ArchivedAt)
+		}
+
+		for query, want := range map[string][]int64{
+			"":                       {1, 3},
+			"?archived=false":        {1, 3},
+			"?archived=true":         {2},
+			"?include_archived=true": {1, 2, 3},
+		} {
+			if got := list(query); !slices.Equal(got, want) {
+				t.Errorf("GET /todo%s: expected %v, got %v", query, want, got)
+			}
+		}
+
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, newJSONRequest(http.MethodPut, "/todo/2", fmt.Sprintf(`{"title":"b, renamed","version":%d}`, again.Version)))
+		if rr.Code != http.StatusConflict {
+			t.Fatalf("update while archived: expected status %d, got %d", http.StatusConflict, rr.Code)
+		}
+
+		var errBody errorResponse
+		if err := json.NewDecoder(rr.Body).Decode(&errBody); err != nil {
+			t.Fatal(err)
+		}
+		if errBody.Code != codeTodoArchived {
+			t.Errorf("expected code %q, got %q", codeTodoArchived, errBody.Code)
+		}
+
+		status, unarchived := post("/todo/2/unarchive")
+		if status != http.StatusOK || unarchived.Archived || unarchived.ArchivedAt != nil {
+			t.Fatalf("expected an unarchived todo, got %d %+v", status, unarchived)
+		}
+
+		if got := list(""); !slices.Equal(got, []int64{1, 2, 3}) {
+			t.Errorf("expected every todo after unarchiving, got %v", got)
+		}
+
+		rr = httptest.NewRecorder()
+		r.ServeHTTP(rr, newJSONRequest(http.MethodPut, "/todo/2", fmt.Sprintf(`{"title":"b, renamed","version":%d}`, unarchived.Version)))
+		if rr.Code != http.StatusOK {
+			t.Errorf("update after unarchiving: expected status %d, got %d", http.StatusOK, rr.Code)
+		}
+	})
+}
+
+func TestArchiveTodoErrors(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store TodoStore) {
+		r := newTodoRouter(t, store)
+
+		tests := []struct {
+			method, target string
+			status         int
+		}{
+			{http.MethodPost, "/todo/1/archive", http.StatusNotFound},
+			{http.MethodPost, "/todo/1/unarchive", http.StatusNotFound},
+			{http.MethodGet, "/todo?archived=maybe", http.StatusBadRequest},
+			{http.MethodGet, "/todo?include_archived=maybe", http.StatusBadRequest},
+			{http.MethodGet, "/todo?archived=true&include_archived=true", http.StatusBadRequest},
+		}
+
+		for _, tt := range tests {
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, httptest.NewRequest(tt.method, tt.target, nil))
+
+			if rr.Code != tt.status {
+				t.Errorf("%s %s: expected status %d, got %d", tt.method, tt.target, tt.status, rr.Code)
+			}
+		}
+	})
+}
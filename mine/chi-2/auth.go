@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type contextKey string
+
+const (
+	subjectCtx contextKey = "subject"
+	rolesCtx   contextKey = "roles"
+)
+
+// roleAdmin is the role that may look past the owner scoping with ?all=true
+// and use the /admin routes.
+const roleAdmin = "admin"
+
+type tokenClaims struct {
+	jwt.RegisteredClaims
+	Roles []string `json:"roles,omitempty"`
+	// Use is empty on access tokens and tokenUseRefresh on refresh tokens,
+	// so neither is accepted in place of the other.
+	Use string `json:"use,omitempty"`
+}
+
+const tokenUseRefresh = "refresh"
+
+// jwtSecret signs and verifies the HS256 bearer tokens. main loads it from
+// JWT_SECRET.
+var jwtSecret []byte
+
+// AuthMiddleware requires a valid HS256 bearer token and stores its subject
+// and roles in the request context.
+func AuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+			unauthorized(w, r, "missing bearer token")
+			return
+		}
+
+		claims, err := parseToken(token)
+		if err != nil {
+			logError(r, "rejected bearer token: %v", err)
+
+			if errors.Is(err, jwt.ErrTokenExpired) {
+				unauthorized(w, r, "token has expired")
+				return
+			}
+
+			unauthorized(w, r, "invalid token")
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), subjectCtx, claims.Subject)
+		ctx = context.WithValue(ctx, rolesCtx, claims.Roles)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func subjectFromContext(ctx context.Context) (string, bool) {
+	subject, ok := ctx.Value(subjectCtx).(string)
+	return subject, ok
+}
+
+func rolesFromContext(ctx context.Context) []string {
+	roles, _ := ctx.Value(rolesCtx).([]string)
+	return roles
+}
+
+func hasRole(ctx context.Context, role string) bool {
+	return slices.Contains(rolesFromContext(ctx), role)
+}
+
+// newToken mints a token for subject that expires after ttl. It is what the
+// tests use to authenticate, and what a login endpoint would hand out.
+func newToken(subject string, ttl time.Duration) (string, error) {
+	return newTokenWithRoles(subject, ttl)
+}
+
+func newTokenWithRoles(subject string, ttl time.Duration, roles ...string) (string, error) {
+	now := time.Now()
+
+	claims := tokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   subject,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+		Roles: roles,
+	}
+
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(jwtSecret)
+}
+
+// parseToken verifies an access token.
+func parseToken(token string) (*tokenClaims, error) {
+	claims, err := parseClaims(token)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.Use != "" {
+		return nil, errors.New("not an access token")
+	}
+
+	return claims, nil
+}
+
+func parseClaims(token string) (*tokenClaims, error) {
+	var claims tokenClaims
+
+	_, err := jwt.ParseWithClaims(token, &claims, func(t *jwt.Token) (any, error) {
+		return jwtSecret, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Name}),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.Subject == "" {
+		return nil, errors.New("token has no subject")
+	}
+
+	return &claims, nil
+}
+
+func unauthorized(w http.ResponseWriter, r *http.Request, message string) {
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	respondError(w, r, http.StatusUnauthorized, codeUnauthorized, message)
+}
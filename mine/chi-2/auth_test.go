@@ -0,0 +1,128 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// withTestSecret has the test sign and verify its tokens with a fixed secret,
+// restored to none afterwards.
+func withTestSecret(t *testing.T) {
+	t.Helper()
+
+	jwtSecret = []byte("test-secret")
+	t.Cleanup(func() { jwtSecret = nil })
+}
+
+// testToken mints a minute long access token for subject, with roles.
+func testToken(t *testing.T, subject string, roles ...string) string {
+	t.Helper()
+
+	token, err := newTokenWithRoles(subject, time.Minute, roles...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	r := newAPIRouter(t, &todoHandler{store: newMemoryTodoStore()}, nil, nil)
+
+	valid := testToken(t, "ana")
+
+	expired, err := newToken("ana", -time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jwtSecret = []byte("other-secret")
+	forged, err := newToken("ana", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwtSecret = []byte("test-secret")
+
+	tests := []struct {
+		name   string
+		path   string
+		header string
+		status int
+	}{
+		{name: "no token", path: "/v1/todo", status: http.StatusUnauthorized},
+		{name: "bad token", path: "/v1/todo", header: "Bearer not-a-jwt", status: http.StatusUnauthorized},
+		{name: "wrong secret", path: "/v1/todo", header: "Bearer " + forged, status: http.StatusUnauthorized},
+		{name: "expired token", path: "/v1/todo", header: "Bearer " + expired, status: http.StatusUnauthorized},
+		{name: "wrong scheme", path: "/v1/todo", header: "Basic " + valid, status: http.StatusUnauthorized},
+		{name: "valid token", path: "/v1/todo", header: "Bearer " + valid, status: http.StatusOK},
+		{name: "hello stays public", path: "/v1/", status: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			if rr.Code != tt.status {
+				t.Fatalf("expected status %d, got %d", tt.status, rr.Code)
+			}
+
+			if tt.status == http.StatusUnauthorized && rr.Header().Get("Content-Type") != "application/json" {
+				t.Errorf("expected a JSON error body, got %q", rr.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestAuthMiddlewareSubject(t *testing.T) {
+	withTestSecret(t)
+
+	token := testToken(t, "ana")
+
+	var subject string
+	handler := AuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		subject, _ = subjectFromContext(r.Context())
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/todo", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if subject != "ana" {
+		t.Errorf("expected subject ana in context, got %q", subject)
+	}
+}
+
+func TestTokenRolesClaim(t *testing.T) {
+	withTestSecret(t)
+
+	token, err := newTokenWithRoles("root", time.Minute, roleAdmin, "editor")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(token, claims); err != nil {
+		t.Fatal(err)
+	}
+	if roles, ok := claims["roles"].([]any); !ok || len(roles) != 2 {
+		t.Errorf("expected a roles claim listing both roles, got %v", claims["roles"])
+	}
+
+	parsed, err := parseToken(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(parsed.Roles, []string{roleAdmin, "editor"}) {
+		t.Errorf("expected the roles claim to round-trip, got %v", parsed.Roles)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// maxBulkIDs bounds how many todos one bulk request may touch.
+const maxBulkIDs = 100
+
+type bulkPayload struct {
+	IDs []int64 `json:"ids"`
+}
+
+type bulkResult struct {
+	Completed []int64 `json:"completed"`
+	Missing   []int64 `json:"missing"`
+}
+
+// bulkComplete marks up to maxBulkIDs todos done with one store call. Ids
+// that are repeated are only reported once. It serves both POST
+// /todo/bulk/complete and POST /todo/complete.
+func (h *todoHandler) bulkComplete(w http.ResponseWriter, r *http.Request) {
+	var payload bulkPayload
+	if err := decodeJSON(r, &payload); err != nil {
+		respondDecodeError(w, r, err)
+		return
+	}
+
+	ids := uniqueIDs(payload.IDs)
+	if len(ids) == 0 {
+		respondError(w, r, http.StatusBadRequest, codeBadRequest, "ids must list at least one todo id")
+		return
+	}
+
+	if len(ids) > maxBulkIDs {
+		respondError(w, r, http.StatusRequestEntityTooLarge, codePayloadTooLarge, fmt.Sprintf("ids may list at most %d todos", maxBulkIDs))
+		return
+	}
+
+	completed, missing, _, err := h.storeFor(r).CompleteMany(r.Context(), ids)
+	if err != nil {
+		logError(r, "failed to bulk complete todos: %v", err)
+		respondError(w, r, http.StatusInternalServerError, codeInternal, "the server encountered a problem")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, bulkResult{Completed: completed, Missing: missing})
+}
+
+func uniqueIDs(ids []int64) []int64 {
+	seen := make(map[int64]bool, len(ids))
+	unique := make([]int64, 0, len(ids))
+
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+
+	return unique
+}
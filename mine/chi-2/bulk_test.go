@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestBulkComplete(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store TodoStore) {
+		for _, title := range []string{"a", "b", "c"} {
+			if err := store.Create(context.Background(), &Todo{Title: title}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		done, err := store.SetDone(context.Background(), 2, true, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		r := newTodoRouter(t, store)
+
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, newJSONRequest(http.MethodPost, "/todo/bulk/complete", `{"ids":[1,2,2,99,3]}`))
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		var result bulkResult
+		if err := json.NewDecoder(rr.Body).Decode(&result); err != nil {
+			t.Fatal(err)
+		}
+
+		if !slices.Equal(result.Completed, []int64{1, 2, 3}) || !slices.Equal(result.Missing, []int64{99}) {
+			t.Errorf("unexpected result %+v", result)
+		}
+
+		for id := int64(1); id <= 3; id++ {
+			todo, _ := store.Get(context.Background(), id)
+			if !todo.Done || todo.CompletedAt == nil {
+				t.Errorf("expected todo %d to be done, got %+v", id, todo)
+			}
+		}
+
+		// completing an already done todo keeps its original completion time
+		if todo, _ := store.Get(context.Background(), 2); !todo.CompletedAt.Equal(*done.CompletedAt) {
+			t.Errorf("expected completed_at %v to be kept, got %v", done.CompletedAt, todo.CompletedAt)
+		}
+	})
+}
+
+func TestBulkCompleteRejected(t *testing.T) {
+	r := newTodoRouter(t, newMemoryTodoStore())
+
+	ids := make([]string, maxBulkIDs+1)
+	for i := range ids {
+		ids[i] = fmt.Sprint(i + 1)
+	}
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{name: "absent ids", body: `{}`, status: http.StatusBadRequest},
+		{name: "empty ids", body: `{"ids":[]}`, status: http.StatusBadRequest},
+		{name: "not ids", body: `{"ids":["a"]}`, status: http.StatusBadRequest},
+		{name: "too many ids", body: `{"ids":[` + strings.Join(ids, ",") + `]}`, status: http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, newJSONRequest(http.MethodPost, "/todo/bulk/complete", tt.body))
+
+			if rr.Code != tt.status {
+				t.Errorf("expected status %d, got %d", tt.status, rr.Code)
+			}
+		})
+	}
+}
+
+// TestBulkCompleteConcurrent is meant for go test -race: bulk completes race
+// against single updates of the same todos.
+func TestBulkCompleteConcurrent(t *testing.T) {
+	store := newMemoryTodoStore()
+	for i := 0; i < 10; i++ {
+		if err := store.Create(context.Background(), &Todo{Title: fmt.Sprintf("todo %d", i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := newTodoRouter(t, store)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+
+		go func() {
+			defer wg.Done()
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, newJSONRequest(http.MethodPost, "/todo/bulk/complete", `{"ids":[1,2,3,4,5,6,7,8,9,10]}`))
+			if rr.Code != http.StatusOK {
+				t.Errorf("bulk complete: expected status %d, got %d", http.StatusOK, rr.Code)
+			}
+		}()
+
+		go func(id int64) {
+			defer wg.Done()
+
+			todo, err := store.Get(context.Background(), id)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			// a bulk complete may land between the read and the write
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, withIfMatch(httptest.NewRequest(http.MethodPatch, fmt.Sprintf("/todo/%d/uncomplete", id), nil), todo.Version))
+			if rr.Code != http.StatusOK && rr.Code != http.StatusConflict {
+				t.Errorf("uncomplete: expected status %d or %d, got %d", http.StatusOK, http.StatusConflict, rr.Code)
+			}
+		}(int64(i%10 + 1))
+	}
+	wg.Wait()
+
+	todos, _, err := store.List(context.Background(), ListQuery{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, todo := range todos {
+		if todo.Done != (todo.CompletedAt != nil) {
+			t.Errorf("todo %d is inconsistent: done=%v completed_at=%v", todo.ID, todo.Done, todo.CompletedAt)
+		}
+	}
+}
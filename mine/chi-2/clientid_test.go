@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const testClientID = "0b6e1c52-3f5d-4c1e-9a7e-2d8f4b6a9c10"
+
+func TestPutByClientID(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store TodoStore) {
+		r := newAPIRouter(t, &todoHandler{store: store}, nil, nil)
+		token := testToken(t, "ana")
+
+		put := func(target, body string) (*httptest.ResponseRecorder, Todo) {
+			t.Helper()
+
+			req := newJSONRequest(http.MethodPut, target, body)
+			req.Header.Set("Authorization", "Bearer "+token)
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			var todo Todo
+			if rr.Code < 300 {
+				if err := json.NewDecoder(rr.Body).Decode(&todo); err != nil {
+					t.Fatal(err)
+				}
+			}
+			return rr, todo
+		}
+
+		// a client that lost the response replays the request
+		body := `{"title":"buy oat milk","tags":["shop"]}`
+		var first Todo
+		for i, status := range []int{http.StatusCreated, http.StatusOK, http.StatusOK} {
+			rr, todo := put("/v1/todo/"+testClientID, body)
+			if rr.Code != status {
+				t.Fatalf("PUT %d: expected status %d, got %d: %s", i+1, status, rr.Code, rr.Body)
+			}
+			if i == 0 {
+				first = todo
+				if location := rr.Header().Get("Location"); location != "/v1/todo/1" {
+					t.Errorf("expected Location /v1/todo/1, got %q", location)
+				}
+				continue
+			}
+			if todo.ID != first.ID || todo.Version != first.Version {
+				t.Errorf("PUT %d: expected the todo unchanged at version %d, got id %d version %d", i+1, first.Version, todo.ID, todo.Version)
+			}
+		}
+		if first.ClientID != testClientID || first.OwnerID != "ana" {
+			t.Errorf("expected the client id and owner stored, got %+v", first)
+		}
+
+		todos, total, err := store.List(context.Background(), ListQuery{Limit: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if total != 1 || len(todos) != 1 {
+			t.Fatalf("expected exactly one todo, got %d", total)
+		}
+
+		// the client id is not case sensitive
+		rr, todo := put("/v1/todo/0B6E1C52-3F5D-4C1E-9A7E-2D8F4B6A9C10", `{"title":"buy soy milk","done":true}`)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+		if todo.ID != first.ID || todo.Title != "buy soy milk" || !todo.Done || todo.Version != first.Version+1 {
+			t.Errorf("expected todo %d updated, got %+v", first.ID, todo)
+		}
+
+		// numeric ids still go to the PUT that checks versions
+		if rr, _ := put("/v1/todo/1", `{"title":"buy rice milk"}`); rr.Code != http.StatusPreconditionRequired {
+			t.Errorf("expected status %d, got %d", http.StatusPreconditionRequired, rr.Code)
+		}
+	})
+}
+
+func TestStorePutByClientID(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store TodoStore) {
+		ctx := context.Background()
+		ana, bob := store.ForOwner("ana"), store.ForOwner("bob")
+
+		put := func(store TodoStore, title string) (Todo, PutResult, error) {
+			todo := Todo{Title: title, Priority: defaultPriority, ClientID: testClientID}
+			result, err := store.PutByClientID(ctx, &todo)
+			return todo, result, err
+		}
+
+		created, result, err := put(ana, "a")
+		if err != nil || result != PutCreated {
+			t.Fatalf("expected PutCreated, got %v %v", result, err)
+		}
+
+		// the same client id is a different todo for someone else
+		other, result, err := put(bob, "b")
+		if err != nil || result != PutCreated || other.ID == created.ID {
+			t.Fatalf("expected bob to get a todo of his own, got %+v %v %v", other, result, err)
+		}
+
+		if _, result, err := put(ana, "a"); err != nil || result != PutUnchanged {
+			t.Errorf("expected PutUnchanged, got %v %v", result, err)
+		}
+		if updated, result, err := put(ana, "a2"); err != nil || result != PutUpdated || updated.ID != created.ID {
+			t.Errorf("expected todo %d updated, got %+v %v %v", created.ID, updated, result, err)
+		}
+
+		if _, err := ana.SetArchived(ctx, created.ID, true); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := put(ana, "a3"); err != ErrTodoArchived {
+			t.Errorf("expected ErrTodoArchived, got %v", err)
+		}
+
+		// a trashed todo keeps its client id until it is purged
+		if err := bob.Delete(ctx, other.ID); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := put(bob, "b2"); err != ErrTodoNotFound {
+			t.Errorf("expected ErrTodoNotFound, got %v", err)
+		}
+	})
+}
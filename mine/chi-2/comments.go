@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// maxCommentLength is the most characters a comment body can have.
+const maxCommentLength = 2000
+
+var (
+	ErrCommentNotFound = errors.New("comment not found")
+	// ErrNotCommentAuthor is returned when someone other than the author of
+	// a comment tries to delete it.
+	ErrNotCommentAuthor = errors.New("not the author of the comment")
+)
+
+type Comment struct {
+	ID     int64 `json:"id"`
+	TodoID int64 `json:"todo_id"`
+	// AuthorID is the subject of the token the comment was posted with, or
+	// empty when the routes are mounted without AuthMiddleware.
+	AuthorID  string    `json:"author_id"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type CommentQuery struct {
+	Offset int
+	Limit  int
+}
+
+type commentPayload struct {
+	Body string `json:"body"`
+}
+
+func validateCommentBody(body string) ValidationErrors {
+	switch {
+	case body == "":
+		return ValidationErrors{{Field: "body", Message: "is required"}}
+	case utf8.RuneCountInString(body) > maxCommentLength:
+		return ValidationErrors{{Field: "body", Message: fmt.Sprintf("must be at most %d characters", maxCommentLength)}}
+	}
+
+	return nil
+}
+
+func (h *todoHandler) commentRoutes(r chi.Router) {
+	r.Method(http.MethodGet, "/", documented(routeDoc{Summary: "List the comments on a todo", Response: []Comment{}}, h.listComments))
+	r.Method(http.MethodPost, "/", documented(routeDoc{Summary: "Comment on a todo", Request: commentPayload{}, Response: Comment{}, Status: http.StatusCreated}, h.createComment))
+	r.Method(http.MethodDelete, "/{commentID}", documented(routeDoc{Summary: "Delete a comment", Status: http.StatusNoContent}, h.deleteComment))
+}
+
+// listComments pages through the comments oldest first, the way a thread
+// reads.
+func (h *todoHandler) listComments(w http.ResponseWriter, r *http.Request) {
+	todoID := TodoIDFromContext(r.Context())
+
+	page, err := parsePagination(r)
+	if err != nil {
+		respondParamErrors(w, r, err)
+		return
+	}
+
+	comments, total, err := h.storeFor(r).ListComments(r.Context(), todoID, CommentQuery{Offset: page.offset, Limit: page.limit})
+	if err != nil {
+		commentStoreError(w, r, todoID, "list", err)
+		return
+	}
+
+	page.setHeaders(w, r.URL, total)
+
+	respondJSON(w, http.StatusOK, comments)
+}
+
+func (h *todoHandler) createComment(w http.ResponseWriter, r *http.Request) {
+	todoID := TodoIDFromContext(r.Context())
+
+	var payload commentPayload
+	if err := decodeJSON(r, &payload); err != nil {
+		respondDecodeError(w, r, err)
+		return
+	}
+
+	author, _ := subjectFromContext(r.Context())
+	comment := &Comment{TodoID: todoID, AuthorID: author, Body: strings.TrimSpace(payload.Body)}
+	if errs := validateCommentBody(comment.Body); errs != nil {
+		writeValidationErrors(w, r, errs)
+		return
+	}
+
+	if err := h.storeFor(r).CreateComment(r.Context(), comment); err != nil {
+		commentStoreError(w, r, todoID, "create", err)
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/"+apiVersion+"/todo/%d/comments/%d", todoID, comment.ID))
+	respondJSON(w, http.StatusCreated, comment)
+}
+
+// deleteComment lets the author delete their comment, and admins delete any.
+func (h *todoHandler) deleteComment(w http.ResponseWriter, r *http.Request) {
+	todoID := TodoIDFromContext(r.Context())
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "commentID"), 10, 64)
+	if err != nil || id < 1 {
+		respondError(w, r, http.StatusBadRequest, codeBadRequest, "comment id must be a positive integer")
+		return
+	}
+
+	author, _ := subjectFromContext(r.Context())
+	if hasRole(r.Context(), roleAdmin) {
+		author = ""
+	}
+
+	if err := h.storeFor(r).DeleteComment(r.Context(), todoID, id, author); err != nil {
+		commentStoreError(w, r, todoID, "delete", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// commentStoreError is subtaskStoreError for comments.
+func commentStoreError(w http.ResponseWriter, r *http.Request, todoID int64, action string, err error) {
+	switch {
+	case errors.Is(err, ErrTodoNotFound):
+		respondError(w, r, http.StatusNotFound, codeNotFound, fmt.Sprintf("todo %d not found", todoID))
+	case errors.Is(err, ErrCommentNotFound):
+		respondError(w, r, http.StatusNotFound, codeNotFound, "comment not found")
+	case errors.Is(err, ErrNotCommentAuthor):
+		respondError(w, r, http.StatusForbidden, codeForbidden, "only the author or an admin can delete a comment")
+	default:
+		storeError(w, r, action+" comment on", err)
+	}
+}
+
+func (s *memoryTodoStore) CreateComment(ctx context.Context, comment *Comment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	i := s.indexOf(comment.TodoID)
+	if i < 0 {
+		return ErrTodoNotFound
+	}
+
+	comment.ID = s.nextCommentID
+	comment.CreatedAt = s.now().UTC()
+	s.nextCommentID++
+
+	s.comments[comment.TodoID] = append(s.comments[comment.TodoID], *comment)
+	s.todos[i].CommentCount = len(s.comments[comment.TodoID])
+
+	return nil
+}
+
+func (s *memoryTodoStore) ListComments(ctx context.Context, todoID int64, q CommentQuery) ([]Comment, int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	if s.indexOf(todoID) < 0 {
+		return nil, 0, ErrTodoNotFound
+	}
+
+	// comments are appended as they are created, so already oldest first
+	comments := s.comments[todoID]
+	total := len(comments)
+	offset := min(q.Offset, total)
+	end := min(offset+q.Limit, total)
+
+	page := make([]Comment, end-offset)
+	copy(page, comments[offset:end])
+
+	return page, total, nil
+}
+
+func (s *memoryTodoStore) DeleteComment(ctx context.Context, todoID, id int64, author string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	i := s.indexOf(todoID)
+	if i < 0 {
+		return ErrTodoNotFound
+	}
+
+	comments := s.comments[todoID]
+	for j := range comments {
+		if comments[j].ID != id {
+			continue
+		}
+
+		if author != "" && comments[j].AuthorID != author {
+			return ErrNotCommentAuthor
+		}
+
+		s.comments[todoID] = append(comments[:j], comments[j+1:]...)
+		s.todos[i].CommentCount = len(s.comments[todoID])
+		return nil
+	}
+
+	return ErrCommentNotFound
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestComments(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store TodoStore) {
+		r := newAPIRouter(t, &todoHandler{store: store}, nil, nil)
+
+		ana, bob, admin := testToken(t, "ana"), testToken(t, "bob"), testToken(t, "root", roleAdmin)
+
+		do := func(token, method, target, body string, out any) *httptest.ResponseRecorder {
+			t.Helper()
+
+			req := newJSONRequest(method, target, body)
+			req.Header.Set("Authorization", "Bearer "+token)
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			if out != nil {
+				if err := json.NewDecoder(rr.Body).Decode(out); err != nil {
+					t.Fatalf("%s %s: %v", method, target, err)
+				}
+			}
+
+			return rr
+		}
+
+		do(ana, http.MethodPost, "/v1/todo", `{"title":"plan the offsite"}`, nil)
+		for _, body := range []string{"first", "second", "third"} {
+			var comment Comment
+			rr := do(ana, http.MethodPost, "/v1/todo/1/comments", `{"body":"`+body+`"}`, &comment)
+			if rr.Code != http.StatusCreated {
+				t.Fatalf("expected status %d, got %d", http.StatusCreated, rr.Code)
+			}
+			if comment.AuthorID != "ana" || comment.Body != body {
+				t.Errorf("expected ana's comment %q, got %+v", body, comment)
+			}
+			if location, want := rr.Header().Get("Location"), "/v1/todo/1/comments/"+strconv.FormatInt(comment.ID, 10); location != want {
+				t.Errorf("expected Location %s, got %q", want, location)
+			}
+		}
+		// admins comment on someone else's todo through all=true
+		do(admin, http.MethodPost, "/v1/todo/1/comments?all=true", `{"body":"approved"}`, nil)
+
+		var page []Comment
+		rr := do(ana, http.MethodGet, "/v1/todo/1/comments?limit=2&offset=1", "", &page)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, rr.Code)
+		}
+		if len(page) != 2 || page[0].Body != "second" || page[1].Body != "third" {
+			t.Errorf("expected the second and third comment, got %+v", page)
+		}
+		if total := rr.Header().Get("X-Total-Count"); total != "4" {
+			t.Errorf("expected X-Total-Count 4, got %q", total)
+		}
+
+		var todos []Todo
+		do(ana, http.MethodGet, "/v1/todo", "", &todos)
+		if len(todos) != 1 || todos[0].CommentCount != 4 {
+			t.Errorf("expected a comment_count of 4, got %+v", todos)
+		}
+
+		// only the author or an admin deletes a comment
+		if rr := do(ana, http.MethodDelete, "/v1/todo/1/comments/4", "", nil); rr.Code != http.StatusForbidden {
+			t.Errorf("deleting the admin's comment: expected status %d, got %d", http.StatusForbidden, rr.Code)
+		}
+		if rr := do(bob, http.MethodDelete, "/v1/todo/1/comments/1", "", nil); rr.Code != http.StatusNotFound {
+			t.Errorf("deleting a comment on someone else's todo: expected status %d, got %d", http.StatusNotFound, rr.Code)
+		}
+		if rr := do(admin, http.MethodDelete, "/v1/todo/1/comments/1?all=true", "", nil); rr.Code != http.StatusNoContent {
+			t.Errorf("admin deleting ana's comment: expected status %d, got %d", http.StatusNoContent, rr.Code)
+		}
+		if rr := do(ana, http.MethodDelete, "/v1/todo/1/comments/2", "", nil); rr.Code != http.StatusNoContent {
+			t.Errorf("ana deleting her comment: expected status %d, got %d", http.StatusNoContent, rr.Code)
+		}
+		if rr := do(ana, http.MethodDelete, "/v1/todo/1/comments/2", "", nil); rr.Code != http.StatusNotFound {
+			t.Errorf("deleting it again: expected status %d, got %d", http.StatusNotFound, rr.Code)
+		}
+
+		var missing errorResponse
+		if rr := do(ana, http.MethodGet, "/v1/todo/99/comments", "", &missing); rr.Code != http.StatusNotFound || missing.Error != "todo 99 not found" {
+			t.Errorf("expected a 404 about the todo, got %d %q", rr.Code, missing.Error)
+		}
+
+		long := `{"body":"` + strings.Repeat("a", maxCommentLength+1) + `"}`
+		if rr := do(ana, http.MethodPost, "/v1/todo/1/comments", long, nil); rr.Code != http.StatusUnprocessableEntity {
+			t.Errorf("expected status %d for a long comment, got %d", http.StatusUnprocessableEntity, rr.Code)
+		}
+	})
+}
+
+func TestCommentsFollowTheirTodo(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store TodoStore) {
+		ctx := context.Background()
+
+		todo := Todo{Title: "plan the offsite"}
+		if err := store.Create(ctx, &todo); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.CreateComment(ctx, &Comment{TodoID: todo.ID, AuthorID: "ana", Body: "soon"}); err != nil {
+			t.Fatal(err)
+		}
+
+		// trashed, the comments are out of reach but kept for a restore
+		if err := store.Delete(ctx, todo.ID); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := store.ListComments(ctx, todo.ID, CommentQuery{Limit: 10}); !errors.Is(err, ErrTodoNotFound) {
+			t.Errorf("expected ErrTodoNotFound, got %v", err)
+		}
+		if _, err := store.Restore(ctx, todo.ID); err != nil {
+			t.Fatal(err)
+		}
+		if comments, total, err := store.ListComments(ctx, todo.ID, CommentQuery{Limit: 10}); err != nil || total != 1 || len(comments) != 1 {
+			t.Errorf("expected the comment back after the restore, got %d (%v)", total, err)
+		}
+
+		if err := store.Delete(ctx, todo.ID); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := store.PurgeTrash(ctx, time.Now().Add(time.Minute)); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := store.ListComments(ctx, todo.ID, CommentQuery{Limit: 10}); !errors.Is(err, ErrTodoNotFound) {
+			t.Errorf("expected ErrTodoNotFound after the purge, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCompleteTodo(t *testing.T) {
+	store := newMemoryTodoStore()
+	if err := store.Create(context.Background(), &Todo{Title: "write tests"}); err != nil {
+		t.Fatal(err)
+	}
+	r := newTodoRouter(t, store)
+
+	version := 1
+	patch := func(target string) (int, Todo) {
+		t.Helper()
+
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, withIfMatch(httptest.NewRequest(http.MethodPatch, target, nil), version))
+
+		var todo Todo
+		if rr.Code == http.StatusOK {
+			if err := json.NewDecoder(rr.Body).Decode(&todo); err != nil {
+				t.Fatal(err)
+			}
+			version = todo.Version
+		}
+
+		return rr.Code, todo
+	}
+
+	status, first := patch("/todo/1/complete")
+	if status != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, status)
+	}
+	if !first.Done || first.CompletedAt == nil {
+		t.Fatalf("expected a completed todo, got %+v", first)
+	}
+
+	status, second := patch("/todo/1/complete")
+	if status != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, status)
+	}
+	if second.CompletedAt == nil || !second.CompletedAt.Equal(*first.CompletedAt) {
+		t.Errorf("completing twice changed completed_at from %v to %v", first.CompletedAt, second.CompletedAt)
+	}
+
+	for i := 0; i < 2; i++ {
+		status, reopened := patch("/todo/1/uncomplete")
+		if status != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, status)
+		}
+		if reopened.Done || reopened.CompletedAt != nil {
+			t.Errorf("expected an open todo without completed_at, got %+v", reopened)
+		}
+	}
+
+	for _, target := range []string{"/todo/99/complete", "/todo/99/uncomplete"} {
+		if status, _ := patch(target); status != http.StatusNotFound {
+			t.Errorf("%s: expected status %d, got %d", target, http.StatusNotFound, status)
+		}
+	}
+}
+
+func TestListTodosDoneFilter(t *testing.T) {
+	store := newMemoryTodoStore()
+	for _, todo := range []Todo{{Title: "a", Done: true}, {Title: "b"}, {Title: "c", Done: true}} {
+		if err := store.Create(context.Background(), &todo); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r := newTodoRouter(t, store)
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{query: "", want: "abc"},
+		{query: "?done=true", want: "ac"},
+		{query: "?done=false", want: "b"},
+	}
+
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/todo"+tt.query, nil))
+
+		var todos []Todo
+		if err := json.NewDecoder(rr.Body).Decode(&todos); err != nil {
+			t.Fatal(err)
+		}
+
+		var got string
+		for _, todo := range todos {
+			got += todo.Title
+		}
+
+		if got != tt.want {
+			t.Errorf("GET /todo%s: expected %q, got %q", tt.query, tt.want, got)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/todo?done=maybe", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// newTodoRouter serves the /todo and /lists routes of store without
+// authentication, everything in it belongs to no one. newAPIRouter is the
+// one to use for owners.
+func newTodoRouter(t *testing.T, store TodoStore) http.Handler {
+	t.Helper()
+
+	h := &todoHandler{store: store}
+
+	r := chi.NewRouter()
+	r.Mount("/todo", h.routes())
+	r.Mount("/lists", h.listRoutes())
+
+	return r
+}
+
+// newJSONRequest builds a request with a JSON body, as decodeJSON expects.
+func newJSONRequest(method, target, body string) *http.Request {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+
+	return r
+}
+
+func TestTodoLifecycle(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store TodoStore) {
+		r := newTodoRouter(t, store)
+
+		do := func(method, target, body string) *httptest.ResponseRecorder {
+			t.Helper()
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, newJSONRequest(method, target, body))
+
+			if ct := rr.Header().Get("Content-Type"); rr.Code != http.StatusNoContent && ct != "application/json" {
+				t.Errorf("%s %s: expected application/json, got %q", method, target, ct)
+			}
+
+			return rr
+		}
+
+		decode := func(rr *httptest.ResponseRecorder) Todo {
+			t.Helper()
+
+			var todo Todo
+			if err := json.NewDecoder(rr.Body).Decode(&todo); err != nil {
+				t.Fatal(err)
+			}
+			return todo
+		}
+
+		rr := do(http.MethodPost, "/todo", `{"title":"buy milk"}`)
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("create: expected status %d, got %d", http.StatusCreated, rr.Code)
+		}
+		if got := rr.Header().Get("Location"); got != "/v1/todo/1" {
+			t.Errorf("expected Location /v1/todo/1, got %q", got)
+		}
+
+		created := decode(rr)
+
+		rr = do(http.MethodGet, "/todo/1", "")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("get: expected status %d, got %d", http.StatusOK, rr.Code)
+		}
+		if got := decode(rr); got.Title != "buy milk" || got.Done {
+			t.Errorf("unexpected todo %+v", got)
+		}
+
+		rr = do(http.MethodPut, "/todo/1", `{"title":"buy oat milk","done":true,"version":1}`)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("update: expected status %d, got %d", http.StatusOK, rr.Code)
+		}
+		updated := decode(rr)
+		if updated.Title != "buy oat milk" || !updated.Done || !updated.CreatedAt.Equal(created.CreatedAt) || updated.Version != created.Version+1 {
+			t.Errorf("unexpected updated todo %+v", updated)
+		}
+
+		rr = do(http.MethodGet, "/todo", "")
+		var todos []Todo
+		if err := json.NewDecoder(rr.Body).Decode(&todos); err != nil {
+			t.Fatal(err)
+		}
+		if len(todos) != 1 || todos[0].Title != "buy oat milk" {
+			t.Errorf("unexpected list %+v", todos)
+		}
+
+		if rr = do(http.MethodDelete, "/todo/1", ""); rr.Code != http.StatusNoContent {
+			t.Fatalf("delete: expected status %d, got %d", http.StatusNoContent, rr.Code)
+		}
+
+		for _, method := range []string{http.MethodGet, http.MethodDelete} {
+			if rr = do(method, "/todo/1", ""); rr.Code != http.StatusNotFound {
+				t.Errorf("%s after delete: expected status %d, got %d", method, http.StatusNotFound, rr.Code)
+			}
+		}
+
+		if rr = do(http.MethodPut, "/todo/1", `{"title":"ghost","version":2}`); rr.Code != http.StatusNotFound {
+			t.Errorf("update after delete: expected status %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+}
+
+func TestTodoErrors(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store TodoStore) {
+		r := newTodoRouter(t, store)
+
+		tests := []struct {
+			name   string
+			method string
+			target string
+			body   string
+			status int
+		}{
+			{name: "non numeric id", method: http.MethodGet, target: "/todo/abc", status: http.StatusBadRequest},
+			{name: "negative id", method: http.MethodDelete, target: "/todo/-1", status: http.StatusBadRequest},
+			{name: "malformed update", method: http.MethodPut, target: "/todo/1", body: `{`, status: http.StatusBadRequest},
+			{name: "empty title on update", method: http.MethodPut, target: "/todo/1", body: `{"title":" "}`, status: http.StatusUnprocessableEntity},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				rr := httptest.NewRecorder()
+				r.ServeHTTP(rr, newJSONRequest(tt.method, tt.target, tt.body))
+
+				if rr.Code != tt.status {
+					t.Fatalf("expected status %d, got %d", tt.status, rr.Code)
+				}
+
+				var body map[string]any
+				if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+					t.Errorf("expected a JSON body: %v", err)
+				}
+			})
+		}
+	})
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	// defaultDrainDelay is how long the server keeps serving after a drain
+	// starts, long enough for a load balancer to notice the failing
+	// /healthz and stop sending traffic.
+	defaultDrainDelay      = 10 * time.Second
+	defaultShutdownTimeout = 30 * time.Second
+)
+
+// drainer takes the server out of rotation ahead of a shutdown. Once drain
+// is called /healthz reports not ready, while the server keeps serving
+// whatever still reaches it; main shuts down after the drain delay.
+type drainer struct {
+	draining atomic.Bool
+	once     sync.Once
+	// started is closed by the first drain, main waits on it next to the
+	// signals.
+	started chan struct{}
+}
+
+func newDrainer() *drainer {
+	return &drainer{started: make(chan struct{})}
+}
+
+// drain is idempotent, a second call changes nothing.
+func (d *drainer) drain() {
+	d.once.Do(func() {
+		d.draining.Store(true)
+		close(d.started)
+	})
+}
+
+// mount serves POST /internal/drain, for admins only.
+func (d *drainer) mount(r chi.Router) {
+	r.With(AuthMiddleware, RequireRole(roleAdmin)).Method(http.MethodPost, "/internal/drain", documented(routeDoc{Summary: "Take the server out of rotation", Response: healthResponse{}, Status: http.StatusAccepted}, d.drainHandler))
+}
+
+func (d *drainer) drainHandler(w http.ResponseWriter, r *http.Request) {
+	d.drain()
+
+	respondJSON(w, http.StatusAccepted, healthResponse{Status: "draining"})
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestDrain(t *testing.T) {
+	withTestSecret(t)
+
+	d := newDrainer()
+	r := chi.NewRouter()
+	useJSONRouteErrors(r)
+	d.mount(r)
+	newHealthChecks(d, defaultHealthCheckTimeout).mount(r)
+
+	health := func() (int, string) {
+		t.Helper()
+
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+		var body healthResponse
+		if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		return rr.Code, body.Status
+	}
+
+	drain := func(token string) int {
+		t.Helper()
+
+		req := httptest.NewRequest(http.MethodPost, "/internal/drain", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	if code, status := health(); code != http.StatusOK || status != "ok" {
+		t.Fatalf("expected a ready server, got %d %q", code, status)
+	}
+
+	if code := drain(""); code != http.StatusUnauthorized {
+		t.Errorf("without a token: expected status %d, got %d", http.StatusUnauthorized, code)
+	}
+	if code := drain(testToken(t, "ana")); code != http.StatusForbidden {
+		t.Errorf("as a user: expected status %d, got %d", http.StatusForbidden, code)
+	}
+	if code, _ := health(); code != http.StatusOK {
+		t.Fatalf("expected refused drains to keep the server ready, got %d", code)
+	}
+
+	if code := drain(testToken(t, "root", roleAdmin)); code != http.StatusAccepted {
+		t.Fatalf("as an admin: expected status %d, got %d", http.StatusAccepted, code)
+	}
+
+	if code, status := health(); code != http.StatusServiceUnavailable || status != "draining" {
+		t.Errorf("expected a draining server, got %d %q", code, status)
+	}
+
+	select {
+	case <-d.started:
+	default:
+		t.Error("expected main to be told the drain started")
+	}
+
+	// repeating it is harmless
+	if code := drain(testToken(t, "root", roleAdmin)); code != http.StatusAccepted {
+		t.Errorf("second drain: expected status %d, got %d", http.StatusAccepted, code)
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Event types sent on GET /todo/events.
+const (
+	eventCreated   = "created"
+	eventUpdated   = "updated"
+	eventDeleted   = "deleted"
+	eventCompleted = "completed"
+)
+
+const (
+	// eventHistory is how many past events are kept for Last-Event-ID replay.
+	eventHistory = 256
+	// subscriberBuffer is how far a subscriber may fall behind before it is
+	// dropped. Its EventSource reconnects and catches up from the history.
+	subscriberBuffer = 16
+)
+
+// eventKeepAlive is how often an idle stream gets a comment line, which keeps
+// proxies from closing the connection.
+var eventKeepAlive = 15 * time.Second
+
+type todoEvent struct {
+	ID   uint64 `json:"id"`
+	Type string `json:"type"`
+	Todo Todo   `json:"todo"`
+}
+
+// todoEvents is the subscriber registry notifyingStore publishes to.
+type todoEvents struct {
+	mu     sync.Mutex
+	nextID uint64
+	// history holds the last eventHistory events, oldest first.
+	history     []todoEvent
+	subscribers map[chan todoEvent]struct{}
+}
+
+func newTodoEvents() *todoEvents {
+	return &todoEvents{subscribers: make(map[chan todoEvent]struct{})}
+}
+
+// visibleTo reports whether a subscriber scoped to owner may see the event,
+// an empty owner sees everything.
+func (event todoEvent) visibleTo(owner string) bool {
+	return owner == "" || event.Todo.OwnerID == owner
+}
+
+func (e *todoEvents) publish(eventType string, todo Todo) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.nextID++
+	event := todoEvent{ID: e.nextID, Type: eventType, Todo: todo}
+
+	if len(e.history) == eventHistory {
+		copy(e.history, e.history[1:])
+		e.history = e.history[:eventHistory-1]
+	}
+	e.history = append(e.history, event)
+
+	for events := range e.subscribers {
+		select {
+		case events <- event:
+		default:
+			delete(e.subscribers, events)
+			close(events)
+		}
+	}
+}
+
+// subscribe registers a new subscriber. With replay set it also returns the
+// kept events after lastID, taken under the same lock so none fall in
+// between.
+func (e *todoEvents) subscribe(lastID uint64, replay bool) (chan todoEvent, []todoEvent) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	events := make(chan todoEvent, subscriberBuffer)
+	e.subscribers[events] = struct{}{}
+
+	var missed []todoEvent
+	if replay {
+		for _, event := range e.history {
+			if event.ID > lastID {
+				missed = append(missed, event)
+			}
+		}
+	}
+
+	return events, missed
+}
+
+func (e *todoEvents) unsubscribe(events chan todoEvent) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	// publish may already have dropped a subscriber that fell behind
+	if _, ok := e.subscribers[events]; ok {
+		delete(e.subscribers, events)
+		close(events)
+	}
+}
+
+func (e *todoEvents) subscriberCount() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return len(e.subscribers)
+}
+
+// streamEvents serves GET /todo/events as server-sent events. A reconnecting
+// client sends Last-Event-ID and gets the events it missed first, as long as
+// they are still in the history.
+func (h *todoHandler) streamEvents(w http.ResponseWriter, r *http.Request) {
+	if h.events == nil {
+		respondError(w, r, http.StatusNotFound, codeNotFound, "the event stream is not enabled")
+		return
+	}
+
+	var lastID uint64
+	value := r.Header.Get("Last-Event-ID")
+	if value != "" {
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			respondError(w, r, http.StatusBadRequest, codeBadRequest, "Last-Event-ID must be an event id")
+			return
+		}
+		lastID = id
+	}
+
+	events, missed := h.events.subscribe(lastID, value != "")
+	defer h.events.unsubscribe(events)
+
+	owner := ownerFromContext(r.Context())
+	controller := http.NewResponseController(w)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprint(w, ": connected\n\n")
+	for _, event := range missed {
+		writeTodoEvent(w, r, owner, event)
+	}
+	if err := controller.Flush(); err != nil {
+		return
+	}
+
+	keepAlive := time.NewTicker(eventKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+
+			writeTodoEvent(w, r, owner, event)
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		}
+
+		if err := controller.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// writeTodoEvent writes event unless its todo belongs to someone other than
+// owner.
+func writeTodoEvent(w http.ResponseWriter, r *http.Request, owner string, event todoEvent) {
+	if !event.visibleTo(owner) {
+		return
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		logError(r, "failed to encode %s event: %v", event.Type, err)
+		return
+	}
+
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+}
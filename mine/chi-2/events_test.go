@@ -0,0 +1,281 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// startEventServer serves the /todo routes with the event stream enabled.
+func startEventServer(t *testing.T) (*httptest.Server, *todoHandler) {
+	t.Helper()
+
+	events := newTodoEvents()
+	h := &todoHandler{store: &notifyingStore{TodoStore: newMemoryTodoStore(), events: events}, events: events}
+
+	r := chi.NewRouter()
+	r.Mount("/todo", h.routes())
+
+	server := httptest.NewServer(r)
+	t.Cleanup(server.Close)
+
+	return server, h
+}
+
+// eventStream reads the stream of an open GET /todo/events line by line.
+type eventStream struct {
+	t     *testing.T
+	lines chan string
+}
+
+func openEventStream(t *testing.T, ctx context.Context, url string, header http.Header) *eventStream {
+	t.Helper()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { res.Body.Close() })
+
+	if ct := res.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("expected text/event-stream, got %q", ct)
+	}
+
+	stream := &eventStream{t: t, lines: make(chan string)}
+	go func() {
+		scanner := bufio.NewScanner(res.Body)
+		for scanner.Scan() {
+			stream.lines <- scanner.Text()
+		}
+		close(stream.lines)
+	}()
+
+	// the subscription is live once the connected comment arrives
+	if line := stream.line(); line != ": connected" {
+		t.Fatalf("expected the connected comment, got %q", line)
+	}
+	stream.line()
+
+	return stream
+}
+
+func (s *eventStream) line() string {
+	s.t.Helper()
+
+	select {
+	case line, ok := <-s.lines:
+		if !ok {
+			s.t.Fatal("stream closed")
+		}
+		return line
+	case <-time.After(2 * time.Second):
+		s.t.Fatal("timed out waiting for the stream")
+	}
+	return ""
+}
+
+// next reads one event, skipping keep-alive comments.
+func (s *eventStream) next() todoEvent {
+	s.t.Helper()
+
+	line := s.line()
+	for strings.HasPrefix(line, ":") || line == "" {
+		line = s.line()
+	}
+
+	if !strings.HasPrefix(line, "id: ") {
+		s.t.Fatalf("expected an id line, got %q", line)
+	}
+	eventType, ok := strings.CutPrefix(s.line(), "event: ")
+	if !ok {
+		s.t.Fatal("expected an event line")
+	}
+	data, ok := strings.CutPrefix(s.line(), "data: ")
+	if !ok {
+		s.t.Fatal("expected a data line")
+	}
+
+	var event todoEvent
+	if err := json.Unmarshal([]byte(data), &event); err != nil {
+		s.t.Fatal(err)
+	}
+	if event.Type != eventType {
+		s.t.Errorf("event line says %q, data says %q", eventType, event.Type)
+	}
+
+	return event
+}
+
+func TestTodoEvents(t *testing.T) {
+	server, _ := startEventServer(t)
+	stream := openEventStream(t, context.Background(), server.URL+"/todo/events", nil)
+
+	do := func(method, path, body string) {
+		t.Helper()
+
+		req := newJSONRequest(method, server.URL+path, body)
+		req.RequestURI = ""
+
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+	}
+
+	do(http.MethodPost, "/todo", `{"title":"buy milk"}`)
+	do(http.MethodPut, "/todo/1", `{"title":"buy oat milk","version":1}`)
+	do(http.MethodPatch, "/todo/1/complete", `{"version":2}`)
+	do(http.MethodDelete, "/todo/1", "")
+
+	for i, want := range []struct {
+		typ   string
+		title string
+		done  bool
+	}{
+		{eventCreated, "buy milk", false},
+		{eventUpdated, "buy oat milk", false},
+		{eventCompleted, "buy oat milk", true},
+		{eventDeleted, "buy oat milk", true},
+	} {
+		event := stream.next()
+		if event.ID != uint64(i+1) || event.Type != want.typ || event.Todo.Title != want.title || event.Todo.Done != want.done {
+			t.Errorf("event %d: expected %s of %q, got %+v", i+1, want.typ, want.title, event)
+		}
+	}
+}
+
+func TestTodoEventsRecurring(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store TodoStore) {
+		ctx := context.Background()
+		events := newTodoEvents()
+		store = &notifyingStore{TodoStore: store, events: events}
+
+		due := time.Date(2024, 1, 31, 9, 0, 0, 0, time.UTC)
+		recurring := func(title string) Todo {
+			t.Helper()
+
+			todo := Todo{Title: title, Priority: defaultPriority, DueDate: &due, Recurrence: &Recurrence{Frequency: FrequencyMonthly, Interval: 1}}
+			if err := store.Create(ctx, &todo); err != nil {
+				t.Fatal(err)
+			}
+			return todo
+		}
+		plants, bins := recurring("water the plants"), recurring("take out the bins")
+
+		if _, err := store.SetDone(ctx, plants.ID, true, plants.Version); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, _, err := store.CompleteMany(ctx, []int64{bins.ID}); err != nil {
+			t.Fatal(err)
+		}
+
+		_, published := events.subscribe(0, true)
+		got := []string{}
+		for _, event := range published {
+			got = append(got, event.Type+" "+event.Todo.Title)
+			if event.Type == eventCreated && event.Todo.ID == 0 {
+				t.Errorf("expected the created event to carry the stored todo, got %+v", event.Todo)
+			}
+		}
+
+		want := []string{
+			"created water the plants",
+			"created take out the bins",
+			"completed water the plants",
+			"created water the plants",
+			"completed take out the bins",
+			"created take out the bins",
+		}
+		if strings.Join(got, ", ") != strings.Join(want, ", ") {
+			t.Errorf("expected events %v, got %v", want, got)
+		}
+	})
+}
+
+func TestTodoEventsReplay(t *testing.T) {
+	server, h := startEventServer(t)
+	events := h.events
+
+	for _, title := range []string{"a", "b", "c"} {
+		events.publish(eventCreated, Todo{Title: title, Priority: defaultPriority})
+	}
+
+	stream := openEventStream(t, context.Background(), server.URL+"/todo/events", http.Header{"Last-Event-Id": {"1"}})
+	for _, want := range []string{"b", "c"} {
+		if event := stream.next(); event.Todo.Title != want {
+			t.Errorf("expected the missed event for %q, got %+v", want, event)
+		}
+	}
+
+	events.publish(eventCreated, Todo{Title: "d", Priority: defaultPriority})
+	if event := stream.next(); event.ID != 4 {
+		t.Errorf("expected the live event 4 after the replay, got %+v", event)
+	}
+}
+
+func TestTodoEventsKeepAliveAndCleanup(t *testing.T) {
+	original := eventKeepAlive
+	eventKeepAlive = 10 * time.Millisecond
+	t.Cleanup(func() { eventKeepAlive = original })
+
+	server, h := startEventServer(t)
+	events := h.events
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := openEventStream(t, ctx, server.URL+"/todo/events", nil)
+
+	if line := stream.line(); line != ": keep-alive" {
+		t.Errorf("expected a keep-alive comment, got %q", line)
+	}
+	if got := events.subscriberCount(); got != 1 {
+		t.Fatalf("expected 1 subscriber, got %d", got)
+	}
+
+	cancel()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for events.subscriberCount() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("the subscription outlived the client")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestTodoEventsScopedToOwner(t *testing.T) {
+	events := newTodoEvents()
+	h := &todoHandler{store: &notifyingStore{TodoStore: newMemoryTodoStore(), events: events}, events: events}
+
+	server := httptest.NewServer(newAPIRouter(t, h, nil, nil))
+	t.Cleanup(server.Close)
+
+	ctx := context.Background()
+	stream := openEventStream(t, ctx, server.URL+"/v1/todo/events", http.Header{"Authorization": {"Bearer " + testToken(t, "bob")}})
+
+	if err := h.store.ForOwner("ana").Create(ctx, &Todo{Title: "ana's"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.store.ForOwner("bob").Create(ctx, &Todo{Title: "bob's"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if event := stream.next(); event.Todo.Title != "bob's" {
+		t.Errorf("bob should only see his own todos, got %+v", event)
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// defaultHealthCheckTimeout bounds every check on its own, a hung database
+// ping can't hold up /healthz.
+const defaultHealthCheckTimeout = 2 * time.Second
+
+// healthCheck returns nil when the dependency it looks at is usable.
+type healthCheck func(ctx context.Context) error
+
+// healthChecks serves GET /healthz, which runs every registered check in
+// parallel: 200 when they all pass, 503 listing the failing ones otherwise.
+// A draining server answers 503 without running them, the load balancer
+// should stop sending traffic either way.
+//
+// Checks are registered by main before the server starts and never after,
+// so the list needs no lock.
+type healthChecks struct {
+	timeout time.Duration
+	drain   *drainer
+	names   []string
+	checks  map[string]healthCheck
+}
+
+// errCheckTimedOut is reported for a check that didn't finish in time.
+var errCheckTimedOut = errors.New("timed out")
+
+type healthResponse struct {
+	// Status is ok, failing or draining.
+	Status string `json:"status"`
+	// Checks holds ok, failing or timed out for every check by name. The
+	// errors themselves are only logged, /healthz is open to anyone and they
+	// can name hosts and paths.
+	Checks  map[string]string `json:"checks,omitempty"`
+	Failing []string          `json:"failing,omitempty"`
+}
+
+func newHealthChecks(drain *drainer, timeout time.Duration) *healthChecks {
+	return &healthChecks{timeout: timeout, drain: drain, checks: make(map[string]healthCheck)}
+}
+
+func (h *healthChecks) register(name string, check healthCheck) {
+	if _, ok := h.checks[name]; !ok {
+		h.names = append(h.names, name)
+	}
+	h.checks[name] = check
+}
+
+// mount serves GET /healthz outside of the auth group, the load balancer has
+// no token.
+func (h *healthChecks) mount(r chi.Router) {
+	r.Method(http.MethodGet, "/healthz", documented(routeDoc{Summary: "Check the server and its dependencies", Response: healthResponse{}}, h.serveHealth))
+}
+
+func (h *healthChecks) serveHealth(w http.ResponseWriter, r *http.Request) {
+	if h.drain != nil && h.drain.draining.Load() {
+		respondJSON(w, http.StatusServiceUnavailable, healthResponse{Status: "draining"})
+		return
+	}
+
+	response := healthResponse{Status: "ok", Checks: h.run(r.Context())}
+	for _, name := range h.names {
+		if response.Checks[name] != "ok" {
+			response.Failing = append(response.Failing, name)
+		}
+	}
+
+	if len(response.Failing) > 0 {
+		response.Status = "failing"
+		respondJSON(w, http.StatusServiceUnavailable, response)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+func (h *healthChecks) run(ctx context.Context) map[string]string {
+	type result struct {
+		name string
+		err  error
+	}
+
+	// buffered, a check that outlives its timeout can still finish and go
+	results := make(chan result, len(h.names))
+	for _, name := range h.names {
+		go func() {
+			results <- result{name: name, err: h.runOne(ctx, h.checks[name])}
+		}()
+	}
+
+	statuses := make(map[string]string, len(h.names))
+	for range h.names {
+		res := <-results
+		switch {
+		case res.err == nil:
+			statuses[res.name] = "ok"
+		case errors.Is(res.err, errCheckTimedOut):
+			statuses[res.name] = res.err.Error()
+		default:
+			log.Printf("health check %s failed: %v", res.name, res.err)
+			statuses[res.name] = "failing"
+		}
+	}
+
+	return statuses
+}
+
+// runOne gives up on check after h.timeout even when it ignores its context.
+func (h *healthChecks) runOne(ctx context.Context, check healthCheck) error {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- check(ctx) }()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return errCheckTimedOut
+	}
+}
+
+// dirWritable checks that a file can be created in dir, where the SQLite
+// database and its journal live.
+func dirWritable(dir string) healthCheck {
+	return func(ctx context.Context) error {
+		f, err := os.CreateTemp(dir, ".healthz-*")
+		if err != nil {
+			return err
+		}
+
+		name := f.Name()
+		return errors.Join(f.Close(), os.Remove(name))
+	}
+}
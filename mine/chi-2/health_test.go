@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func getHealth(t *testing.T, h *healthChecks) (int, healthResponse) {
+	t.Helper()
+
+	r := chi.NewRouter()
+	h.mount(r)
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	var body healthResponse
+	if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	return rr.Code, body
+}
+
+func TestHealthChecks(t *testing.T) {
+	pass := func(ctx context.Context) error { return nil }
+
+	t.Run("all pass", func(t *testing.T) {
+		h := newHealthChecks(nil, time.Second)
+		h.register("store", pass)
+		h.register("webhooks", pass)
+
+		code, body := getHealth(t, h)
+		if code != http.StatusOK || body.Status != "ok" {
+			t.Fatalf("expected 200 ok, got %d %q", code, body.Status)
+		}
+		if body.Checks["store"] != "ok" || body.Checks["webhooks"] != "ok" {
+			t.Errorf("expected both checks ok, got %v", body.Checks)
+		}
+		if len(body.Failing) != 0 {
+			t.Errorf("expected nothing failing, got %v", body.Failing)
+		}
+	})
+
+	t.Run("failing and hung checks", func(t *testing.T) {
+		hung := make(chan struct{})
+		t.Cleanup(func() { close(hung) })
+
+		h := newHealthChecks(nil, 50*time.Millisecond)
+		h.register("store", pass)
+		h.register("disk", func(ctx context.Context) error { return errors.New("read-only file system") })
+		// ignores its context, runOne must give up on it anyway
+		h.register("webhooks", func(ctx context.Context) error {
+			<-hung
+			return nil
+		})
+
+		start := time.Now()
+		code, body := getHealth(t, h)
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("expected the hung check cut off after its timeout, took %s", elapsed)
+		}
+
+		if code != http.StatusServiceUnavailable || body.Status != "failing" {
+			t.Fatalf("expected 503 failing, got %d %q", code, body.Status)
+		}
+
+		want := map[string]string{"store": "ok", "disk": "failing", "webhooks": "timed out"}
+		for name, status := range want {
+			if body.Checks[name] != status {
+				t.Errorf("expected %s %q, got %q", name, status, body.Checks[name])
+			}
+		}
+		if !slices.Equal(body.Failing, []string{"disk", "webhooks"}) {
+			t.Errorf("expected disk and webhooks failing in registration order, got %v", body.Failing)
+		}
+	})
+
+	t.Run("draining", func(t *testing.T) {
+		d := newDrainer()
+		d.drain()
+
+		h := newHealthChecks(d, time.Second)
+		h.register("store", func(ctx context.Context) error {
+			t.Error("expected no checks run while draining")
+			return nil
+		})
+
+		if code, body := getHealth(t, h); code != http.StatusServiceUnavailable || body.Status != "draining" {
+			t.Errorf("expected 503 draining, got %d %q", code, body.Status)
+		}
+	})
+}
+
+func TestDirWritable(t *testing.T) {
+	dir := t.TempDir()
+	if err := dirWritable(dir)(context.Background()); err != nil {
+		t.Fatalf("expected %s writable, got %v", dir, err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("expected the probe file removed, found %d entries", len(entries))
+	}
+
+	if err := dirWritable(filepath.Join(dir, "missing"))(context.Background()); err == nil {
+		t.Error("expected a missing directory to fail")
+	}
+}
+
+func TestWebhookQueueCheck(t *testing.T) {
+	d := newWebhookDispatcher(newTodoEvents(), newWebhookRegistry(), 1, false)
+
+	if err := d.checkQueue(context.Background()); err != nil {
+		t.Fatalf("expected an empty queue to pass, got %v", err)
+	}
+
+	d.pending.Add(maxWebhookQueueDepth + 1)
+	if err := d.checkQueue(context.Background()); err == nil {
+		t.Error("expected a full queue to fail")
+	}
+}
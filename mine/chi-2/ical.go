@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	icalProductID = "-//yowger//chi-2//EN"
+	// icalUIDDomain makes the UIDs globally unique as RFC 5545 asks, a todo
+	// keeps its UID across exports so calendars update it in place.
+	icalUIDDomain  = "chi-2.todo"
+	icalTimeFormat = "20060102T150405Z"
+	// icalLineOctets is the longest a content line may be before folding,
+	// without its CRLF.
+	icalLineOctets = 75
+)
+
+// exportICal serves the caller's todos that have a due date as VTODOs, so a
+// calendar can subscribe to them. ?tag= narrows it like it does the list.
+//
+// Recurring todos are exported without an RRULE: the next occurrence becomes
+// a todo of its own once this one is done, and it is exported then.
+func (h *todoHandler) exportICal(w http.ResponseWriter, r *http.Request) {
+	query := ListQuery{Limit: pageSizes.Max, Tags: tagsFromQuery(r.URL.Query()), Sort: sortDue, Order: orderAsc}
+
+	var todos []Todo
+	for {
+		page, total, err := h.storeFor(r).List(r.Context(), query)
+		if err != nil {
+			logError(r, "failed to export todos: %v", err)
+			respondError(w, r, http.StatusInternalServerError, codeInternal, "the server encountered a problem")
+			return
+		}
+
+		for _, todo := range page {
+			if todo.DueDate != nil {
+				todos = append(todos, todo)
+			}
+		}
+
+		query.Offset += len(page)
+		if len(page) == 0 || query.Offset >= total {
+			break
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="todos.ics"`)
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(renderICal(todos, h.clock().UTC())))
+}
+
+// renderICal builds a VCALENDAR holding one VTODO per todo, stamped with now.
+// Every todo must have a due date.
+func renderICal(todos []Todo, now time.Time) string {
+	var b strings.Builder
+	line := func(name, value string) {
+		b.WriteString(foldICalLine(name + ":" + value))
+		b.WriteString("\r\n")
+	}
+
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", icalProductID)
+	line("CALSCALE", "GREGORIAN")
+
+	for _, todo := range todos {
+		line("BEGIN", "VTODO")
+		line("UID", fmt.Sprintf("todo-%d@%s", todo.ID, icalUIDDomain))
+		line("DTSTAMP", now.Format(icalTimeFormat))
+		line("CREATED", todo.CreatedAt.UTC().Format(icalTimeFormat))
+		line("SUMMARY", escapeICalText(todo.Title))
+		line("DUE", todo.DueDate.UTC().Format(icalTimeFormat))
+		line("STATUS", icalStatus(todo))
+		if todo.CompletedAt != nil {
+			line("COMPLETED", todo.CompletedAt.UTC().Format(icalTimeFormat))
+		}
+		line("PRIORITY", icalPriority(todo.Priority))
+		if len(todo.Tags) > 0 {
+			categories := make([]string, len(todo.Tags))
+			for i, tag := range todo.Tags {
+				categories[i] = escapeICalText(tag)
+			}
+			line("CATEGORIES", strings.Join(categories, ","))
+		}
+		line("END", "VTODO")
+	}
+
+	line("END", "VCALENDAR")
+
+	return b.String()
+}
+
+// icalStatus maps a todo to a VTODO STATUS. Archiving wins over done, an
+// archived todo is off the list either way.
+func icalStatus(todo Todo) string {
+	switch {
+	case todo.Archived:
+		return "CANCELLED"
+	case todo.Done:
+		return "COMPLETED"
+	default:
+		return "NEEDS-ACTION"
+	}
+}
+
+// icalPriority maps onto the 1 (highest) to 9 (lowest) scale, using the
+// values RFC 5545 gives for high, medium and low.
+func icalPriority(priority Priority) string {
+	switch priority {
+	case PriorityHigh:
+		return "1"
+	case PriorityLow:
+		return "9"
+	default:
+		return "5"
+	}
+}
+
+var icalTextEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	";", `\;`,
+	",", `\,`,
+	"\r\n", `\n`,
+	"\n", `\n`,
+	"\r", `\n`,
+)
+
+// escapeICalText escapes a TEXT value as RFC 5545 section 3.3.11 describes.
+func escapeICalText(text string) string {
+	return icalTextEscaper.Replace(text)
+}
+
+// foldICalLine splits a content line into lines of at most icalLineOctets
+// octets, each continuation starting with a space. It never cuts a UTF-8
+// sequence in half.
+func foldICalLine(line string) string {
+	if len(line) <= icalLineOctets {
+		return line
+	}
+
+	var b strings.Builder
+	limit := icalLineOctets
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		// the leading space counts towards the next line
+		limit = icalLineOctets - 1
+	}
+	b.WriteString(line)
+
+	return b.String()
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// icalComponent is a parsed BEGIN/END block, properties keyed by name with
+// TEXT values unescaped.
+type icalComponent struct {
+	name       string
+	props      map[string]string
+	components []*icalComponent
+}
+
+// parseICal checks the framing of an iCalendar document, that every line
+// ends in CRLF and fits in 75 octets, then unfolds and parses it.
+func parseICal(t *testing.T, doc string) *icalComponent {
+	t.Helper()
+
+	if !strings.HasSuffix(doc, "\r\n") {
+		t.Fatal("expected the document to end with CRLF")
+	}
+
+	physical := strings.Split(strings.TrimSuffix(doc, "\r\n"), "\r\n")
+	for _, line := range physical {
+		if len(line) > icalLineOctets {
+			t.Errorf("line of %d octets: %q", len(line), line)
+		}
+		if strings.ContainsAny(line, "\r\n") {
+			t.Errorf("bare CR or LF in %q", line)
+		}
+		if !utf8.ValidString(line) {
+			t.Errorf("folding split a character in %q", line)
+		}
+	}
+
+	var logical []string
+	for _, line := range physical {
+		if strings.HasPrefix(line, " ") && len(logical) > 0 {
+			logical[len(logical)-1] += line[1:]
+			continue
+		}
+		logical = append(logical, line)
+	}
+
+	var root *icalComponent
+	var stack []*icalComponent
+	for _, line := range logical {
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			t.Fatalf("expected NAME:value, got %q", line)
+		}
+
+		switch name {
+		case "BEGIN":
+			component := &icalComponent{name: value, props: map[string]string{}}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.components = append(parent.components, component)
+			} else if root == nil {
+				root = component
+			} else {
+				t.Fatalf("second top level component %s", value)
+			}
+			stack = append(stack, component)
+		case "END":
+			if len(stack) == 0 || stack[len(stack)-1].name != value {
+				t.Fatalf("unbalanced END:%s", value)
+			}
+			stack = stack[:len(stack)-1]
+		default:
+			if len(stack) == 0 {
+				t.Fatalf("property %s outside a component", name)
+			}
+			stack[len(stack)-1].props[name] = unescapeICalText(value)
+		}
+	}
+
+	if root == nil || len(stack) != 0 {
+		t.Fatal("expected one complete VCALENDAR")
+	}
+
+	return root
+}
+
+func unescapeICalText(value string) string {
+	return strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n").Replace(value)
+}
+
+func exportTodos(t *testing.T, r http.Handler, target string) *icalComponent {
+	t.Helper()
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, target, nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "text/calendar; charset=utf-8" {
+		t.Errorf("unexpected Content-Type %q", ct)
+	}
+
+	calendar := parseICal(t, rr.Body.String())
+	if calendar.name != "VCALENDAR" || calendar.props["VERSION"] != "2.0" || calendar.props["PRODID"] == "" {
+		t.Errorf("unexpected calendar %+v", calendar)
+	}
+
+	return calendar
+}
+
+func TestExportICal(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store TodoStore) {
+		r := newTodoRouter(t, store)
+
+		do := func(method, target, body string) Todo {
+			t.Helper()
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, newJSONRequest(method, target, body))
+			if rr.Code >= 300 {
+				t.Fatalf("%s %s: status %d: %s", method, target, rr.Code, rr.Body)
+			}
+
+			var todo Todo
+			if rr.Code != http.StatusNoContent {
+				if err := json.NewDecoder(rr.Body).Decode(&todo); err != nil {
+					t.Fatal(err)
+				}
+			}
+			return todo
+		}
+
+		longTitle := "Plan the offsite; book rooms, order lunch\nand send the agenda to everyone — ünïcödé included " + strings.Repeat("é", 40)
+
+		open := do(http.MethodPost, "/todo", fmt.Sprintf(`{"title":%q,"due_date":"2025-03-10T09:30:00+02:00","priority":"high","tags":["work","q1"]}`, longTitle))
+		done := do(http.MethodPost, "/todo", `{"title":"File taxes","due_date":"2025-04-15T00:00:00Z","tags":["home"]}`)
+		do(http.MethodPatch, fmt.Sprintf("/todo/%d/complete", done.ID), fmt.Sprintf(`{"version":%d}`, done.Version))
+		archived := do(http.MethodPost, "/todo", `{"title":"Old plan","due_date":"2025-01-01T00:00:00Z","priority":"low","tags":["work"]}`)
+		do(http.MethodPost, fmt.Sprintf("/todo/%d/archive", archived.ID), "")
+		do(http.MethodPost, "/todo", `{"title":"Someday","tags":["work"]}`)
+
+		calendar := exportTodos(t, r, "/todo/export.ics")
+		if len(calendar.components) != 3 {
+			t.Fatalf("expected the 3 todos with a due date, got %d", len(calendar.components))
+		}
+
+		byUID := map[string]*icalComponent{}
+		for _, component := range calendar.components {
+			if component.name != "VTODO" {
+				t.Errorf("expected a VTODO, got %s", component.name)
+			}
+			if component.props["DTSTAMP"] == "" {
+				t.Errorf("expected a DTSTAMP in %+v", component.props)
+			}
+			byUID[component.props["UID"]] = component
+		}
+
+		tests := []struct {
+			id                             int64
+			summary, due, status, priority string
+			categories                     string
+		}{
+			{open.ID, longTitle, "20250310T073000Z", "NEEDS-ACTION", "1", "work,q1"},
+			{done.ID, "File taxes", "20250415T000000Z", "COMPLETED", "5", "home"},
+			{archived.ID, "Old plan", "20250101T000000Z", "CANCELLED", "9", "work"},
+		}
+
+		for _, tt := range tests {
+			uid := fmt.Sprintf("todo-%d@%s", tt.id, icalUIDDomain)
+			component, ok := byUID[uid]
+			if !ok {
+				t.Errorf("expected a VTODO with UID %s", uid)
+				continue
+			}
+
+			props := component.props
+			if props["SUMMARY"] != tt.summary {
+				t.Errorf("%s: expected SUMMARY %q, got %q", uid, tt.summary, props["SUMMARY"])
+			}
+			if props["DUE"] != tt.due || props["STATUS"] != tt.status || props["PRIORITY"] != tt.priority || props["CATEGORIES"] != tt.categories {
+				t.Errorf("%s: unexpected properties %+v", uid, props)
+			}
+			if _, completed := props["COMPLETED"]; completed != (tt.status == "COMPLETED") {
+				t.Errorf("%s: expected COMPLETED only for a done todo, got %+v", uid, props)
+			}
+		}
+
+		calendar = exportTodos(t, r, "/todo/export.ics?tag=home")
+		if len(calendar.components) != 1 || calendar.components[0].props["SUMMARY"] != "File taxes" {
+			t.Errorf("expected only the home todo, got %+v", calendar.components)
+		}
+
+		calendar = exportTodos(t, r, "/todo/export.ics?tag=nothing")
+		if len(calendar.components) != 0 {
+			t.Errorf("expected an empty calendar, got %+v", calendar.components)
+		}
+	})
+}
+
+func TestExportICalPages(t *testing.T) {
+	store := newMemoryTodoStore()
+	r := newTodoRouter(t, store)
+
+	count := pageSizes.Max + 5
+	for i := range count {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, newJSONRequest(http.MethodPost, "/todo", fmt.Sprintf(`{"title":"todo %d","due_date":"2025-03-10T09:30:00Z"}`, i)))
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("expected status %d, got %d", http.StatusCreated, rr.Code)
+		}
+	}
+
+	if calendar := exportTodos(t, r, "/todo/export.ics"); len(calendar.components) != count {
+		t.Errorf("expected all %d todos, got %d", count, len(calendar.components))
+	}
+}
+
+func TestEscapeICalText(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"plain", "plain"},
+		{"a, b; c", `a\, b\; c`},
+		{`back\slash`, `back\\slash`},
+		{"two\nlines\r\nand more", `two\nlines\nand more`},
+	}
+
+	for _, tt := range tests {
+		if got := escapeICalText(tt.in); got != tt.want {
+			t.Errorf("escapeICalText(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestFoldICalLine(t *testing.T) {
+	for _, line := range []string{
+		"SUMMARY:short",
+		"SUMMARY:" + strings.Repeat("a", 67),
+		"SUMMARY:" + strings.Repeat("a", 300),
+		"SUMMARY:" + strings.Repeat("日本", 60),
+	} {
+		folded := foldICalLine(line)
+
+		for _, physical := range strings.Split(folded, "\r\n") {
+			if len(physical) > icalLineOctets {
+				t.Errorf("line of %d octets in %q", len(physical), folded)
+			}
+			if !utf8.ValidString(physical) {
+				t.Errorf("split character in %q", physical)
+			}
+		}
+
+		if unfolded := strings.ReplaceAll(folded, "\r\n ", ""); unfolded != line {
+			t.Errorf("expected unfolding to give back %q, got %q", line, unfolded)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// maxImportBodySize caps POST /todo/import, which takes far more than one todo.
+const maxImportBodySize = 32 << 20
+
+type importError struct {
+	Index   int    `json:"index"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+type importReport struct {
+	// Imported counts the todos that were created, or would have been on a
+	// dry run.
+	Imported int           `json:"imported"`
+	Errors   []importError `json:"errors"`
+	DryRun   bool          `json:"dry_run,omitempty"`
+}
+
+// importTodos creates the todos in a JSON array body. Every entry goes through
+// the same rules as POST /todo; the invalid ones are listed in the report by
+// their index and the rest are created. ?dry_run=true only validates.
+//
+// The array is decoded one entry at a time so a large import is never held in
+// memory as raw JSON as well. Nothing is created until the whole body has
+// been read, so a body that turns out to be malformed halfway imports nothing.
+func (h *todoHandler) importTodos(w http.ResponseWriter, r *http.Request) {
+	dryRun, err := queryBool(r, "dry_run")
+	if err != nil {
+		respondError(w, r, http.StatusBadRequest, codeBadRequest, "dry_run must be true or false")
+		return
+	}
+
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		respondDecodeError(w, r, ErrUnsupportedMediaType)
+		return
+	}
+
+	todos, report, err := readImport(http.MaxBytesReader(w, r.Body, maxImportBodySize))
+	if err != nil {
+		respondDecodeError(w, r, err)
+		return
+	}
+
+	report.DryRun = dryRun
+	if !dryRun {
+		store := h.storeFor(r)
+		for _, todo := range todos {
+			if err := store.Create(r.Context(), todo); err != nil {
+				logError(r, "failed to import todo %d of %d: %v", report.Imported+1, len(todos), err)
+				respondError(w, r, http.StatusInternalServerError, codeInternal, "the server encountered a problem")
+				return
+			}
+			report.Imported++
+		}
+	} else {
+		report.Imported = len(todos)
+	}
+
+	respondJSON(w, http.StatusOK, report)
+}
+
+// readImport decodes and validates an array of todo payloads. Entries that
+// are well formed JSON but don't fit a todo end up in the report; anything
+// that breaks the array itself is returned as an error for respondDecodeError.
+func readImport(body io.Reader) ([]*Todo, importReport, error) {
+	report := importReport{Errors: []importError{}}
+
+	dec := json.NewDecoder(body)
+	dec.DisallowUnknownFields()
+
+	if token, err := dec.Token(); err != nil || token != json.Delim('[') {
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, report, importDecodeError(err)
+		}
+		return nil, report, &MalformedBodyError{Message: "request body must be a JSON array of todos", Err: err}
+	}
+
+	var todos []*Todo
+	for index := 0; dec.More(); index++ {
+		var payload todoPayload
+		if err := dec.Decode(&payload); err != nil {
+			entryErr, ok := importEntryError(index, err)
+			if !ok {
+				return nil, report, importDecodeError(err)
+			}
+
+			report.Errors = append(report.Errors, entryErr)
+			continue
+		}
+
+		todo, errs := payload.todo()
+		for _, fe := range errs {
+			report.Errors = append(report.Errors, importError{Index: index, Field: fe.Field, Message: fe.Message})
+		}
+		if errs == nil {
+			todos = append(todos, todo)
+		}
+	}
+
+	if _, err := dec.Token(); err != nil {
+		return nil, report, importDecodeError(err)
+	}
+
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return nil, report, &MalformedBodyError{Message: "request body must hold a single JSON value"}
+	}
+
+	return todos, report, nil
+}
+
+// importEntryError reports an entry the decoder read in full but couldn't
+// store in a todoPayload, the array can carry on after it. It returns false
+// for errors that leave the decoder unusable.
+func importEntryError(index int, err error) (importError, bool) {
+	var (
+		priorityErr *ErrInvalidPriority
+		typeErr     *json.UnmarshalTypeError
+	)
+
+	switch {
+	case errors.As(err, &priorityErr):
+		return importError{Index: index, Field: "priority", Message: "must be one of low, medium, high"}, true
+	case errors.As(err, &typeErr):
+		if typeErr.Field == "" {
+			return importError{Index: index, Message: "must be a JSON object"}, true
+		}
+		return importError{Index: index, Field: typeErr.Field, Message: fmt.Sprintf("must be a %s", typeErr.Type)}, true
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		return importError{Index: index, Field: field, Message: "is not a todo field"}, true
+	default:
+		return importError{}, false
+	}
+}
+
+func importDecodeError(err error) error {
+	if errors.Is(err, io.EOF) {
+		err = io.ErrUnexpectedEOF
+	}
+
+	return decodeError(err)
+}
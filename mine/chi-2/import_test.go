@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func postImport(t *testing.T, r http.Handler, target, body string) (*httptest.ResponseRecorder, importReport) {
+	t.Helper()
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, newJSONRequest(http.MethodPost, target, body))
+
+	var report importReport
+	if rr.Code == http.StatusOK {
+		if err := json.NewDecoder(rr.Body).Decode(&report); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return rr, report
+}
+
+const mixedImport = `[
+	{"title":"Buy milk","tags":["Home"," errands "]},
+	{"title":"   "},
+	{"title":"File taxes","done":true,"due_date":"2025-04-15T00:00:00Z"},
+	{"title":"Call mom","due_date":"tomorrow"},
+	{"title":42},
+	{"title":"Walk","colour":"red"},
+	"not a todo",
+	{"title":"Plan","priority":"urgent"},
+	{"title":"Water plants","tags":["garden"]}
+]`
+
+func TestImportTodos(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store TodoStore) {
+		r := newTodoRouter(t, store)
+
+		rr, report := postImport(t, r, "/todo/import", mixedImport)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+
+		if report.Imported != 3 || report.DryRun {
+			t.Errorf("expected 3 imported, got %+v", report)
+		}
+
+		want := []importError{
+			{Index: 1, Field: "title"},
+			{Index: 3, Field: "due_date"},
+			{Index: 4, Field: "title"},
+			{Index: 5, Field: "colour"},
+			{Index: 6},
+			{Index: 7, Field: "priority"},
+		}
+		if len(report.Errors) != len(want) {
+			t.Fatalf("expected %d errors, got %+v", len(want), report.Errors)
+		}
+		for i, w := range want {
+			got := report.Errors[i]
+			if got.Index != w.Index || got.Field != w.Field || got.Message == "" {
+				t.Errorf("error %d: expected index %d on %q, got %+v", i, w.Index, w.Field, got)
+			}
+		}
+
+		todos, total, err := store.List(context.Background(), ListQuery{Limit: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if total != 3 {
+			t.Fatalf("expected 3 stored todos, got %d", total)
+		}
+
+		titles := make([]string, len(todos))
+		for i, todo := range todos {
+			titles[i] = todo.Title
+		}
+		if strings.Join(titles, ",") != "Buy milk,File taxes,Water plants" {
+			t.Errorf("unexpected todos %v", titles)
+		}
+		if strings.Join(todos[0].Tags, ",") != "home,errands" {
+			t.Errorf("expected normalized tags, got %v", todos[0].Tags)
+		}
+		if !todos[1].Done || todos[1].DueDate == nil {
+			t.Errorf("expected a done todo with a due date, got %+v", todos[1])
+		}
+	})
+}
+
+func TestImportTodosDryRun(t *testing.T) {
+	store := newMemoryTodoStore()
+	r := newTodoRouter(t, store)
+
+	rr, report := postImport(t, r, "/todo/import?dry_run=true", mixedImport)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	if report.Imported != 3 || len(report.Errors) != 6 || !report.DryRun {
+		t.Errorf("expected the same report as a real import, got %+v", report)
+	}
+
+	if _, total, _ := store.List(context.Background(), ListQuery{Limit: 10}); total != 0 {
+		t.Errorf("expected a dry run to store nothing, got %d todos", total)
+	}
+
+	// an all valid import reports no errors as an empty list
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, newJSONRequest(http.MethodPost, "/todo/import?dry_run=1", `[{"title":"a"}]`))
+	if body := rr.Body.String(); !strings.Contains(body, `"errors":[]`) {
+		t.Errorf("expected an empty errors list, got %s", body)
+	}
+}
+
+func TestImportTodosRejectsBody(t *testing.T) {
+	tests := []struct {
+		name, target, body string
+		status             int
+	}{
+		{"object", "/todo/import", `{"title":"a"}`, http.StatusBadRequest},
+		{"empty", "/todo/import", ``, http.StatusBadRequest},
+		{"unterminated", "/todo/import", `[{"title":"a"},`, http.StatusBadRequest},
+		{"broken entry", "/todo/import", `[{"title":"a"},{"title":}]`, http.StatusBadRequest},
+		{"trailing value", "/todo/import", `[{"title":"a"}] []`, http.StatusBadRequest},
+		{"bad dry run", "/todo/import?dry_run=maybe", `[]`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newMemoryTodoStore()
+			r := newTodoRouter(t, store)
+
+			rr, _ := postImport(t, r, tt.target, tt.body)
+			if rr.Code != tt.status {
+				t.Errorf("expected status %d, got %d: %s", tt.status, rr.Code, rr.Body)
+			}
+
+			// nothing is created before the whole array has been read
+			if _, total, _ := store.List(context.Background(), ListQuery{Limit: 10}); total != 0 {
+				t.Errorf("expected nothing stored, got %d todos", total)
+			}
+		})
+	}
+
+	t.Run("content type", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		newTodoRouter(t, newMemoryTodoStore()).ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/todo/import", strings.NewReader(`[]`)))
+
+		if rr.Code != http.StatusUnsupportedMediaType {
+			t.Errorf("expected status %d, got %d", http.StatusUnsupportedMediaType, rr.Code)
+		}
+	})
+}
+
+func TestImportTodosLarge(t *testing.T) {
+	store := newMemoryTodoStore()
+	r := newTodoRouter(t, store)
+
+	// well past maxBodySize, which only applies to single todos
+	const count = 50_000
+
+	var body strings.Builder
+	body.WriteString("[")
+	for i := range count {
+		if i > 0 {
+			body.WriteString(",")
+		}
+		fmt.Fprintf(&body, `{"title":"imported todo number %d","tags":["bulk"]}`, i)
+	}
+	body.WriteString("]")
+
+	rr, report := postImport(t, r, "/todo/import", body.String())
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+	}
+	if report.Imported != count || len(report.Errors) != 0 {
+		t.Errorf("expected %d imported without errors, got %d and %d errors", count, report.Imported, len(report.Errors))
+	}
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// The windows ?due= accepts, see ListQuery.expandDue.
+const (
+	dueToday = "today"
+	dueWeek  = "week"
+)
+
+// ParamError is a query parameter a request got wrong.
+type ParamError struct {
+	Param   string `json:"param"`
+	Message string `json:"message"`
+}
+
+// ParamErrors collects every bad parameter of a request, so a client can fix
+// them all in one go rather than one 400 at a time.
+type ParamErrors []ParamError
+
+func (p ParamErrors) Error() string {
+	messages := make([]string, len(p))
+	for i, pe := range p {
+		messages[i] = fmt.Sprintf("%s: %s", pe.Param, pe.Message)
+	}
+
+	return strings.Join(messages, "; ")
+}
+
+func (p *ParamErrors) add(param, message string) {
+	*p = append(*p, ParamError{Param: param, Message: message})
+}
+
+// err returns p as an error, or a plain nil when nothing was added.
+func (p ParamErrors) err() error {
+	if len(p) == 0 {
+		return nil
+	}
+
+	return p
+}
+
+// ParseListQuery reads the query string of GET /todo into a ListQuery:
+//
+//	page, per_page   or limit, offset; per_page and limit default to
+//	                 pageSizes.Default and are capped at pageSizes.Max
+//	done, overdue    true or false
+//	archived         true or false, false by default
+//	include_archived true for archived and unarchived todos alike
+//	priority         low, medium or high
+//	tag              repeated or comma separated, any of them matches
+//	series           a positive series id
+//	list             a positive list id
+//	due_after        an RFC3339 time, the earliest due date to include
+//	due_before       an RFC3339 time, the latest due date to include
+//	due              today or week, in place of due_after and due_before
+//	sort             created, due (or due_date) or priority
+//	order            asc or desc; desc by default for priority, asc otherwise
+//
+// Every parameter is checked, the error is a ParamErrors listing all the bad
+// ones. Now is left for the caller to set, and so is expanding ?due= with
+// expandDue.
+func ParseListQuery(q url.Values) (ListQuery, error) {
+	page, errs := paginationFromQuery(q)
+
+	query := ListQuery{
+		Offset: page.offset,
+		Limit:  page.limit,
+		Tags:   tagsFromQuery(q),
+		page:   page,
+	}
+
+	query.Done = boolParam(q, "done", &errs)
+	if overdue := boolParam(q, "overdue", &errs); overdue != nil {
+		query.Overdue = *overdue
+	}
+	query.Archived = archivedFromQuery(q, &errs)
+
+	if value := q.Get("series"); value != "" {
+		series, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || series < 1 {
+			errs.add("series", "must be a positive integer")
+		}
+		query.Series = series
+	}
+
+	if value := q.Get("list"); value != "" {
+		list, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || list < 1 {
+			errs.add("list", "must be a positive integer")
+		}
+		query.ListID = list
+	}
+
+	query.DueAfter = timeParam(q, "due_after", &errs)
+	query.DueBefore = timeParam(q, "due_before", &errs)
+	if query.DueAfter != nil && query.DueBefore != nil && query.DueAfter.After(*query.DueBefore) {
+		errs.add("due_after", "must not be later than due_before")
+	}
+
+	if value := q.Get("due"); value != "" {
+		if value != dueToday && value != dueWeek {
+			errs.add("due", "must be one of today, week")
+		}
+		if q.Has("due_after") || q.Has("due_before") {
+			errs.add("due", "can't be combined with due_after or due_before")
+		}
+		query.due = value
+	}
+
+	if value := q.Get("priority"); value != "" {
+		priority, err := ParsePriority(value)
+		if err != nil {
+			errs.add("priority", "must be one of low, medium, high")
+		}
+		query.Priority = priority
+	}
+
+	query.Sort = q.Get("sort")
+	if query.Sort == sortDueDate {
+		query.Sort = sortDue
+	}
+	if query.Sort != "" && query.Sort != sortCreated && query.Sort != sortDue && query.Sort != sortPriority {
+		errs.add("sort", "must be one of created, due, due_date, priority")
+	}
+
+	// priorities read most naturally high to low, everything else ascending
+	query.Order = q.Get("order")
+	if query.Order == "" {
+		query.Order = orderAsc
+		if query.Sort == sortPriority {
+			query.Order = orderDesc
+		}
+	}
+	if query.Order != orderAsc && query.Order != orderDesc {
+		errs.add("order", "must be one of asc, desc")
+	}
+
+	if len(errs) > 0 {
+		return ListQuery{}, errs
+	}
+
+	return query, nil
+}
+
+// archivedFromQuery turns ?archived= and ?include_archived= into
+// ListQuery.Archived: unarchived todos only by default, archived=true for
+// archived ones only and include_archived=true for all of them.
+func archivedFromQuery(q url.Values, errs *ParamErrors) *bool {
+	archived := boolParam(q, "archived", errs)
+
+	if includeArchived := boolParam(q, "include_archived", errs); includeArchived != nil && *includeArchived {
+		if q.Has("archived") {
+			errs.add("include_archived", "can't be combined with archived")
+		}
+
+		return nil
+	}
+
+	if archived == nil {
+		archived = new(bool)
+	}
+
+	return archived
+}
+
+// expandDue sets DueAfter and DueBefore from ?due=: today runs from midnight
+// to the last microsecond before the next one in loc, week is today and the
+// six days after it. The memory and SQLite stores keep fractions of a second,
+// Postgres stores due dates in whole seconds, TIMESTAMP(0), so the last one it
+// can hold before midnight is well inside the bound.
+func (q *ListQuery) expandDue(now time.Time, loc *time.Location) {
+	var days int
+	switch q.due {
+	case dueToday:
+		days = 1
+	case dueWeek:
+		days = 7
+	default:
+		return
+	}
+
+	now = now.In(loc)
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	end := start.AddDate(0, 0, days).Add(-time.Microsecond)
+
+	q.DueAfter, q.DueBefore = utcTime(&start), utcTime(&end)
+}
+
+// timeParam returns nil when key is missing or empty, and adds a value that
+// isn't RFC3339 to errs.
+func timeParam(q url.Values, key string, errs *ParamErrors) *time.Time {
+	value := q.Get(key)
+	if value == "" {
+		return nil
+	}
+
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		errs.add(key, "must be an RFC3339 time")
+		return nil
+	}
+
+	return utcTime(&parsed)
+}
+
+// boolParam returns nil when key is missing or empty, and adds a malformed
+// value to errs.
+func boolParam(q url.Values, key string, errs *ParamErrors) *bool {
+	value := q.Get(key)
+	if value == "" {
+		return nil
+	}
+
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		errs.add(key, "must be true or false")
+		return nil
+	}
+
+	return &parsed
+}
+
+// intParam returns fallback when key is missing or empty, and adds message to
+// errs when the value is not an integer of at least least.
+func intParam(q url.Values, key string, fallback, least int, message string, errs *ParamErrors) int {
+	value := q.Get(key)
+	if value == "" {
+		return fallback
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < least {
+		errs.add(key, message)
+		return fallback
+	}
+
+	return parsed
+}
+
+// respondParamErrors writes the 400 for err, listing every bad parameter
+// when it is a ParamErrors.
+func respondParamErrors(w http.ResponseWriter, r *http.Request, err error) {
+	var params ParamErrors
+	if pe, ok := err.(ParamErrors); ok {
+		params = pe
+	}
+
+	respondJSON(w, http.StatusBadRequest, struct {
+		errorResponse
+		Params ParamErrors `json:"params,omitempty"`
+	}{
+		errorResponse: errorResponse{Error: err.Error(), Code: codeBadRequest, RequestID: middleware.GetReqID(r.Context())},
+		Params:        params,
+	})
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseListQuery(t *testing.T) {
+	yes, no := true, false
+	june, juneEvening := time.Date(2030, 6, 1, 0, 0, 0, 0, time.UTC), time.Date(2030, 6, 1, 22, 0, 0, 0, time.UTC)
+	firstPage := pagination{limit: pageSizes.Default, byPage: true, page: 1}
+
+	tests := []struct {
+		name   string
+		query  string
+		want   ListQuery
+		params []string
+	}{
+		{
+			name:  "defaults",
+			query: "",
+			want:  ListQuery{Limit: pageSizes.Default, Archived: &no, Order: orderAsc, page: firstPage},
+		},
+		{
+			name:  "pages",
+			query: "page=3&per_page=10",
+			want:  ListQuery{Offset: 20, Limit: 10, Archived: &no, Order: orderAsc, page: pagination{offset: 20, limit: 10, byPage: true, page: 3}},
+		},
+		{
+			name:  "limit is capped",
+			query: "limit=500&offset=7",
+			want:  ListQuery{Offset: 7, Limit: pageSizes.Max, Archived: &no, Order: orderAsc, page: pagination{offset: 7, limit: pageSizes.Max}},
+		},
+		{
+			name:  "filters",
+			query: "done=true&overdue=1&priority=high&tag=home,work&tag=home&series=4",
+			want: ListQuery{
+				Limit: pageSizes.Default, Done: &yes, Overdue: true, Priority: PriorityHigh, Tags: []string{"home", "work"},
+				Series: 4, Archived: &no, Order: orderAsc, page: firstPage,
+			},
+		},
+		{
+			name:  "archived only",
+			query: "archived=true",
+			want:  ListQuery{Limit: pageSizes.Default, Archived: &yes, Order: orderAsc, page: firstPage},
+		},
+		{
+			name:  "include archived",
+			query: "include_archived=true",
+			want:  ListQuery{Limit: pageSizes.Default, Order: orderAsc, page: firstPage},
+		},
+		{
+			name:  "priority sorts high to low",
+			query: "sort=priority",
+			want:  ListQuery{Limit: pageSizes.Default, Archived: &no, Sort: sortPriority, Order: orderDesc, page: firstPage},
+		},
+		{
+			name:  "due_date alias",
+			query: "sort=due_date&order=desc",
+			want:  ListQuery{Limit: pageSizes.Default, Archived: &no, Sort: sortDue, Order: orderDesc, page: firstPage},
+		},
+		{
+			name:  "due window",
+			query: "due_after=2030-06-01T00:00:00Z&due_before=2030-06-02T00:00:00%2B02:00",
+			want:  ListQuery{Limit: pageSizes.Default, Archived: &no, DueAfter: &june, DueBefore: &juneEvening, Order: orderAsc, page: firstPage},
+		},
+		{
+			name:  "due shorthand",
+			query: "due=week",
+			want:  ListQuery{Limit: pageSizes.Default, Archived: &no, due: dueWeek, Order: orderAsc, page: firstPage},
+		},
+		{name: "mixed pagination styles", query: "page=2&limit=5", params: []string{"page"}},
+		{name: "bad window", query: "per_page=0&page=x", params: []string{"per_page", "page"}},
+		{name: "negative offset", query: "limit=ten&offset=-1", params: []string{"limit", "offset"}},
+		{name: "archived both ways", query: "archived=false&include_archived=true", params: []string{"include_archived"}},
+		{name: "bad due times", query: "due_after=yesterday&due_before=2030-06-01", params: []string{"due_after", "due_before"}},
+		{name: "due window inside out", query: "due_after=2030-06-02T00:00:00Z&due_before=2030-06-01T00:00:00Z", params: []string{"due_after"}},
+		{name: "due shorthand with bounds", query: "due=month&due_before=2030-06-01T00:00:00Z", params: []string{"due", "due"}},
+		{
+			name:   "every filter wrong",
+			query:  "done=maybe&overdue=soon&archived=x&priority=urgent&series=0&sort=title&order=up",
+			params: []string{"done", "overdue", "archived", "series", "priority", "sort", "order"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := ParseListQuery(values)
+			if tt.params == nil {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("expected %+v, got %+v", tt.want, got)
+				}
+				return
+			}
+
+			errs, ok := err.(ParamErrors)
+			if !ok {
+				t.Fatalf("expected ParamErrors, got %v", err)
+			}
+
+			params := make([]string, len(errs))
+			for i, pe := range errs {
+				params[i] = pe.Param
+			}
+			if !reflect.DeepEqual(params, tt.params) {
+				t.Errorf("expected errors for %v, got %v", tt.params, errs)
+			}
+		})
+	}
+}
+
+func TestListTodosReportsEveryBadParam(t *testing.T) {
+	r := newTodoRouter(t, newMemoryTodoStore())
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/todo?done=maybe&sort=title&limit=0", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+
+	var body struct {
+		errorResponse
+		Params ParamErrors `json:"params"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Code != codeBadRequest || len(body.Params) != 3 {
+		t.Errorf("expected a bad_request listing 3 params, got %+v", body)
+	}
+}
+
+func TestExpandDue(t *testing.T) {
+	// 06:00 on June 2nd, ten hours ahead of UTC
+	now := time.Date(2030, 6, 1, 20, 0, 0, 0, time.UTC)
+	loc := time.FixedZone("UTC+10", 10*60*60)
+	start := time.Date(2030, 6, 1, 14, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		due        string
+		after, end time.Time
+	}{
+		{due: dueToday, after: start, end: start.Add(24*time.Hour - time.Microsecond)},
+		{due: dueWeek, after: start, end: start.Add(7*24*time.Hour - time.Microsecond)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.due, func(t *testing.T) {
+			q := ListQuery{due: tt.due}
+			q.expandDue(now, loc)
+
+			if q.DueAfter == nil || !q.DueAfter.Equal(tt.after) || q.DueBefore == nil || !q.DueBefore.Equal(tt.end) {
+				t.Errorf("expected %s to %s, got %v to %v", tt.after, tt.end, q.DueAfter, q.DueBefore)
+			}
+		})
+	}
+
+	q := ListQuery{}
+	q.expandDue(now, loc)
+	if q.DueAfter != nil || q.DueBefore != nil {
+		t.Errorf("expected no window without ?due=, got %v to %v", q.DueAfter, q.DueBefore)
+	}
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+)
+
+var (
+	ErrListNotFound = errors.New("list not found")
+	// ErrListNotEmpty is returned by DeleteList without force for a list that
+	// still has todos.
+	ErrListNotEmpty = errors.New("list is not empty")
+)
+
+// TodoList groups todos, like the todos of one project. Todos created with
+// POST /todo belong to no list.
+type TodoList struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	OwnerID   string    `json:"owner_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type listPayload struct {
+	Name string `json:"name"`
+}
+
+func validateListName(name string) ValidationErrors {
+	switch {
+	case name == "":
+		return ValidationErrors{{Field: "name", Message: "is required"}}
+	case utf8.RuneCountInString(name) > maxTitleLength:
+		return ValidationErrors{{Field: "name", Message: fmt.Sprintf("must be at most %d characters", maxTitleLength)}}
+	}
+
+	return nil
+}
+
+// listRoutes serves /lists. The todos of a list are the /todo routes for
+// listing and creating with the list filled in, everything else about them
+// stays under /todo/{todoID}.
+func (h *todoHandler) listRoutes() chi.Router {
+	r := chi.NewRouter()
+	r.Use(scopeMiddleware)
+	r.Use(timeoutMiddleware(h.requestTimeout()))
+
+	r.Method(http.MethodGet, "/", documented(routeDoc{Summary: "List todo lists", Response: []TodoList{}}, h.listLists))
+	r.Method(http.MethodPost, "/", documented(routeDoc{Summary: "Create a todo list", Request: listPayload{}, Response: TodoList{}, Status: http.StatusCreated}, h.createList))
+
+	r.Route("/{listID}", func(r chi.Router) {
+		r.Use(listIDMiddleware)
+
+		r.Method(http.MethodGet, "/", documented(routeDoc{Summary: "Get a todo list", Response: TodoList{}}, h.getList))
+		r.Method(http.MethodPatch, "/", documented(routeDoc{Summary: "Rename a todo list", Request: listPayload{}, Response: TodoList{}}, h.renameList))
+		r.Method(http.MethodDelete, "/", documented(routeDoc{Summary: "Delete a todo list", Status: http.StatusNoContent}, h.deleteList))
+		r.Method(http.MethodGet, "/todos", documented(routeDoc{Summary: "List the todos of a list", Response: []Todo{}}, h.listTodos))
+		r.Method(http.MethodPost, "/todos", documented(routeDoc{Summary: "Create a todo in a list", Request: todoPayload{}, Response: Todo{}, Status: http.StatusCreated}, h.createTodo))
+	})
+
+	return r
+}
+
+func (h *todoHandler) listLists(w http.ResponseWriter, r *http.Request) {
+	lists, err := h.storeFor(r).ListLists(r.Context())
+	if err != nil {
+		listStoreError(w, r, "list", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, lists)
+}
+
+func (h *todoHandler) createList(w http.ResponseWriter, r *http.Request) {
+	name, ok := decodeListName(w, r)
+	if !ok {
+		return
+	}
+
+	list := &TodoList{Name: name}
+	if err := h.storeFor(r).CreateList(r.Context(), list); err != nil {
+		listStoreError(w, r, "create", err)
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/"+apiVersion+"/lists/%d", list.ID))
+	respondJSON(w, http.StatusCreated, list)
+}
+
+func (h *todoHandler) getList(w http.ResponseWriter, r *http.Request) {
+	list, err := h.storeFor(r).GetList(r.Context(), ListIDFromContext(r.Context()))
+	if err != nil {
+		listStoreError(w, r, "get", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, list)
+}
+
+func (h *todoHandler) renameList(w http.ResponseWriter, r *http.Request) {
+	name, ok := decodeListName(w, r)
+	if !ok {
+		return
+	}
+
+	list, err := h.storeFor(r).RenameList(r.Context(), ListIDFromContext(r.Context()), name)
+	if err != nil {
+		listStoreError(w, r, "rename", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, list)
+}
+
+// deleteList refuses a list that still has todos with a 409, unless
+// ?force=true asks for the todos to go with it. The trashed todos of the list
+// never hold it up, they are purged along with it.
+func (h *todoHandler) deleteList(w http.ResponseWriter, r *http.Request) {
+	force, err := queryBool(r, "force")
+	if err != nil {
+		respondError(w, r, http.StatusBadRequest, codeBadRequest, "force must be true or false")
+		return
+	}
+
+	if _, err := h.storeFor(r).DeleteList(r.Context(), ListIDFromContext(r.Context()), force); err != nil {
+		listStoreError(w, r, "delete", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func decodeListName(w http.ResponseWriter, r *http.Request) (string, bool) {
+	var payload listPayload
+	if err := decodeJSON(r, &payload); err != nil {
+		respondDecodeError(w, r, err)
+		return "", false
+	}
+
+	name := strings.TrimSpace(payload.Name)
+	if errs := validateListName(name); errs != nil {
+		writeValidationErrors(w, r, errs)
+		return "", false
+	}
+
+	return name, true
+}
+
+// listStoreError writes 404 for ErrListNotFound, 409 for ErrListNotEmpty and
+// logs anything else as a 500.
+func listStoreError(w http.ResponseWriter, r *http.Request, action string, err error) {
+	switch {
+	case errors.Is(err, ErrListNotFound):
+		respondError(w, r, http.StatusNotFound, codeNotFound, "list not found")
+	case errors.Is(err, ErrListNotEmpty):
+		respondError(w, r, http.StatusConflict, codeListNotEmpty, "list still has todos, delete it with force=true to delete them too")
+	default:
+		logError(r, "failed to %s list: %v", action, err)
+		respondError(w, r, http.StatusInternalServerError, codeInternal, "the server encountered a problem")
+	}
+}
+
+const listIDCtx contextKey = "listID"
+
+// listIDMiddleware is todoIDMiddleware for {listID}.
+func listIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "listID"), 10, 64)
+		if err != nil || id < 1 {
+			respondError(w, r, http.StatusBadRequest, codeBadRequest, "list id must be a positive integer")
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), listIDCtx, id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// ListIDFromContext returns the id listIDMiddleware parsed, or 0 outside of
+// the /lists/{listID} routes.
+func ListIDFromContext(ctx context.Context) int64 {
+	id, _ := ctx.Value(listIDCtx).(int64)
+	return id
+}
+
+func (s *memoryTodoStore) ownsList(list *TodoList) bool {
+	return s.owner == "" || list.OwnerID == s.owner
+}
+
+// indexOfList returns the index of the list in s.lists, or -1 when it is
+// missing or someone else's. It must be called with s.mu held.
+func (s *memoryTodoStore) indexOfList(id int64) int {
+	return slices.IndexFunc(s.lists, func(list TodoList) bool {
+		return list.ID == id && s.ownsList(&list)
+	})
+}
+
+func (s *memoryTodoStore) CreateList(ctx context.Context, list *TodoList) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	list.ID = s.nextListID
+	list.CreatedAt = s.now().UTC()
+	if s.owner != "" {
+		list.OwnerID = s.owner
+	}
+	s.nextListID++
+
+	s.lists = append(s.lists, *list)
+
+	return nil
+}
+
+func (s *memoryTodoStore) GetList(ctx context.Context, id int64) (TodoList, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return TodoList{}, err
+	}
+
+	i := s.indexOfList(id)
+	if i < 0 {
+		return TodoList{}, ErrListNotFound
+	}
+
+	return s.lists[i], nil
+}
+
+func (s *memoryTodoStore) ListLists(ctx context.Context) ([]TodoList, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	lists := []TodoList{}
+	for i := range s.lists {
+		if s.ownsList(&s.lists[i]) {
+			lists = append(lists, s.lists[i])
+		}
+	}
+
+	return lists, nil
+}
+
+func (s *memoryTodoStore) RenameList(ctx context.Context, id int64, name string) (TodoList, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return TodoList{}, err
+	}
+
+	i := s.indexOfList(id)
+	if i < 0 {
+		return TodoList{}, ErrListNotFound
+	}
+
+	s.lists[i].Name = name
+
+	return s.lists[i], nil
+}
+
+func (s *memoryTodoStore) DeleteList(ctx context.Context, id int64, force bool) ([]Todo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	i := s.indexOfList(id)
+	if i < 0 {
+		return nil, ErrListNotFound
+	}
+
+	deleted := []Todo{}
+	for j := range s.todos {
+		if s.todos[j].ListID == id && s.todos[j].DeletedAt == nil {
+			deleted = append(deleted, s.todos[j])
+		}
+	}
+	if len(deleted) > 0 && !force {
+		return nil, ErrListNotEmpty
+	}
+
+	s.todos = slices.DeleteFunc(s.todos, func(todo Todo) bool {
+		if todo.ListID != id {
+			return false
+		}
+
+		// the tag index only counts todos outside the trash
+		if todo.DeletedAt == nil {
+			s.indexTags(todo.Tags, -1)
+		}
+		delete(s.subtasks, todo.ID)
+		delete(s.comments, todo.ID)
+		return true
+	})
+	s.lists = slices.Delete(s.lists, i, i+1)
+
+	return deleted, nil
+}
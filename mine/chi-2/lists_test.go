@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLists(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store TodoStore) {
+		r := newAPIRouter(t, &todoHandler{store: store}, nil, nil)
+		ana, bob := testToken(t, "ana"), testToken(t, "bob")
+
+		do := func(token, method, target, body string, out any) *httptest.ResponseRecorder {
+			t.Helper()
+
+			req := newJSONRequest(method, target, body)
+			req.Header.Set("Authorization", "Bearer "+token)
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			if out != nil {
+				if err := json.NewDecoder(rr.Body).Decode(out); err != nil {
+					t.Fatalf("%s %s: %v", method, target, err)
+				}
+			}
+
+			return rr
+		}
+		titles := func(todos []Todo) []string {
+			titles := []string{}
+			for _, todo := range todos {
+				titles = append(titles, todo.Title)
+			}
+			return titles
+		}
+
+		var work, home TodoList
+		rr := do(ana, http.MethodPost, "/v1/lists", `{"name":"work"}`, &work)
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("expected status %d, got %d", http.StatusCreated, rr.Code)
+		}
+		if location := rr.Header().Get("Location"); location != "/v1/lists/1" {
+			t.Errorf("expected Location /v1/lists/1, got %q", location)
+		}
+		do(ana, http.MethodPost, "/v1/lists", `{"name":"home"}`, &home)
+
+		var todo Todo
+		if rr := do(ana, http.MethodPost, "/v1/lists/1/todos", `{"title":"write the report"}`, &todo); rr.Code != http.StatusCreated {
+			t.Fatalf("expected status %d, got %d", http.StatusCreated, rr.Code)
+		}
+		if todo.ListID != work.ID {
+			t.Errorf("expected the todo in list %d, got %d", work.ID, todo.ListID)
+		}
+		do(ana, http.MethodPost, "/v1/lists/2/todos", `{"title":"water the plants"}`, nil)
+		do(ana, http.MethodPost, "/v1/todo", `{"title":"call mom"}`, nil)
+
+		// a list only holds its own todos
+		var todos []Todo
+		do(ana, http.MethodGet, "/v1/lists/1/todos", "", &todos)
+		if got := titles(todos); len(got) != 1 || got[0] != "write the report" {
+			t.Errorf("expected only the work todo, got %v", got)
+		}
+
+		// /todo lists across lists, ?list= narrows it down
+		do(ana, http.MethodGet, "/v1/todo", "", &todos)
+		if len(todos) != 3 {
+			t.Errorf("expected 3 todos across the lists, got %v", titles(todos))
+		}
+		do(ana, http.MethodGet, "/v1/todo?list=2", "", &todos)
+		if got := titles(todos); len(got) != 1 || got[0] != "water the plants" {
+			t.Errorf("expected only the home todo, got %v", got)
+		}
+		if rr := do(ana, http.MethodGet, "/v1/todo?list=abc", "", nil); rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status %d for a bad list, got %d", http.StatusBadRequest, rr.Code)
+		}
+
+		// someone else's list is as good as missing
+		if rr := do(bob, http.MethodGet, "/v1/lists/1/todos", "", nil); rr.Code != http.StatusNotFound {
+			t.Errorf("expected status %d for ana's list, got %d", http.StatusNotFound, rr.Code)
+		}
+		if rr := do(bob, http.MethodPost, "/v1/lists/1/todos", `{"title":"sneak in"}`, nil); rr.Code != http.StatusNotFound {
+			t.Errorf("expected status %d creating in ana's list, got %d", http.StatusNotFound, rr.Code)
+		}
+		var lists []TodoList
+		do(bob, http.MethodGet, "/v1/lists", "", &lists)
+		if len(lists) != 0 {
+			t.Errorf("expected bob to have no lists, got %+v", lists)
+		}
+
+		var renamed TodoList
+		if rr := do(ana, http.MethodPatch, "/v1/lists/2", `{"name":"chores"}`, &renamed); rr.Code != http.StatusOK || renamed.Name != "chores" {
+			t.Errorf("expected the list renamed, got %d %+v", rr.Code, renamed)
+		}
+	})
+}
+
+func TestDeleteList(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store TodoStore) {
+		r := newTodoRouter(t, store)
+
+		do := func(method, target, body string) *httptest.ResponseRecorder {
+			t.Helper()
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, newJSONRequest(method, target, body))
+			return rr
+		}
+
+		do(http.MethodPost, "/lists", `{"name":"work"}`)
+		do(http.MethodPost, "/lists", `{"name":"empty"}`)
+		do(http.MethodPost, "/lists/1/todos", `{"title":"write the report"}`)
+		do(http.MethodPost, "/lists/1/todos", `{"title":"old draft"}`)
+		do(http.MethodDelete, "/todo/2", "")
+
+		// blocked while the list has todos
+		if rr := do(http.MethodDelete, "/lists/1", ""); rr.Code != http.StatusConflict {
+			t.Fatalf("expected status %d, got %d", http.StatusConflict, rr.Code)
+		}
+		if rr := do(http.MethodGet, "/todo/1", ""); rr.Code != http.StatusOK {
+			t.Errorf("expected the todo to survive, got status %d", rr.Code)
+		}
+
+		// an empty list goes without force
+		if rr := do(http.MethodDelete, "/lists/2", ""); rr.Code != http.StatusNoContent {
+			t.Errorf("expected status %d for the empty list, got %d", http.StatusNoContent, rr.Code)
+		}
+
+		// force takes the todos with it, the trashed one included
+		if rr := do(http.MethodDelete, "/lists/1?force=true", ""); rr.Code != http.StatusNoContent {
+			t.Fatalf("expected status %d, got %d", http.StatusNoContent, rr.Code)
+		}
+		if rr := do(http.MethodGet, "/todo/1", ""); rr.Code != http.StatusNotFound {
+			t.Errorf("expected the todo gone with its list, got status %d", rr.Code)
+		}
+		if rr := do(http.MethodPost, "/todo/2/restore", ""); rr.Code != http.StatusNotFound {
+			t.Errorf("expected the trashed todo gone with its list, got status %d", rr.Code)
+		}
+		if rr := do(http.MethodGet, "/lists/1", ""); rr.Code != http.StatusNotFound {
+			t.Errorf("expected the list gone, got status %d", rr.Code)
+		}
+	})
+}
+
+func TestListLocation(t *testing.T) {
+	s := newRouterServer(t)
+
+	req := newJSONRequest(http.MethodPost, "/v1/lists", `{"name":"garden"}`)
+	req.Header.Set("Authorization", "Bearer "+s.tokens["ana"])
+	rr := httptest.NewRecorder()
+	s.app.router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d", http.StatusCreated, rr.Code)
+	}
+
+	if status := s.do(t, http.MethodGet, routeRequest{as: "ana", target: rr.Header().Get("Location")}); status != http.StatusOK {
+		t.Errorf("following the Location %q: expected status %d, got %d", rr.Header().Get("Location"), http.StatusOK, status)
+	}
+}
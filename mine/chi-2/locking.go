@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// ErrVersionConflict is returned by Update and SetDone when the todo was
+// written since the version the caller based its change on. Current is the
+// todo as it is stored now.
+type ErrVersionConflict struct {
+	Current Todo
+}
+
+func (e *ErrVersionConflict) Error() string {
+	return fmt.Sprintf("todo %d is at version %d", e.Current.ID, e.Current.Version)
+}
+
+// versionConflictResponse is the 409 for a stale write. It carries the
+// stored todo so a client can merge its change into it and retry.
+type versionConflictResponse struct {
+	errorResponse
+	Current Todo `json:"current"`
+}
+
+// versionPayload is the optional body of PATCH /complete and /uncomplete.
+type versionPayload struct {
+	Version *int `json:"version"`
+}
+
+// todoETag is the strong ETag of a todo, its version in quotes.
+func todoETag(todo Todo) string {
+	return strconv.Quote(strconv.Itoa(todo.Version))
+}
+
+// respondTodo writes todo with its ETag, which a later PUT or PATCH can send
+// back as If-Match.
+func respondTodo(w http.ResponseWriter, status int, todo Todo) {
+	w.Header().Set("ETag", todoETag(todo))
+	respondJSON(w, status, todo)
+}
+
+// expectedVersion returns the version a PUT or PATCH was based on, taken from
+// If-Match or the version field of the body, which must agree when both are
+// there. Without either it writes a 428, as it does a 400 for a malformed
+// one; it returns false when a response has already been written.
+func expectedVersion(w http.ResponseWriter, r *http.Request, body *int) (int, bool) {
+	header := r.Header.Get("If-Match")
+	if header == "" && body == nil {
+		respondError(w, r, http.StatusPreconditionRequired, codePreconditionRequired, "send the todo version in If-Match or the version field")
+		return 0, false
+	}
+
+	if body != nil && *body < 1 {
+		writeValidationErrors(w, r, ValidationErrors{{Field: "version", Message: "must be a positive integer"}})
+		return 0, false
+	}
+
+	if header == "" {
+		return *body, true
+	}
+
+	// a bare number is accepted too, not every client quotes its ETags
+	version, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(header, `"`), `"`))
+	if err != nil || version < 1 {
+		respondError(w, r, http.StatusBadRequest, codeBadRequest, `If-Match must be a single todo version, like "3"`)
+		return 0, false
+	}
+
+	if body != nil && *body != version {
+		respondError(w, r, http.StatusBadRequest, codeBadRequest, "If-Match and the version field disagree")
+		return 0, false
+	}
+
+	return version, true
+}
+
+func respondVersionConflict(w http.ResponseWriter, r *http.Request, current Todo) {
+	w.Header().Set("ETag", todoETag(current))
+	respondJSON(w, http.StatusConflict, versionConflictResponse{
+		errorResponse: errorResponse{
+			Error:     fmt.Sprintf("todo changed since the version sent, it is at version %d now", current.Version),
+			Code:      codeVersionConflict,
+			RequestID: middleware.GetReqID(r.Context()),
+		},
+		Current: current,
+	})
+}
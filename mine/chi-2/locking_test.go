@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// withIfMatch sets If-Match to version, the way a client echoes an ETag.
+func withIfMatch(r *http.Request, version int) *http.Request {
+	r.Header.Set("If-Match", strconv.Quote(strconv.Itoa(version)))
+	return r
+}
+
+func TestOptimisticLocking(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store TodoStore) {
+		r := newTodoRouter(t, store)
+
+		serve := func(req *http.Request) *httptest.ResponseRecorder {
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+			return rr
+		}
+
+		rr := serve(newJSONRequest(http.MethodPost, "/todo", `{"title":"buy milk"}`))
+		if rr.Code != http.StatusCreated || rr.Header().Get("ETag") != `"1"` {
+			t.Fatalf("create: expected status %d with ETag \"1\", got %d %q", http.StatusCreated, rr.Code, rr.Header().Get("ETag"))
+		}
+
+		// two tabs loaded version 1, the first save wins
+		rr = serve(newJSONRequest(http.MethodPut, "/todo/1", `{"title":"buy oat milk","version":1}`))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("first update: expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+		if got := rr.Header().Get("ETag"); got != `"2"` {
+			t.Errorf("expected ETag \"2\" after the first update, got %q", got)
+		}
+
+		rr = serve(newJSONRequest(http.MethodPut, "/todo/1", `{"title":"buy soy milk","version":1}`))
+		if rr.Code != http.StatusConflict {
+			t.Fatalf("second update: expected status %d, got %d: %s", http.StatusConflict, rr.Code, rr.Body)
+		}
+		if got := rr.Header().Get("ETag"); got != `"2"` {
+			t.Errorf("expected the conflict to carry ETag \"2\", got %q", got)
+		}
+
+		var conflict versionConflictResponse
+		if err := json.NewDecoder(rr.Body).Decode(&conflict); err != nil {
+			t.Fatal(err)
+		}
+		if conflict.Code != codeVersionConflict || conflict.Current.Title != "buy oat milk" || conflict.Current.Version != 2 {
+			t.Errorf("expected the stored todo in the conflict, got %+v", conflict)
+		}
+
+		rr = serve(httptest.NewRequest(http.MethodGet, "/todo/1", nil))
+		var stored Todo
+		if err := json.NewDecoder(rr.Body).Decode(&stored); err != nil {
+			t.Fatal(err)
+		}
+		if stored.Title != "buy oat milk" || stored.Version != 2 || rr.Header().Get("ETag") != `"2"` {
+			t.Errorf("expected the losing update to write nothing, got %+v with ETag %q", stored, rr.Header().Get("ETag"))
+		}
+
+		// the merged retry, and If-Match instead of the body field
+		rr = serve(withIfMatch(newJSONRequest(http.MethodPut, "/todo/1", `{"title":"buy oat and soy milk"}`), 2))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("retry: expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+
+		rr = serve(withIfMatch(httptest.NewRequest(http.MethodPatch, "/todo/1/complete", nil), 2))
+		if rr.Code != http.StatusConflict {
+			t.Errorf("stale complete: expected status %d, got %d", http.StatusConflict, rr.Code)
+		}
+
+		rr = serve(withIfMatch(httptest.NewRequest(http.MethodPatch, "/todo/1/complete", nil), 3))
+		var completed Todo
+		if err := json.NewDecoder(rr.Body).Decode(&completed); err != nil {
+			t.Fatal(err)
+		}
+		if rr.Code != http.StatusOK || !completed.Done || completed.Version != 4 {
+			t.Errorf("complete: expected a done todo at version 4, got %d %+v", rr.Code, completed)
+		}
+	})
+}
+
+func TestOptimisticLockingErrors(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store TodoStore) {
+		if err := store.Create(context.Background(), &Todo{Title: "a"}); err != nil {
+			t.Fatal(err)
+		}
+		r := newTodoRouter(t, store)
+
+		ifMatch := func(req *http.Request, value string) *http.Request {
+			req.Header.Set("If-Match", value)
+			return req
+		}
+
+		tests := []struct {
+			name   string
+			req    *http.Request
+			status int
+			code   string
+		}{
+			{"put without a version", newJSONRequest(http.MethodPut, "/todo/1", `{"title":"b"}`), http.StatusPreconditionRequired, codePreconditionRequired},
+			{"patch without a version", httptest.NewRequest(http.MethodPatch, "/todo/1/complete", nil), http.StatusPreconditionRequired, codePreconditionRequired},
+			{"missing todo without a version", newJSONRequest(http.MethodPut, "/todo/99", `{"title":"b"}`), http.StatusPreconditionRequired, codePreconditionRequired},
+			{"wildcard", ifMatch(newJSONRequest(http.MethodPut, "/todo/1", `{"title":"b"}`), "*"), http.StatusBadRequest, codeBadRequest},
+			{"weak etag", ifMatch(newJSONRequest(http.MethodPut, "/todo/1", `{"title":"b"}`), `W/"1"`), http.StatusBadRequest, codeBadRequest},
+			{"zero", ifMatch(newJSONRequest(http.MethodPut, "/todo/1", `{"title":"b"}`), `"0"`), http.StatusBadRequest, codeBadRequest},
+			{"header and body disagree", withIfMatch(newJSONRequest(http.MethodPut, "/todo/1", `{"title":"b","version":2}`), 1), http.StatusBadRequest, codeBadRequest},
+			{"negative body version", newJSONRequest(http.MethodPut, "/todo/1", `{"title":"b","version":-1}`), http.StatusUnprocessableEntity, codeValidationFailed},
+			{"bare number", ifMatch(httptest.NewRequest(http.MethodPatch, "/todo/1/complete", nil), "1"), http.StatusOK, ""},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				rr := httptest.NewRecorder()
+				r.ServeHTTP(rr, tt.req)
+
+				if rr.Code != tt.status {
+					t.Fatalf("expected status %d, got %d: %s", tt.status, rr.Code, rr.Body)
+				}
+				if tt.code == "" {
+					return
+				}
+
+				var body errorResponse
+				if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+					t.Fatal(err)
+				}
+				if body.Code != tt.code {
+					t.Errorf("expected code %q, got %q", tt.code, body.Code)
+				}
+			})
+		}
+	})
+}
+
+// TestVersionBumps checks every kind of write moves the version on, so a
+// client holding an older one can't overwrite it.
+func TestVersionBumps(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store TodoStore) {
+		ctx := context.Background()
+
+		todo := &Todo{Title: "a"}
+		if err := store.Create(ctx, todo); err != nil {
+			t.Fatal(err)
+		}
+		if todo.Version != 1 {
+			t.Fatalf("expected a new todo at version 1, got %d", todo.Version)
+		}
+
+		writes := []struct {
+			name  string
+			write func() error
+		}{
+			{"update", func() error { return store.Update(ctx, &Todo{ID: todo.ID, Title: "b"}) }},
+			{"complete", func() error { _, err := store.SetDone(ctx, todo.ID, true, 0); return err }},
+			{"complete again", func() error { _, err := store.SetDone(ctx, todo.ID, true, 0); return err }},
+			{"bulk complete", func() error { _, _, _, err := store.CompleteMany(ctx, []int64{todo.ID}); return err }},
+			{"archive", func() error { _, err := store.SetArchived(ctx, todo.ID, true); return err }},
+			{"unarchive", func() error { _, err := store.SetArchived(ctx, todo.ID, false); return err }},
+			{"delete", func() error { return store.Delete(ctx, todo.ID) }},
+			{"restore", func() error { _, err := store.Restore(ctx, todo.ID); return err }},
+		}
+
+		version := todo.Version
+		for _, w := range writes {
+			if err := w.write(); err != nil {
+				t.Fatalf("%s: %v", w.name, err)
+			}
+
+			// Get misses a trashed todo, Restore brings it back right after
+			if w.name == "delete" {
+				version++
+				continue
+			}
+
+			got, err := store.Get(ctx, todo.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Version != version+1 {
+				t.Errorf("%s: expected version %d, got %d", w.name, version+1, got.Version)
+			}
+			version = got.Version
+		}
+
+		var conflict *ErrVersionConflict
+		if _, err := store.SetDone(ctx, todo.ID, false, 1); !errors.As(err, &conflict) || conflict.Current.Version != version {
+			t.Errorf("expected a conflict at version %d, got %v", version, err)
+		}
+	})
+}
+
+// TestConcurrentUpdates races updates that all start from version 1, exactly
+// one of them may win.
+func TestConcurrentUpdates(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store TodoStore) {
+		if err := store.Create(context.Background(), &Todo{Title: "a"}); err != nil {
+			t.Fatal(err)
+		}
+		r := newTodoRouter(t, store)
+
+		const writers = 10
+		statuses := make([]int, writers)
+
+		var wg sync.WaitGroup
+		for i := range writers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				rr := httptest.NewRecorder()
+				r.ServeHTTP(rr, newJSONRequest(http.MethodPut, "/todo/1", fmt.Sprintf(`{"title":"writer %d","version":1}`, i)))
+				statuses[i] = rr.Code
+			}()
+		}
+		wg.Wait()
+
+		won := 0
+		for _, status := range statuses {
+			switch status {
+			case http.StatusOK:
+				won++
+			case http.StatusConflict:
+			default:
+				t.Errorf("expected status %d or %d, got %d", http.StatusOK, http.StatusConflict, status)
+			}
+		}
+		if won != 1 {
+			t.Errorf("expected exactly one update to win, got %d", won)
+		}
+
+		todo, err := store.Get(context.Background(), 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if todo.Version != 2 {
+			t.Errorf("expected version 2, got %d", todo.Version)
+		}
+	})
+}
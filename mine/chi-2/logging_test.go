@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+type requestRecord struct {
+	Msg       string `json:"msg"`
+	Method    string `json:"method"`
+	Route     string `json:"route"`
+	Status    int    `json:"status"`
+	Bytes     int    `json:"bytes"`
+	Duration  int64  `json:"duration"`
+	RemoteIP  string `json:"remote_ip"`
+	RequestID string `json:"request_id"`
+}
+
+func TestRequestLogger(t *testing.T) {
+	store := newMemoryTodoStore()
+	if err := store.Create(context.Background(), &Todo{Title: "buy milk"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var logs bytes.Buffer
+	r := chi.NewRouter()
+	r.Use(middleware.RequestID)
+	r.Use(requestLogger(slog.New(slog.NewJSONHandler(&logs, nil))))
+	useJSONRouteErrors(r)
+	r.Mount("/todo", (&todoHandler{store: store}).routes())
+
+	tests := []struct {
+		target string
+		status int
+		route  string
+	}{
+		{target: "/todo/1", status: http.StatusOK, route: "/todo/{todoID}"},
+		{target: "/todo/2", status: http.StatusNotFound, route: "/todo/{todoID}"},
+		{target: "/todo/1/subtasks", status: http.StatusOK, route: "/todo/{todoID}/subtasks"},
+		{target: "/nowhere", status: http.StatusNotFound, route: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			logs.Reset()
+
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			req.RemoteAddr = "203.0.113.7:5123"
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+			if rr.Code != tt.status {
+				t.Fatalf("expected status %d, got %d", tt.status, rr.Code)
+			}
+
+			var record requestRecord
+			if err := json.Unmarshal(logs.Bytes(), &record); err != nil {
+				t.Fatalf("expected one JSON record, got %q: %v", logs.String(), err)
+			}
+
+			want := requestRecord{
+				Msg:       "request",
+				Method:    http.MethodGet,
+				Route:     tt.route,
+				Status:    tt.status,
+				Bytes:     rr.Body.Len(),
+				Duration:  record.Duration,
+				RemoteIP:  "203.0.113.7",
+				RequestID: record.RequestID,
+			}
+			if record != want {
+				t.Errorf("expected %+v, got %+v", want, record)
+			}
+			if record.RequestID == "" {
+				t.Error("expected the request id in the record")
+			}
+		})
+	}
+}
+
+type loggedBody struct {
+	Bytes     int    `json:"bytes"`
+	Body      string `json:"body"`
+	Truncated bool   `json:"truncated"`
+}
+
+type bodyRecord struct {
+	Msg      string     `json:"msg"`
+	Level    string     `json:"level"`
+	Route    string     `json:"route"`
+	Request  loggedBody `json:"request"`
+	Response loggedBody `json:"response"`
+}
+
+func TestBodyLogger(t *testing.T) {
+	var logs bytes.Buffer
+	r := chi.NewRouter()
+	r.Use(bodyLogger(slog.New(slog.NewJSONHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))))
+	r.Mount("/todo", (&todoHandler{store: newMemoryTodoStore()}).routes())
+	r.Post("/upload", func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write([]byte{0xff, 0xd8, 0xff})
+	})
+
+	send := func(target, contentType, body string) bodyRecord {
+		t.Helper()
+		logs.Reset()
+
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		r.ServeHTTP(httptest.NewRecorder(), req)
+
+		var record bodyRecord
+		if err := json.Unmarshal(logs.Bytes(), &record); err != nil {
+			t.Fatalf("expected one JSON record, got %q: %v", logs.String(), err)
+		}
+		return record
+	}
+
+	record := send("/todo", "application/json", `{"title":"buy milk"}`)
+	if record.Level != "DEBUG" || record.Route != "/todo" {
+		t.Errorf("expected a debug record for /todo, got %+v", record)
+	}
+	if record.Request != (loggedBody{Bytes: 20, Body: `{"title":"buy milk"}`}) {
+		t.Errorf("expected the request body, got %+v", record.Request)
+	}
+	if !strings.Contains(record.Response.Body, `"title":"buy milk"`) || record.Response.Truncated {
+		t.Errorf("expected the created todo in the response body, got %+v", record.Response)
+	}
+
+	// the handler still gets the whole body
+	long := `{"title":"` + strings.Repeat("a", maxLoggedBody) + `"}`
+	record = send("/todo", "application/json", long)
+	if record.Request.Bytes != len(long) || len(record.Request.Body) != maxLoggedBody || !record.Request.Truncated {
+		t.Errorf("expected the request body cut at %d bytes, got %d of %d", maxLoggedBody, len(record.Request.Body), record.Request.Bytes)
+	}
+
+	record = send("/upload", "image/png", "\x89PNG")
+	if record.Request != (loggedBody{Bytes: 4}) || record.Response != (loggedBody{Bytes: 3}) {
+		t.Errorf("expected only the size of binary bodies, got %+v and %+v", record.Request, record.Response)
+	}
+}
+
+func TestBodyLoggerOffByDefault(t *testing.T) {
+	withTestSecret(t)
+
+	var logs bytes.Buffer
+	app, err := buildRouter(routerConfig{
+		store:  newMemoryTodoStore(),
+		logger: slog.New(slog.NewJSONHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug})),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/refresh", strings.NewReader(`{"refresh_token":"secret"}`))
+	req.Header.Set("Content-Type", "application/json")
+	app.router.ServeHTTP(httptest.NewRecorder(), req)
+
+	if strings.Contains(logs.String(), "secret") {
+		t.Errorf("expected no bodies logged without debug, got %s", logs.String())
+	}
+}
@@ -1,51 +1,167 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
 	"log"
+	"log/slog"
 	"net/http"
-
-	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/chi/v5/middleware"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 )
 
 func main() {
-	r := chi.NewRouter()
+	dbPath := flag.String("db", "", "path of the SQLite database file, the todos are kept in memory when empty")
+	dbAttempts := flag.Int("db-attempts", 10, "how often to ping the database at startup before giving up, at least once")
+	webhookAttempts := flag.Int("webhook-attempts", defaultWebhookAttempts, "how often to try delivering an event to a webhook before giving up")
+	webhookAllowPrivate := flag.Bool("webhook-allow-private", false, "let webhooks point to loopback and private addresses, for receivers on the same machine or network")
+	trashRetention := flag.Duration("trash-retention", defaultTrashRetention, "how long DELETE /todo/trash keeps deleted todos restorable")
+	requestTimeout := flag.Duration("request-timeout", defaultRequestTimeout, "how long a request may take before it is answered with a 503")
+	drainDelay := flag.Duration("drain-delay", defaultDrainDelay, "how long to keep serving after a drain or a shutdown signal, before shutting down")
+	shutdownTimeout := flag.Duration("shutdown-timeout", defaultShutdownTimeout, "how long to wait for open requests to finish on shutdown")
+	accessTokenTTL := flag.Duration("access-token-ttl", defaultAccessTokenTTL, "how long an access token from POST /v1/refresh is valid")
+	refreshTokenTTL := flag.Duration("refresh-token-ttl", defaultRefreshTokenTTL, "how long a refresh token is valid, unless revoked with POST /v1/logout")
+	rateLimit := flag.Float64("rate-limit", 0, "how many requests per second a client IP gets back, 0 turns rate limiting off")
+	rateBurst := flag.Int("rate-burst", defaultRateBurst, "how many requests a client IP can make back to back before -rate-limit kicks in")
+	timezone := flag.String("timezone", "UTC", "the IANA time zone ?due=today and ?due=week count days in, like Europe/Berlin")
+	debug := flag.Bool("debug", envBool("DEBUG"), "log request and response bodies, never turn this on in production; defaults to $DEBUG")
+	flag.Parse()
 
-	r.Use(middleware.Logger)
+	pageSizes = pageSizesFromEnv()
 
-	r.Group(func(r chi.Router) {
-		r.Get("/", helloWorldHandler)
-	})
-	r.Group(func(r chi.Router) {
-		// r.Use(AuthMiddleware)
-		r.Route("/todo", func(r chi.Router) {
-			r.Get("/", func(w http.ResponseWriter, r *http.Request) {
-				w.Write([]byte("Get all todos"))
-			})
-
-			r.Route("/{todoID}", func(r chi.Router) {
-				r.Get("/", func(w http.ResponseWriter, r *http.Request) {
-					w.Write([]byte("Get single todo"))
-				})
-				r.Put("/", func(w http.ResponseWriter, r *http.Request) {
-					w.Write([]byte("Update todo"))
-				})
-				r.Delete("/", func(w http.ResponseWriter, r *http.Request) {
-					w.Write([]byte("Delete dodo"))
-				})
-			})
-		})
+	jwtSecret = []byte(os.Getenv("JWT_SECRET"))
+	if len(jwtSecret) == 0 {
+		log.Fatal("JWT_SECRET must be set")
+	}
+
+	location, err := time.LoadLocation(*timezone)
+	if err != nil {
+		log.Fatal("Could not load the time zone:", err)
+	}
+
+	store, err := newTodoStore(context.Background(), *dbPath, *dbAttempts)
+	if err != nil {
+		log.Fatal("Could not create todo store:", err)
+	}
+
+	app, err := buildRouter(routerConfig{
+		store:               store,
+		dbPath:              *dbPath,
+		logger:              newLogger(*debug),
+		trashRetention:      *trashRetention,
+		requestTimeout:      *requestTimeout,
+		webhookAttempts:     *webhookAttempts,
+		webhookAllowPrivate: *webhookAllowPrivate,
+		accessTokenTTL:      *accessTokenTTL,
+		refreshTokenTTL:     *refreshTokenTTL,
+		rateLimit:           *rateLimit,
+		rateBurst:           *rateBurst,
+		timezone:            location,
+		debug:               *debug,
 	})
+	if err != nil {
+		log.Fatal("Could not build the router:", err)
+	}
+	go app.dispatcher.run(context.Background())
+	drain := app.drain
+
+	srv := &http.Server{Addr: ":3000", Handler: app.router}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal("Could not start server:", err)
+		}
+	}()
+
+	signals, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	select {
+	case <-signals.Done():
+		drain.drain()
+	case <-drain.started:
+	}
+	// a second signal kills the process right away
+	stop()
+
+	log.Printf("draining for %s before shutting down", *drainDelay)
+	time.Sleep(*drainDelay)
+
+	ctx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Printf("shutdown: %v", err)
+	}
+}
+
+// newTodoStore connects to Postgres when DATABASE_URL is set, opens the SQLite
+// file at dbPath when that is set and falls back to the in-memory store
+// otherwise. Either database is pinged up to attempts times before giving up.
+func newTodoStore(ctx context.Context, dbPath string, attempts int) (TodoStore, error) {
+	dsn := os.Getenv("DATABASE_URL")
+	if dsn == "" {
+		if dbPath == "" {
+			return newMemoryTodoStore(), nil
+		}
 
-	if err := http.ListenAndServe(":3000", r); err != nil {
-		log.Fatal("Could not start server:", err)
+		db, err := openSQLite(dbPath)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := pingWithRetry(ctx, db, attempts, firstPingBackoff); err != nil {
+			return nil, err
+		}
+
+		return newSQLiteTodoStore(ctx, db)
 	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := pingWithRetry(ctx, db, attempts, firstPingBackoff); err != nil {
+		return nil, err
+	}
+
+	return newPostgresTodoStore(ctx, db)
+}
+
+// newLogger writes JSON records to stdout, the debug ones only with debug on.
+func newLogger(debug bool) *slog.Logger {
+	level := slog.LevelInfo
+	if debug {
+		level = slog.LevelDebug
+	}
+
+	return slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: level}))
+}
+
+// envBool reads a boolean like DEBUG=1, anything unparsable counts as false.
+func envBool(key string) bool {
+	b, _ := strconv.ParseBool(os.Getenv(key))
+	return b
 }
 
 func helloWorldHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("Hello, world!"))
 }
 
+type adminProfile struct {
+	Subject string   `json:"subject"`
+	Roles   []string `json:"roles"`
+	Message string   `json:"message"`
+}
+
 func getAdminProfileHandler(w http.ResponseWriter, r *http.Request) {
-	w.Write([]byte("Hello admin!"))
+	subject, _ := subjectFromContext(r.Context())
+
+	respondJSON(w, http.StatusOK, adminProfile{
+		Subject: subject,
+		Roles:   rolesFromContext(r.Context()),
+		Message: "Hello admin!",
+	})
 }
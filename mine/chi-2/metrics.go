@@ -0,0 +1,143 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// durationBuckets are the upper bounds, in seconds, of the request duration
+// histogram. The last one matches defaultRequestTimeout.
+var durationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// httpMetrics counts requests and their durations for GET /metrics, in the
+// Prometheus text format. The series are labeled by method, chi route pattern
+// and status class, never by the raw path, so /todo/1 and /todo/2 share one.
+type httpMetrics struct {
+	mu     sync.Mutex
+	series map[requestLabels]*requestSeries
+}
+
+type requestLabels struct {
+	method string
+	route  string
+	// status is the class, like 2xx.
+	status string
+}
+
+type requestSeries struct {
+	count uint64
+	sum   float64
+	// buckets are cumulative, buckets[i] counts the durations up to
+	// durationBuckets[i].
+	buckets []uint64
+}
+
+func newHTTPMetrics() *httpMetrics {
+	return &httpMetrics{series: make(map[requestLabels]*requestSeries)}
+}
+
+// middleware records every request once it has been served, when the router
+// has resolved its route pattern.
+func (m *httpMetrics) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		next.ServeHTTP(ww, r)
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		route := routePattern(r)
+		if route == "" {
+			route = "unmatched"
+		}
+
+		m.observe(requestLabels{method: r.Method, route: route, status: fmt.Sprintf("%dxx", status/100)}, time.Since(start))
+	})
+}
+
+func (m *httpMetrics) observe(labels requestLabels, duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.series[labels]
+	if !ok {
+		s = &requestSeries{buckets: make([]uint64, len(durationBuckets))}
+		m.series[labels] = s
+	}
+
+	seconds := duration.Seconds()
+	s.count++
+	s.sum += seconds
+	for i, bound := range durationBuckets {
+		if seconds <= bound {
+			s.buckets[i]++
+		}
+	}
+}
+
+// ServeHTTP is the GET /metrics scrape.
+func (m *httpMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.write(w)
+}
+
+func (m *httpMetrics) write(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// sorted so scrapes are stable, which makes them diffable
+	labels := make([]requestLabels, 0, len(m.series))
+	for l := range m.series {
+		labels = append(labels, l)
+	}
+	slices.SortFunc(labels, func(a, b requestLabels) int {
+		return cmp.Or(cmp.Compare(a.route, b.route), cmp.Compare(a.method, b.method), cmp.Compare(a.status, b.status))
+	})
+
+	fmt.Fprintln(w, "# HELP http_requests_total Requests served, by method, route and status class.")
+	fmt.Fprintln(w, "# TYPE http_requests_total counter")
+	for _, l := range labels {
+		fmt.Fprintf(w, "http_requests_total{%s} %d\n", l.format(), m.series[l].count)
+	}
+
+	fmt.Fprintln(w, "# HELP http_request_duration_seconds Time to serve a request, by method, route and status class.")
+	fmt.Fprintln(w, "# TYPE http_request_duration_seconds histogram")
+	for _, l := range labels {
+		s := m.series[l]
+		for i, bound := range durationBuckets {
+			fmt.Fprintf(w, "http_request_duration_seconds_bucket{%s,le=%q} %d\n", l.format(), formatFloat(bound), s.buckets[i])
+		}
+		fmt.Fprintf(w, "http_request_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", l.format(), s.count)
+		fmt.Fprintf(w, "http_request_duration_seconds_sum{%s} %s\n", l.format(), formatFloat(s.sum))
+		fmt.Fprintf(w, "http_request_duration_seconds_count{%s} %d\n", l.format(), s.count)
+	}
+}
+
+func (l requestLabels) format() string {
+	return fmt.Sprintf(`method="%s",route="%s",status="%s"`, escapeLabel(l.method), escapeLabel(l.route), escapeLabel(l.status))
+}
+
+// labelEscaper applies the escapes the text format knows, %q would add Go's
+// own on top.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
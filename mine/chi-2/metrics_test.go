@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func newMetricsRouter(t *testing.T) http.Handler {
+	t.Helper()
+
+	store := newMemoryTodoStore()
+	if err := store.Create(context.Background(), &Todo{Title: "buy milk"}); err != nil {
+		t.Fatal(err)
+	}
+
+	metrics := newHTTPMetrics()
+	r := chi.NewRouter()
+	r.Use(metrics.middleware)
+	useJSONRouteErrors(r)
+	r.Get("/metrics", metrics.ServeHTTP)
+	r.Mount("/todo", (&todoHandler{store: store}).routes())
+
+	return r
+}
+
+// scrape returns the sample lines of GET /metrics keyed by name and labels.
+func scrape(t *testing.T, r http.Handler) map[string]string {
+	t.Helper()
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	if got := rr.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/plain; version=0.0.4") {
+		t.Errorf("expected the Prometheus text format, got %q", got)
+	}
+
+	samples := map[string]string{}
+	for _, line := range strings.Split(strings.TrimSpace(rr.Body.String()), "\n") {
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		series, value, _ := strings.Cut(line, " ")
+		samples[series] = value
+	}
+
+	return samples
+}
+
+func TestMetrics(t *testing.T) {
+	r := newMetricsRouter(t)
+
+	requests := []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/todo/1", nil),
+		httptest.NewRequest(http.MethodGet, "/todo/1", nil),
+		httptest.NewRequest(http.MethodGet, "/todo/99", nil),
+		newJSONRequest(http.MethodPost, "/todo", `{"title":"walk the dog"}`),
+		newJSONRequest(http.MethodPost, "/todo", `{"title":""}`),
+		httptest.NewRequest(http.MethodGet, "/nowhere/42", nil),
+	}
+	for _, req := range requests {
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	samples := scrape(t, r)
+
+	want := map[string]string{
+		`http_requests_total{method="GET",route="/todo/{todoID}",status="2xx"}`:                            "2",
+		`http_requests_total{method="GET",route="/todo/{todoID}",status="4xx"}`:                            "1",
+		`http_requests_total{method="POST",route="/todo",status="2xx"}`:                                    "1",
+		`http_requests_total{method="POST",route="/todo",status="4xx"}`:                                    "1",
+		`http_requests_total{method="GET",route="unmatched",status="4xx"}`:                                 "1",
+		`http_request_duration_seconds_count{method="GET",route="/todo/{todoID}",status="2xx"}`:            "2",
+		`http_request_duration_seconds_bucket{method="GET",route="/todo/{todoID}",status="2xx",le="+Inf"}`: "2",
+	}
+	for series, value := range want {
+		if samples[series] != value {
+			t.Errorf("expected %s %s, got %q", series, value, samples[series])
+		}
+	}
+
+	for series := range samples {
+		if strings.Contains(series, "/todo/1") || strings.Contains(series, "/todo/99") {
+			t.Errorf("expected no raw paths in the labels, got %s", series)
+		}
+	}
+
+	// the scrape above is counted by the next one
+	if got := scrape(t, r)[`http_requests_total{method="GET",route="/metrics",status="2xx"}`]; got != "1" {
+		t.Errorf("expected the first scrape counted once, got %q", got)
+	}
+}
+
+func TestMetricsConcurrent(t *testing.T) {
+	r := newMetricsRouter(t)
+
+	const requests = 50
+
+	var wg sync.WaitGroup
+	for range requests {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/todo/1", nil))
+		}()
+	}
+	wg.Wait()
+
+	if got := scrape(t, r)[`http_requests_total{method="GET",route="/todo/{todoID}",status="2xx"}`]; got != "50" {
+		t.Errorf("expected %d requests counted, got %q", requests, got)
+	}
+}
@@ -0,0 +1,16 @@
+CREATE TABLE todos (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	title TEXT NOT NULL,
+	done BOOLEAN NOT NULL DEFAULT FALSE,
+	priority TEXT NOT NULL DEFAULT 'medium',
+	-- a JSON array of strings
+	tags TEXT NOT NULL DEFAULT '[]',
+	due_date DATETIME,
+	created_at DATETIME NOT NULL,
+	completed_at DATETIME,
+	owner_id TEXT NOT NULL DEFAULT '',
+	archived BOOLEAN NOT NULL DEFAULT FALSE,
+	archived_at DATETIME
+);
+
+CREATE INDEX todos_owner_id_idx ON todos (owner_id);
@@ -0,0 +1,9 @@
+CREATE TABLE subtasks (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	todo_id INTEGER NOT NULL REFERENCES todos (id) ON DELETE CASCADE,
+	title TEXT NOT NULL,
+	done BOOLEAN NOT NULL DEFAULT FALSE,
+	created_at DATETIME NOT NULL
+);
+
+CREATE INDEX subtasks_todo_id_idx ON subtasks (todo_id);
@@ -0,0 +1,6 @@
+-- a restricted RRULE like FREQ=WEEKLY;INTERVAL=2, empty for a one-off todo
+ALTER TABLE todos ADD COLUMN recurrence TEXT NOT NULL DEFAULT '';
+-- the id of the first occurrence, 0 for a todo that never recurred
+ALTER TABLE todos ADD COLUMN series_id INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX todos_series_id_idx ON todos (series_id) WHERE series_id <> 0;
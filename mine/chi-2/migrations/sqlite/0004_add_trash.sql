@@ -0,0 +1,4 @@
+-- set when the todo is moved to the trash, the row stays until it is purged
+ALTER TABLE todos ADD COLUMN deleted_at DATETIME;
+
+CREATE INDEX todos_deleted_at_idx ON todos (deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,2 @@
+-- goes up with every write, PUT and PATCH compare it before writing
+ALTER TABLE todos ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,5 @@
+-- the id an offline client gave the todo with PUT /todo/{clientID}, NULL for
+-- todos created with POST
+ALTER TABLE todos ADD COLUMN client_id TEXT;
+
+CREATE UNIQUE INDEX todos_owner_client_id_idx ON todos (owner_id, client_id) WHERE client_id IS NOT NULL;
@@ -0,0 +1,9 @@
+CREATE TABLE comments (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	todo_id INTEGER NOT NULL REFERENCES todos (id) ON DELETE CASCADE,
+	author_id TEXT NOT NULL DEFAULT '',
+	body TEXT NOT NULL,
+	created_at DATETIME NOT NULL
+);
+
+CREATE INDEX comments_todo_id_idx ON comments (todo_id);
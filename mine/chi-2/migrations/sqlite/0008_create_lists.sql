@@ -0,0 +1,13 @@
+CREATE TABLE lists (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL,
+	owner_id TEXT NOT NULL DEFAULT '',
+	created_at DATETIME NOT NULL
+);
+
+CREATE INDEX lists_owner_id_idx ON lists (owner_id);
+
+-- deleting a list deletes its todos, and with them their subtasks and comments
+ALTER TABLE todos ADD COLUMN list_id INTEGER REFERENCES lists (id) ON DELETE CASCADE;
+
+CREATE INDEX todos_list_id_idx ON todos (list_id) WHERE list_id IS NOT NULL;
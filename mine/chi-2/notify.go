@@ -0,0 +1,201 @@
+package main
+
+import "context"
+
+// notifyingStore publishes a todoEvent after every successful change to the
+// wrapped store, whichever implementation that is.
+type notifyingStore struct {
+	TodoStore
+	events *todoEvents
+}
+
+func (s *notifyingStore) ForOwner(owner string) TodoStore {
+	return &notifyingStore{TodoStore: s.TodoStore.ForOwner(owner), events: s.events}
+}
+
+func (s *notifyingStore) Create(ctx context.Context, todo *Todo) error {
+	if err := s.TodoStore.Create(ctx, todo); err != nil {
+		return err
+	}
+
+	s.events.publish(eventCreated, *todo)
+	return nil
+}
+
+func (s *notifyingStore) Update(ctx context.Context, todo *Todo) error {
+	if err := s.TodoStore.Update(ctx, todo); err != nil {
+		return err
+	}
+
+	s.events.publish(eventUpdated, *todo)
+	s.publishNext(*todo)
+	return nil
+}
+
+// PutByClientID publishes nothing for a repeat that changed nothing.
+func (s *notifyingStore) PutByClientID(ctx context.Context, todo *Todo) (PutResult, error) {
+	result, err := s.TodoStore.PutByClientID(ctx, todo)
+	if err != nil {
+		return 0, err
+	}
+
+	switch result {
+	case PutCreated:
+		s.events.publish(eventCreated, *todo)
+	case PutUpdated:
+		s.events.publish(eventUpdated, *todo)
+		s.publishNext(*todo)
+	}
+	return result, nil
+}
+
+func (s *notifyingStore) Delete(ctx context.Context, id int64) error {
+	// the deleted event carries the todo as it was
+	todo, err := s.TodoStore.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.TodoStore.Delete(ctx, id); err != nil {
+		return err
+	}
+
+	s.events.publish(eventDeleted, todo)
+	return nil
+}
+
+// Restore publishes the todo as created, it is back where the deleted event
+// took it from.
+func (s *notifyingStore) Restore(ctx context.Context, id int64) (Todo, error) {
+	todo, err := s.TodoStore.Restore(ctx, id)
+	if err != nil {
+		return Todo{}, err
+	}
+
+	s.events.publish(eventCreated, todo)
+	return todo, nil
+}
+
+func (s *notifyingStore) SetDone(ctx context.Context, id int64, done bool, version int) (Todo, error) {
+	todo, err := s.TodoStore.SetDone(ctx, id, done, version)
+	if err != nil {
+		return Todo{}, err
+	}
+
+	if done {
+		s.events.publish(eventCompleted, todo)
+	} else {
+		s.events.publish(eventUpdated, todo)
+	}
+	s.publishNext(todo)
+
+	return todo, nil
+}
+
+func (s *notifyingStore) SetArchived(ctx context.Context, id int64, archived bool) (Todo, error) {
+	todo, err := s.TodoStore.SetArchived(ctx, id, archived)
+	if err != nil {
+		return Todo{}, err
+	}
+
+	s.events.publish(eventUpdated, todo)
+	return todo, nil
+}
+
+func (s *notifyingStore) CompleteMany(ctx context.Context, ids []int64) ([]int64, []int64, []Todo, error) {
+	completed, missing, spawned, err := s.TodoStore.CompleteMany(ctx, ids)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	for _, id := range completed {
+		s.publishTodo(ctx, eventCompleted, id)
+	}
+	for _, next := range spawned {
+		s.events.publish(eventCreated, next)
+	}
+
+	return completed, missing, spawned, nil
+}
+
+// The subtask methods publish the parent todo, whose subtask counts changed.
+
+func (s *notifyingStore) CreateSubtask(ctx context.Context, subtask *Subtask) error {
+	if err := s.TodoStore.CreateSubtask(ctx, subtask); err != nil {
+		return err
+	}
+
+	s.publishTodo(ctx, eventUpdated, subtask.TodoID)
+	return nil
+}
+
+func (s *notifyingStore) UpdateSubtask(ctx context.Context, todoID, id int64, patch SubtaskPatch) (Subtask, error) {
+	subtask, err := s.TodoStore.UpdateSubtask(ctx, todoID, id, patch)
+	if err != nil {
+		return Subtask{}, err
+	}
+
+	s.publishTodo(ctx, eventUpdated, todoID)
+	return subtask, nil
+}
+
+func (s *notifyingStore) DeleteSubtask(ctx context.Context, todoID, id int64) error {
+	if err := s.TodoStore.DeleteSubtask(ctx, todoID, id); err != nil {
+		return err
+	}
+
+	s.publishTodo(ctx, eventUpdated, todoID)
+	return nil
+}
+
+// The comment methods do the same for the comment count.
+
+func (s *notifyingStore) CreateComment(ctx context.Context, comment *Comment) error {
+	if err := s.TodoStore.CreateComment(ctx, comment); err != nil {
+		return err
+	}
+
+	s.publishTodo(ctx, eventUpdated, comment.TodoID)
+	return nil
+}
+
+func (s *notifyingStore) DeleteComment(ctx context.Context, todoID, id int64, author string) error {
+	if err := s.TodoStore.DeleteComment(ctx, todoID, id, author); err != nil {
+		return err
+	}
+
+	s.publishTodo(ctx, eventUpdated, todoID)
+	return nil
+}
+
+// DeleteList publishes a deleted event for every todo that went with the
+// list.
+func (s *notifyingStore) DeleteList(ctx context.Context, id int64, force bool) ([]Todo, error) {
+	deleted, err := s.TodoStore.DeleteList(ctx, id, force)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, todo := range deleted {
+		s.events.publish(eventDeleted, todo)
+	}
+	return deleted, nil
+}
+
+// publishTodo publishes the current state of the todo. One that is gone
+// already, deleted by a concurrent request, has its own deleted event.
+func (s *notifyingStore) publishTodo(ctx context.Context, eventType string, id int64) {
+	todo, err := s.TodoStore.Get(ctx, id)
+	if err != nil {
+		return
+	}
+
+	s.events.publish(eventType, todo)
+}
+
+// publishNext publishes the occurrence completing todo created, if any.
+func (s *notifyingStore) publishNext(todo Todo) {
+	if todo.next != nil {
+		s.events.publish(eventCreated, *todo.next)
+	}
+}
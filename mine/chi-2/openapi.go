@@ -0,0 +1,311 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// routeDoc is the OpenAPI metadata of a route, registered next to its handler
+// with documented.
+type routeDoc struct {
+	Summary string
+	// Request and Response are values of the body types, only their types
+	// matter. nil means no body.
+	Request  any
+	Response any
+	// Status is the success status, 200 when zero.
+	Status int
+	// ContentType is the media type of the response, application/json when
+	// empty.
+	ContentType string
+}
+
+// documentedHandler is a handler carrying its routeDoc, so chi.Walk finds the
+// metadata again on the route it was registered on.
+type documentedHandler struct {
+	http.HandlerFunc
+	doc routeDoc
+}
+
+// documented pairs handler with doc. Register the result with r.Method:
+//
+//	r.Method(http.MethodGet, "/", documented(routeDoc{Summary: "List todos", Response: []Todo{}}, h.listTodos))
+func documented(doc routeDoc, handler http.HandlerFunc) http.Handler {
+	return documentedHandler{HandlerFunc: handler, doc: doc}
+}
+
+// undocumentedRoute is the description of a route registered without a
+// routeDoc. It is still listed, so a missing doc shows in the document
+// instead of the route going missing from it.
+const undocumentedRoute = "No documentation was registered for this route."
+
+type openAPIDocument struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       openAPIInfo                             `json:"info"`
+	Paths      map[string]map[string]*openAPIOperation `json:"paths"`
+	Components openAPIComponents                       `json:"components"`
+}
+
+type openAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type openAPIComponents struct {
+	Schemas map[string]jsonSchema `json:"schemas"`
+}
+
+type openAPIOperation struct {
+	Summary     string                     `json:"summary,omitempty"`
+	Description string                     `json:"description,omitempty"`
+	Parameters  []openAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *openAPIRequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]openAPIResponse `json:"responses"`
+}
+
+type openAPIParameter struct {
+	Name     string     `json:"name"`
+	In       string     `json:"in"`
+	Required bool       `json:"required"`
+	Schema   jsonSchema `json:"schema"`
+}
+
+type openAPIRequestBody struct {
+	Required bool                        `json:"required"`
+	Content  map[string]openAPIMediaType `json:"content"`
+}
+
+type openAPIResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]openAPIMediaType `json:"content,omitempty"`
+}
+
+type openAPIMediaType struct {
+	Schema jsonSchema `json:"schema,omitempty"`
+}
+
+type jsonSchema map[string]any
+
+// openAPIMethods are the methods an OpenAPI path item can describe. A route
+// registered with r.Handle answers CONNECT too, which it has no field for.
+var openAPIMethods = map[string]bool{
+	http.MethodGet: true, http.MethodPut: true, http.MethodPost: true, http.MethodDelete: true,
+	http.MethodOptions: true, http.MethodHead: true, http.MethodPatch: true, http.MethodTrace: true,
+}
+
+// openAPISpec serves GET /openapi.json. main builds it once every route is
+// mounted, from the router itself, so the document can't drift from the
+// routes.
+type openAPISpec struct {
+	doc *openAPIDocument
+}
+
+func (s *openAPISpec) build(routes chi.Routes) error {
+	doc, err := generateOpenAPI(routes)
+	if err != nil {
+		return err
+	}
+
+	s.doc = doc
+	return nil
+}
+
+func (s *openAPISpec) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, s.doc)
+}
+
+func generateOpenAPI(routes chi.Routes) (*openAPIDocument, error) {
+	doc := &openAPIDocument{
+		OpenAPI:    "3.0.3",
+		Info:       openAPIInfo{Title: "chi-2 todo API", Version: version},
+		Paths:      make(map[string]map[string]*openAPIOperation),
+		Components: openAPIComponents{Schemas: make(map[string]jsonSchema)},
+	}
+	schemas := &schemaBuilder{components: doc.Components.Schemas}
+
+	err := chi.Walk(routes, func(method, route string, handler http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+		if !openAPIMethods[method] {
+			return nil
+		}
+
+		path, params := openAPIPath(route)
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = make(map[string]*openAPIOperation)
+		}
+		doc.Paths[path][strings.ToLower(method)] = newOperation(handler, params, schemas)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return doc, nil
+}
+
+func newOperation(handler http.Handler, params []string, schemas *schemaBuilder) *openAPIOperation {
+	op := &openAPIOperation{
+		Responses: map[string]openAPIResponse{
+			"default": {
+				Description: "Error",
+				Content:     map[string]openAPIMediaType{"application/json": {Schema: schemas.of(reflect.TypeOf(errorResponse{}))}},
+			},
+		},
+	}
+	for _, name := range params {
+		op.Parameters = append(op.Parameters, openAPIParameter{Name: name, In: "path", Required: true, Schema: jsonSchema{"type": "string"}})
+	}
+
+	documented, ok := handler.(documentedHandler)
+	if !ok {
+		op.Description = undocumentedRoute
+		op.Responses["200"] = openAPIResponse{Description: http.StatusText(http.StatusOK)}
+		return op
+	}
+	doc := documented.doc
+
+	op.Summary = doc.Summary
+	if doc.Request != nil {
+		op.RequestBody = &openAPIRequestBody{
+			Required: true,
+			Content:  map[string]openAPIMediaType{"application/json": {Schema: schemas.of(reflect.TypeOf(doc.Request))}},
+		}
+	}
+
+	status := cmp.Or(doc.Status, http.StatusOK)
+	response := openAPIResponse{Description: http.StatusText(status)}
+	if doc.Response != nil {
+		response.Content = map[string]openAPIMediaType{"application/json": {Schema: schemas.of(reflect.TypeOf(doc.Response))}}
+	}
+	if doc.ContentType != "" {
+		response.Content = map[string]openAPIMediaType{doc.ContentType: {Schema: jsonSchema{"type": "string"}}}
+	}
+	op.Responses[strconv.Itoa(status)] = response
+
+	return op
+}
+
+// routeParam matches a chi URL parameter, with or without a regexp.
+var routeParam = regexp.MustCompile(`\{([^}:]+)(:[^}]*)?\}`)
+
+// openAPIPath turns a chi route pattern into an OpenAPI path and the names of
+// its parameters. The slash chi.Walk leaves after a mounted router's "/" is
+// dropped, /v1/todo/ is served as /v1/todo.
+func openAPIPath(route string) (string, []string) {
+	if len(route) > 1 {
+		route = strings.TrimSuffix(route, "/")
+	}
+
+	var params []string
+	path := routeParam.ReplaceAllStringFunc(route, func(param string) string {
+		name := routeParam.FindStringSubmatch(param)[1]
+		params = append(params, name)
+		return "{" + name + "}"
+	})
+
+	return path, params
+}
+
+// schemaBuilder derives JSON schemas from Go types the way encoding/json
+// would encode them. Named structs go to the components and are referenced,
+// anonymous ones are inlined.
+type schemaBuilder struct {
+	components map[string]jsonSchema
+}
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	marshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+func (b *schemaBuilder) of(t reflect.Type) jsonSchema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return jsonSchema{"type": "string", "format": "date-time"}
+	case t.Implements(marshalerType) || reflect.PointerTo(t).Implements(marshalerType):
+		// Recurrence and the like encode themselves, as strings
+		return jsonSchema{"type": "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return jsonSchema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return jsonSchema{"type": "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return jsonSchema{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return jsonSchema{"type": "number"}
+	case reflect.String:
+		return jsonSchema{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return jsonSchema{"type": "string", "format": "byte"}
+		}
+		return jsonSchema{"type": "array", "items": b.of(t.Elem())}
+	case reflect.Map:
+		return jsonSchema{"type": "object", "additionalProperties": b.of(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.object(t)
+		}
+
+		ref := jsonSchema{"$ref": "#/components/schemas/" + t.Name()}
+		if _, ok := b.components[t.Name()]; !ok {
+			// claimed before the fields are walked, a type that refers to
+			// itself gets the reference
+			b.components[t.Name()] = nil
+			b.components[t.Name()] = b.object(t)
+		}
+		return ref
+	}
+
+	return jsonSchema{}
+}
+
+// object lists the properties of a struct without marking any required: the
+// same types are decoded from requests, where most fields may be left out.
+func (b *schemaBuilder) object(t reflect.Type) jsonSchema {
+	properties := jsonSchema{}
+	b.fields(t, properties)
+
+	return jsonSchema{"type": "object", "properties": properties}
+}
+
+// fields adds the encoded fields of t, and of the structs it embeds, to
+// properties.
+func (b *schemaBuilder) fields(t reflect.Type, properties jsonSchema) {
+	for i := range t.NumField() {
+		field := t.Field(i)
+
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			b.fields(field.Type, properties)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = b.of(field.Type)
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestOpenAPI(t *testing.T) {
+	r := newAPIRouter(t, &todoHandler{store: newMemoryTodoStore()}, nil, nil)
+
+	spec := &openAPISpec{}
+	r.Method(http.MethodGet, "/openapi.json", documented(routeDoc{Summary: "This document"}, spec.ServeHTTP))
+	drain := newDrainer()
+	drain.mount(r)
+	newHealthChecks(drain, defaultHealthCheckTimeout).mount(r)
+	// registered without a routeDoc
+	r.Get("/bare", helloWorldHandler)
+
+	if err := spec.build(r); err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	var doc struct {
+		OpenAPI string `json:"openapi"`
+		Paths   map[string]map[string]struct {
+			Summary     string `json:"summary"`
+			Description string `json:"description"`
+			Parameters  []struct {
+				Name string `json:"name"`
+				In   string `json:"in"`
+			} `json:"parameters"`
+		} `json:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]json.RawMessage `json:"properties"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.OpenAPI != "3.0.3" {
+		t.Errorf("expected OpenAPI 3.0.3, got %q", doc.OpenAPI)
+	}
+
+	walked := 0
+	err := chi.Walk(r, func(method, route string, handler http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+		if !openAPIMethods[method] {
+			return nil
+		}
+		walked++
+
+		path, params := openAPIPath(route)
+		op, ok := doc.Paths[path][strings.ToLower(method)]
+		if !ok {
+			t.Errorf("%s %s is missing from the document", method, route)
+			return nil
+		}
+		if op.Summary == "" && op.Description == "" {
+			t.Errorf("%s %s has neither a summary nor a description", method, route)
+		}
+		if len(op.Parameters) != len(params) {
+			t.Errorf("%s %s: expected parameters %v, got %+v", method, route, params, op.Parameters)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if walked == 0 {
+		t.Fatal("expected routes to walk")
+	}
+
+	if got := doc.Paths["/v1/todo/{todoID}"]["put"].Summary; got != "Replace a todo" {
+		t.Errorf("expected the PUT summary, got %q", got)
+	}
+	if got := doc.Paths["/bare"]["get"].Description; got != undocumentedRoute {
+		t.Errorf("expected an undocumented route to get the default description, got %q", got)
+	}
+
+	want := map[string][]string{
+		"Todo":        {"id", "title", "done", "priority", "tags", "due_date", "created_at", "completed_at", "archived", "recurrence", "version", "subtask_counts", "comment_count"},
+		"todoPayload": {"title", "done", "priority", "tags", "due_date", "recurrence", "version"},
+		"Subtask":     {"id", "todo_id", "title", "done", "created_at"},
+		"Comment":     {"id", "todo_id", "author_id", "body", "created_at"},
+	}
+	for name, properties := range want {
+		schema, ok := doc.Components.Schemas[name]
+		if !ok {
+			t.Errorf("expected a %s schema", name)
+			continue
+		}
+		for _, property := range properties {
+			if _, ok := schema.Properties[property]; !ok {
+				t.Errorf("expected %s to have a %s property", name, property)
+			}
+		}
+	}
+
+	var dueDate map[string]string
+	if err := json.Unmarshal(doc.Components.Schemas["Todo"].Properties["due_date"], &dueDate); err != nil {
+		t.Fatal(err)
+	}
+	if dueDate["type"] != "string" || dueDate["format"] != "date-time" {
+		t.Errorf("expected due_date as a date-time string, got %v", dueDate)
+	}
+}
+
+func TestOpenAPIPath(t *testing.T) {
+	tests := []struct {
+		route  string
+		path   string
+		params []string
+	}{
+		{route: "/", path: "/"},
+		{route: "/v1/todo/", path: "/v1/todo"},
+		{route: "/v1/todo/{todoID}/subtasks/{subtaskID}", path: "/v1/todo/{todoID}/subtasks/{subtaskID}", params: []string{"todoID", "subtaskID"}},
+		{route: "/v1/files/{name:[a-z]+}", path: "/v1/files/{name}", params: []string{"name"}},
+	}
+
+	for _, tt := range tests {
+		path, params := openAPIPath(tt.route)
+		if path != tt.path || strings.Join(params, ",") != strings.Join(tt.params, ",") {
+			t.Errorf("%s: expected %s %v, got %s %v", tt.route, tt.path, tt.params, path, params)
+		}
+	}
+}
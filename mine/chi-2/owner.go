@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"net/http"
+)
+
+const ownerCtx contextKey = "owner"
+
+// scopeMiddleware limits the /todo routes to the caller's own todos. Admins
+// can pass ?all=true to see everyone's. Without a subject in the context, as
+// when the routes are mounted without AuthMiddleware, nothing is scoped.
+func scopeMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		subject, ok := subjectFromContext(r.Context())
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		all, err := queryBool(r, "all")
+		if err != nil {
+			respondError(w, r, http.StatusBadRequest, codeBadRequest, "all must be true or false")
+			return
+		}
+		if all {
+			if !hasRole(r.Context(), roleAdmin) {
+				respondError(w, r, http.StatusForbidden, codeForbidden, "only admins can list all todos")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), ownerCtx, subject)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// storeFor returns the store scoped to the owner scopeMiddleware picked, or
+// the store itself when the request is not scoped.
+func (h *todoHandler) storeFor(r *http.Request) TodoStore {
+	owner := ownerFromContext(r.Context())
+	if owner == "" {
+		return h.store
+	}
+
+	return h.store.ForOwner(owner)
+}
+
+// ownerFromContext returns the owner scopeMiddleware picked, or "" for an
+// unscoped request.
+func ownerFromContext(ctx context.Context) string {
+	owner, _ := ctx.Value(ownerCtx).(string)
+	return owner
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestOwnership(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store TodoStore) {
+		r := newAPIRouter(t, &todoHandler{store: store}, nil, nil)
+
+		ana, bob, admin := testToken(t, "ana"), testToken(t, "bob"), testToken(t, "root", roleAdmin)
+
+		do := func(token, method, target, body string, out any) int {
+			t.Helper()
+
+			req := newJSONRequest(method, target, body)
+			req.Header.Set("Authorization", "Bearer "+token)
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			if out != nil {
+				if err := json.NewDecoder(rr.Body).Decode(out); err != nil {
+					t.Fatalf("%s %s: %v", method, target, err)
+				}
+			}
+
+			return rr.Code
+		}
+
+		list := func(token, target string) []string {
+			t.Helper()
+
+			var todos []Todo
+			if status := do(token, http.MethodGet, target, "", &todos); status != http.StatusOK {
+				t.Fatalf("GET %s: expected status %d, got %d", target, http.StatusOK, status)
+			}
+			return todoTitles(todos)
+		}
+
+		var created Todo
+		do(ana, http.MethodPost, "/v1/todo", `{"title":"ana's"}`, &created)
+		if created.OwnerID != "ana" {
+			t.Errorf("expected the todo to be owned by ana, got %q", created.OwnerID)
+		}
+		do(bob, http.MethodPost, "/v1/todo", `{"title":"bob's"}`, nil)
+		do(ana, http.MethodPost, "/v1/todo/1/subtasks", `{"title":"step"}`, nil)
+
+		if got := list(ana, "/v1/todo"); len(got) != 1 || got[0] != "ana's" {
+			t.Errorf("ana should only see her todo, got %v", got)
+		}
+		if got := list(bob, "/v1/todo"); len(got) != 1 || got[0] != "bob's" {
+			t.Errorf("bob should only see his todo, got %v", got)
+		}
+
+		// someone else's todo looks exactly like a missing one
+		for _, tt := range []struct {
+			token, method, target, body string
+		}{
+			{bob, http.MethodGet, "/v1/todo/1", ""},
+			{bob, http.MethodPut, "/v1/todo/1", `{"title":"taken","version":1}`},
+			{bob, http.MethodPatch, "/v1/todo/1/complete", `{"version":1}`},
+			{bob, http.MethodGet, "/v1/todo/1/subtasks", ""},
+			{bob, http.MethodPatch, "/v1/todo/1/subtasks/1", `{"done":true}`},
+			{bob, http.MethodDelete, "/v1/todo/1", ""},
+			{ana, http.MethodGet, "/v1/todo/2", ""},
+			{ana, http.MethodPut, "/v1/todo/2", `{"title":"taken","version":1}`},
+			{ana, http.MethodPost, "/v1/todo/2/archive", ""},
+			{ana, http.MethodDelete, "/v1/todo/2", ""},
+		} {
+			if status := do(tt.token, tt.method, tt.target, tt.body, nil); status != http.StatusNotFound {
+				t.Errorf("%s %s: expected status %d, got %d", tt.method, tt.target, http.StatusNotFound, status)
+			}
+		}
+
+		for _, target := range []string{"/v1/todo/bulk/complete", "/v1/todo/complete"} {
+			var result bulkResult
+			if status := do(bob, http.MethodPost, target, `{"ids":[1,2,99]}`, &result); status != http.StatusOK {
+				t.Fatalf("POST %s: expected status %d, got %d", target, http.StatusOK, status)
+			}
+			if !slices.Equal(result.Completed, []int64{2}) || !slices.Equal(result.Missing, []int64{1, 99}) {
+				t.Errorf("POST %s: bob should only complete his own todo, got %+v", target, result)
+			}
+		}
+
+		var todo Todo
+		if status := do(ana, http.MethodGet, "/v1/todo/1", "", &todo); status != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, status)
+		}
+		if todo.Title != "ana's" || todo.Done || todo.SubtaskCounts.Total != 1 {
+			t.Errorf("bob's requests changed ana's todo: %+v", todo)
+		}
+
+		if status := do(bob, http.MethodGet, "/v1/todo?all=true", "", nil); status != http.StatusForbidden {
+			t.Errorf("expected status %d for a non admin, got %d", http.StatusForbidden, status)
+		}
+		if status := do(admin, http.MethodGet, "/v1/todo?all=maybe", "", nil); status != http.StatusBadRequest {
+			t.Errorf("expected status %d, got %d", http.StatusBadRequest, status)
+		}
+		if got := list(admin, "/v1/todo"); len(got) != 0 {
+			t.Errorf("an admin without all=true should see only their own todos, got %v", got)
+		}
+		if got := list(admin, "/v1/todo?all=true"); len(got) != 2 {
+			t.Errorf("expected all todos for an admin, got %v", got)
+		}
+		if status := do(admin, http.MethodGet, "/v1/todo/1?all=true", "", nil); status != http.StatusOK {
+			t.Errorf("expected status %d, got %d", http.StatusOK, status)
+		}
+
+		// the trash and search are scoped the same way
+		if got := list(bob, "/v1/todo/search?q=ana"); len(got) != 0 {
+			t.Errorf("bob's search should not find ana's todo, got %v", got)
+		}
+		if status := do(ana, http.MethodDelete, "/v1/todo/1", "", nil); status != http.StatusNoContent {
+			t.Fatalf("expected status %d, got %d", http.StatusNoContent, status)
+		}
+		if got := list(bob, "/v1/todo/trash"); len(got) != 0 {
+			t.Errorf("bob's trash should not hold ana's todo, got %v", got)
+		}
+		if status := do(bob, http.MethodPost, "/v1/todo/1/restore", "", nil); status != http.StatusNotFound {
+			t.Errorf("bob restoring ana's todo: expected status %d, got %d", http.StatusNotFound, status)
+		}
+		if got := list(ana, "/v1/todo/trash"); len(got) != 1 || got[0] != "ana's" {
+			t.Errorf("expected ana's todo in her trash, got %v", got)
+		}
+	})
+}
+
+func TestMemoryStoreForOwner(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryTodoStore()
+	ana, bob := store.ForOwner("ana"), store.ForOwner("bob")
+
+	for _, tt := range []struct {
+		store TodoStore
+		todo  Todo
+	}{
+		{ana, Todo{Title: "a", Tags: []string{"home"}}},
+		{bob, Todo{Title: "b", Tags: []string{"home", "work"}}},
+	} {
+		if err := tt.store.Create(ctx, &tt.todo); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := bob.Get(ctx, 1); err != ErrTodoNotFound {
+		t.Errorf("expected ErrTodoNotFound, got %v", err)
+	}
+	if _, err := ana.Get(ctx, 1); err != nil {
+		t.Errorf("expected ana to get her todo, got %v", err)
+	}
+
+	counts, err := ana.TagCounts(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(counts) != 1 || counts[0] != (TagCount{Tag: "home", Count: 1}) {
+		t.Errorf("expected only ana's tags, got %+v", counts)
+	}
+
+	todos, total, err := store.List(ctx, ListQuery{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 2 || len(todos) != 2 {
+		t.Errorf("the unscoped store should list every todo, got %d", total)
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"os"
+	"strconv"
+)
+
+// pageSizeConfig bounds the todo lists: Default is the window of a request
+// without per_page or limit, Max the largest one it may ask for.
+type pageSizeConfig struct {
+	Default int
+	Max     int
+}
+
+// pageSizes is read from the environment by main; the fallbacks keep it usable
+// in tests.
+var pageSizes = pageSizeConfig{Default: 20, Max: 100}
+
+// pageSizesFromEnv reads DEFAULT_PAGE_SIZE and MAX_PAGE_SIZE, the same
+// variables as the root module's internal/pagesize. Missing, malformed and
+// non-positive values fall back, and a default above the max is lowered to it.
+func pageSizesFromEnv() pageSizeConfig {
+	cfg := pageSizeConfig{
+		Default: positiveEnv("DEFAULT_PAGE_SIZE", 20),
+		Max:     positiveEnv("MAX_PAGE_SIZE", 100),
+	}
+	cfg.Default = min(cfg.Default, cfg.Max)
+
+	return cfg
+}
+
+func positiveEnv(key string, fallback int) int {
+	n, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || n < 1 {
+		return fallback
+	}
+
+	return n
+}
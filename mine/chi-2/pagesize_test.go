@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPageSizesFromEnv(t *testing.T) {
+	t.Setenv("DEFAULT_PAGE_SIZE", "5")
+	t.Setenv("MAX_PAGE_SIZE", "8")
+
+	original := pageSizes
+	t.Cleanup(func() { pageSizes = original })
+	pageSizes = pageSizesFromEnv()
+
+	if pageSizes != (pageSizeConfig{Default: 5, Max: 8}) {
+		t.Fatalf("unexpected page sizes %+v", pageSizes)
+	}
+
+	store := newMemoryTodoStore()
+	for i := 1; i <= 20; i++ {
+		if err := store.Create(context.Background(), &Todo{Title: fmt.Sprintf("todo %d", i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r := newTodoRouter(t, store)
+
+	for target, want := range map[string]int{
+		"/todo":               5,
+		"/todo?per_page=50":   8,
+		"/todo?limit=7":       7,
+		"/todo/search?q=todo": 5,
+	} {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, target, nil))
+
+		var todos []Todo
+		if err := json.NewDecoder(rr.Body).Decode(&todos); err != nil {
+			t.Fatal(err)
+		}
+		if len(todos) != want {
+			t.Errorf("%s: expected %d todos, got %d", target, want, len(todos))
+		}
+	}
+}
+
+func TestPageSizesFallback(t *testing.T) {
+	t.Setenv("DEFAULT_PAGE_SIZE", "200")
+	t.Setenv("MAX_PAGE_SIZE", "zero")
+
+	if got := pageSizesFromEnv(); got != (pageSizeConfig{Default: 100, Max: 100}) {
+		t.Errorf("expected the default lowered to the fallback max, got %+v", got)
+	}
+}
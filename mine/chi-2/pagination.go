@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// pagination is the window a list request asked for. Clients either page
+// with ?page=&per_page= (the default) or slice with ?limit=&offset=; the Link
+// header answers in the same style the request used.
+type pagination struct {
+	offset int
+	limit  int
+	// byPage is false when the request used limit and offset.
+	byPage bool
+	page   int
+}
+
+// parsePagination is paginationFromQuery for the endpoints that take nothing
+// but a window, the error is a ParamErrors.
+func parsePagination(r *http.Request) (pagination, error) {
+	page, errs := paginationFromQuery(r.URL.Query())
+
+	return page, errs.err()
+}
+
+func paginationFromQuery(q url.Values) (pagination, ParamErrors) {
+	var errs ParamErrors
+
+	usesPages := q.Has("page") || q.Has("per_page")
+	usesOffset := q.Has("limit") || q.Has("offset")
+	if usesPages && usesOffset {
+		errs.add("page", "use either page and per_page or limit and offset, not both")
+		return pagination{}, errs
+	}
+
+	if usesOffset {
+		limit := intParam(q, "limit", pageSizes.Default, 1, "must be a positive integer", &errs)
+		offset := intParam(q, "offset", 0, 0, "must be a non-negative integer", &errs)
+
+		return pagination{offset: offset, limit: min(limit, pageSizes.Max)}, errs
+	}
+
+	perPage := min(intParam(q, "per_page", pageSizes.Default, 1, "must be a positive integer", &errs), pageSizes.Max)
+	page := intParam(q, "page", 1, 1, "must be a positive integer", &errs)
+	// the offset of a page this far out doesn't fit in an int
+	if page-1 > math.MaxInt/perPage {
+		errs.add("page", "is too large")
+		page = 1
+	}
+
+	return pagination{offset: (page - 1) * perPage, limit: perPage, byPage: true, page: page}, errs
+}
+
+// setHeaders writes X-Total-Count and the Link header of a page of total
+// items.
+func (p pagination) setHeaders(w http.ResponseWriter, u *url.URL, total int) {
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	w.Header().Set("Link", p.links(u, total))
+}
+
+// links builds the RFC 5988 Link header for a result of total items, keeping
+// every other query parameter of u (like filters) untouched. next is left out
+// on the last page and past the end, prev on the first one.
+func (p pagination) links(u *url.URL, total int) string {
+	lastPage := max((total+p.limit-1)/p.limit, 1)
+
+	if !p.byPage {
+		links := []string{p.offsetLink(u, 0, "first")}
+		if p.offset > 0 {
+			links = append(links, p.offsetLink(u, max(min(p.offset-p.limit, (lastPage-1)*p.limit), 0), "prev"))
+		}
+		// offset can be anything up to math.MaxInt, adding the limit to it
+		// could wrap around
+		if p.offset < total-p.limit {
+			links = append(links, p.offsetLink(u, p.offset+p.limit, "next"))
+		}
+		links = append(links, p.offsetLink(u, (lastPage-1)*p.limit, "last"))
+
+		return strings.Join(links, ", ")
+	}
+
+	links := []string{p.pageLink(u, 1, "first")}
+	if p.page > 1 {
+		links = append(links, p.pageLink(u, min(p.page-1, lastPage), "prev"))
+	}
+	if p.page < lastPage {
+		links = append(links, p.pageLink(u, p.page+1, "next"))
+	}
+	links = append(links, p.pageLink(u, lastPage, "last"))
+
+	return strings.Join(links, ", ")
+}
+
+func (p pagination) pageLink(u *url.URL, page int, rel string) string {
+	q := u.Query()
+	q.Set("page", strconv.Itoa(page))
+	q.Set("per_page", strconv.Itoa(p.limit))
+
+	return link(u, q, rel)
+}
+
+func (p pagination) offsetLink(u *url.URL, offset int, rel string) string {
+	q := u.Query()
+	q.Set("limit", strconv.Itoa(p.limit))
+	q.Set("offset", strconv.Itoa(offset))
+
+	return link(u, q, rel)
+}
+
+func link(u *url.URL, q url.Values, rel string) string {
+	target := url.URL{Path: u.Path, RawQuery: q.Encode()}
+
+	return fmt.Sprintf(`<%s>; rel="%s"`, target.String(), rel)
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+)
+
+var linkPattern = regexp.MustCompile(`<([^>]*)>; rel="([a-z]+)"`)
+
+// parseLinks maps each rel of a Link header to its target.
+func parseLinks(header string) map[string]string {
+	links := map[string]string{}
+	for _, match := range linkPattern.FindAllStringSubmatch(header, -1) {
+		links[match[2]] = match[1]
+	}
+
+	return links
+}
+
+func TestListTodosPages(t *testing.T) {
+	store := newMemoryTodoStore()
+	for i := 1; i <= 60; i++ {
+		todo := &Todo{Title: fmt.Sprintf("todo %d", i), Done: i%2 == 0}
+		if err := store.Create(context.Background(), todo); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r := newTodoRouter(t, store)
+
+	get := func(target string) (*httptest.ResponseRecorder, []Todo) {
+		t.Helper()
+
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, target, nil))
+
+		var todos []Todo
+		if rr.Code == http.StatusOK {
+			if err := json.NewDecoder(rr.Body).Decode(&todos); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return rr, todos
+	}
+
+	t.Run("follow next links", func(t *testing.T) {
+		// the done filter must survive in every link
+		target := "/todo?done=false&per_page=7"
+		var seen, pages int
+
+		for target != "" {
+			rr, todos := get(target)
+			if rr.Code != http.StatusOK {
+				t.Fatalf("%s: expected status %d, got %d", target, http.StatusOK, rr.Code)
+			}
+
+			for _, todo := range todos {
+				if todo.Done {
+					t.Fatalf("%s: filter was lost, got done todo %d", target, todo.ID)
+				}
+			}
+
+			if got := rr.Header().Get("X-Total-Count"); got != "30" {
+				t.Fatalf("expected X-Total-Count 30, got %q", got)
+			}
+
+			links := parseLinks(rr.Header().Get("Link"))
+			if links["first"] != "/todo?done=false&page=1&per_page=7" || links["last"] != "/todo?done=false&page=5&per_page=7" {
+				t.Fatalf("unexpected first/last links %v", links)
+			}
+
+			seen += len(todos)
+			pages++
+			target = links["next"]
+		}
+
+		if seen != 30 || pages != 5 {
+			t.Errorf("expected 30 todos over 5 pages, got %d over %d", seen, pages)
+		}
+	})
+
+	t.Run("middle page", func(t *testing.T) {
+		rr, todos := get("/todo?page=2&per_page=25")
+
+		if len(todos) != 25 || todos[0].ID != 26 {
+			t.Fatalf("unexpected page %d..", todos[0].ID)
+		}
+
+		links := parseLinks(rr.Header().Get("Link"))
+		want := map[string]string{
+			"first": "/todo?page=1&per_page=25",
+			"prev":  "/todo?page=1&per_page=25",
+			"next":  "/todo?page=3&per_page=25",
+			"last":  "/todo?page=3&per_page=25",
+		}
+		for rel, target := range want {
+			if links[rel] != target {
+				t.Errorf("expected %s link %q, got %q", rel, target, links[rel])
+			}
+		}
+	})
+
+	t.Run("past the end", func(t *testing.T) {
+		rr, todos := get("/todo?page=9&per_page=25")
+
+		if rr.Code != http.StatusOK || len(todos) != 0 {
+			t.Fatalf("expected an empty page, got status %d and %d todos", rr.Code, len(todos))
+		}
+
+		links := parseLinks(rr.Header().Get("Link"))
+		if _, ok := links["next"]; ok {
+			t.Errorf("expected no next link past the end, got %v", links)
+		}
+		if links["prev"] != "/todo?page=3&per_page=25" || links["last"] != "/todo?page=3&per_page=25" {
+			t.Errorf("expected prev and last to point at page 3, got %v", links)
+		}
+	})
+
+	t.Run("per_page is capped", func(t *testing.T) {
+		_, todos := get("/todo?per_page=500")
+
+		if len(todos) != 60 {
+			t.Errorf("expected all 60 todos, got %d", len(todos))
+		}
+	})
+
+	for _, query := range []string{"page=0", "page=abc", "per_page=0", "page=2&offset=10", "page=922337203685477581&per_page=100"} {
+		t.Run(query, func(t *testing.T) {
+			if rr, _ := get("/todo?" + query); rr.Code != http.StatusBadRequest {
+				t.Errorf("expected status %d, got %d", http.StatusBadRequest, rr.Code)
+			}
+		})
+	}
+}
+
+func TestListTodosEmptyStoreLinks(t *testing.T) {
+	r := newTodoRouter(t, newMemoryTodoStore())
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/todo", nil))
+
+	want := `</todo?page=1&per_page=20>; rel="first", </todo?page=1&per_page=20>; rel="last"`
+	if got := rr.Header().Get("Link"); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestMemoryListNegativeOffset(t *testing.T) {
+	store := newMemoryTodoStore()
+	if err := store.Create(context.Background(), &Todo{Title: "buy milk"}); err != nil {
+		t.Fatal(err)
+	}
+
+	todos, total, err := store.List(context.Background(), ListQuery{Offset: -80, Limit: 10})
+	if err != nil || total != 1 || len(todos) != 1 {
+		t.Errorf("expected the one todo from the start, got %d of %d (%v)", len(todos), total, err)
+	}
+}
+
+func TestListTodosHugeOffsetLinks(t *testing.T) {
+	store := newMemoryTodoStore()
+	if err := store.Create(context.Background(), &Todo{Title: "buy milk"}); err != nil {
+		t.Fatal(err)
+	}
+	r := newTodoRouter(t, store)
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/todo?offset=9223372036854775807", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	links := parseLinks(rr.Header().Get("Link"))
+	if next, ok := links["next"]; ok {
+		t.Errorf("expected no next link past the end, got %q", next)
+	}
+	if links["prev"] != "/todo?limit=20&offset=0" {
+		t.Errorf("expected prev to point at the last page, got %q", links["prev"])
+	}
+}
@@ -0,0 +1,986 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// postgresMigrations are applied in order on startup. Every statement must be
+// safe to run against an already migrated database.
+var postgresMigrations = []string{
+	`CREATE TABLE IF NOT EXISTS todos (
+		id BIGSERIAL PRIMARY KEY,
+		title TEXT NOT NULL,
+		done BOOLEAN NOT NULL DEFAULT FALSE,
+		created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW()
+	)`,
+	`ALTER TABLE todos ADD COLUMN IF NOT EXISTS due_date TIMESTAMP(0) WITH TIME ZONE`,
+	`ALTER TABLE todos ADD COLUMN IF NOT EXISTS completed_at TIMESTAMP(0) WITH TIME ZONE`,
+	`ALTER TABLE todos ADD COLUMN IF NOT EXISTS priority TEXT NOT NULL DEFAULT 'medium'`,
+	`ALTER TABLE todos ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}'`,
+	`CREATE TABLE IF NOT EXISTS subtasks (
+		id BIGSERIAL PRIMARY KEY,
+		todo_id BIGINT NOT NULL REFERENCES todos (id) ON DELETE CASCADE,
+		title TEXT NOT NULL,
+		done BOOLEAN NOT NULL DEFAULT FALSE,
+		created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW()
+	)`,
+	`CREATE INDEX IF NOT EXISTS subtasks_todo_id_idx ON subtasks (todo_id)`,
+	`ALTER TABLE todos ADD COLUMN IF NOT EXISTS archived BOOLEAN NOT NULL DEFAULT FALSE`,
+	`ALTER TABLE todos ADD COLUMN IF NOT EXISTS archived_at TIMESTAMP(0) WITH TIME ZONE`,
+	`ALTER TABLE todos ADD COLUMN IF NOT EXISTS owner_id TEXT NOT NULL DEFAULT ''`,
+	`CREATE INDEX IF NOT EXISTS todos_owner_id_idx ON todos (owner_id)`,
+	`ALTER TABLE todos ADD COLUMN IF NOT EXISTS recurrence TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE todos ADD COLUMN IF NOT EXISTS series_id BIGINT NOT NULL DEFAULT 0`,
+	`CREATE INDEX IF NOT EXISTS todos_series_id_idx ON todos (series_id) WHERE series_id <> 0`,
+	`ALTER TABLE todos ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP(0) WITH TIME ZONE`,
+	`CREATE INDEX IF NOT EXISTS todos_deleted_at_idx ON todos (deleted_at) WHERE deleted_at IS NOT NULL`,
+	`ALTER TABLE todos ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1`,
+	`ALTER TABLE todos ADD COLUMN IF NOT EXISTS client_id TEXT`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS todos_owner_client_id_idx ON todos (owner_id, client_id) WHERE client_id IS NOT NULL`,
+	`CREATE TABLE IF NOT EXISTS comments (
+		id BIGSERIAL PRIMARY KEY,
+		todo_id BIGINT NOT NULL REFERENCES todos (id) ON DELETE CASCADE,
+		author_id TEXT NOT NULL DEFAULT '',
+		body TEXT NOT NULL,
+		created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW()
+	)`,
+	`CREATE INDEX IF NOT EXISTS comments_todo_id_idx ON comments (todo_id)`,
+	`CREATE TABLE IF NOT EXISTS lists (
+		id BIGSERIAL PRIMARY KEY,
+		name TEXT NOT NULL,
+		owner_id TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW()
+	)`,
+	`CREATE INDEX IF NOT EXISTS lists_owner_id_idx ON lists (owner_id)`,
+	`ALTER TABLE todos ADD COLUMN IF NOT EXISTS list_id BIGINT REFERENCES lists (id) ON DELETE CASCADE`,
+	`CREATE INDEX IF NOT EXISTS todos_list_id_idx ON todos (list_id) WHERE list_id IS NOT NULL`,
+}
+
+const todoColumns = `id, title, done, priority, tags, due_date, created_at, completed_at, owner_id, archived, archived_at, recurrence, series_id, deleted_at, version, COALESCE(client_id, ''), COALESCE(list_id, 0),
+	(SELECT COUNT(*) FROM subtasks WHERE subtasks.todo_id = todos.id),
+	(SELECT COUNT(*) FROM subtasks WHERE subtasks.todo_id = todos.id AND subtasks.done),
+	(SELECT COUNT(*) FROM comments WHERE comments.todo_id = todos.id)`
+
+const subtaskColumns = `id, todo_id, title, done, created_at`
+
+const commentColumns = `id, todo_id, author_id, body, created_at`
+
+const listColumns = `id, name, owner_id, created_at`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanTodo(row rowScanner, todo *Todo) error {
+	var (
+		tags       pq.StringArray
+		recurrence string
+	)
+	if err := row.Scan(&todo.ID, &todo.Title, &todo.Done, &todo.Priority, &tags, &todo.DueDate, &todo.CreatedAt, &todo.CompletedAt, &todo.OwnerID, &todo.Archived, &todo.ArchivedAt, &recurrence, &todo.SeriesID, &todo.DeletedAt, &todo.Version, &todo.ClientID, &todo.ListID, &todo.SubtaskCounts.Total, &todo.SubtaskCounts.Done, &todo.CommentCount); err != nil {
+		return err
+	}
+
+	var err error
+	if todo.Recurrence, err = scanRecurrence(recurrence); err != nil {
+		return fmt.Errorf("failed to decode recurrence of todo %d: %w", todo.ID, err)
+	}
+
+	todo.Tags = nil
+	if len(tags) > 0 {
+		todo.Tags = tags
+	}
+
+	return nil
+}
+
+// tagsColumn stores a todo without tags as an empty array, the column is NOT
+// NULL.
+func tagsColumn(tags []string) pq.StringArray {
+	if tags == nil {
+		return pq.StringArray{}
+	}
+
+	return pq.StringArray(tags)
+}
+
+// tagsParam keeps a nil slice as NULL so "no tag filter" can be told apart
+// from an empty one.
+func tagsParam(tags []string) any {
+	if tags == nil {
+		return nil
+	}
+
+	return pq.StringArray(tags)
+}
+
+// inTx runs fn in a transaction that is committed when fn returns nil and
+// rolled back otherwise.
+func inTx(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+type postgresTodoStore struct {
+	db *sql.DB
+	// owner, when set, limits every statement to that owner's todos.
+	owner string
+}
+
+func (s *postgresTodoStore) ForOwner(owner string) TodoStore {
+	return &postgresTodoStore{db: s.db, owner: owner}
+}
+
+// Ping backs the store check of /healthz.
+func (s *postgresTodoStore) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// ownedBy is the SQL version of memoryTodoStore.owns, param holds the owner.
+func ownedBy(param string) string {
+	return `(` + param + ` = '' OR owner_id = ` + param + `)`
+}
+
+// visibleTo is the SQL version of memoryTodoStore.sees, ownedBy without the
+// trashed todos.
+func visibleTo(param string) string {
+	return ownedBy(param) + ` AND deleted_at IS NULL`
+}
+
+func newPostgresTodoStore(ctx context.Context, db *sql.DB) (*postgresTodoStore, error) {
+	for i, migration := range postgresMigrations {
+		if _, err := db.ExecContext(ctx, migration); err != nil {
+			return nil, fmt.Errorf("failed to apply migration %d: %w", i+1, err)
+		}
+	}
+
+	return &postgresTodoStore{db: db}, nil
+}
+
+func (s *postgresTodoStore) Create(ctx context.Context, todo *Todo) error {
+	if todo.Priority == "" {
+		todo.Priority = defaultPriority
+	}
+	if s.owner != "" {
+		todo.OwnerID = s.owner
+	}
+
+	return inTx(ctx, s.db, func(tx *sql.Tx) error {
+		if todo.ListID != 0 {
+			if err := s.listExists(ctx, tx, todo.ListID); err != nil {
+				return err
+			}
+		}
+
+		return s.insert(ctx, tx, todo, "")
+	})
+}
+
+// insert is the body of Create. onConflict goes between the values and
+// RETURNING, when it skips the insert the error is sql.ErrNoRows.
+func (s *postgresTodoStore) insert(ctx context.Context, tx *sql.Tx, todo *Todo, onConflict string) error {
+	query := `
+		INSERT INTO todos (title, done, due_date, priority, tags, owner_id, recurrence, completed_at, client_id, list_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, CASE WHEN $2 THEN NOW() END, NULLIF($8, ''), NULLIF($9, 0))
+		` + onConflict + `
+		RETURNING ` + todoColumns
+
+	if err := scanTodo(tx.QueryRowContext(ctx, query, todo.Title, todo.Done, todo.DueDate, todo.Priority, tagsColumn(todo.Tags), todo.OwnerID, recurrenceColumn(todo.Recurrence), todo.ClientID, todo.ListID), todo); err != nil {
+		return err
+	}
+
+	if todo.Recurrence == nil {
+		return nil
+	}
+
+	// the first occurrence names the series, its id is only known now
+	return scanTodo(tx.QueryRowContext(ctx, `UPDATE todos SET series_id = id WHERE id = $1 RETURNING `+todoColumns, todo.ID), todo)
+}
+
+func (s *postgresTodoStore) Get(ctx context.Context, id int64) (Todo, error) {
+	query := `SELECT ` + todoColumns + ` FROM todos WHERE id = $1 AND ` + visibleTo("$2")
+
+	var todo Todo
+	err := scanTodo(s.db.QueryRowContext(ctx, query, id, s.owner), &todo)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Todo{}, ErrTodoNotFound
+	}
+
+	return todo, err
+}
+
+func (s *postgresTodoStore) Update(ctx context.Context, todo *Todo) error {
+	return inTx(ctx, s.db, func(tx *sql.Tx) error {
+		return s.update(ctx, tx, todo)
+	})
+}
+
+// update is the body of Update.
+func (s *postgresTodoStore) update(ctx context.Context, tx *sql.Tx, todo *Todo) error {
+	// a todo keeps its series once it had a rule, even if the rule goes
+	query := `
+		UPDATE todos
+		SET title = $2, done = $3, due_date = $4, priority = $5, tags = $6, completed_at = ` + completedAtUpdate("$3") + `,
+			recurrence = $8, series_id = CASE WHEN series_id = 0 AND $8 <> '' THEN id ELSE series_id END,
+			version = version + 1
+		WHERE id = $1 AND NOT archived AND ($9 = 0 OR version = $9) AND ` + visibleTo("$7") + `
+		RETURNING ` + todoColumns
+
+	err := scanTodo(tx.QueryRowContext(ctx, query, todo.ID, todo.Title, todo.Done, todo.DueDate, todo.Priority, tagsColumn(todo.Tags), s.owner, recurrenceColumn(todo.Recurrence), todo.Version), todo)
+	if err == nil {
+		todo.next, err = s.recur(ctx, tx, *todo)
+		return err
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	// no row was updated, tell a missing todo from a newer or an archived
+	// one
+	var current Todo
+	err = scanTodo(tx.QueryRowContext(ctx, `SELECT `+todoColumns+` FROM todos WHERE id = $1 AND `+visibleTo("$2"), todo.ID, s.owner), &current)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return ErrTodoNotFound
+	case err != nil:
+		return err
+	case todo.Version != 0 && current.Version != todo.Version:
+		return &ErrVersionConflict{Current: current}
+	default:
+		return ErrTodoArchived
+	}
+}
+
+func (s *postgresTodoStore) PutByClientID(ctx context.Context, todo *Todo) (PutResult, error) {
+	if todo.Priority == "" {
+		todo.Priority = defaultPriority
+	}
+	todo.OwnerID = s.owner
+
+	result := PutCreated
+	err := inTx(ctx, s.db, func(tx *sql.Tx) error {
+		// the unique index on the client id makes a concurrent first PUT
+		// skip the insert instead of creating a second todo
+		err := s.insert(ctx, tx, todo, `ON CONFLICT (owner_id, client_id) WHERE client_id IS NOT NULL DO NOTHING`)
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		// trashed todos included, a client id stays taken until the todo is
+		// purged
+		var stored Todo
+		if err := scanTodo(tx.QueryRowContext(ctx, `SELECT `+todoColumns+` FROM todos WHERE owner_id = $1 AND client_id = $2`, s.owner, todo.ClientID), &stored); err != nil {
+			return err
+		}
+		switch {
+		case stored.DeletedAt != nil:
+			return ErrTodoNotFound
+		case stored.sameContent(todo):
+			result, *todo = PutUnchanged, stored
+			return nil
+		}
+
+		result = PutUpdated
+		todo.ID, todo.Version = stored.ID, 0
+		return s.update(ctx, tx, todo)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return result, nil
+}
+
+func (s *postgresTodoStore) SetDone(ctx context.Context, id int64, done bool, version int) (Todo, error) {
+	query := `
+		UPDATE todos
+		SET done = $2, completed_at = ` + completedAtUpdate("$2") + `, version = version + 1
+		WHERE id = $1 AND ($4 = 0 OR version = $4) AND ` + visibleTo("$3") + `
+		RETURNING ` + todoColumns
+
+	var todo Todo
+	err := inTx(ctx, s.db, func(tx *sql.Tx) error {
+		err := scanTodo(tx.QueryRowContext(ctx, query, id, done, s.owner, version), &todo)
+		if err == nil {
+			todo.next, err = s.recur(ctx, tx, todo)
+			return err
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		// no row was updated, the todo is either missing or newer
+		var current Todo
+		if err := scanTodo(tx.QueryRowContext(ctx, `SELECT `+todoColumns+` FROM todos WHERE id = $1 AND `+visibleTo("$2"), id, s.owner), &current); err != nil {
+			return err
+		}
+
+		return &ErrVersionConflict{Current: current}
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return Todo{}, ErrTodoNotFound
+	}
+
+	return todo, err
+}
+
+func (s *postgresTodoStore) CompleteMany(ctx context.Context, ids []int64) ([]int64, []int64, []Todo, error) {
+	// a single statement, so either every row is updated or none is; the
+	// next occurrences are added in the same transaction
+	query := `
+		UPDATE todos
+		SET done = TRUE, completed_at = ` + completedAtUpdate("TRUE") + `, version = version + 1
+		WHERE id = ANY($1) AND ` + visibleTo("$2") + `
+		RETURNING ` + todoColumns
+
+	found := make(map[int64]bool, len(ids))
+	spawned := []Todo{}
+	err := inTx(ctx, s.db, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, query, pq.Int64Array(ids), s.owner)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		var todos []Todo
+		for rows.Next() {
+			var todo Todo
+			if err := scanTodo(rows, &todo); err != nil {
+				return err
+			}
+
+			found[todo.ID] = true
+			todos = append(todos, todo)
+		}
+
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		rows.Close()
+
+		for _, todo := range todos {
+			next, err := s.recur(ctx, tx, todo)
+			if err != nil {
+				return err
+			}
+			if next != nil {
+				spawned = append(spawned, *next)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	completed, missing := []int64{}, []int64{}
+	for _, id := range ids {
+		if found[id] {
+			completed = append(completed, id)
+		} else {
+			missing = append(missing, id)
+		}
+	}
+
+	return completed, missing, spawned, nil
+}
+
+func (s *postgresTodoStore) SetArchived(ctx context.Context, id int64, archived bool) (Todo, error) {
+	query := `
+		UPDATE todos
+		SET archived = $2, archived_at = CASE WHEN archived = $2 THEN archived_at WHEN $2 THEN NOW() END, version = version + 1
+		WHERE id = $1 AND ` + visibleTo("$3") + `
+		RETURNING ` + todoColumns
+
+	var todo Todo
+	err := scanTodo(s.db.QueryRowContext(ctx, query, id, archived, s.owner), &todo)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Todo{}, ErrTodoNotFound
+	}
+
+	return todo, err
+}
+
+// recur inserts the next occurrence of todo, see memoryTodoStore.recur.
+func (s *postgresTodoStore) recur(ctx context.Context, tx *sql.Tx, todo Todo) (*Todo, error) {
+	if !todo.Done || todo.Recurrence == nil || todo.DueDate == nil {
+		return nil, nil
+	}
+
+	query := `
+		INSERT INTO todos (title, priority, tags, owner_id, due_date, recurrence, series_id, list_id)
+		SELECT title, priority, tags, owner_id, $2, recurrence, series_id, list_id
+		FROM todos AS current
+		WHERE id = $1 AND NOT EXISTS (
+			SELECT 1 FROM todos AS later
+			WHERE later.series_id = current.series_id AND later.due_date > current.due_date
+		)
+		RETURNING ` + todoColumns
+
+	var next Todo
+	err := scanTodo(tx.QueryRowContext(ctx, query, todo.ID, todo.Recurrence.Next(*todo.DueDate)), &next)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &next, nil
+}
+
+// completedAtUpdate mirrors Todo.setDone for the done value in param: the
+// column only changes when done does, since SET sees the old row values.
+func completedAtUpdate(param string) string {
+	return `CASE WHEN done = ` + param + ` THEN completed_at WHEN ` + param + ` THEN NOW() END`
+}
+
+func (s *postgresTodoStore) Delete(ctx context.Context, id int64) error {
+	result, err := s.db.ExecContext(ctx, `UPDATE todos SET deleted_at = NOW(), version = version + 1 WHERE id = $1 AND `+visibleTo("$2"), id, s.owner)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrTodoNotFound
+	}
+
+	return nil
+}
+
+func (s *postgresTodoStore) ListTrash(ctx context.Context, q TrashQuery) ([]Todo, int, error) {
+	where := `WHERE deleted_at IS NOT NULL AND ` + ownedBy("$1")
+
+	var total int
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM todos `+where, s.owner).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `
+		SELECT ` + todoColumns + `
+		FROM todos
+		` + where + `
+		ORDER BY deleted_at DESC, id DESC
+		LIMIT $2 OFFSET $3
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, s.owner, q.Limit, q.Offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	todos := []Todo{}
+	for rows.Next() {
+		var todo Todo
+		if err := scanTodo(rows, &todo); err != nil {
+			return nil, 0, err
+		}
+
+		todos = append(todos, todo)
+	}
+
+	return todos, total, rows.Err()
+}
+
+func (s *postgresTodoStore) Restore(ctx context.Context, id int64) (Todo, error) {
+	query := `
+		UPDATE todos
+		SET deleted_at = NULL, version = version + 1
+		WHERE id = $1 AND deleted_at IS NOT NULL AND ` + ownedBy("$2") + `
+		RETURNING ` + todoColumns
+
+	var todo Todo
+	err := scanTodo(s.db.QueryRowContext(ctx, query, id, s.owner), &todo)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Todo{}, ErrTodoNotFound
+	}
+
+	return todo, err
+}
+
+// PurgeTrash leaves the subtasks to ON DELETE CASCADE.
+func (s *postgresTodoStore) PurgeTrash(ctx context.Context, cutoff time.Time) (int, error) {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM todos WHERE deleted_at < $1 AND `+ownedBy("$2"), cutoff, s.owner)
+	if err != nil {
+		return 0, err
+	}
+
+	purged, err := result.RowsAffected()
+	return int(purged), err
+}
+
+func (s *postgresTodoStore) List(ctx context.Context, q ListQuery) ([]Todo, int, error) {
+	where := `
+		WHERE ($1 = FALSE OR (done = FALSE AND due_date < $2))
+		AND ($3::BOOLEAN IS NULL OR done = $3)
+		AND ($4 = '' OR priority = $4)
+		AND ($5::TEXT[] IS NULL OR tags && $5)
+		AND ($6::BOOLEAN IS NULL OR archived = $6)
+		AND ` + visibleTo("$7") + `
+		AND ($8::BIGINT = 0 OR series_id = $8)
+		AND ($9::BIGINT = 0 OR list_id = $9)
+		AND ($10::TIMESTAMPTZ IS NULL OR due_date >= $10)
+		AND ($11::TIMESTAMPTZ IS NULL OR due_date <= $11)
+	`
+	args := []any{q.Overdue, q.Now, q.Done, q.Priority, tagsParam(q.Tags), q.Archived, s.owner, q.Series, q.ListID, q.DueAfter, q.DueBefore}
+
+	var total int
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM todos `+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `
+		SELECT ` + todoColumns + `
+		FROM todos
+		` + where + `
+		ORDER BY ` + sqlOrderBy(q) + `
+		LIMIT $12 OFFSET $13
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, append(args, q.Limit, q.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	todos := []Todo{}
+	for rows.Next() {
+		var todo Todo
+		if err := scanTodo(rows, &todo); err != nil {
+			return nil, 0, err
+		}
+
+		todos = append(todos, todo)
+	}
+
+	return todos, total, rows.Err()
+}
+
+// postgresSearchScore mirrors searchScore, $1 is the search text with LIKE
+// wildcards escaped.
+const postgresSearchScore = `CASE
+		WHEN title ILIKE $1 || '%' THEN 3
+		WHEN title ILIKE '%' || $1 || '%' THEN 2
+		WHEN EXISTS (SELECT 1 FROM UNNEST(tags) AS tag WHERE tag ILIKE '%' || $1 || '%') THEN 1
+		ELSE 0
+	END`
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func (s *postgresTodoStore) Search(ctx context.Context, q SearchQuery) ([]Todo, int, error) {
+	text := likeEscaper.Replace(q.Text)
+
+	var total int
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM todos WHERE `+postgresSearchScore+` > 0 AND `+visibleTo("$2"), text, s.owner).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `
+		SELECT ` + todoColumns + `
+		FROM todos
+		WHERE ` + postgresSearchScore + ` > 0 AND ` + visibleTo("$2") + `
+		ORDER BY ` + postgresSearchScore + ` DESC, id
+		LIMIT $3 OFFSET $4
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, text, s.owner, q.Limit, q.Offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	todos := []Todo{}
+	for rows.Next() {
+		var todo Todo
+		if err := scanTodo(rows, &todo); err != nil {
+			return nil, 0, err
+		}
+
+		todos = append(todos, todo)
+	}
+
+	return todos, total, rows.Err()
+}
+
+func (s *postgresTodoStore) TagCounts(ctx context.Context) ([]TagCount, error) {
+	query := `
+		SELECT tag, COUNT(*)
+		FROM todos, UNNEST(tags) AS tag
+		WHERE ` + visibleTo("$1") + `
+		GROUP BY tag
+		ORDER BY COUNT(*) DESC, tag
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, s.owner)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := []TagCount{}
+	for rows.Next() {
+		var count TagCount
+		if err := rows.Scan(&count.Tag, &count.Count); err != nil {
+			return nil, err
+		}
+
+		counts = append(counts, count)
+	}
+
+	return counts, rows.Err()
+}
+
+func (s *postgresTodoStore) Stats(ctx context.Context, now time.Time) (TodoStats, error) {
+	query := `
+		SELECT COUNT(*), COUNT(*) FILTER (WHERE done), COUNT(*) FILTER (WHERE NOT done AND due_date < $2)
+		FROM todos
+		WHERE ` + visibleTo("$1")
+
+	var stats TodoStats
+	if err := s.db.QueryRowContext(ctx, query, s.owner, now).Scan(&stats.Total, &stats.Done, &stats.Overdue); err != nil {
+		return TodoStats{}, err
+	}
+	stats.Open = stats.Total - stats.Done
+
+	return stats, nil
+}
+
+func (s *postgresTodoStore) CreateSubtask(ctx context.Context, subtask *Subtask) error {
+	// selecting the parent turns a missing todo into sql.ErrNoRows
+	query := `
+		INSERT INTO subtasks (todo_id, title, done)
+		SELECT id, $2, $3 FROM todos WHERE id = $1 AND ` + visibleTo("$4") + `
+		RETURNING id, created_at
+	`
+
+	err := s.db.QueryRowContext(ctx, query, subtask.TodoID, subtask.Title, subtask.Done, s.owner).Scan(&subtask.ID, &subtask.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrTodoNotFound
+	}
+
+	return err
+}
+
+func (s *postgresTodoStore) ListSubtasks(ctx context.Context, todoID int64) ([]Subtask, error) {
+	if err := s.todoExists(ctx, todoID); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT `+subtaskColumns+` FROM subtasks WHERE todo_id = $1 ORDER BY id`, todoID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subtasks := []Subtask{}
+	for rows.Next() {
+		var subtask Subtask
+		if err := rows.Scan(&subtask.ID, &subtask.TodoID, &subtask.Title, &subtask.Done, &subtask.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		subtasks = append(subtasks, subtask)
+	}
+
+	return subtasks, rows.Err()
+}
+
+func (s *postgresTodoStore) UpdateSubtask(ctx context.Context, todoID, id int64, patch SubtaskPatch) (Subtask, error) {
+	query := `
+		UPDATE subtasks
+		SET title = COALESCE($3, title), done = COALESCE($4, done)
+		WHERE todo_id = $1 AND id = $2 AND todo_id IN (SELECT id FROM todos WHERE ` + visibleTo("$5") + `)
+		RETURNING ` + subtaskColumns
+
+	var subtask Subtask
+	err := s.db.QueryRowContext(ctx, query, todoID, id, patch.Title, patch.Done, s.owner).
+		Scan(&subtask.ID, &subtask.TodoID, &subtask.Title, &subtask.Done, &subtask.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Subtask{}, s.missingSubtask(ctx, todoID)
+	}
+
+	return subtask, err
+}
+
+func (s *postgresTodoStore) DeleteSubtask(ctx context.Context, todoID, id int64) error {
+	query := `DELETE FROM subtasks WHERE todo_id = $1 AND id = $2 AND todo_id IN (SELECT id FROM todos WHERE ` + visibleTo("$3") + `)`
+
+	result, err := s.db.ExecContext(ctx, query, todoID, id, s.owner)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return s.missingSubtask(ctx, todoID)
+	}
+
+	return nil
+}
+
+func (s *postgresTodoStore) todoExists(ctx context.Context, id int64) error {
+	var exists bool
+	if err := s.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM todos WHERE id = $1 AND `+visibleTo("$2")+`)`, id, s.owner).Scan(&exists); err != nil {
+		return err
+	}
+
+	if !exists {
+		return ErrTodoNotFound
+	}
+
+	return nil
+}
+
+// missingSubtask tells apart a missing parent from a missing subtask after a
+// statement matched no rows.
+func (s *postgresTodoStore) missingSubtask(ctx context.Context, todoID int64) error {
+	if err := s.todoExists(ctx, todoID); err != nil {
+		return err
+	}
+
+	return ErrSubtaskNotFound
+}
+
+func (s *postgresTodoStore) CreateComment(ctx context.Context, comment *Comment) error {
+	// selecting the parent turns a missing todo into sql.ErrNoRows
+	query := `
+		INSERT INTO comments (todo_id, author_id, body)
+		SELECT id, $2, $3 FROM todos WHERE id = $1 AND ` + visibleTo("$4") + `
+		RETURNING id, created_at
+	`
+
+	err := s.db.QueryRowContext(ctx, query, comment.TodoID, comment.AuthorID, comment.Body, s.owner).Scan(&comment.ID, &comment.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrTodoNotFound
+	}
+
+	return err
+}
+
+func (s *postgresTodoStore) ListComments(ctx context.Context, todoID int64, q CommentQuery) ([]Comment, int, error) {
+	if err := s.todoExists(ctx, todoID); err != nil {
+		return nil, 0, err
+	}
+
+	var total int
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM comments WHERE todo_id = $1`, todoID).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT `+commentColumns+` FROM comments WHERE todo_id = $1 ORDER BY id LIMIT $2 OFFSET $3`, todoID, q.Limit, q.Offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	comments := []Comment{}
+	for rows.Next() {
+		var comment Comment
+		if err := rows.Scan(&comment.ID, &comment.TodoID, &comment.AuthorID, &comment.Body, &comment.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+
+		comments = append(comments, comment)
+	}
+
+	return comments, total, rows.Err()
+}
+
+func (s *postgresTodoStore) DeleteComment(ctx context.Context, todoID, id int64, author string) error {
+	query := `
+		DELETE FROM comments
+		WHERE todo_id = $1 AND id = $2 AND ($4 = '' OR author_id = $4)
+			AND todo_id IN (SELECT id FROM todos WHERE ` + visibleTo("$3") + `)`
+
+	result, err := s.db.ExecContext(ctx, query, todoID, id, s.owner, author)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return s.missingComment(ctx, todoID, id)
+	}
+
+	return nil
+}
+
+// missingComment tells apart a missing todo, a missing comment and someone
+// else's comment after DeleteComment matched no rows.
+func (s *postgresTodoStore) missingComment(ctx context.Context, todoID, id int64) error {
+	if err := s.todoExists(ctx, todoID); err != nil {
+		return err
+	}
+
+	var exists bool
+	if err := s.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM comments WHERE todo_id = $1 AND id = $2)`, todoID, id).Scan(&exists); err != nil {
+		return err
+	}
+
+	if !exists {
+		return ErrCommentNotFound
+	}
+
+	return ErrNotCommentAuthor
+}
+
+// sqlOrderBy builds the ORDER BY clause for List, Postgres and SQLite both
+// understand it.
+func sqlOrderBy(q ListQuery) string {
+	direction := "ASC"
+	if q.Order == orderDesc {
+		direction = "DESC"
+	}
+
+	switch q.Sort {
+	case sortCreated:
+		return "created_at " + direction + ", id " + direction
+	case sortDue:
+		return "due_date " + direction + " NULLS LAST, id"
+	case sortPriority:
+		return "CASE priority WHEN 'high' THEN 3 WHEN 'medium' THEN 2 ELSE 1 END " + direction + ", created_at, id"
+	default:
+		return "id"
+	}
+}
+
+func scanList(row rowScanner, list *TodoList) error {
+	return row.Scan(&list.ID, &list.Name, &list.OwnerID, &list.CreatedAt)
+}
+
+// listExists returns ErrListNotFound unless the list is there and the
+// owner's.
+func (s *postgresTodoStore) listExists(ctx context.Context, tx *sql.Tx, id int64) error {
+	var exists bool
+	if err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM lists WHERE id = $1 AND `+ownedBy("$2")+`)`, id, s.owner).Scan(&exists); err != nil {
+		return err
+	}
+
+	if !exists {
+		return ErrListNotFound
+	}
+
+	return nil
+}
+
+func (s *postgresTodoStore) CreateList(ctx context.Context, list *TodoList) error {
+	if s.owner != "" {
+		list.OwnerID = s.owner
+	}
+
+	query := `INSERT INTO lists (name, owner_id) VALUES ($1, $2) RETURNING ` + listColumns
+
+	return scanList(s.db.QueryRowContext(ctx, query, list.Name, list.OwnerID), list)
+}
+
+func (s *postgresTodoStore) GetList(ctx context.Context, id int64) (TodoList, error) {
+	var list TodoList
+	err := scanList(s.db.QueryRowContext(ctx, `SELECT `+listColumns+` FROM lists WHERE id = $1 AND `+ownedBy("$2"), id, s.owner), &list)
+	if errors.Is(err, sql.ErrNoRows) {
+		return TodoList{}, ErrListNotFound
+	}
+
+	return list, err
+}
+
+func (s *postgresTodoStore) ListLists(ctx context.Context) ([]TodoList, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+listColumns+` FROM lists WHERE `+ownedBy("$1")+` ORDER BY id`, s.owner)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lists := []TodoList{}
+	for rows.Next() {
+		var list TodoList
+		if err := scanList(rows, &list); err != nil {
+			return nil, err
+		}
+
+		lists = append(lists, list)
+	}
+
+	return lists, rows.Err()
+}
+
+func (s *postgresTodoStore) RenameList(ctx context.Context, id int64, name string) (TodoList, error) {
+	query := `UPDATE lists SET name = $3 WHERE id = $1 AND ` + ownedBy("$2") + ` RETURNING ` + listColumns
+
+	var list TodoList
+	err := scanList(s.db.QueryRowContext(ctx, query, id, s.owner, name), &list)
+	if errors.Is(err, sql.ErrNoRows) {
+		return TodoList{}, ErrListNotFound
+	}
+
+	return list, err
+}
+
+// DeleteList leaves the todos, and their subtasks and comments, to ON DELETE
+// CASCADE.
+func (s *postgresTodoStore) DeleteList(ctx context.Context, id int64, force bool) ([]Todo, error) {
+	deleted := []Todo{}
+	err := inTx(ctx, s.db, func(tx *sql.Tx) error {
+		if err := s.listExists(ctx, tx, id); err != nil {
+			return err
+		}
+
+		rows, err := tx.QueryContext(ctx, `SELECT `+todoColumns+` FROM todos WHERE list_id = $1 AND deleted_at IS NULL ORDER BY id`, id)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var todo Todo
+			if err := scanTodo(rows, &todo); err != nil {
+				return err
+			}
+
+			deleted = append(deleted, todo)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if len(deleted) > 0 && !force {
+			return ErrListNotEmpty
+		}
+
+		_, err = tx.ExecContext(ctx, `DELETE FROM lists WHERE id = $1`, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return deleted, nil
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"slices"
+	"testing"
+)
+
+func newTestPostgresStore(t *testing.T) *postgresTodoStore {
+	t.Helper()
+
+	dsn := os.Getenv("DATABASE_URL")
+	if dsn == "" {
+		t.Skip("DATABASE_URL is not set")
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	ctx := context.Background()
+
+	store, err := newPostgresTodoStore(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := db.ExecContext(ctx, `TRUNCATE todos, subtasks RESTART IDENTITY`); err != nil {
+		t.Fatal(err)
+	}
+
+	return store
+}
+
+func TestPostgresTodoStore(t *testing.T) {
+	store := newTestPostgresStore(t)
+	ctx := context.Background()
+
+	t.Run("migrations are idempotent", func(t *testing.T) {
+		if _, err := newPostgresTodoStore(ctx, store.db); err != nil {
+			t.Fatalf("re-running migrations failed: %v", err)
+		}
+	})
+
+	t.Run("create and list", func(t *testing.T) {
+		for _, title := range []string{"first", "second", "third"} {
+			todo := &Todo{Title: title}
+			if err := store.Create(ctx, todo); err != nil {
+				t.Fatal(err)
+			}
+
+			if todo.ID == 0 || todo.CreatedAt.IsZero() {
+				t.Fatalf("expected id and created_at to be set, got %+v", todo)
+			}
+		}
+
+		todos, total, err := store.List(ctx, ListQuery{Offset: 1, Limit: 5})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if total != 3 {
+			t.Errorf("expected total 3, got %d", total)
+		}
+
+		if len(todos) != 2 || todos[0].Title != "second" || todos[1].Title != "third" {
+			t.Errorf("unexpected window %+v", todos)
+		}
+	})
+
+	t.Run("get, update and delete", func(t *testing.T) {
+		todo, err := store.Get(ctx, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		todo.Title = "first, renamed"
+		todo.Done = true
+		if err := store.Update(ctx, &todo); err != nil {
+			t.Fatal(err)
+		}
+
+		if got, _ := store.Get(ctx, 1); got.Title != "first, renamed" || !got.Done {
+			t.Errorf("update was not persisted: %+v", got)
+		}
+
+		if err := store.Delete(ctx, 1); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := store.Get(ctx, 1); !errors.Is(err, ErrTodoNotFound) {
+			t.Errorf("expected ErrTodoNotFound after delete, got %v", err)
+		}
+
+		if err := store.Delete(ctx, 1); !errors.Is(err, ErrTodoNotFound) {
+			t.Errorf("expected ErrTodoNotFound deleting twice, got %v", err)
+		}
+	})
+
+	t.Run("search ranking", func(t *testing.T) {
+		for _, todo := range []*Todo{
+			{Title: "tagged", Tags: []string{"groceries"}},
+			{Title: "weekly groceries"},
+			{Title: "Groceries first"},
+			{Title: "100% off_sale"},
+		} {
+			if err := store.Create(ctx, todo); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		todos, total, err := store.Search(ctx, SearchQuery{Text: "groceries", Limit: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if want := []string{"Groceries first", "weekly groceries", "tagged"}; total != 3 || !slices.Equal(todoTitles(todos), want) {
+			t.Errorf("expected %v, got %v of %d", want, todoTitles(todos), total)
+		}
+
+		// LIKE wildcards in the text are matched literally
+		if _, total, _ := store.Search(ctx, SearchQuery{Text: "0%", Limit: 10}); total != 1 {
+			t.Errorf("expected one literal %% match, got %d", total)
+		}
+		if _, total, _ := store.Search(ctx, SearchQuery{Text: "f_s", Limit: 10}); total != 1 {
+			t.Errorf("expected one literal _ match, got %d", total)
+		}
+	})
+
+	t.Run("archive", func(t *testing.T) {
+		todo := &Todo{Title: "to archive"}
+		if err := store.Create(ctx, todo); err != nil {
+			t.Fatal(err)
+		}
+
+		archived, err := store.SetArchived(ctx, todo.ID, true)
+		if err != nil || !archived.Archived || archived.ArchivedAt == nil {
+			t.Fatalf("expected an archived todo, got %+v (%v)", archived, err)
+		}
+
+		if err := store.Update(ctx, &Todo{ID: todo.ID, Title: "renamed"}); !errors.Is(err, ErrTodoArchived) {
+			t.Errorf("expected ErrTodoArchived, got %v", err)
+		}
+
+		onlyArchived := true
+		todos, total, err := store.List(ctx, ListQuery{Limit: 10, Archived: &onlyArchived})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if total != 1 || todos[0].ID != todo.ID {
+			t.Errorf("expected only the archived todo, got %v of %d", todoTitles(todos), total)
+		}
+
+		if _, err := store.SetArchived(ctx, 9999, true); !errors.Is(err, ErrTodoNotFound) {
+			t.Errorf("expected ErrTodoNotFound, got %v", err)
+		}
+	})
+
+	t.Run("owner scoping", func(t *testing.T) {
+		ana, bob := store.ForOwner("ana"), store.ForOwner("bob")
+
+		todo := &Todo{Title: "ana's"}
+		if err := ana.Create(ctx, todo); err != nil {
+			t.Fatal(err)
+		}
+		if todo.OwnerID != "ana" {
+			t.Errorf("expected owner ana, got %q", todo.OwnerID)
+		}
+
+		if _, err := bob.Get(ctx, todo.ID); !errors.Is(err, ErrTodoNotFound) {
+			t.Errorf("expected ErrTodoNotFound, got %v", err)
+		}
+		if err := bob.Update(ctx, &Todo{ID: todo.ID, Title: "taken"}); !errors.Is(err, ErrTodoNotFound) {
+			t.Errorf("expected ErrTodoNotFound, got %v", err)
+		}
+		if err := bob.Delete(ctx, todo.ID); !errors.Is(err, ErrTodoNotFound) {
+			t.Errorf("expected ErrTodoNotFound, got %v", err)
+		}
+		if err := bob.CreateSubtask(ctx, &Subtask{TodoID: todo.ID, Title: "step"}); !errors.Is(err, ErrTodoNotFound) {
+			t.Errorf("expected ErrTodoNotFound, got %v", err)
+		}
+
+		todos, total, err := ana.List(ctx, ListQuery{Limit: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if total != 1 || todos[0].ID != todo.ID {
+			t.Errorf("expected only ana's todo, got %v of %d", todoTitles(todos), total)
+		}
+		if _, total, _ := bob.List(ctx, ListQuery{Limit: 10}); total != 0 {
+			t.Errorf("expected bob to see nothing, got %d todos", total)
+		}
+	})
+}
+
+func todoTitles(todos []Todo) []string {
+	titles := make([]string, len(todos))
+	for i, todo := range todos {
+		titles[i] = todo.Title
+	}
+
+	return titles
+}
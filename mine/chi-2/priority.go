@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+type Priority string
+
+const (
+	PriorityLow    Priority = "low"
+	PriorityMedium Priority = "medium"
+	PriorityHigh   Priority = "high"
+
+	defaultPriority = PriorityMedium
+)
+
+// ErrInvalidPriority is returned for anything outside of low, medium, high.
+type ErrInvalidPriority struct {
+	Value string
+}
+
+func (e *ErrInvalidPriority) Error() string {
+	return fmt.Sprintf("invalid priority %q, must be one of low, medium, high", e.Value)
+}
+
+func ParsePriority(s string) (Priority, error) {
+	p := Priority(s)
+	if !p.Valid() {
+		return "", &ErrInvalidPriority{Value: s}
+	}
+
+	return p, nil
+}
+
+func (p Priority) Valid() bool {
+	return p.rank() > 0
+}
+
+// rank orders priorities from low to high; invalid values rank 0.
+func (p Priority) rank() int {
+	switch p {
+	case PriorityLow:
+		return 1
+	case PriorityMedium:
+		return 2
+	case PriorityHigh:
+		return 3
+	default:
+		return 0
+	}
+}
+
+func (p *Priority) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	parsed, err := ParsePriority(s)
+	if err != nil {
+		return err
+	}
+
+	*p = parsed
+
+	return nil
+}
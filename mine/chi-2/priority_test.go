@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPriorityJSON(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Priority
+		invalid bool
+	}{
+		{input: `"low"`, want: PriorityLow},
+		{input: `"medium"`, want: PriorityMedium},
+		{input: `"high"`, want: PriorityHigh},
+		{input: `"HIGH"`, invalid: true},
+		{input: `"urgent"`, invalid: true},
+		{input: `""`, invalid: true},
+		{input: `3`, invalid: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			var p Priority
+			err := json.Unmarshal([]byte(tt.input), &p)
+
+			if tt.invalid {
+				if err == nil {
+					t.Fatalf("expected an error, got %q", p)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if p != tt.want {
+				t.Fatalf("expected %q, got %q", tt.want, p)
+			}
+
+			out, err := json.Marshal(p)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(out) != tt.input {
+				t.Errorf("round trip: expected %s, got %s", tt.input, out)
+			}
+		})
+	}
+
+	var invalid *ErrInvalidPriority
+	if err := json.Unmarshal([]byte(`{"priority":"urgent"}`), &todoPayload{}); !errors.As(err, &invalid) {
+		t.Errorf("expected ErrInvalidPriority from a payload, got %v", err)
+	}
+}
+
+func TestTodoPriority(t *testing.T) {
+	h := &todoHandler{store: newMemoryTodoStore()}
+
+	tests := []struct {
+		body   string
+		status int
+		want   Priority
+	}{
+		{body: `{"title":"a"}`, status: http.StatusCreated, want: PriorityMedium},
+		{body: `{"title":"b","priority":"high"}`, status: http.StatusCreated, want: PriorityHigh},
+		{body: `{"title":"c","priority":"urgent"}`, status: http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		h.createTodo(rr, newJSONRequest(http.MethodPost, "/todo", tt.body))
+
+		if rr.Code != tt.status {
+			t.Fatalf("%s: expected status %d, got %d", tt.body, tt.status, rr.Code)
+		}
+
+		if tt.status != http.StatusCreated {
+			continue
+		}
+
+		var todo Todo
+		if err := json.NewDecoder(rr.Body).Decode(&todo); err != nil {
+			t.Fatal(err)
+		}
+
+		if todo.Priority != tt.want {
+			t.Errorf("%s: expected priority %q, got %q", tt.body, tt.want, todo.Priority)
+		}
+	}
+
+	r := newTodoRouter(t, h.store)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, newJSONRequest(http.MethodPut, "/todo/1", `{"title":"a","priority":"none"}`))
+
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("update: expected status %d, got %d", http.StatusUnprocessableEntity, rr.Code)
+	}
+}
+
+func TestListTodosByPriority(t *testing.T) {
+	store := newMemoryTodoStore()
+	h := &todoHandler{store: store}
+
+	for _, todo := range []*Todo{
+		{Title: "a", Priority: PriorityLow},
+		{Title: "b", Priority: PriorityHigh},
+		{Title: "c", Priority: PriorityMedium},
+		{Title: "d", Priority: PriorityHigh},
+		{Title: "e", Priority: PriorityLow},
+	} {
+		if err := store.Create(context.Background(), todo); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	base := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := range store.todos {
+		store.todos[i].CreatedAt = base.Add(time.Duration(i) * time.Minute)
+	}
+
+	tests := []struct {
+		query  string
+		status int
+		want   string
+	}{
+		{query: "sort=priority", status: http.StatusOK, want: "bdcae"},
+		{query: "sort=priority&order=asc", status: http.StatusOK, want: "aecbd"},
+		{query: "priority=high", status: http.StatusOK, want: "bd"},
+		{query: "priority=low&sort=created&order=desc", status: http.StatusOK, want: "ea"},
+		{query: "priority=urgent", status: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			h.listTodos(rr, httptest.NewRequest(http.MethodGet, "/todo?"+tt.query, nil))
+
+			if rr.Code != tt.status {
+				t.Fatalf("expected status %d, got %d", tt.status, rr.Code)
+			}
+
+			if tt.status != http.StatusOK {
+				return
+			}
+
+			var todos []Todo
+			if err := json.NewDecoder(rr.Body).Decode(&todos); err != nil {
+				t.Fatal(err)
+			}
+
+			var got string
+			for _, todo := range todos {
+				got += todo.Title
+			}
+
+			if got != tt.want {
+				t.Errorf("expected order %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultRateBurst = 20
+	// rateLimiterPrune is how often the limiter looks for buckets it can
+	// forget.
+	rateLimiterPrune = time.Minute
+)
+
+// rateLimiter throttles each client IP with a token bucket that holds burst
+// tokens and gets rate of them back per second. Every response it limits
+// carries the state of the caller's bucket:
+//
+//	X-RateLimit-Limit      the size of the bucket
+//	X-RateLimit-Remaining  the requests left right now
+//	X-RateLimit-Reset      seconds until the bucket is full again
+//
+// A request finding the bucket empty gets a 429 with Retry-After set to the
+// seconds until its next token.
+type rateLimiter struct {
+	mu        sync.Mutex
+	rate      float64
+	burst     int
+	buckets   map[string]*tokenBucket
+	lastPrune time.Time
+	// now is swapped by the tests to refill the buckets.
+	now func() time.Time
+}
+
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// rateLimitState is a bucket right after a request took, or failed to take,
+// its token.
+type rateLimitState struct {
+	allowed   bool
+	remaining int
+	// reset is how long until the bucket is full, retryAfter until it has a
+	// token again.
+	reset      time.Duration
+	retryAfter time.Duration
+}
+
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	return &rateLimiter{rate: rate, burst: burst, buckets: make(map[string]*tokenBucket), now: time.Now}
+}
+
+// take refills the bucket of client for the time since it was last used and
+// takes a token from it if there is one.
+func (l *rateLimiter) take(client string) rateLimitState {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.prune(now)
+
+	b, ok := l.buckets[client]
+	if !ok {
+		b = &tokenBucket{tokens: float64(l.burst), updated: now}
+		l.buckets[client] = b
+	}
+	b.tokens = min(float64(l.burst), b.tokens+now.Sub(b.updated).Seconds()*l.rate)
+	b.updated = now
+
+	state := rateLimitState{allowed: b.tokens >= 1}
+	if state.allowed {
+		b.tokens--
+	} else {
+		state.retryAfter = l.refill(1 - b.tokens)
+	}
+	state.remaining = int(b.tokens)
+	state.reset = l.refill(float64(l.burst) - b.tokens)
+
+	return state
+}
+
+// refill is how long the bucket takes to get tokens back.
+func (l *rateLimiter) refill(tokens float64) time.Duration {
+	return time.Duration(tokens / l.rate * float64(time.Second))
+}
+
+// prune forgets the buckets that have refilled completely, they are the
+// same as a new one. It runs at most once per rateLimiterPrune and must be
+// called with mu held.
+func (l *rateLimiter) prune(now time.Time) {
+	if now.Sub(l.lastPrune) < rateLimiterPrune {
+		return
+	}
+	l.lastPrune = now
+
+	full := l.refill(float64(l.burst))
+	for client, b := range l.buckets {
+		if now.Sub(b.updated) >= full {
+			delete(l.buckets, client)
+		}
+	}
+}
+
+func (l *rateLimiter) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		state := l.take(clientIP(r))
+
+		h := w.Header()
+		h.Set("X-RateLimit-Limit", strconv.Itoa(l.burst))
+		h.Set("X-RateLimit-Remaining", strconv.Itoa(state.remaining))
+		h.Set("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(state.reset)))
+
+		if !state.allowed {
+			h.Set("Retry-After", strconv.Itoa(max(ceilSeconds(state.retryAfter), 1)))
+			respondError(w, r, http.StatusTooManyRequests, codeRateLimited, "too many requests, try again later")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// clientIP is the address the request came from. Behind a proxy that is the
+// proxy, every client then shares one bucket.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
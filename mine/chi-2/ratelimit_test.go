@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// newRateLimitRouter serves GET / behind a limiter whose clock the test
+// moves by hand.
+func newRateLimitRouter(rate float64, burst int) (http.Handler, *time.Time) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter := newRateLimiter(rate, burst)
+	limiter.now = func() time.Time { return now }
+
+	r := chi.NewRouter()
+	r.Use(limiter.middleware)
+	r.Get("/", helloWorldHandler)
+
+	return r, &now
+}
+
+func getFrom(r http.Handler, remoteAddr string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = remoteAddr
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestRateLimitHeaders(t *testing.T) {
+	// a token back every two seconds
+	r, now := newRateLimitRouter(0.5, 3)
+
+	for i, want := range []struct {
+		status           int
+		remaining, reset string
+	}{
+		{http.StatusOK, "2", "2"},
+		{http.StatusOK, "1", "4"},
+		{http.StatusOK, "0", "6"},
+		{http.StatusTooManyRequests, "0", "6"},
+	} {
+		rr := getFrom(r, "203.0.113.7:4000")
+		if rr.Code != want.status {
+			t.Fatalf("request %d: expected status %d, got %d", i+1, want.status, rr.Code)
+		}
+
+		h := rr.Header()
+		if got := h.Get("X-RateLimit-Limit"); got != "3" {
+			t.Errorf("request %d: expected X-RateLimit-Limit 3, got %q", i+1, got)
+		}
+		if got := h.Get("X-RateLimit-Remaining"); got != want.remaining {
+			t.Errorf("request %d: expected X-RateLimit-Remaining %s, got %q", i+1, want.remaining, got)
+		}
+		if got := h.Get("X-RateLimit-Reset"); got != want.reset {
+			t.Errorf("request %d: expected X-RateLimit-Reset %s, got %q", i+1, want.reset, got)
+		}
+	}
+
+	rr := getFrom(r, "203.0.113.7:4001")
+	if got := rr.Header().Get("Retry-After"); got != "2" {
+		t.Errorf("expected Retry-After 2, got %q", got)
+	}
+	var body errorResponse
+	if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Code != codeRateLimited {
+		t.Errorf("expected code %q, got %q", codeRateLimited, body.Code)
+	}
+
+	// other clients have buckets of their own
+	if rr := getFrom(r, "198.51.100.1:4000"); rr.Code != http.StatusOK || rr.Header().Get("X-RateLimit-Remaining") != "2" {
+		t.Errorf("expected a full bucket for another client, got %d with %q left", rr.Code, rr.Header().Get("X-RateLimit-Remaining"))
+	}
+
+	*now = now.Add(3 * time.Second)
+	rr = getFrom(r, "203.0.113.7:4000")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected a token back after 3s, got status %d", rr.Code)
+	}
+	// 1.5 tokens came back and one was taken
+	if got := rr.Header().Get("X-RateLimit-Remaining"); got != "0" {
+		t.Errorf("expected X-RateLimit-Remaining 0, got %q", got)
+	}
+	if got := rr.Header().Get("X-RateLimit-Reset"); got != "5" {
+		t.Errorf("expected X-RateLimit-Reset 5, got %q", got)
+	}
+}
+
+func TestRateLimitPrune(t *testing.T) {
+	limiter := newRateLimiter(1, 2)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter.now = func() time.Time { return now }
+
+	limiter.take("203.0.113.7")
+	now = now.Add(rateLimiterPrune)
+	limiter.take("198.51.100.1")
+
+	if _, ok := limiter.buckets["203.0.113.7"]; ok {
+		t.Error("expected the refilled bucket to be forgotten")
+	}
+	if len(limiter.buckets) != 1 {
+		t.Errorf("expected 1 bucket, got %d", len(limiter.buckets))
+	}
+}
+
+func TestRouterRateLimit(t *testing.T) {
+	withTestSecret(t)
+
+	app, err := buildRouter(routerConfig{
+		store:     newMemoryTodoStore(),
+		logger:    slog.New(slog.NewJSONHandler(io.Discard, nil)),
+		rateLimit: 1,
+		rateBurst: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the headers are on every response, errors included
+	for _, want := range []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusTooManyRequests} {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/v1/todo", nil)
+		req.RemoteAddr = "203.0.113.7:4000"
+		app.router.ServeHTTP(rr, req)
+
+		if rr.Code != want {
+			t.Fatalf("expected status %d, got %d", want, rr.Code)
+		}
+		if rr.Header().Get("X-RateLimit-Limit") != "2" {
+			t.Errorf("expected X-RateLimit-Limit 2, got %q", rr.Header().Get("X-RateLimit-Limit"))
+		}
+	}
+
+	// with the bucket empty, the operational routes still answer
+	for _, target := range []string{"/healthz", "/metrics"} {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.RemoteAddr = "203.0.113.7:4000"
+		app.router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("GET %s: expected status %d, got %d", target, http.StatusOK, rr.Code)
+		}
+		if rr.Header().Get("X-RateLimit-Limit") != "" {
+			t.Errorf("GET %s: expected no rate limit headers, got %q", target, rr.Header().Get("X-RateLimit-Limit"))
+		}
+	}
+}
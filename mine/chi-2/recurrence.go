@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Frequency is how often a recurring todo comes back, before its interval.
+type Frequency string
+
+const (
+	FrequencyDaily   Frequency = "DAILY"
+	FrequencyWeekly  Frequency = "WEEKLY"
+	FrequencyMonthly Frequency = "MONTHLY"
+
+	maxRecurrenceInterval = 365
+)
+
+// Recurrence is a restricted iCalendar RRULE: FREQ is one of DAILY, WEEKLY or
+// MONTHLY and INTERVAL, which defaults to 1, counts how many of those pass
+// between occurrences. "FREQ=WEEKLY;INTERVAL=2" is every other week.
+type Recurrence struct {
+	Frequency Frequency
+	Interval  int
+}
+
+// ErrInvalidRecurrence is returned for a rule ParseRecurrence can't accept.
+type ErrInvalidRecurrence struct {
+	Value  string
+	Reason string
+}
+
+func (e *ErrInvalidRecurrence) Error() string {
+	return fmt.Sprintf("invalid recurrence %q: %s", e.Value, e.Reason)
+}
+
+// ParseRecurrence reads a rule like "FREQ=MONTHLY;INTERVAL=3". Names and
+// values are case insensitive, each part may appear once.
+func ParseRecurrence(s string) (Recurrence, error) {
+	invalid := func(reason string) (Recurrence, error) {
+		return Recurrence{}, &ErrInvalidRecurrence{Value: s, Reason: reason}
+	}
+
+	var rule Recurrence
+	seen := map[string]bool{}
+
+	for _, part := range strings.Split(s, ";") {
+		name, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		name = strings.ToUpper(strings.TrimSpace(name))
+		value = strings.ToUpper(strings.TrimSpace(value))
+		if !ok || name == "" || value == "" {
+			return invalid("parts must look like NAME=VALUE")
+		}
+
+		if seen[name] {
+			return invalid(name + " is given more than once")
+		}
+		seen[name] = true
+
+		switch name {
+		case "FREQ":
+			rule.Frequency = Frequency(value)
+			if !rule.Frequency.valid() {
+				return invalid("FREQ must be one of DAILY, WEEKLY, MONTHLY")
+			}
+		case "INTERVAL":
+			interval, err := strconv.Atoi(value)
+			if err != nil || interval < 1 || interval > maxRecurrenceInterval {
+				return invalid(fmt.Sprintf("INTERVAL must be a whole number from 1 to %d", maxRecurrenceInterval))
+			}
+			rule.Interval = interval
+		default:
+			return invalid("only FREQ and INTERVAL are supported")
+		}
+	}
+
+	if rule.Frequency == "" {
+		return invalid("FREQ is required")
+	}
+	if rule.Interval == 0 {
+		rule.Interval = 1
+	}
+
+	return rule, nil
+}
+
+func (f Frequency) valid() bool {
+	return f == FrequencyDaily || f == FrequencyWeekly || f == FrequencyMonthly
+}
+
+// String formats the rule the way ParseRecurrence reads it, leaving out an
+// INTERVAL of 1.
+func (r Recurrence) String() string {
+	if r.Interval <= 1 {
+		return "FREQ=" + string(r.Frequency)
+	}
+
+	return fmt.Sprintf("FREQ=%s;INTERVAL=%d", r.Frequency, r.Interval)
+}
+
+// Next returns the due date of the occurrence after one due at due. Monthly
+// rules keep the day of the month, clamped to the last day of shorter months:
+// Jan 31 is followed by Feb 28, or Feb 29 in a leap year. The clamped day then
+// carries on, so the occurrence after Feb 28 is Mar 28.
+func (r Recurrence) Next(due time.Time) time.Time {
+	interval := max(r.Interval, 1)
+
+	switch r.Frequency {
+	case FrequencyDaily:
+		return due.AddDate(0, 0, interval)
+	case FrequencyWeekly:
+		return due.AddDate(0, 0, 7*interval)
+	default:
+		return addMonthsClamped(due, interval)
+	}
+}
+
+// addMonthsClamped is AddDate(0, months, 0) without the overflow into the
+// following month, Jan 31 plus one month is Feb 28 rather than Mar 3.
+func addMonthsClamped(t time.Time, months int) time.Time {
+	year, month, day := t.Date()
+	hour, minute, second := t.Clock()
+
+	first := time.Date(year, month+time.Month(months), 1, hour, minute, second, t.Nanosecond(), t.Location())
+	// day 0 of the month after is the last day of this one
+	lastDay := time.Date(first.Year(), first.Month()+1, 0, 0, 0, 0, 0, t.Location()).Day()
+
+	return first.AddDate(0, 0, min(day, lastDay)-1)
+}
+
+func (r Recurrence) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.String())
+}
+
+func (r *Recurrence) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	parsed, err := ParseRecurrence(s)
+	if err != nil {
+		return err
+	}
+
+	*r = parsed
+
+	return nil
+}
+
+// recurrenceColumn stores a todo without a rule as an empty string.
+func recurrenceColumn(r *Recurrence) string {
+	if r == nil {
+		return ""
+	}
+
+	return r.String()
+}
+
+// scanRecurrence is the reverse of recurrenceColumn.
+func scanRecurrence(column string) (*Recurrence, error) {
+	if column == "" {
+		return nil, nil
+	}
+
+	rule, err := ParseRecurrence(column)
+	if err != nil {
+		return nil, err
+	}
+
+	return &rule, nil
+}
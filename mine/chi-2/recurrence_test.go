@@ -0,0 +1,295 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseRecurrence(t *testing.T) {
+	valid := []struct {
+		in   string
+		want Recurrence
+	}{
+		{"FREQ=DAILY", Recurrence{FrequencyDaily, 1}},
+		{"FREQ=WEEKLY;INTERVAL=1", Recurrence{FrequencyWeekly, 1}},
+		{"freq=weekly;interval=2", Recurrence{FrequencyWeekly, 2}},
+		{" FREQ = MONTHLY ; INTERVAL = 3 ", Recurrence{FrequencyMonthly, 3}},
+		{"INTERVAL=10;FREQ=DAILY", Recurrence{FrequencyDaily, 10}},
+		{"FREQ=DAILY;INTERVAL=365", Recurrence{FrequencyDaily, 365}},
+	}
+
+	for _, tt := range valid {
+		got, err := ParseRecurrence(tt.in)
+		if err != nil {
+			t.Errorf("ParseRecurrence(%q): %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseRecurrence(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+
+	invalid := []string{
+		"",
+		"DAILY",
+		"FREQ=",
+		"=DAILY",
+		"FREQ=DAILY;",
+		"FREQ=YEARLY",
+		"FREQ=HOURLY",
+		"INTERVAL=2",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=DAILY;INTERVAL=-1",
+		"FREQ=DAILY;INTERVAL=366",
+		"FREQ=DAILY;INTERVAL=1.5",
+		"FREQ=DAILY;FREQ=WEEKLY",
+		"FREQ=WEEKLY;BYDAY=MO",
+		"FREQ=DAILY,INTERVAL=2",
+	}
+
+	for _, in := range invalid {
+		_, err := ParseRecurrence(in)
+
+		var ruleErr *ErrInvalidRecurrence
+		if err == nil {
+			t.Errorf("ParseRecurrence(%q): expected an error", in)
+		} else if !errors.As(err, &ruleErr) || ruleErr.Value != in || ruleErr.Reason == "" {
+			t.Errorf("ParseRecurrence(%q): expected an *ErrInvalidRecurrence with a reason, got %v", in, err)
+		}
+	}
+}
+
+func TestRecurrenceString(t *testing.T) {
+	for _, in := range []string{"FREQ=DAILY", "FREQ=WEEKLY;INTERVAL=2", "FREQ=MONTHLY;INTERVAL=12"} {
+		rule, err := ParseRecurrence(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := rule.String(); got != in {
+			t.Errorf("expected %q to round trip, got %q", in, got)
+		}
+	}
+
+	if got := (Recurrence{Frequency: FrequencyDaily, Interval: 1}).String(); got != "FREQ=DAILY" {
+		t.Errorf("expected INTERVAL=1 to be left out, got %q", got)
+	}
+}
+
+func TestRecurrenceJSON(t *testing.T) {
+	var todo Todo
+	if err := json.Unmarshal([]byte(`{"recurrence":"freq=monthly;interval=2"}`), &todo); err != nil {
+		t.Fatal(err)
+	}
+	if todo.Recurrence == nil || *todo.Recurrence != (Recurrence{FrequencyMonthly, 2}) {
+		t.Fatalf("unexpected recurrence %+v", todo.Recurrence)
+	}
+
+	encoded, err := json.Marshal(todo.Recurrence)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(encoded) != `"FREQ=MONTHLY;INTERVAL=2"` {
+		t.Errorf("unexpected encoding %s", encoded)
+	}
+
+	if err := json.Unmarshal([]byte(`{"recurrence":"FREQ=YEARLY"}`), &todo); err == nil {
+		t.Error("expected an invalid rule to fail decoding")
+	}
+}
+
+func TestRecurrenceNext(t *testing.T) {
+	date := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 9, 30, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name string
+		rule Recurrence
+		from time.Time
+		want time.Time
+	}{
+		{"daily", Recurrence{FrequencyDaily, 1}, date(2024, 3, 10), date(2024, 3, 11)},
+		{"daily across a month", Recurrence{FrequencyDaily, 3}, date(2024, 1, 30), date(2024, 2, 2)},
+		{"daily across a year", Recurrence{FrequencyDaily, 1}, date(2024, 12, 31), date(2025, 1, 1)},
+		{"weekly", Recurrence{FrequencyWeekly, 1}, date(2024, 3, 10), date(2024, 3, 17)},
+		{"every other week", Recurrence{FrequencyWeekly, 2}, date(2024, 2, 20), date(2024, 3, 5)},
+		{"monthly", Recurrence{FrequencyMonthly, 1}, date(2024, 3, 15), date(2024, 4, 15)},
+		{"monthly across a year", Recurrence{FrequencyMonthly, 1}, date(2024, 12, 15), date(2025, 1, 15)},
+		{"jan 31 to feb 28", Recurrence{FrequencyMonthly, 1}, date(2023, 1, 31), date(2023, 2, 28)},
+		{"jan 31 to feb 29 in a leap year", Recurrence{FrequencyMonthly, 1}, date(2024, 1, 31), date(2024, 2, 29)},
+		{"mar 31 to apr 30", Recurrence{FrequencyMonthly, 1}, date(2024, 3, 31), date(2024, 4, 30)},
+		{"clamped day carries on", Recurrence{FrequencyMonthly, 1}, date(2023, 2, 28), date(2023, 3, 28)},
+		{"every 3 months from nov 30", Recurrence{FrequencyMonthly, 3}, date(2023, 11, 30), date(2024, 2, 29)},
+		{"every 12 months from feb 29", Recurrence{FrequencyMonthly, 12}, date(2024, 2, 29), date(2025, 2, 28)},
+		{"zero interval counts as one", Recurrence{FrequencyWeekly, 0}, date(2024, 3, 10), date(2024, 3, 17)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rule.Next(tt.from); !got.Equal(tt.want) {
+				t.Errorf("Next(%s) = %s, want %s", tt.from, got, tt.want)
+			}
+		})
+	}
+
+	// the time of day and the zone survive the clamping
+	manila := time.FixedZone("PHT", 8*60*60)
+	from := time.Date(2024, 1, 31, 18, 45, 0, 0, manila)
+	if got, want := (Recurrence{FrequencyMonthly, 1}).Next(from), time.Date(2024, 2, 29, 18, 45, 0, 0, manila); !got.Equal(want) || got.Location() != manila {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
+
+func TestRecurringTodos(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store TodoStore) {
+		r := newTodoRouter(t, store)
+
+		do := func(method, target, body string, out any) int {
+			t.Helper()
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, newJSONRequest(method, target, body))
+
+			if out != nil && rr.Code < 300 {
+				if err := json.NewDecoder(rr.Body).Decode(out); err != nil {
+					t.Fatalf("%s %s: %v", method, target, err)
+				}
+			}
+
+			return rr.Code
+		}
+
+		series := func(id int64) []Todo {
+			t.Helper()
+
+			var todos []Todo
+			if status := do(http.MethodGet, fmt.Sprintf("/todo?series=%d", id), "", &todos); status != http.StatusOK {
+				t.Fatalf("expected status %d, got %d", http.StatusOK, status)
+			}
+			return todos
+		}
+
+		var plants Todo
+		if status := do(http.MethodPost, "/todo", `{"title":"Water the plants","tags":["home"],"due_date":"2024-01-31T09:00:00Z","recurrence":"FREQ=MONTHLY"}`, &plants); status != http.StatusCreated {
+			t.Fatalf("expected status %d, got %d", http.StatusCreated, status)
+		}
+		if plants.SeriesID != plants.ID || plants.Recurrence == nil || plants.Recurrence.String() != "FREQ=MONTHLY" {
+			t.Fatalf("expected the first occurrence to start a series, got %+v", plants)
+		}
+
+		// a one-off todo stays out of every series
+		var oneOff Todo
+		do(http.MethodPost, "/todo", `{"title":"Buy a watering can","due_date":"2024-01-31T09:00:00Z"}`, &oneOff)
+		if oneOff.SeriesID != 0 {
+			t.Errorf("expected no series for a one-off todo, got %d", oneOff.SeriesID)
+		}
+
+		var completed Todo
+		if status := do(http.MethodPatch, fmt.Sprintf("/todo/%d/complete", plants.ID), `{"version":1}`, &completed); status != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, status)
+		}
+		if !completed.Done {
+			t.Errorf("expected the completed occurrence to be done, got %+v", completed)
+		}
+
+		chain := series(plants.SeriesID)
+		if len(chain) != 2 {
+			t.Fatalf("expected 2 occurrences, got %d", len(chain))
+		}
+
+		next := chain[1]
+		if next.Done || next.Title != "Water the plants" || len(next.Tags) != 1 || next.SeriesID != plants.ID {
+			t.Errorf("unexpected next occurrence %+v", next)
+		}
+		if want := time.Date(2024, 2, 29, 9, 0, 0, 0, time.UTC); next.DueDate == nil || !next.DueDate.Equal(want) {
+			t.Errorf("expected the next occurrence due %s, got %v", want, next.DueDate)
+		}
+
+		// reopening and completing again must not add a second february
+		do(http.MethodPatch, fmt.Sprintf("/todo/%d/uncomplete", plants.ID), fmt.Sprintf(`{"version":%d}`, completed.Version), nil)
+		do(http.MethodPatch, fmt.Sprintf("/todo/%d/complete", plants.ID), fmt.Sprintf(`{"version":%d}`, completed.Version+1), nil)
+		if chain := series(plants.SeriesID); len(chain) != 2 {
+			t.Errorf("expected completing twice to keep 2 occurrences, got %d", len(chain))
+		}
+
+		// completing through PUT and the bulk endpoint recurs as well
+		body := `{"title":"Water the plants","done":true,"due_date":"2024-02-29T09:00:00Z","recurrence":"FREQ=MONTHLY","version":1}`
+		if status := do(http.MethodPut, fmt.Sprintf("/todo/%d", next.ID), body, nil); status != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, status)
+		}
+
+		chain = series(plants.SeriesID)
+		if len(chain) != 3 {
+			t.Fatalf("expected 3 occurrences after the update, got %d", len(chain))
+		}
+
+		do(http.MethodPost, "/todo/bulk/complete", fmt.Sprintf(`{"ids":[%d]}`, chain[2].ID), nil)
+
+		chain = series(plants.SeriesID)
+		if len(chain) != 4 {
+			t.Fatalf("expected 4 occurrences after the bulk complete, got %d", len(chain))
+		}
+		if want := time.Date(2024, 4, 29, 9, 0, 0, 0, time.UTC); chain[3].DueDate == nil || !chain[3].DueDate.Equal(want) {
+			t.Errorf("expected the clamped day to carry on to %s, got %v", want, chain[3].DueDate)
+		}
+
+		// dropping the rule keeps the todo in its series but stops it recurring
+		body = fmt.Sprintf(`{"title":"Water the plants","done":true,"due_date":%q,"version":%d}`, chain[3].DueDate.Format(time.RFC3339), chain[3].Version)
+		var stopped Todo
+		do(http.MethodPut, fmt.Sprintf("/todo/%d", chain[3].ID), body, &stopped)
+		if stopped.Recurrence != nil || stopped.SeriesID != plants.ID {
+			t.Errorf("unexpected todo after dropping the rule %+v", stopped)
+		}
+		if chain := series(plants.SeriesID); len(chain) != 4 {
+			t.Errorf("expected no new occurrence without a rule, got %d", len(chain))
+		}
+
+		// adding a rule later starts a series at that todo
+		var started Todo
+		do(http.MethodPut, fmt.Sprintf("/todo/%d", oneOff.ID), `{"title":"Buy a watering can","due_date":"2024-01-31T09:00:00Z","recurrence":"FREQ=WEEKLY;INTERVAL=2","version":1}`, &started)
+		if started.SeriesID != oneOff.ID {
+			t.Errorf("expected the todo to start its own series, got %+v", started)
+		}
+	})
+}
+
+func TestRecurringTodoErrors(t *testing.T) {
+	r := newTodoRouter(t, newMemoryTodoStore())
+
+	tests := []struct {
+		name, method, target, body string
+		status                     int
+	}{
+		{"no due date", http.MethodPost, "/todo", `{"title":"Water the plants","recurrence":"FREQ=DAILY"}`, http.StatusUnprocessableEntity},
+		{"unknown frequency", http.MethodPost, "/todo", `{"title":"Water the plants","due_date":"2024-01-31T09:00:00Z","recurrence":"FREQ=YEARLY"}`, http.StatusUnprocessableEntity},
+		{"bad interval", http.MethodPost, "/todo", `{"title":"Water the plants","due_date":"2024-01-31T09:00:00Z","recurrence":"FREQ=DAILY;INTERVAL=0"}`, http.StatusUnprocessableEntity},
+		{"bad series", http.MethodGet, "/todo?series=abc", "", http.StatusBadRequest},
+		{"zero series", http.MethodGet, "/todo?series=0", "", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, newJSONRequest(tt.method, tt.target, tt.body))
+
+			if rr.Code != tt.status {
+				t.Fatalf("expected status %d, got %d: %s", tt.status, rr.Code, rr.Body)
+			}
+
+			if tt.status == http.StatusUnprocessableEntity {
+				var body struct{ Errors ValidationErrors }
+				if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+					t.Fatal(err)
+				}
+				if len(body.Errors) != 1 || body.Errors[0].Field != "recurrence" {
+					t.Errorf("expected a recurrence field error, got %+v", body.Errors)
+				}
+			}
+		})
+	}
+}
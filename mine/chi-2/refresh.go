@@ -0,0 +1,194 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// defaultAccessTokenTTL is kept short, an access token can't be revoked
+	// so a client refreshes it instead of holding on to it.
+	defaultAccessTokenTTL  = 15 * time.Minute
+	defaultRefreshTokenTTL = 30 * 24 * time.Hour
+)
+
+// refreshTokens issues refresh tokens and tracks the active ones by their jti.
+// A refresh token is only honored while it is in the set, so POST /logout
+// revokes it by removing it, even though its signature stays valid until it
+// expires. The set lives in memory, a restart logs everyone out.
+type refreshTokens struct {
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+
+	mu sync.Mutex
+	// active holds the expiry of every issued token that wasn't revoked.
+	active map[string]time.Time
+}
+
+func newRefreshTokens(accessTTL, refreshTTL time.Duration) *refreshTokens {
+	return &refreshTokens{accessTTL: accessTTL, refreshTTL: refreshTTL, active: make(map[string]time.Time)}
+}
+
+// issue mints a refresh token for subject and roles. It is what the tests use,
+// and what a login endpoint would hand out next to the first access token.
+func (s *refreshTokens) issue(subject string, roles ...string) (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims := tokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        hex.EncodeToString(id),
+			Subject:   subject,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(s.refreshTTL)),
+		},
+		Roles: roles,
+		Use:   tokenUseRefresh,
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(jwtSecret)
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.prune(now)
+	s.active[claims.ID] = claims.ExpiresAt.Time
+	return token, nil
+}
+
+// prune forgets the expired tokens, their signature check fails anyway.
+func (s *refreshTokens) prune(now time.Time) {
+	for id, expires := range s.active {
+		if !expires.After(now) {
+			delete(s.active, id)
+		}
+	}
+}
+
+func (s *refreshTokens) isActive(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.active[id]
+	return ok
+}
+
+func (s *refreshTokens) revoke(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.active, id)
+}
+
+// parseRefreshToken verifies a refresh token, its signature and expiry the
+// same as parseToken does for an access token.
+func parseRefreshToken(token string) (*tokenClaims, error) {
+	claims, err := parseClaims(token)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.Use != tokenUseRefresh || claims.ID == "" {
+		return nil, errors.New("not a refresh token")
+	}
+
+	return claims, nil
+}
+
+// mount serves POST /refresh and POST /logout. Neither goes through
+// AuthMiddleware, the refresh token in the body is the credential.
+func (s *refreshTokens) mount(r chi.Router) {
+	r.Method(http.MethodPost, "/refresh", documented(routeDoc{Summary: "Trade a refresh token for an access token", Request: refreshPayload{}, Response: accessTokenResponse{}}, s.refresh))
+	r.Method(http.MethodPost, "/logout", documented(routeDoc{Summary: "Revoke a refresh token", Request: refreshPayload{}, Status: http.StatusNoContent}, s.logout))
+}
+
+type refreshPayload struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type accessTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	// ExpiresIn is in seconds.
+	ExpiresIn int `json:"expires_in"`
+}
+
+func (s *refreshTokens) refresh(w http.ResponseWriter, r *http.Request) {
+	claims, ok := s.decodeRefreshToken(w, r)
+	if !ok {
+		return
+	}
+
+	if !s.isActive(claims.ID) {
+		unauthorized(w, r, "refresh token has been revoked")
+		return
+	}
+
+	token, err := newTokenWithRoles(claims.Subject, s.accessTTL, claims.Roles...)
+	if err != nil {
+		logError(r, "failed to sign access token: %v", err)
+		respondError(w, r, http.StatusInternalServerError, codeInternal, "the server encountered a problem")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, accessTokenResponse{
+		AccessToken: token,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(s.accessTTL.Seconds()),
+	})
+}
+
+// logout revokes the refresh token. Logging out twice is not an error, the
+// token is revoked either way.
+func (s *refreshTokens) logout(w http.ResponseWriter, r *http.Request) {
+	claims, ok := s.decodeRefreshToken(w, r)
+	if !ok {
+		return
+	}
+
+	s.revoke(claims.ID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// decodeRefreshToken reads and verifies the refresh token in the body. It
+// returns false when a response has already been written.
+func (s *refreshTokens) decodeRefreshToken(w http.ResponseWriter, r *http.Request) (*tokenClaims, bool) {
+	var payload refreshPayload
+	if err := decodeJSON(r, &payload); err != nil {
+		respondDecodeError(w, r, err)
+		return nil, false
+	}
+	if payload.RefreshToken == "" {
+		writeValidationErrors(w, r, ValidationErrors{{Field: "refresh_token", Message: "is required"}})
+		return nil, false
+	}
+
+	claims, err := parseRefreshToken(payload.RefreshToken)
+	if err != nil {
+		logError(r, "rejected refresh token: %v", err)
+
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			unauthorized(w, r, "refresh token has expired")
+			return nil, false
+		}
+
+		unauthorized(w, r, "invalid refresh token")
+		return nil, false
+	}
+
+	return claims, true
+}
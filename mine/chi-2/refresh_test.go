@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+func postRefreshToken(r http.Handler, target, token string) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, newJSONRequest(http.MethodPost, target, `{"refresh_token":"`+token+`"}`))
+	return rr
+}
+
+func TestRefresh(t *testing.T) {
+	tokens := newRefreshTokens(time.Minute, time.Hour)
+	r := newAPIRouter(t, &todoHandler{store: newMemoryTodoStore()}, nil, tokens)
+
+	refresh, err := tokens.issue("root", roleAdmin)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := postRefreshToken(r, "/v1/refresh", refresh)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+	}
+
+	var body accessTokenResponse
+	if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body.TokenType != "Bearer" || body.ExpiresIn != 60 {
+		t.Errorf("unexpected response %+v", body)
+	}
+
+	claims, err := parseToken(body.AccessToken)
+	if err != nil {
+		t.Fatalf("expected a valid access token, got %v", err)
+	}
+	if claims.Subject != "root" || !slices.Equal(claims.Roles, []string{roleAdmin}) {
+		t.Errorf("expected the refresh token's subject and roles, got %q %v", claims.Subject, claims.Roles)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/admin/profile", nil)
+	req.Header.Set("Authorization", "Bearer "+body.AccessToken)
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected the new access token accepted, got status %d", rr.Code)
+	}
+
+	// the refresh token stays usable until it is revoked
+	if rr := postRefreshToken(r, "/v1/refresh", refresh); rr.Code != http.StatusOK {
+		t.Errorf("second refresh: expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+}
+
+func TestRefreshAfterLogout(t *testing.T) {
+	tokens := newRefreshTokens(time.Minute, time.Hour)
+	r := newAPIRouter(t, &todoHandler{store: newMemoryTodoStore()}, nil, tokens)
+
+	refresh, err := tokens.issue("ana")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := tokens.issue("ana")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rr := postRefreshToken(r, "/v1/logout", refresh); rr.Code != http.StatusNoContent {
+		t.Fatalf("logout: expected status %d, got %d", http.StatusNoContent, rr.Code)
+	}
+
+	rr := postRefreshToken(r, "/v1/refresh", refresh)
+	if rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected status %d, got %d", http.StatusUnauthorized, rr.Code)
+	}
+
+	var body errorResponse
+	if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Code != codeUnauthorized {
+		t.Errorf("expected code %q, got %q", codeUnauthorized, body.Code)
+	}
+
+	if rr := postRefreshToken(r, "/v1/logout", refresh); rr.Code != http.StatusNoContent {
+		t.Errorf("second logout: expected status %d, got %d", http.StatusNoContent, rr.Code)
+	}
+
+	// only the token that was logged out is revoked
+	if rr := postRefreshToken(r, "/v1/refresh", other); rr.Code != http.StatusOK {
+		t.Errorf("other session: expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+}
+
+func TestRefreshRejects(t *testing.T) {
+	tokens := newRefreshTokens(time.Minute, time.Hour)
+	r := newAPIRouter(t, &todoHandler{store: newMemoryTodoStore()}, nil, tokens)
+
+	expired, err := newRefreshTokens(time.Minute, -time.Minute).issue("ana")
+	if err != nil {
+		t.Fatal(err)
+	}
+	access := testToken(t, "ana")
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{name: "expired", body: `{"refresh_token":"` + expired + `"}`, status: http.StatusUnauthorized},
+		{name: "access token", body: `{"refresh_token":"` + access + `"}`, status: http.StatusUnauthorized},
+		{name: "garbage", body: `{"refresh_token":"not-a-jwt"}`, status: http.StatusUnauthorized},
+		{name: "missing", body: `{}`, status: http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, newJSONRequest(http.MethodPost, "/v1/refresh", tt.body))
+
+			if rr.Code != tt.status {
+				t.Errorf("expected status %d, got %d", tt.status, rr.Code)
+			}
+		})
+	}
+
+	t.Run("refresh token as bearer", func(t *testing.T) {
+		refresh, err := tokens.issue("ana")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/v1/todo", nil)
+		req.Header.Set("Authorization", "Bearer "+refresh)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusUnauthorized {
+			t.Errorf("expected status %d, got %d", http.StatusUnauthorized, rr.Code)
+		}
+	})
+}
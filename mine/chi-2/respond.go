@@ -0,0 +1,220 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// maxBodySize caps request bodies, todos are a title and a few fields.
+const maxBodySize = 1 << 20
+
+// Error codes give clients something stable to branch on, the message is
+// meant for humans and may change.
+const (
+	codeBadRequest           = "bad_request"
+	codeUnauthorized         = "unauthorized"
+	codeForbidden            = "forbidden"
+	codeNotFound             = "not_found"
+	codeMethodNotAllowed     = "method_not_allowed"
+	codePayloadTooLarge      = "payload_too_large"
+	codeUnsupportedMediaType = "unsupported_media_type"
+	codeValidationFailed     = "validation_failed"
+	codeTodoArchived         = "todo_archived"
+	codeListNotEmpty         = "list_not_empty"
+	codeVersionConflict      = "version_conflict"
+	codePreconditionRequired = "precondition_required"
+	codeTimeout              = "timeout"
+	codeRateLimited          = "rate_limited"
+	codeInternal             = "internal_error"
+)
+
+var (
+	ErrUnsupportedMediaType = errors.New("content type must be application/json")
+	ErrBodyTooLarge         = fmt.Errorf("request body must be at most %d bytes", maxBodySize)
+)
+
+// MalformedBodyError is a body that is not valid JSON for the destination.
+// Err keeps the decoder error so callers can still look for their own types
+// in it, like *ErrInvalidPriority.
+type MalformedBodyError struct {
+	Message string
+	Err     error
+}
+
+func (e *MalformedBodyError) Error() string {
+	return e.Message
+}
+
+func (e *MalformedBodyError) Unwrap() error {
+	return e.Err
+}
+
+type errorResponse struct {
+	Error     string `json:"error"`
+	Code      string `json:"code"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+func respondJSON(w http.ResponseWriter, status int, data any) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	return json.NewEncoder(w).Encode(data)
+}
+
+// respondError writes a JSON error carrying the id middleware.RequestID put
+// on the request, the same id the request log line has.
+func respondError(w http.ResponseWriter, r *http.Request, status int, code, message string) {
+	respondJSON(w, status, errorResponse{
+		Error:     message,
+		Code:      code,
+		RequestID: middleware.GetReqID(r.Context()),
+	})
+}
+
+// decodeJSON reads a single JSON value from the body into dst. The body must
+// be declared as application/json, fit in maxBodySize and only use fields dst
+// knows about. Errors are ErrUnsupportedMediaType, ErrBodyTooLarge or a
+// *MalformedBodyError, respondDecodeError turns them into a response.
+func decodeJSON(r *http.Request, dst any) error {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		return ErrUnsupportedMediaType
+	}
+
+	dec := json.NewDecoder(http.MaxBytesReader(nil, r.Body, maxBodySize))
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(dst); err != nil {
+		return decodeError(err)
+	}
+
+	if dec.More() {
+		return &MalformedBodyError{Message: "request body must hold a single JSON value"}
+	}
+
+	return nil
+}
+
+func decodeError(err error) error {
+	var (
+		tooLarge  *http.MaxBytesError
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+	)
+
+	switch {
+	case errors.As(err, &tooLarge):
+		return ErrBodyTooLarge
+	case errors.Is(err, io.EOF):
+		return &MalformedBodyError{Message: "request body is empty", Err: err}
+	case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF):
+		return &MalformedBodyError{Message: "request body is not valid JSON", Err: err}
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		return &MalformedBodyError{Message: fmt.Sprintf("field %q must be a %s", typeErr.Field, typeErr.Type), Err: err}
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// the decoder has no typed error for this one
+		return &MalformedBodyError{Message: strings.TrimPrefix(err.Error(), "json: "), Err: err}
+	default:
+		return &MalformedBodyError{Message: "invalid request body", Err: err}
+	}
+}
+
+// respondDecodeError maps a decodeJSON error to 415, 413 or 400.
+func respondDecodeError(w http.ResponseWriter, r *http.Request, err error) {
+	var malformed *MalformedBodyError
+
+	switch {
+	case errors.Is(err, ErrUnsupportedMediaType):
+		respondError(w, r, http.StatusUnsupportedMediaType, codeUnsupportedMediaType, err.Error())
+	case errors.Is(err, ErrBodyTooLarge):
+		respondError(w, r, http.StatusRequestEntityTooLarge, codePayloadTooLarge, err.Error())
+	case errors.As(err, &malformed):
+		respondError(w, r, http.StatusBadRequest, codeBadRequest, malformed.Message)
+	default:
+		respondError(w, r, http.StatusBadRequest, codeBadRequest, "invalid request body")
+	}
+}
+
+// logError logs err prefixed with the request id, matching the format
+// middleware.Logger uses.
+func logError(r *http.Request, format string, args ...any) {
+	// the id may come from the client, so it stays an argument, never format
+	if id := middleware.GetReqID(r.Context()); id != "" {
+		format, args = "[%s] "+format, append([]any{id}, args...)
+	}
+
+	log.Printf(format, args...)
+}
+
+// useJSONRouteErrors replaces chi's plain text 404 and 405 with the JSON
+// error envelope. Call it before mounting or grouping anything, chi copies the
+// handlers into subrouters at that point.
+func useJSONRouteErrors(r *chi.Mux) {
+	r.NotFound(func(w http.ResponseWriter, r *http.Request) {
+		respondError(w, r, http.StatusNotFound, codeNotFound, "no route for "+r.URL.Path)
+	})
+
+	// chi only sets Allow in its own 405 handler, so work it out again from
+	// the route tree
+	r.MethodNotAllowed(func(w http.ResponseWriter, req *http.Request) {
+		for _, method := range allowedMethods(r, req.URL.Path) {
+			w.Header().Add("Allow", method)
+		}
+
+		respondError(w, req, http.StatusMethodNotAllowed, codeMethodNotAllowed,
+			fmt.Sprintf("method %s is not allowed on %s", req.Method, req.URL.Path))
+	})
+}
+
+// allowedMethods lists the methods routes has for path. Mux.Match can't be
+// used for this, it reports every method on the path of a mounted router.
+func allowedMethods(routes chi.Routes, path string) []string {
+	var allowed []string
+	chi.Walk(routes, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		if routeMatches(route, path) && !slices.Contains(allowed, method) {
+			allowed = append(allowed, method)
+		}
+		return nil
+	})
+
+	slices.Sort(allowed)
+	return allowed
+}
+
+// routeMatches reports whether a chi pattern like /todo/{todoID}/ matches
+// path, ignoring trailing slashes. A {param} matches any one segment and a
+// trailing * the rest of the path.
+func routeMatches(route, path string) bool {
+	routeParts := strings.Split(strings.Trim(route, "/"), "/")
+	pathParts := strings.Split(strings.Trim(path, "/"), "/")
+
+	for i, part := range routeParts {
+		if part == "*" {
+			return true
+		}
+		if i >= len(pathParts) {
+			return false
+		}
+		if strings.HasPrefix(part, "{") {
+			if pathParts[i] == "" {
+				return false
+			}
+			continue
+		}
+		if part != pathParts[i] {
+			return false
+		}
+	}
+
+	return len(routeParts) == len(pathParts)
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// brokenStore fails every Get as if the database were down.
+type brokenStore struct {
+	TodoStore
+}
+
+func (brokenStore) Get(context.Context, int64) (Todo, error) {
+	return Todo{}, errors.New("connection refused")
+}
+
+func newRequestIDRouter(store TodoStore) http.Handler {
+	r := chi.NewRouter()
+	r.Use(middleware.RequestID)
+	r.Mount("/todo", (&todoHandler{store: store}).routes())
+
+	return r
+}
+
+func TestErrorResponseRequestID(t *testing.T) {
+	r := newRequestIDRouter(newMemoryTodoStore())
+
+	req := httptest.NewRequest(http.MethodGet, "/todo/999", nil)
+	req.Header.Set(middleware.RequestIDHeader, "req-404")
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected status %d, got %d", http.StatusNotFound, rr.Code)
+	}
+
+	var body errorResponse
+	if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+
+	want := errorResponse{Error: "todo not found", Code: codeNotFound, RequestID: "req-404"}
+	if body != want {
+		t.Errorf("expected %+v, got %+v", want, body)
+	}
+}
+
+func TestValidationErrorRequestID(t *testing.T) {
+	r := newRequestIDRouter(newMemoryTodoStore())
+
+	req := newJSONRequest(http.MethodPost, "/todo", `{"title":""}`)
+	req.Header.Set(middleware.RequestIDHeader, "req-422")
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	var body struct {
+		Code      string `json:"code"`
+		RequestID string `json:"request_id"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+
+	if rr.Code != http.StatusUnprocessableEntity || body.Code != codeValidationFailed || body.RequestID != "req-422" {
+		t.Errorf("unexpected %d response %+v", rr.Code, body)
+	}
+}
+
+func TestInternalErrorLogsRequestID(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	r := newRequestIDRouter(brokenStore{newMemoryTodoStore()})
+
+	req := httptest.NewRequest(http.MethodGet, "/todo/1", nil)
+	req.Header.Set(middleware.RequestIDHeader, "req-500")
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Fatalf("expected status %d, got %d", http.StatusInternalServerError, rr.Code)
+	}
+
+	if !strings.Contains(rr.Body.String(), `"request_id":"req-500"`) {
+		t.Errorf("expected the request id in the body, got %s", rr.Body)
+	}
+
+	if !strings.Contains(logs.String(), "[req-500] failed to get todo: connection refused") {
+		t.Errorf("expected the request id in the log, got %q", logs.String())
+	}
+}
+
+func TestDecodeJSON(t *testing.T) {
+	type payload struct {
+		Title string `json:"title"`
+		Done  bool   `json:"done"`
+	}
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        error
+		message     string
+	}{
+		{name: "valid", contentType: "application/json", body: `{"title":"a","done":true}`},
+		{name: "charset parameter", contentType: "application/json; charset=utf-8", body: `{"title":"a"}`},
+		{name: "missing content type", body: `{"title":"a"}`, want: ErrUnsupportedMediaType},
+		{name: "form content type", contentType: "application/x-www-form-urlencoded", body: `title=a`, want: ErrUnsupportedMediaType},
+		{name: "too large", contentType: "application/json", body: `{"title":"` + strings.Repeat("a", maxBodySize) + `"}`, want: ErrBodyTooLarge},
+		{name: "empty", contentType: "application/json", message: "request body is empty"},
+		{name: "syntax error", contentType: "application/json", body: `{"title":`, message: "request body is not valid JSON"},
+		{name: "wrong type", contentType: "application/json", body: `{"done":"yes"}`, message: `field "done" must be a bool`},
+		{name: "unknown field", contentType: "application/json", body: `{"title":"a","owner":"bob"}`, message: `unknown field "owner"`},
+		{name: "trailing value", contentType: "application/json", body: `{"title":"a"}{"title":"b"}`, message: "request body must hold a single JSON value"},
+		{name: "trailing garbage", contentType: "application/json", body: `{"title":"a"} x`, message: "request body must hold a single JSON value"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				r.Header.Set("Content-Type", tt.contentType)
+			}
+
+			var dst payload
+			err := decodeJSON(r, &dst)
+
+			if tt.message != "" {
+				var malformed *MalformedBodyError
+				if !errors.As(err, &malformed) || malformed.Message != tt.message {
+					t.Fatalf("expected a malformed body error %q, got %v", tt.message, err)
+				}
+				return
+			}
+
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, err)
+			}
+
+			if err == nil && dst.Title != "a" {
+				t.Errorf("expected the body to be decoded, got %+v", dst)
+			}
+		})
+	}
+}
+
+func TestDecodeJSONKeepsCause(t *testing.T) {
+	r := newJSONRequest(http.MethodPost, "/", `{"priority":"urgent"}`)
+
+	var dst struct {
+		Priority Priority `json:"priority"`
+	}
+
+	var priorityErr *ErrInvalidPriority
+	if err := decodeJSON(r, &dst); !errors.As(err, &priorityErr) {
+		t.Errorf("expected the priority error to be reachable, got %v", err)
+	}
+}
+
+func TestRespondDecodeError(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int
+		code   string
+		msg    string
+	}{
+		{ErrUnsupportedMediaType, http.StatusUnsupportedMediaType, codeUnsupportedMediaType, ErrUnsupportedMediaType.Error()},
+		{ErrBodyTooLarge, http.StatusRequestEntityTooLarge, codePayloadTooLarge, ErrBodyTooLarge.Error()},
+		{&MalformedBodyError{Message: `unknown field "owner"`}, http.StatusBadRequest, codeBadRequest, `unknown field "owner"`},
+		{errors.New("something else"), http.StatusBadRequest, codeBadRequest, "invalid request body"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			respondDecodeError(rr, httptest.NewRequest(http.MethodPost, "/", nil), tt.err)
+
+			if rr.Code != tt.status {
+				t.Fatalf("expected status %d, got %d", tt.status, rr.Code)
+			}
+
+			var body errorResponse
+			if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+
+			if want := (errorResponse{Error: tt.msg, Code: tt.code}); body != want {
+				t.Errorf("expected %+v, got %+v", want, body)
+			}
+		})
+	}
+}
+
+func TestRespondJSON(t *testing.T) {
+	rr := httptest.NewRecorder()
+	respondJSON(rr, http.StatusAccepted, map[string]int{"n": 1})
+
+	if rr.Code != http.StatusAccepted || rr.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("unexpected status %d and content type %q", rr.Code, rr.Header().Get("Content-Type"))
+	}
+
+	if got := rr.Body.String(); got != "{\"n\":1}\n" {
+		t.Errorf("unexpected body %q", got)
+	}
+}
+
+func TestJSONRouteErrors(t *testing.T) {
+	r := chi.NewRouter()
+	r.Use(middleware.RequestID)
+	useJSONRouteErrors(r)
+	r.Get("/", helloWorldHandler)
+	r.Mount("/todo", (&todoHandler{store: newMemoryTodoStore()}).routes())
+
+	tests := []struct {
+		method, target string
+		status         int
+		code           string
+		allow          []string
+	}{
+		{method: http.MethodGet, target: "/nope", status: http.StatusNotFound, code: codeNotFound},
+		{method: http.MethodGet, target: "/todo/1/nope", status: http.StatusNotFound, code: codeNotFound},
+		{method: http.MethodPatch, target: "/", status: http.StatusMethodNotAllowed, code: codeMethodNotAllowed, allow: []string{http.MethodGet}},
+		{method: http.MethodPatch, target: "/todo/1", status: http.StatusMethodNotAllowed, code: codeMethodNotAllowed, allow: []string{http.MethodDelete, http.MethodGet, http.MethodPut}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.target, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, nil)
+			req.Header.Set(middleware.RequestIDHeader, "req-route")
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			if rr.Code != tt.status {
+				t.Fatalf("expected status %d, got %d", tt.status, rr.Code)
+			}
+			if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("expected application/json, got %q", ct)
+			}
+
+			var body errorResponse
+			if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			if body.Code != tt.code || body.RequestID != "req-route" {
+				t.Errorf("unexpected body %+v", body)
+			}
+
+			if got := rr.Header().Values("Allow"); strings.Join(got, ",") != strings.Join(tt.allow, ",") {
+				t.Errorf("expected Allow %v, got %v", tt.allow, got)
+			}
+		})
+	}
+}
+
+func TestRouteMatches(t *testing.T) {
+	tests := []struct {
+		route, path string
+		want        bool
+	}{
+		{"/", "/", true},
+		{"/todo/", "/todo", true},
+		{"/todo/{todoID}/", "/todo/1", true},
+		{"/todo/{todoID}/complete", "/todo/1/complete", true},
+		{"/todo/{todoID}/complete", "/todo/1", false},
+		{"/todo/{todoID}/", "/todo/1/complete", false},
+		{"/todo/{todoID}/", "/todo//", false},
+		{"/static/*", "/static/css/app.css", true},
+	}
+
+	for _, tt := range tests {
+		if got := routeMatches(tt.route, tt.path); got != tt.want {
+			t.Errorf("routeMatches(%q, %q) = %v, want %v", tt.route, tt.path, got, tt.want)
+		}
+	}
+}
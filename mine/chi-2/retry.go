@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+const (
+	firstPingBackoff = 500 * time.Millisecond
+	maxPingBackoff   = 10 * time.Second
+)
+
+// pinger is the part of *sql.DB pingWithRetry needs.
+type pinger interface {
+	PingContext(ctx context.Context) error
+}
+
+// pingWithRetry pings db up to attempts times, waiting backoff after the first
+// failure and doubling the wait after every further one, up to
+// maxPingBackoff. It is for startup, where the database container may still
+// be booting, and returns the last error once the attempts run out. Fewer
+// than one attempt still pings once.
+func pingWithRetry(ctx context.Context, db pinger, attempts int, backoff time.Duration) error {
+	attempts = max(attempts, 1)
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if err = db.PingContext(ctx); err == nil {
+			if attempt > 1 {
+				log.Printf("database is up after %d attempts", attempt)
+			}
+			return nil
+		}
+
+		if attempt == attempts {
+			break
+		}
+
+		log.Printf("database not ready (attempt %d of %d), retrying in %s: %v", attempt, attempts, backoff, err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+
+		backoff = min(backoff*2, maxPingBackoff)
+	}
+
+	return fmt.Errorf("database not ready after %d attempts: %w", attempts, err)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// flakyDB fails the first failures pings, like a database that is still
+// starting.
+type flakyDB struct {
+	failures int
+	pings    int
+}
+
+func (db *flakyDB) PingContext(context.Context) error {
+	db.pings++
+	if db.pings <= db.failures {
+		return errors.New("connection refused")
+	}
+
+	return nil
+}
+
+func TestPingWithRetry(t *testing.T) {
+	db := &flakyDB{failures: 3}
+
+	if err := pingWithRetry(context.Background(), db, 5, time.Millisecond); err != nil {
+		t.Fatalf("expected the ping to succeed, got %v", err)
+	}
+	if db.pings != 4 {
+		t.Errorf("expected 4 pings, got %d", db.pings)
+	}
+}
+
+func TestPingWithRetryGivesUp(t *testing.T) {
+	db := &flakyDB{failures: 10}
+
+	err := pingWithRetry(context.Background(), db, 3, time.Millisecond)
+	if err == nil || err.Error() != "database not ready after 3 attempts: connection refused" {
+		t.Fatalf("expected the last ping error, got %v", err)
+	}
+	if db.pings != 3 {
+		t.Errorf("expected 3 pings, got %d", db.pings)
+	}
+}
+
+func TestPingWithRetryPingsAtLeastOnce(t *testing.T) {
+	for _, attempts := range []int{0, -1} {
+		db := &flakyDB{}
+		if err := pingWithRetry(context.Background(), db, attempts, time.Millisecond); err != nil {
+			t.Errorf("%d attempts: expected the ping to succeed, got %v", attempts, err)
+		}
+		if db.pings != 1 {
+			t.Errorf("%d attempts: expected 1 ping, got %d", attempts, db.pings)
+		}
+	}
+
+	err := pingWithRetry(context.Background(), &flakyDB{failures: 1}, 0, time.Millisecond)
+	if err == nil || err.Error() != "database not ready after 1 attempts: connection refused" {
+		t.Errorf("expected the ping error, got %v", err)
+	}
+}
+
+func TestPingWithRetryCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	db := &flakyDB{failures: 10}
+	if err := pingWithRetry(ctx, db, 5, time.Hour); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if db.pings != 1 {
+		t.Errorf("expected to stop after the first ping, got %d", db.pings)
+	}
+}
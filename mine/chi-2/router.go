@@ -0,0 +1,104 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// routerConfig is what buildRouter takes from the flags and the environment.
+// Zero durations and attempts fall back to their defaults.
+type routerConfig struct {
+	store TodoStore
+	// dbPath is the SQLite file, whose directory /healthz checks is
+	// writable.
+	dbPath          string
+	logger          *slog.Logger
+	trashRetention  time.Duration
+	requestTimeout  time.Duration
+	webhookAttempts int
+	// webhookAllowPrivate lets webhooks point to loopback and private
+	// addresses, see webhookHandler.
+	webhookAllowPrivate bool
+	accessTokenTTL      time.Duration
+	refreshTokenTTL     time.Duration
+	// rateLimit is how many requests per second a client IP gets back, 0
+	// turns rate limiting off. rateBurst is the size of its bucket. Only the
+	// /v1 routes are limited.
+	rateLimit float64
+	rateBurst int
+	// timezone is where ?due=today starts and ends; nil means UTC.
+	timezone *time.Location
+	// debug logs the request and response bodies, see bodyLogger.
+	debug bool
+}
+
+// app is the router along with the parts main drives from outside of it: it
+// runs the webhook dispatcher and waits on the drain. tokens is there for a
+// login endpoint, and the tests, to issue refresh tokens.
+type app struct {
+	router     *chi.Mux
+	drain      *drainer
+	dispatcher *webhookDispatcher
+	tokens     *refreshTokens
+}
+
+// buildRouter wires every route and middleware of the server. main serves
+// the result, the router tests run it under httptest.
+func buildRouter(cfg routerConfig) (*app, error) {
+	events := newTodoEvents()
+	todos := &todoHandler{
+		store:          &notifyingStore{TodoStore: cfg.store, events: events},
+		events:         events,
+		trashRetention: cfg.trashRetention,
+		timeout:        cfg.requestTimeout,
+		location:       cfg.timezone,
+	}
+	webhooks := newWebhookRegistry()
+	dispatcher := newWebhookDispatcher(events, webhooks, cmp.Or(cfg.webhookAttempts, defaultWebhookAttempts), cfg.webhookAllowPrivate)
+
+	r := chi.NewRouter()
+	r.Use(middleware.RequestID)
+	r.Use(requestLogger(cfg.logger))
+	if cfg.debug {
+		r.Use(bodyLogger(cfg.logger))
+	}
+	metrics := newHTTPMetrics()
+	r.Use(metrics.middleware)
+	useJSONRouteErrors(r)
+
+	r.Method(http.MethodGet, "/metrics", documented(routeDoc{Summary: "Scrape request metrics", ContentType: "text/plain"}, metrics.ServeHTTP))
+	spec := &openAPISpec{}
+	r.Method(http.MethodGet, "/openapi.json", documented(routeDoc{Summary: "This document"}, spec.ServeHTTP))
+	drain := newDrainer()
+	drain.mount(r)
+
+	health := newHealthChecks(drain, defaultHealthCheckTimeout)
+	if p, ok := cfg.store.(interface{ Ping(context.Context) error }); ok {
+		health.register("store", p.Ping)
+	}
+	if _, ok := cfg.store.(*sqliteTodoStore); ok && cfg.dbPath != "" {
+		health.register("disk", dirWritable(filepath.Dir(cfg.dbPath)))
+	}
+	health.register("webhooks", dispatcher.checkQueue)
+	health.mount(r)
+
+	tokens := newRefreshTokens(cmp.Or(cfg.accessTokenTTL, defaultAccessTokenTTL), cmp.Or(cfg.refreshTokenTTL, defaultRefreshTokenTTL))
+	var apiMiddlewares []func(http.Handler) http.Handler
+	if cfg.rateLimit > 0 {
+		apiMiddlewares = append(apiMiddlewares, newRateLimiter(cfg.rateLimit, cmp.Or(cfg.rateBurst, defaultRateBurst)).middleware)
+	}
+	mountAPI(r, todos, &webhookHandler{registry: webhooks, allowPrivate: cfg.webhookAllowPrivate}, tokens, apiMiddlewares...)
+
+	if err := spec.build(r); err != nil {
+		return nil, err
+	}
+
+	return &app{router: r, drain: drain, dispatcher: dispatcher, tokens: tokens}, nil
+}
@@ -0,0 +1,473 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"golang.org/x/net/websocket"
+)
+
+// routeAccess is who a route lets in, the suite checks the ones below it are
+// turned away.
+type routeAccess int
+
+const (
+	public routeAccess = iota
+	// user routes answer 401 without a token.
+	user
+	// admin routes answer 401 without a token and 403 to a user.
+	admin
+)
+
+// routeRequest is a request against the seeded server, see newRouterServer.
+type routeRequest struct {
+	name string
+	// as is "", "ana", "bob" or "root", the admin.
+	as     string
+	target string
+	body   string
+	header map[string]string
+	status int
+}
+
+// routeCoverage lists the requests for one route of buildRouter, keyed the way
+// chi.Walk reports it. A method of "*" covers a route registered with
+// r.Handle, which answers every method.
+type routeCoverage struct {
+	method   string
+	route    string
+	access   routeAccess
+	requests []routeRequest
+}
+
+// coveredRoutes must list every route the router serves: TestRouterCoverage
+// fails for a route missing here, so a new route needs its cases before it
+// can be merged.
+var coveredRoutes = []routeCoverage{
+	{method: "*", route: "/", requests: []routeRequest{
+		{name: "redirects", target: "/", status: http.StatusPermanentRedirect},
+	}},
+	{method: "*", route: "/todo", requests: []routeRequest{
+		{name: "redirects", target: "/todo", status: http.StatusPermanentRedirect},
+	}},
+	{method: "*", route: "/todo/*", requests: []routeRequest{
+		{name: "redirects", target: "/todo/1", status: http.StatusPermanentRedirect},
+	}},
+	{method: "GET", route: "/healthz", requests: []routeRequest{
+		{name: "ready", target: "/healthz", status: http.StatusOK},
+	}},
+	{method: "GET", route: "/metrics", requests: []routeRequest{
+		{name: "scrape", target: "/metrics", status: http.StatusOK},
+	}},
+	{method: "GET", route: "/openapi.json", requests: []routeRequest{
+		{name: "document", target: "/openapi.json", status: http.StatusOK},
+	}},
+	{method: "GET", route: "/version", requests: []routeRequest{
+		{name: "version", target: "/version", status: http.StatusOK},
+	}},
+	{method: "POST", route: "/internal/drain", access: admin, requests: []routeRequest{
+		{name: "drain", as: "root", target: "/internal/drain", status: http.StatusAccepted},
+	}},
+	{method: "GET", route: "/v1/", requests: []routeRequest{
+		{name: "hello", target: "/v1/", status: http.StatusOK},
+	}},
+	{method: "POST", route: "/v1/refresh", requests: []routeRequest{
+		{name: "refresh", target: "/v1/refresh", body: `{"refresh_token":"{refresh}"}`, status: http.StatusOK},
+		{name: "bad token", target: "/v1/refresh", body: `{"refresh_token":"not-a-jwt"}`, status: http.StatusUnauthorized},
+		{name: "no token", target: "/v1/refresh", body: `{}`, status: http.StatusUnprocessableEntity},
+	}},
+	{method: "POST", route: "/v1/logout", requests: []routeRequest{
+		{name: "logout", target: "/v1/logout", body: `{"refresh_token":"{refresh}"}`, status: http.StatusNoContent},
+		{name: "bad token", target: "/v1/logout", body: `{"refresh_token":"not-a-jwt"}`, status: http.StatusUnauthorized},
+	}},
+	{method: "GET", route: "/v1/todo/", access: user, requests: []routeRequest{
+		{name: "list", as: "ana", target: "/v1/todo", status: http.StatusOK},
+		{name: "bad limit", as: "ana", target: "/v1/todo?limit=0", status: http.StatusBadRequest},
+		{name: "all as a user", as: "ana", target: "/v1/todo?all=true", status: http.StatusForbidden},
+		{name: "all as an admin", as: "root", target: "/v1/todo?all=true", status: http.StatusOK},
+	}},
+	{method: "POST", route: "/v1/todo/", access: user, requests: []routeRequest{
+		{name: "create", as: "ana", target: "/v1/todo", body: `{"title":"walk the dog"}`, status: http.StatusCreated},
+		{name: "no title", as: "ana", target: "/v1/todo", body: `{"title":""}`, status: http.StatusUnprocessableEntity},
+		{name: "not JSON", as: "ana", target: "/v1/todo", body: `{`, status: http.StatusBadRequest},
+	}},
+	{method: "GET", route: "/v1/todo/events", access: user, requests: []routeRequest{
+		{name: "stream", as: "ana", target: "/v1/todo/events", status: http.StatusOK},
+		{name: "bad Last-Event-ID", as: "ana", target: "/v1/todo/events", header: map[string]string{"Last-Event-ID": "x"}, status: http.StatusBadRequest},
+	}},
+	{method: "GET", route: "/v1/todo/ws", access: user, requests: []routeRequest{
+		{name: "not an upgrade", as: "ana", target: "/v1/todo/ws", status: http.StatusBadRequest},
+	}},
+	{method: "GET", route: "/v1/todo/tags", access: user, requests: []routeRequest{
+		{name: "tags", as: "ana", target: "/v1/todo/tags", status: http.StatusOK},
+	}},
+	{method: "GET", route: "/v1/todo/stats", access: user, requests: []routeRequest{
+		{name: "stats", as: "ana", target: "/v1/todo/stats", status: http.StatusOK},
+	}},
+	{method: "GET", route: "/v1/todo/search", access: user, requests: []routeRequest{
+		{name: "search", as: "ana", target: "/v1/todo/search?q=milk", status: http.StatusOK},
+		{name: "no q", as: "ana", target: "/v1/todo/search", status: http.StatusBadRequest},
+	}},
+	{method: "GET", route: "/v1/todo/trash", access: user, requests: []routeRequest{
+		{name: "trash", as: "ana", target: "/v1/todo/trash", status: http.StatusOK},
+		{name: "bad limit", as: "ana", target: "/v1/todo/trash?limit=abc", status: http.StatusBadRequest},
+	}},
+	{method: "DELETE", route: "/v1/todo/trash", access: user, requests: []routeRequest{
+		{name: "purge", as: "ana", target: "/v1/todo/trash", status: http.StatusOK},
+	}},
+	{method: "GET", route: "/v1/todo/export.ics", access: user, requests: []routeRequest{
+		{name: "export", as: "ana", target: "/v1/todo/export.ics", status: http.StatusOK},
+	}},
+	{method: "POST", route: "/v1/todo/bulk/complete", access: user, requests: []routeRequest{
+		{name: "complete", as: "ana", target: "/v1/todo/bulk/complete", body: `{"ids":[1]}`, status: http.StatusOK},
+		{name: "no ids", as: "ana", target: "/v1/todo/bulk/complete", body: `{"ids":[]}`, status: http.StatusBadRequest},
+	}},
+	{method: "POST", route: "/v1/todo/complete", access: user, requests: []routeRequest{
+		{name: "complete", as: "ana", target: "/v1/todo/complete", body: `{"ids":[1,99]}`, status: http.StatusOK},
+		{name: "no ids", as: "ana", target: "/v1/todo/complete", body: `{"ids":[]}`, status: http.StatusBadRequest},
+	}},
+	{method: "POST", route: "/v1/todo/import", access: user, requests: []routeRequest{
+		{name: "import", as: "ana", target: "/v1/todo/import", body: `[{"title":"water the plants"}]`, status: http.StatusOK},
+		{name: "not an array", as: "ana", target: "/v1/todo/import", body: `{"title":"water the plants"}`, status: http.StatusBadRequest},
+	}},
+	{method: "PUT", route: "/v1/todo/{clientID:" + clientIDPattern + "}", access: user, requests: []routeRequest{
+		{name: "create", as: "ana", target: "/v1/todo/0b6e1c52-3f5d-4c1e-9a7e-2d8f4b6a9c10", body: `{"title":"buy oat milk"}`, status: http.StatusCreated},
+		{name: "invalid", as: "ana", target: "/v1/todo/0b6e1c52-3f5d-4c1e-9a7e-2d8f4b6a9c10", body: `{"title":""}`, status: http.StatusUnprocessableEntity},
+	}},
+	{method: "GET", route: "/v1/todo/{todoID}/", access: user, requests: []routeRequest{
+		{name: "get", as: "ana", target: "/v1/todo/1", status: http.StatusOK},
+		{name: "missing", as: "ana", target: "/v1/todo/99", status: http.StatusNotFound},
+		{name: "bad id", as: "ana", target: "/v1/todo/abc", status: http.StatusBadRequest},
+		{name: "someone else's", as: "bob", target: "/v1/todo/1", status: http.StatusNotFound},
+	}},
+	{method: "PUT", route: "/v1/todo/{todoID}/", access: user, requests: []routeRequest{
+		{name: "replace", as: "ana", target: "/v1/todo/1", body: `{"title":"buy oat milk","version":1}`, status: http.StatusOK},
+		{name: "no version", as: "ana", target: "/v1/todo/1", body: `{"title":"buy oat milk"}`, status: http.StatusPreconditionRequired},
+		{name: "stale version", as: "ana", target: "/v1/todo/1", body: `{"title":"buy oat milk","version":7}`, status: http.StatusConflict},
+	}},
+	{method: "DELETE", route: "/v1/todo/{todoID}/", access: user, requests: []routeRequest{
+		{name: "delete", as: "ana", target: "/v1/todo/1", status: http.StatusNoContent},
+		{name: "missing", as: "ana", target: "/v1/todo/99", status: http.StatusNotFound},
+	}},
+	{method: "PATCH", route: "/v1/todo/{todoID}/complete", access: user, requests: []routeRequest{
+		{name: "complete", as: "ana", target: "/v1/todo/1/complete", header: map[string]string{"If-Match": `"1"`}, status: http.StatusOK},
+		{name: "no version", as: "ana", target: "/v1/todo/1/complete", status: http.StatusPreconditionRequired},
+	}},
+	{method: "PATCH", route: "/v1/todo/{todoID}/uncomplete", access: user, requests: []routeRequest{
+		{name: "uncomplete", as: "ana", target: "/v1/todo/1/uncomplete", header: map[string]string{"If-Match": `"1"`}, status: http.StatusOK},
+		{name: "missing", as: "ana", target: "/v1/todo/99/uncomplete", header: map[string]string{"If-Match": `"1"`}, status: http.StatusNotFound},
+	}},
+	{method: "POST", route: "/v1/todo/{todoID}/archive", access: user, requests: []routeRequest{
+		{name: "archive", as: "ana", target: "/v1/todo/1/archive", status: http.StatusOK},
+		{name: "missing", as: "ana", target: "/v1/todo/99/archive", status: http.StatusNotFound},
+	}},
+	{method: "POST", route: "/v1/todo/{todoID}/unarchive", access: user, requests: []routeRequest{
+		{name: "unarchive", as: "ana", target: "/v1/todo/1/unarchive", status: http.StatusOK},
+		{name: "missing", as: "ana", target: "/v1/todo/99/unarchive", status: http.StatusNotFound},
+	}},
+	{method: "POST", route: "/v1/todo/{todoID}/restore", access: user, requests: []routeRequest{
+		{name: "restore", as: "ana", target: "/v1/todo/2/restore", status: http.StatusOK},
+		{name: "not in the trash", as: "ana", target: "/v1/todo/1/restore", status: http.StatusNotFound},
+	}},
+	{method: "GET", route: "/v1/todo/{todoID}/subtasks/", access: user, requests: []routeRequest{
+		{name: "list", as: "ana", target: "/v1/todo/1/subtasks", status: http.StatusOK},
+		{name: "missing todo", as: "ana", target: "/v1/todo/99/subtasks", status: http.StatusNotFound},
+	}},
+	{method: "POST", route: "/v1/todo/{todoID}/subtasks/", access: user, requests: []routeRequest{
+		{name: "add", as: "ana", target: "/v1/todo/1/subtasks", body: `{"title":"check the fridge"}`, status: http.StatusCreated},
+		{name: "no title", as: "ana", target: "/v1/todo/1/subtasks", body: `{"title":""}`, status: http.StatusUnprocessableEntity},
+	}},
+	{method: "PATCH", route: "/v1/todo/{todoID}/subtasks/{subtaskID}", access: user, requests: []routeRequest{
+		{name: "update", as: "ana", target: "/v1/todo/1/subtasks/1", body: `{"done":true}`, status: http.StatusOK},
+		{name: "missing", as: "ana", target: "/v1/todo/1/subtasks/99", body: `{"done":true}`, status: http.StatusNotFound},
+	}},
+	{method: "DELETE", route: "/v1/todo/{todoID}/subtasks/{subtaskID}", access: user, requests: []routeRequest{
+		{name: "delete", as: "ana", target: "/v1/todo/1/subtasks/1", status: http.StatusNoContent},
+		{name: "missing", as: "ana", target: "/v1/todo/1/subtasks/99", status: http.StatusNotFound},
+	}},
+	{method: "GET", route: "/v1/lists/", access: user, requests: []routeRequest{
+		{name: "list", as: "ana", target: "/v1/lists", status: http.StatusOK},
+	}},
+	{method: "POST", route: "/v1/lists/", access: user, requests: []routeRequest{
+		{name: "create", as: "ana", target: "/v1/lists", body: `{"name":"garden"}`, status: http.StatusCreated},
+		{name: "no name", as: "ana", target: "/v1/lists", body: `{"name":""}`, status: http.StatusUnprocessableEntity},
+	}},
+	{method: "GET", route: "/v1/lists/{listID}/", access: user, requests: []routeRequest{
+		{name: "get", as: "ana", target: "/v1/lists/1", status: http.StatusOK},
+		{name: "someone else's", as: "bob", target: "/v1/lists/1", status: http.StatusNotFound},
+		{name: "bad id", as: "ana", target: "/v1/lists/abc", status: http.StatusBadRequest},
+	}},
+	{method: "PATCH", route: "/v1/lists/{listID}/", access: user, requests: []routeRequest{
+		{name: "rename", as: "ana", target: "/v1/lists/1", body: `{"name":"shopping"}`, status: http.StatusOK},
+		{name: "missing", as: "ana", target: "/v1/lists/99", body: `{"name":"shopping"}`, status: http.StatusNotFound},
+	}},
+	{method: "DELETE", route: "/v1/lists/{listID}/", access: user, requests: []routeRequest{
+		{name: "not empty", as: "ana", target: "/v1/lists/1", status: http.StatusConflict},
+		{name: "force", as: "ana", target: "/v1/lists/1?force=true", status: http.StatusNoContent},
+	}},
+	{method: "GET", route: "/v1/lists/{listID}/todos", access: user, requests: []routeRequest{
+		{name: "list", as: "ana", target: "/v1/lists/1/todos", status: http.StatusOK},
+		{name: "missing list", as: "ana", target: "/v1/lists/99/todos", status: http.StatusNotFound},
+	}},
+	{method: "POST", route: "/v1/lists/{listID}/todos", access: user, requests: []routeRequest{
+		{name: "create", as: "ana", target: "/v1/lists/1/todos", body: `{"title":"butter"}`, status: http.StatusCreated},
+		{name: "someone else's list", as: "bob", target: "/v1/lists/1/todos", body: `{"title":"butter"}`, status: http.StatusNotFound},
+	}},
+	{method: "GET", route: "/v1/todo/{todoID}/comments/", access: user, requests: []routeRequest{
+		{name: "list", as: "ana", target: "/v1/todo/1/comments", status: http.StatusOK},
+		{name: "missing todo", as: "ana", target: "/v1/todo/99/comments", status: http.StatusNotFound},
+	}},
+	{method: "POST", route: "/v1/todo/{todoID}/comments/", access: user, requests: []routeRequest{
+		{name: "add", as: "ana", target: "/v1/todo/1/comments", body: `{"body":"oat or soy?"}`, status: http.StatusCreated},
+		{name: "no body", as: "ana", target: "/v1/todo/1/comments", body: `{"body":" "}`, status: http.StatusUnprocessableEntity},
+	}},
+	{method: "DELETE", route: "/v1/todo/{todoID}/comments/{commentID}", access: user, requests: []routeRequest{
+		{name: "delete", as: "ana", target: "/v1/todo/1/comments/1", status: http.StatusNoContent},
+		{name: "as an admin", as: "root", target: "/v1/todo/1/comments/1?all=true", status: http.StatusNoContent},
+		{name: "missing", as: "ana", target: "/v1/todo/1/comments/99", status: http.StatusNotFound},
+	}},
+	{method: "GET", route: "/v1/admin/profile", access: admin, requests: []routeRequest{
+		{name: "profile", as: "root", target: "/v1/admin/profile", status: http.StatusOK},
+	}},
+	{method: "GET", route: "/v1/admin/todos", access: admin, requests: []routeRequest{
+		{name: "every todo", as: "root", target: "/v1/admin/todos", status: http.StatusOK},
+	}},
+	{method: "POST", route: "/v1/webhooks/", access: user, requests: []routeRequest{
+		{name: "register", as: "ana", target: "/v1/webhooks", body: `{"url":"https://example.com/hooks","events":["created"]}`, status: http.StatusCreated},
+		{name: "bad url", as: "ana", target: "/v1/webhooks", body: `{"url":"example.com","events":["created"]}`, status: http.StatusUnprocessableEntity},
+	}},
+	{method: "GET", route: "/v1/webhooks/{webhookID}", access: user, requests: []routeRequest{
+		{name: "get", as: "ana", target: "/v1/webhooks/1", status: http.StatusOK},
+		{name: "missing", as: "ana", target: "/v1/webhooks/99", status: http.StatusNotFound},
+	}},
+}
+
+// newAPIRouter serves the /v1 routes the way buildRouter mounts them, with
+// the test secret set. webhooks and tokens default to fresh ones when nil.
+func newAPIRouter(t *testing.T, todos *todoHandler, webhooks *webhookHandler, tokens *refreshTokens) *chi.Mux {
+	t.Helper()
+
+	withTestSecret(t)
+
+	if webhooks == nil {
+		webhooks = &webhookHandler{registry: newWebhookRegistry()}
+	}
+	if tokens == nil {
+		tokens = newRefreshTokens(defaultAccessTokenTTL, defaultRefreshTokenTTL)
+	}
+
+	r := chi.NewRouter()
+	useJSONRouteErrors(r)
+	mountAPI(r, todos, webhooks, tokens)
+
+	return r
+}
+
+// routerServer is buildRouter under httptest, seeded by ana with todo 1,
+// which has subtask 1 and comment 1, todo 2, which is in the trash, list 1
+// with todo 3, and webhook 1.
+type routerServer struct {
+	*httptest.Server
+	app     *app
+	tokens  map[string]string
+	refresh string
+}
+
+func newRouterServer(t *testing.T) *routerServer {
+	t.Helper()
+
+	withTestSecret(t)
+
+	app, err := buildRouter(routerConfig{
+		store:  newMemoryTodoStore(),
+		logger: slog.New(slog.NewJSONHandler(io.Discard, nil)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &routerServer{Server: httptest.NewServer(app.router), app: app, tokens: map[string]string{}}
+	t.Cleanup(s.Close)
+
+	for subject, roles := range map[string][]string{"ana": nil, "bob": nil, "root": {roleAdmin}} {
+		s.tokens[subject] = testToken(t, subject, roles...)
+	}
+	if s.refresh, err = app.tokens.issue("ana"); err != nil {
+		t.Fatal(err)
+	}
+
+	seed := []routeRequest{
+		{as: "ana", target: "/v1/todo", body: `{"title":"buy milk"}`, status: http.StatusCreated},
+		{as: "ana", target: "/v1/todo/1/subtasks", body: `{"title":"check the fridge"}`, status: http.StatusCreated},
+		{as: "ana", target: "/v1/todo/1/comments", body: `{"body":"the 2 litre one"}`, status: http.StatusCreated},
+		{as: "ana", target: "/v1/todo", body: `{"title":"old news"}`, status: http.StatusCreated},
+		{as: "ana", target: "/v1/lists", body: `{"name":"groceries"}`, status: http.StatusCreated},
+		{as: "ana", target: "/v1/lists/1/todos", body: `{"title":"bread"}`, status: http.StatusCreated},
+		{as: "ana", target: "/v1/webhooks", body: `{"url":"https://example.com/hooks","events":["created"]}`, status: http.StatusCreated},
+	}
+	for _, req := range seed {
+		if status := s.do(t, http.MethodPost, req); status != req.status {
+			t.Fatalf("seeding POST %s: expected status %d, got %d", req.target, req.status, status)
+		}
+	}
+	if status := s.do(t, http.MethodDelete, routeRequest{as: "ana", target: "/v1/todo/2"}); status != http.StatusNoContent {
+		t.Fatalf("seeding the trash: expected status %d, got %d", http.StatusNoContent, status)
+	}
+
+	return s
+}
+
+// do sends req and returns the status. Redirects aren't followed, and a
+// stream is cut off once its headers are in.
+func (s *routerServer) do(t *testing.T, method string, req routeRequest) int {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var body io.Reader
+	if req.body != "" {
+		body = strings.NewReader(strings.ReplaceAll(req.body, "{refresh}", s.refresh))
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, method, s.URL+req.target, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.body != "" {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	if req.as != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+s.tokens[req.as])
+	}
+	for key, value := range req.header {
+		httpReq.Header.Set(key, value)
+	}
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	res, err := client.Do(httpReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	return res.StatusCode
+}
+
+// coverageMethods expands a "*" to what a route registered with r.Handle
+// answers.
+func coverageMethods(method string) []string {
+	if method != "*" {
+		return []string{method}
+	}
+	return []string{
+		http.MethodConnect, http.MethodDelete, http.MethodGet, http.MethodHead, http.MethodOptions,
+		http.MethodPatch, http.MethodPost, http.MethodPut, http.MethodTrace,
+	}
+}
+
+func TestRouterCoverage(t *testing.T) {
+	s := newRouterServer(t)
+
+	covered := map[string]bool{}
+	for _, c := range coveredRoutes {
+		for _, method := range coverageMethods(c.method) {
+			covered[method+" "+c.route] = true
+		}
+		if len(c.requests) == 0 {
+			t.Errorf("%s %s is listed without a request", c.method, c.route)
+		}
+	}
+
+	walked := map[string]bool{}
+	err := chi.Walk(s.app.router, func(method, route string, handler http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+		key := method + " " + route
+		walked[key] = true
+		if !covered[key] {
+			t.Errorf("%s has no cases in coveredRoutes, add them", key)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for key := range covered {
+		if !walked[key] {
+			t.Errorf("%s is in coveredRoutes but not served, remove it", key)
+		}
+	}
+}
+
+func TestRouter(t *testing.T) {
+	for _, c := range coveredRoutes {
+		method := c.method
+		if method == "*" {
+			method = http.MethodGet
+		}
+
+		for _, req := range c.requests {
+			t.Run(fmt.Sprintf("%s %s %s", method, c.route, req.name), func(t *testing.T) {
+				if status := newRouterServer(t).do(t, method, req); status != req.status {
+					t.Errorf("%s %s: expected status %d, got %d", method, req.target, req.status, status)
+				}
+			})
+		}
+
+		// the first request is the valid one, sent again by someone the
+		// route should turn away
+		valid := c.requests[0]
+		if c.access >= user {
+			t.Run(fmt.Sprintf("%s %s without a token", method, c.route), func(t *testing.T) {
+				valid.as = ""
+				if status := newRouterServer(t).do(t, method, valid); status != http.StatusUnauthorized {
+					t.Errorf("%s %s: expected status %d, got %d", method, valid.target, http.StatusUnauthorized, status)
+				}
+			})
+		}
+		if c.access == admin {
+			t.Run(fmt.Sprintf("%s %s as a user", method, c.route), func(t *testing.T) {
+				valid.as = "ana"
+				if status := newRouterServer(t).do(t, method, valid); status != http.StatusForbidden {
+					t.Errorf("%s %s: expected status %d, got %d", method, valid.target, http.StatusForbidden, status)
+				}
+			})
+		}
+	}
+}
+
+func TestRouterWebSocket(t *testing.T) {
+	s := newRouterServer(t)
+
+	config, err := websocket.NewConfig("ws"+strings.TrimPrefix(s.URL, "http")+"/v1/todo/ws", s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := websocket.DialConfig(config); err == nil {
+		t.Error("expected the upgrade refused without a token")
+	}
+
+	config.Header = http.Header{"Authorization": {"Bearer " + s.tokens["ana"]}}
+	conn, err := websocket.DialConfig(config)
+	if err != nil {
+		t.Fatalf("expected the upgrade with a token, got %v", err)
+	}
+	defer conn.Close()
+
+	var snapshot socketSnapshot
+	if err := websocket.JSON.Receive(conn, &snapshot); err != nil && !errors.Is(err, io.EOF) {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Relevance of a search hit, only the best kind of match a todo has counts.
+const (
+	scoreTag         = 1
+	scoreTitleSubstr = 2
+	scoreTitlePrefix = 3
+)
+
+type SearchQuery struct {
+	// Text is matched case-insensitively against titles and tags.
+	Text   string
+	Offset int
+	Limit  int
+}
+
+// searchScore rates how well todo matches the lowercased text, 0 meaning no
+// match at all.
+func searchScore(todo *Todo, text string) int {
+	title := strings.ToLower(todo.Title)
+
+	switch {
+	case strings.HasPrefix(title, text):
+		return scoreTitlePrefix
+	case strings.Contains(title, text):
+		return scoreTitleSubstr
+	}
+
+	for _, tag := range todo.Tags {
+		if strings.Contains(tag, text) {
+			return scoreTag
+		}
+	}
+
+	return 0
+}
+
+func (s *memoryTodoStore) Search(ctx context.Context, q SearchQuery) ([]Todo, int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	text := strings.ToLower(q.Text)
+
+	type hit struct {
+		todo  Todo
+		score int
+	}
+
+	var hits []hit
+	for i := range s.todos {
+		if !s.sees(&s.todos[i]) {
+			continue
+		}
+
+		if score := searchScore(&s.todos[i], text); score > 0 {
+			hits = append(hits, hit{todo: s.todos[i], score: score})
+		}
+	}
+
+	// todos are kept in id order, so a stable sort leaves ties oldest first
+	sort.SliceStable(hits, func(i, j int) bool {
+		return hits[i].score > hits[j].score
+	})
+
+	total := len(hits)
+	offset := min(q.Offset, total)
+	end := min(offset+q.Limit, total)
+
+	todos := make([]Todo, 0, end-offset)
+	for _, hit := range hits[offset:end] {
+		todos = append(todos, hit.todo)
+	}
+
+	return todos, total, nil
+}
+
+func (h *todoHandler) searchTodos(w http.ResponseWriter, r *http.Request) {
+	text := strings.TrimSpace(r.URL.Query().Get("q"))
+	if text == "" {
+		respondError(w, r, http.StatusBadRequest, codeBadRequest, "q must not be empty")
+		return
+	}
+
+	page, err := parsePagination(r)
+	if err != nil {
+		respondParamErrors(w, r, err)
+		return
+	}
+
+	todos, total, err := h.storeFor(r).Search(r.Context(), SearchQuery{Text: text, Offset: page.offset, Limit: page.limit})
+	if err != nil {
+		logError(r, "failed to search todos: %v", err)
+		respondError(w, r, http.StatusInternalServerError, codeInternal, "the server encountered a problem")
+		return
+	}
+
+	page.setHeaders(w, r.URL, total)
+
+	respondJSON(w, http.StatusOK, todos)
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func newSearchStore(t *testing.T) *memoryTodoStore {
+	t.Helper()
+
+	store := newMemoryTodoStore()
+	for _, todo := range []*Todo{
+		{Title: "Pick up dry cleaning", Tags: []string{"groceries-adjacent"}},
+		{Title: "Weekly groceries"},
+		{Title: "Call mom"},
+		{Title: "groceries for the party"},
+		{Title: "Buy milk", Tags: []string{"groceries"}},
+		{Title: "GROCERIES budget"},
+	} {
+		if err := store.Create(context.Background(), todo); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return store
+}
+
+func TestMemorySearchRanking(t *testing.T) {
+	store := newSearchStore(t)
+
+	tests := []struct {
+		text string
+		want []int64
+	}{
+		// prefix matches 4 and 6, substring 2, tags 1 and 5, each oldest first
+		{text: "groceries", want: []int64{4, 6, 2, 1, 5}},
+		{text: "GroCeries", want: []int64{4, 6, 2, 1, 5}},
+		{text: "mom", want: []int64{3}},
+		{text: "call", want: []int64{3}},
+		{text: "adjacent", want: []int64{1}},
+		{text: "nothing like this", want: []int64{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			todos, total, err := store.Search(context.Background(), SearchQuery{Text: tt.text, Limit: 10})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if total != len(tt.want) {
+				t.Errorf("expected total %d, got %d", len(tt.want), total)
+			}
+
+			if got := todoIDs(todos); !slices.Equal(got, tt.want) {
+				t.Errorf("expected order %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestMemorySearchWindow(t *testing.T) {
+	store := newSearchStore(t)
+
+	todos, total, err := store.Search(context.Background(), SearchQuery{Text: "groceries", Offset: 1, Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if total != 5 || !slices.Equal(todoIDs(todos), []int64{6, 2}) {
+		t.Errorf("expected todos [6 2] of 5, got %v of %d", todoIDs(todos), total)
+	}
+
+	todos, _, _ = store.Search(context.Background(), SearchQuery{Text: "groceries", Offset: 10, Limit: 2})
+	if todos == nil || len(todos) != 0 {
+		t.Errorf("expected an empty, non-nil window past the end, got %#v", todos)
+	}
+}
+
+func TestSearchTodosHandler(t *testing.T) {
+	r := newTodoRouter(t, newSearchStore(t))
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/todo/search?q=groceries&per_page=2&page=2", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	var todos []Todo
+	if err := json.NewDecoder(rr.Body).Decode(&todos); err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Equal(todoIDs(todos), []int64{2, 1}) {
+		t.Errorf("expected the second page [2 1], got %v", todoIDs(todos))
+	}
+
+	if got := rr.Header().Get("X-Total-Count"); got != "5" {
+		t.Errorf("expected X-Total-Count 5, got %q", got)
+	}
+
+	links := parseLinks(rr.Header().Get("Link"))
+	if links["next"] != "/todo/search?page=3&per_page=2&q=groceries" {
+		t.Errorf("expected the next link to keep q, got %v", links)
+	}
+
+	for _, target := range []string{"/todo/search", "/todo/search?q=", "/todo/search?q=%20%20", "/todo/search?q=a&page=0"} {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, target, nil))
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d, got %d", target, http.StatusBadRequest, rr.Code)
+		}
+	}
+}
+
+func todoIDs(todos []Todo) []int64 {
+	ids := make([]int64, len(todos))
+	for i, todo := range todos {
+		ids[i] = todo.ID
+	}
+
+	return ids
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// socketWriteTimeout bounds every write, so a client that stops reading frees
+// the connection instead of holding it forever.
+const socketWriteTimeout = 10 * time.Second
+
+const (
+	actionComplete   = "complete"
+	actionUncomplete = "uncomplete"
+)
+
+// socketSnapshot is the first message on a connection: the first page of
+// todos, as GET /todo would return it.
+type socketSnapshot struct {
+	Type  string `json:"type"`
+	Todos []Todo `json:"todos"`
+	Total int    `json:"total"`
+}
+
+// socketError answers a command that could not be applied. Commands that
+// succeed are confirmed by the change event they cause.
+type socketError struct {
+	Type  string `json:"type"`
+	Error string `json:"error"`
+	Code  string `json:"code"`
+}
+
+type socketCommand struct {
+	Action string `json:"action"`
+	ID     int64  `json:"id"`
+}
+
+// todoSocket serves GET /todo/ws. After the snapshot every todoEvent is sent
+// as a JSON message, and the client may send {"action":"complete","id":N} or
+// "uncomplete". A client that falls behind is dropped by todoEvents.
+func (h *todoHandler) todoSocket(w http.ResponseWriter, r *http.Request) {
+	if h.events == nil {
+		respondError(w, r, http.StatusNotFound, codeNotFound, "live updates are not enabled")
+		return
+	}
+
+	store, owner := h.storeFor(r), ownerFromContext(r.Context())
+
+	// websocket.Server skips the Origin check websocket.Handler does, the
+	// bearer token is what authenticates the client
+	websocket.Server{Handler: func(conn *websocket.Conn) {
+		h.serveSocket(r, conn, store, owner)
+	}}.ServeHTTP(w, r)
+}
+
+func (h *todoHandler) serveSocket(r *http.Request, conn *websocket.Conn, store TodoStore, owner string) {
+	ctx := r.Context()
+
+	// subscribe before the snapshot so no change falls in between
+	updates, _ := h.events.subscribe(0, false)
+	defer h.events.unsubscribe(updates)
+
+	send := func(message any) bool {
+		conn.SetWriteDeadline(time.Now().Add(socketWriteTimeout))
+		return websocket.JSON.Send(conn, message) == nil
+	}
+
+	unarchived := false
+	todos, total, err := store.List(ctx, ListQuery{Limit: pageSizes.Default, Archived: &unarchived, Now: h.clock().UTC()})
+	if err != nil {
+		logError(r, "failed to list todos: %v", err)
+		send(socketError{Type: "error", Error: "the server encountered a problem", Code: codeInternal})
+		conn.Close()
+		return
+	}
+	if !send(socketSnapshot{Type: "snapshot", Todos: todos, Total: total}) {
+		conn.Close()
+		return
+	}
+
+	replies := make(chan socketError)
+	stop := make(chan struct{})
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		for {
+			var command socketCommand
+			err := websocket.JSON.Receive(conn, &command)
+
+			var reply socketError
+			var ok bool
+
+			var syntaxErr *json.SyntaxError
+			var typeErr *json.UnmarshalTypeError
+			switch {
+			case errors.As(err, &syntaxErr) || errors.As(err, &typeErr):
+				reply = socketError{Type: "error", Error: "message must be a JSON command", Code: codeBadRequest}
+			case err != nil:
+				if !errors.Is(err, io.EOF) {
+					logError(r, "websocket read failed: %v", err)
+				}
+				return
+			default:
+				reply, ok = applyCommand(ctx, r, store, command)
+			}
+
+			if ok {
+				continue
+			}
+
+			select {
+			case replies <- reply:
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	// closing the connection ends the reader, stop unblocks it if it is
+	// waiting to hand over a reply
+	defer func() {
+		close(stop)
+		conn.Close()
+		<-done
+	}()
+
+	for {
+		select {
+		case <-done:
+			return
+		case event, ok := <-updates:
+			if !ok {
+				return
+			}
+			if event.visibleTo(owner) && !send(event) {
+				return
+			}
+		case reply := <-replies:
+			if !send(reply) {
+				return
+			}
+		}
+	}
+}
+
+// applyCommand runs one client command against store. It returns false with
+// the error to send back when the command is rejected.
+func applyCommand(ctx context.Context, r *http.Request, store TodoStore, command socketCommand) (socketError, bool) {
+	if command.Action != actionComplete && command.Action != actionUncomplete {
+		return socketError{Type: "error", Error: `action must be one of complete, uncomplete`, Code: codeBadRequest}, false
+	}
+	if command.ID < 1 {
+		return socketError{Type: "error", Error: "id must be a positive integer", Code: codeBadRequest}, false
+	}
+
+	// commands carry no version, they apply to whatever is stored
+	_, err := store.SetDone(ctx, command.ID, command.Action == actionComplete, 0)
+	switch {
+	case errors.Is(err, ErrTodoNotFound):
+		return socketError{Type: "error", Error: "todo not found", Code: codeNotFound}, false
+	case err != nil:
+		logError(r, "failed to %s todo over websocket: %v", command.Action, err)
+		return socketError{Type: "error", Error: "the server encountered a problem", Code: codeInternal}, false
+	}
+
+	return socketError{}, true
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// dialSocket connects to GET /todo/ws and reads the snapshot.
+func dialSocket(t *testing.T, serverURL string) (*websocket.Conn, socketSnapshot) {
+	t.Helper()
+
+	conn, err := websocket.Dial("ws"+strings.TrimPrefix(serverURL, "http")+"/todo/ws", "", serverURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	var snapshot socketSnapshot
+	receive(t, conn, &snapshot)
+
+	return conn, snapshot
+}
+
+func receive(t *testing.T, conn *websocket.Conn, message any) {
+	t.Helper()
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if err := websocket.JSON.Receive(conn, message); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTodoSocket(t *testing.T) {
+	server, h := startEventServer(t)
+	ctx := context.Background()
+
+	if err := h.store.Create(ctx, &Todo{Title: "existing"}); err != nil {
+		t.Fatal(err)
+	}
+
+	conn, snapshot := dialSocket(t, server.URL)
+	if snapshot.Type != "snapshot" || snapshot.Total != 1 || len(snapshot.Todos) != 1 || snapshot.Todos[0].Title != "existing" {
+		t.Fatalf("unexpected snapshot %+v", snapshot)
+	}
+
+	// server to client: a change to the store is pushed
+	if err := h.store.Create(ctx, &Todo{Title: "pushed"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var event todoEvent
+	receive(t, conn, &event)
+	if event.Type != eventCreated || event.Todo.Title != "pushed" {
+		t.Errorf("expected the created event, got %+v", event)
+	}
+
+	// client to server: a command is applied and confirmed by its event
+	if err := websocket.JSON.Send(conn, socketCommand{Action: actionComplete, ID: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	receive(t, conn, &event)
+	if event.Type != eventCompleted || event.Todo.ID != 1 || !event.Todo.Done {
+		t.Errorf("expected todo 1 to be completed, got %+v", event)
+	}
+	if todo, _ := h.store.Get(ctx, 1); !todo.Done {
+		t.Error("the command did not reach the store")
+	}
+
+	for _, tt := range []struct {
+		message string
+		code    string
+	}{
+		{message: `{"action":"explode","id":1}`, code: codeBadRequest},
+		{message: `{"action":"complete","id":0}`, code: codeBadRequest},
+		{message: `{"action":"complete","id":99}`, code: codeNotFound},
+		{message: `not json`, code: codeBadRequest},
+	} {
+		if err := websocket.Message.Send(conn, tt.message); err != nil {
+			t.Fatal(err)
+		}
+
+		var reply socketError
+		receive(t, conn, &reply)
+		if reply.Type != "error" || reply.Code != tt.code {
+			t.Errorf("%s: expected a %s error, got %+v", tt.message, tt.code, reply)
+		}
+	}
+}
+
+func TestTodoSocketUnsubscribesOnClose(t *testing.T) {
+	server, h := startEventServer(t)
+
+	conn, _ := dialSocket(t, server.URL)
+	if got := h.events.subscriberCount(); got != 1 {
+		t.Fatalf("expected 1 subscriber, got %d", got)
+	}
+
+	conn.Close()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for h.events.subscriberCount() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("the subscription outlived the connection")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// TestSlowSubscriberDropped shows the notify path never waits on a reader: a
+// subscriber whose buffer is full is closed instead.
+func TestSlowSubscriberDropped(t *testing.T) {
+	events := newTodoEvents()
+	slow, _ := events.subscribe(0, false)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < subscriberBuffer+1; i++ {
+			events.publish(eventCreated, Todo{Title: "flood"})
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("publish blocked on a slow subscriber")
+	}
+
+	received := 0
+	for range slow {
+		received++
+	}
+	if received != subscriberBuffer {
+		t.Errorf("expected the %d buffered events before the close, got %d", subscriberBuffer, received)
+	}
+	if got := events.subscriberCount(); got != 0 {
+		t.Errorf("expected the slow subscriber to be removed, %d left", got)
+	}
+
+	// the handler still unsubscribes on its way out, which must not panic
+	events.unsubscribe(slow)
+}
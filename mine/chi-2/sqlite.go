@@ -0,0 +1,968 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// sqliteMigrations holds the schema as numbered SQL files, each one is applied
+// once and recorded in the schema_version table.
+//
+//go:embed migrations/sqlite/*.sql
+var sqliteMigrations embed.FS
+
+// openSQLite opens the database file at path, creating it when missing.
+func openSQLite(path string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_time_format=sqlite")
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite takes one writer at a time, a single connection queues the
+	// statements instead of failing them with SQLITE_BUSY
+	db.SetMaxOpenConns(1)
+
+	return db, nil
+}
+
+// migrateSQLite applies the migrations newer than the recorded schema
+// version, each in its own transaction. Running it again is a no-op.
+func migrateSQLite(ctx context.Context, db *sql.DB) error {
+	if _, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_version (version INTEGER NOT NULL)`); err != nil {
+		return fmt.Errorf("failed to create schema_version: %w", err)
+	}
+
+	var current int
+	if err := db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_version`).Scan(&current); err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	// Glob returns the files in lexical order, the zero padded names keep
+	// that the same as the numeric one
+	names, err := fs.Glob(sqliteMigrations, "migrations/sqlite/*.sql")
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		version, err := migrationVersion(name)
+		if err != nil {
+			return err
+		}
+		if version <= current {
+			continue
+		}
+
+		script, err := sqliteMigrations.ReadFile(name)
+		if err != nil {
+			return err
+		}
+
+		if err := applySQLiteMigration(ctx, db, version, string(script)); err != nil {
+			return fmt.Errorf("failed to apply migration %s: %w", path.Base(name), err)
+		}
+	}
+
+	return nil
+}
+
+func applySQLiteMigration(ctx context.Context, db *sql.DB, version int, script string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `INSERT INTO schema_version (version) VALUES (?1)`, version); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// migrationVersion reads the number in front of a migration file name, 7 for
+// 0007_add_things.sql.
+func migrationVersion(name string) (int, error) {
+	prefix, _, _ := strings.Cut(path.Base(name), "_")
+
+	version, err := strconv.Atoi(prefix)
+	if err != nil || version < 1 {
+		return 0, fmt.Errorf("migration %s does not start with a version number", name)
+	}
+
+	return version, nil
+}
+
+func scanSQLiteTodo(row rowScanner, todo *Todo) error {
+	var tags, recurrence string
+	if err := row.Scan(&todo.ID, &todo.Title, &todo.Done, &todo.Priority, &tags, &todo.DueDate, &todo.CreatedAt, &todo.CompletedAt, &todo.OwnerID, &todo.Archived, &todo.ArchivedAt, &recurrence, &todo.SeriesID, &todo.DeletedAt, &todo.Version, &todo.ClientID, &todo.ListID, &todo.SubtaskCounts.Total, &todo.SubtaskCounts.Done, &todo.CommentCount); err != nil {
+		return err
+	}
+
+	todo.Tags = nil
+	if err := json.Unmarshal([]byte(tags), &todo.Tags); err != nil {
+		return fmt.Errorf("failed to decode tags of todo %d: %w", todo.ID, err)
+	}
+	if len(todo.Tags) == 0 {
+		todo.Tags = nil
+	}
+
+	var err error
+	if todo.Recurrence, err = scanRecurrence(recurrence); err != nil {
+		return fmt.Errorf("failed to decode recurrence of todo %d: %w", todo.ID, err)
+	}
+
+	// the driver hands back a fixed +00:00 zone, the other stores use UTC
+	todo.CreatedAt = todo.CreatedAt.UTC()
+	todo.DueDate = utcTime(todo.DueDate)
+	todo.CompletedAt = utcTime(todo.CompletedAt)
+	todo.ArchivedAt = utcTime(todo.ArchivedAt)
+	todo.DeletedAt = utcTime(todo.DeletedAt)
+
+	return nil
+}
+
+func scanSQLiteTodos(rows *sql.Rows) ([]Todo, error) {
+	defer rows.Close()
+
+	todos := []Todo{}
+	for rows.Next() {
+		var todo Todo
+		if err := scanSQLiteTodo(rows, &todo); err != nil {
+			return nil, err
+		}
+
+		todos = append(todos, todo)
+	}
+
+	return todos, rows.Err()
+}
+
+// sqliteTags stores tags as a JSON array, a todo without tags as [].
+func sqliteTags(tags []string) string {
+	if tags == nil {
+		tags = []string{}
+	}
+
+	encoded, _ := json.Marshal(tags)
+	return string(encoded)
+}
+
+// sqliteTagsParam keeps a nil slice as NULL, like tagsParam.
+func sqliteTagsParam(tags []string) any {
+	if tags == nil {
+		return nil
+	}
+
+	return sqliteTags(tags)
+}
+
+type sqliteTodoStore struct {
+	db *sql.DB
+	// owner, when set, limits every statement to that owner's todos.
+	owner string
+	// now stamps created_at, completed_at, archived_at and deleted_at, SQLite
+	// has no NOW() of its own that matches the stored format.
+	now func() time.Time
+}
+
+func newSQLiteTodoStore(ctx context.Context, db *sql.DB) (*sqliteTodoStore, error) {
+	if err := migrateSQLite(ctx, db); err != nil {
+		return nil, err
+	}
+
+	return &sqliteTodoStore{db: db, now: time.Now}, nil
+}
+
+func (s *sqliteTodoStore) ForOwner(owner string) TodoStore {
+	return &sqliteTodoStore{db: s.db, owner: owner, now: s.now}
+}
+
+// Ping backs the store check of /healthz.
+func (s *sqliteTodoStore) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// sqliteCompletedAt is completedAtUpdate with the current time passed in now.
+func sqliteCompletedAt(param, now string) string {
+	return `CASE WHEN done = ` + param + ` THEN completed_at WHEN ` + param + ` THEN ` + now + ` END`
+}
+
+func (s *sqliteTodoStore) Create(ctx context.Context, todo *Todo) error {
+	if todo.Priority == "" {
+		todo.Priority = defaultPriority
+	}
+	if s.owner != "" {
+		todo.OwnerID = s.owner
+	}
+
+	return inTx(ctx, s.db, func(tx *sql.Tx) error {
+		if todo.ListID != 0 {
+			if err := s.listExists(ctx, tx, todo.ListID); err != nil {
+				return err
+			}
+		}
+
+		return s.insert(ctx, tx, todo, "")
+	})
+}
+
+// insert is the body of Create. onConflict goes between the values and
+// RETURNING, when it skips the insert the error is sql.ErrNoRows.
+func (s *sqliteTodoStore) insert(ctx context.Context, tx *sql.Tx, todo *Todo, onConflict string) error {
+	query := `
+		INSERT INTO todos (title, done, due_date, priority, tags, owner_id, created_at, completed_at, recurrence, client_id, list_id)
+		VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, CASE WHEN ?2 THEN ?7 END, ?8, NULLIF(?9, ''), NULLIF(?10, 0))
+		` + onConflict + `
+		RETURNING ` + todoColumns
+
+	if err := scanSQLiteTodo(tx.QueryRowContext(ctx, query, todo.Title, todo.Done, todo.DueDate, todo.Priority, sqliteTags(todo.Tags), todo.OwnerID, s.now().UTC(), recurrenceColumn(todo.Recurrence), todo.ClientID, todo.ListID), todo); err != nil {
+		return err
+	}
+
+	if todo.Recurrence == nil {
+		return nil
+	}
+
+	// the first occurrence names the series, its id is only known now
+	return scanSQLiteTodo(tx.QueryRowContext(ctx, `UPDATE todos SET series_id = id WHERE id = ?1 RETURNING `+todoColumns, todo.ID), todo)
+}
+
+func (s *sqliteTodoStore) Get(ctx context.Context, id int64) (Todo, error) {
+	query := `SELECT ` + todoColumns + ` FROM todos WHERE id = ?1 AND ` + visibleTo("?2")
+
+	var todo Todo
+	err := scanSQLiteTodo(s.db.QueryRowContext(ctx, query, id, s.owner), &todo)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Todo{}, ErrTodoNotFound
+	}
+
+	return todo, err
+}
+
+func (s *sqliteTodoStore) Update(ctx context.Context, todo *Todo) error {
+	return inTx(ctx, s.db, func(tx *sql.Tx) error {
+		return s.update(ctx, tx, todo)
+	})
+}
+
+// update is the body of Update.
+func (s *sqliteTodoStore) update(ctx context.Context, tx *sql.Tx, todo *Todo) error {
+	// a todo keeps its series once it had a rule, even if the rule goes
+	query := `
+		UPDATE todos
+		SET title = ?2, done = ?3, due_date = ?4, priority = ?5, tags = ?6, completed_at = ` + sqliteCompletedAt("?3", "?8") + `,
+			recurrence = ?9, series_id = CASE WHEN series_id = 0 AND ?9 <> '' THEN id ELSE series_id END,
+			version = version + 1
+		WHERE id = ?1 AND NOT archived AND (?10 = 0 OR version = ?10) AND ` + visibleTo("?7") + `
+		RETURNING ` + todoColumns
+
+	err := scanSQLiteTodo(tx.QueryRowContext(ctx, query, todo.ID, todo.Title, todo.Done, todo.DueDate, todo.Priority, sqliteTags(todo.Tags), s.owner, s.now().UTC(), recurrenceColumn(todo.Recurrence), todo.Version), todo)
+	if err == nil {
+		todo.next, err = s.recur(ctx, tx, *todo)
+		return err
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	// no row was updated, tell a missing todo from a newer or an archived
+	// one
+	var current Todo
+	err = scanSQLiteTodo(tx.QueryRowContext(ctx, `SELECT `+todoColumns+` FROM todos WHERE id = ?1 AND `+visibleTo("?2"), todo.ID, s.owner), &current)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return ErrTodoNotFound
+	case err != nil:
+		return err
+	case todo.Version != 0 && current.Version != todo.Version:
+		return &ErrVersionConflict{Current: current}
+	default:
+		return ErrTodoArchived
+	}
+}
+
+func (s *sqliteTodoStore) PutByClientID(ctx context.Context, todo *Todo) (PutResult, error) {
+	if todo.Priority == "" {
+		todo.Priority = defaultPriority
+	}
+	todo.OwnerID = s.owner
+
+	result := PutCreated
+	err := inTx(ctx, s.db, func(tx *sql.Tx) error {
+		// the unique index on the client id makes a concurrent first PUT
+		// skip the insert instead of creating a second todo
+		err := s.insert(ctx, tx, todo, `ON CONFLICT (owner_id, client_id) WHERE client_id IS NOT NULL DO NOTHING`)
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		// trashed todos included, a client id stays taken until the todo is
+		// purged
+		var stored Todo
+		if err := scanSQLiteTodo(tx.QueryRowContext(ctx, `SELECT `+todoColumns+` FROM todos WHERE owner_id = ?1 AND client_id = ?2`, s.owner, todo.ClientID), &stored); err != nil {
+			return err
+		}
+		switch {
+		case stored.DeletedAt != nil:
+			return ErrTodoNotFound
+		case stored.sameContent(todo):
+			result, *todo = PutUnchanged, stored
+			return nil
+		}
+
+		result = PutUpdated
+		todo.ID, todo.Version = stored.ID, 0
+		return s.update(ctx, tx, todo)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return result, nil
+}
+
+func (s *sqliteTodoStore) SetDone(ctx context.Context, id int64, done bool, version int) (Todo, error) {
+	query := `
+		UPDATE todos
+		SET done = ?2, completed_at = ` + sqliteCompletedAt("?2", "?4") + `, version = version + 1
+		WHERE id = ?1 AND (?5 = 0 OR version = ?5) AND ` + visibleTo("?3") + `
+		RETURNING ` + todoColumns
+
+	var todo Todo
+	err := inTx(ctx, s.db, func(tx *sql.Tx) error {
+		err := scanSQLiteTodo(tx.QueryRowContext(ctx, query, id, done, s.owner, s.now().UTC(), version), &todo)
+		if err == nil {
+			todo.next, err = s.recur(ctx, tx, todo)
+			return err
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		// no row was updated, the todo is either missing or newer
+		var current Todo
+		if err := scanSQLiteTodo(tx.QueryRowContext(ctx, `SELECT `+todoColumns+` FROM todos WHERE id = ?1 AND `+visibleTo("?2"), id, s.owner), &current); err != nil {
+			return err
+		}
+
+		return &ErrVersionConflict{Current: current}
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return Todo{}, ErrTodoNotFound
+	}
+
+	return todo, err
+}
+
+func (s *sqliteTodoStore) CompleteMany(ctx context.Context, ids []int64) ([]int64, []int64, []Todo, error) {
+	// ids travel as a JSON array, json_each turns them back into rows
+	encoded, err := json.Marshal(ids)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	query := `
+		UPDATE todos
+		SET done = TRUE, completed_at = ` + sqliteCompletedAt("TRUE", "?3") + `, version = version + 1
+		WHERE id IN (SELECT value FROM json_each(?1)) AND ` + visibleTo("?2") + `
+		RETURNING ` + todoColumns
+
+	found := make(map[int64]bool, len(ids))
+	spawned := []Todo{}
+	err = inTx(ctx, s.db, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, query, string(encoded), s.owner, s.now().UTC())
+		if err != nil {
+			return err
+		}
+
+		todos, err := scanSQLiteTodos(rows)
+		if err != nil {
+			return err
+		}
+
+		for _, todo := range todos {
+			found[todo.ID] = true
+
+			next, err := s.recur(ctx, tx, todo)
+			if err != nil {
+				return err
+			}
+			if next != nil {
+				spawned = append(spawned, *next)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	completed, missing := []int64{}, []int64{}
+	for _, id := range ids {
+		if found[id] {
+			completed = append(completed, id)
+		} else {
+			missing = append(missing, id)
+		}
+	}
+
+	return completed, missing, spawned, nil
+}
+
+// recur inserts the next occurrence of todo, see memoryTodoStore.recur.
+func (s *sqliteTodoStore) recur(ctx context.Context, tx *sql.Tx, todo Todo) (*Todo, error) {
+	if !todo.Done || todo.Recurrence == nil || todo.DueDate == nil {
+		return nil, nil
+	}
+
+	query := `
+		INSERT INTO todos (title, priority, tags, owner_id, due_date, recurrence, series_id, created_at, list_id)
+		SELECT title, priority, tags, owner_id, ?2, recurrence, series_id, ?3, list_id
+		FROM todos AS current
+		WHERE id = ?1 AND NOT EXISTS (
+			SELECT 1 FROM todos AS later
+			WHERE later.series_id = current.series_id AND later.due_date > current.due_date
+		)
+		RETURNING ` + todoColumns
+
+	var next Todo
+	err := scanSQLiteTodo(tx.QueryRowContext(ctx, query, todo.ID, todo.Recurrence.Next(*todo.DueDate), s.now().UTC()), &next)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &next, nil
+}
+
+func (s *sqliteTodoStore) SetArchived(ctx context.Context, id int64, archived bool) (Todo, error) {
+	query := `
+		UPDATE todos
+		SET archived = ?2, archived_at = CASE WHEN archived = ?2 THEN archived_at WHEN ?2 THEN ?4 END, version = version + 1
+		WHERE id = ?1 AND ` + visibleTo("?3") + `
+		RETURNING ` + todoColumns
+
+	var todo Todo
+	err := scanSQLiteTodo(s.db.QueryRowContext(ctx, query, id, archived, s.owner, s.now().UTC()), &todo)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Todo{}, ErrTodoNotFound
+	}
+
+	return todo, err
+}
+
+func (s *sqliteTodoStore) Delete(ctx context.Context, id int64) error {
+	result, err := s.db.ExecContext(ctx, `UPDATE todos SET deleted_at = ?3, version = version + 1 WHERE id = ?1 AND `+visibleTo("?2"), id, s.owner, s.now().UTC())
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrTodoNotFound
+	}
+
+	return nil
+}
+
+func (s *sqliteTodoStore) ListTrash(ctx context.Context, q TrashQuery) ([]Todo, int, error) {
+	where := `WHERE deleted_at IS NOT NULL AND ` + ownedBy("?1")
+
+	var total int
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM todos `+where, s.owner).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `
+		SELECT ` + todoColumns + `
+		FROM todos
+		` + where + `
+		ORDER BY deleted_at DESC, id DESC
+		LIMIT ?2 OFFSET ?3
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, s.owner, q.Limit, q.Offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	todos, err := scanSQLiteTodos(rows)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return todos, total, nil
+}
+
+func (s *sqliteTodoStore) Restore(ctx context.Context, id int64) (Todo, error) {
+	query := `
+		UPDATE todos
+		SET deleted_at = NULL, version = version + 1
+		WHERE id = ?1 AND deleted_at IS NOT NULL AND ` + ownedBy("?2") + `
+		RETURNING ` + todoColumns
+
+	var todo Todo
+	err := scanSQLiteTodo(s.db.QueryRowContext(ctx, query, id, s.owner), &todo)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Todo{}, ErrTodoNotFound
+	}
+
+	return todo, err
+}
+
+// PurgeTrash leaves the subtasks to ON DELETE CASCADE, openSQLite turns
+// foreign keys on.
+func (s *sqliteTodoStore) PurgeTrash(ctx context.Context, cutoff time.Time) (int, error) {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM todos WHERE deleted_at < ?1 AND `+ownedBy("?2"), cutoff.UTC(), s.owner)
+	if err != nil {
+		return 0, err
+	}
+
+	purged, err := result.RowsAffected()
+	return int(purged), err
+}
+
+func (s *sqliteTodoStore) List(ctx context.Context, q ListQuery) ([]Todo, int, error) {
+	where := `
+		WHERE (?1 = FALSE OR (done = FALSE AND due_date < ?2))
+		AND (?3 IS NULL OR done = ?3)
+		AND (?4 = '' OR priority = ?4)
+		AND (?5 IS NULL OR EXISTS (
+			SELECT 1 FROM json_each(todos.tags) AS tag WHERE tag.value IN (SELECT value FROM json_each(?5))
+		))
+		AND (?6 IS NULL OR archived = ?6)
+		AND ` + visibleTo("?7") + `
+		AND (?8 = 0 OR series_id = ?8)
+		AND (?9 = 0 OR list_id = ?9)
+		AND (?10 IS NULL OR due_date >= ?10)
+		AND (?11 IS NULL OR due_date <= ?11)
+	`
+	args := []any{q.Overdue, q.Now.UTC(), q.Done, q.Priority, sqliteTagsParam(q.Tags), q.Archived, s.owner, q.Series, q.ListID, utcTime(q.DueAfter), utcTime(q.DueBefore)}
+
+	var total int
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM todos `+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `
+		SELECT ` + todoColumns + `
+		FROM todos
+		` + where + `
+		ORDER BY ` + sqlOrderBy(q) + `
+		LIMIT ?12 OFFSET ?13
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, append(args, q.Limit, q.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	todos, err := scanSQLiteTodos(rows)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return todos, total, nil
+}
+
+// sqliteSearchScore mirrors searchScore, ?1 is the search text with LIKE
+// wildcards escaped. SQLite's LIKE ignores case for ASCII letters only.
+const sqliteSearchScore = `CASE
+		WHEN title LIKE ?1 || '%' ESCAPE '\' THEN 3
+		WHEN title LIKE '%' || ?1 || '%' ESCAPE '\' THEN 2
+		WHEN EXISTS (SELECT 1 FROM json_each(todos.tags) AS tag WHERE tag.value LIKE '%' || ?1 || '%' ESCAPE '\') THEN 1
+		ELSE 0
+	END`
+
+func (s *sqliteTodoStore) Search(ctx context.Context, q SearchQuery) ([]Todo, int, error) {
+	text := likeEscaper.Replace(q.Text)
+
+	var total int
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM todos WHERE `+sqliteSearchScore+` > 0 AND `+visibleTo("?2"), text, s.owner).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `
+		SELECT ` + todoColumns + `
+		FROM todos
+		WHERE ` + sqliteSearchScore + ` > 0 AND ` + visibleTo("?2") + `
+		ORDER BY ` + sqliteSearchScore + ` DESC, id
+		LIMIT ?3 OFFSET ?4
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, text, s.owner, q.Limit, q.Offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	todos, err := scanSQLiteTodos(rows)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return todos, total, nil
+}
+
+func (s *sqliteTodoStore) TagCounts(ctx context.Context) ([]TagCount, error) {
+	query := `
+		SELECT tag.value, COUNT(*)
+		FROM todos, json_each(todos.tags) AS tag
+		WHERE ` + visibleTo("?1") + `
+		GROUP BY tag.value
+		ORDER BY COUNT(*) DESC, tag.value
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, s.owner)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := []TagCount{}
+	for rows.Next() {
+		var count TagCount
+		if err := rows.Scan(&count.Tag, &count.Count); err != nil {
+			return nil, err
+		}
+
+		counts = append(counts, count)
+	}
+
+	return counts, rows.Err()
+}
+
+func (s *sqliteTodoStore) Stats(ctx context.Context, now time.Time) (TodoStats, error) {
+	query := `
+		SELECT COUNT(*), COALESCE(SUM(done), 0), COALESCE(SUM(done = FALSE AND due_date < ?2), 0)
+		FROM todos
+		WHERE ` + visibleTo("?1")
+
+	var stats TodoStats
+	if err := s.db.QueryRowContext(ctx, query, s.owner, now.UTC()).Scan(&stats.Total, &stats.Done, &stats.Overdue); err != nil {
+		return TodoStats{}, err
+	}
+	stats.Open = stats.Total - stats.Done
+
+	return stats, nil
+}
+
+func (s *sqliteTodoStore) CreateSubtask(ctx context.Context, subtask *Subtask) error {
+	// selecting the parent turns a missing todo into sql.ErrNoRows
+	query := `
+		INSERT INTO subtasks (todo_id, title, done, created_at)
+		SELECT id, ?2, ?3, ?5 FROM todos WHERE id = ?1 AND ` + visibleTo("?4") + `
+		RETURNING id, created_at
+	`
+
+	err := s.db.QueryRowContext(ctx, query, subtask.TodoID, subtask.Title, subtask.Done, s.owner, s.now().UTC()).Scan(&subtask.ID, &subtask.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrTodoNotFound
+	}
+	subtask.CreatedAt = subtask.CreatedAt.UTC()
+
+	return err
+}
+
+func (s *sqliteTodoStore) ListSubtasks(ctx context.Context, todoID int64) ([]Subtask, error) {
+	if err := s.todoExists(ctx, todoID); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT `+subtaskColumns+` FROM subtasks WHERE todo_id = ?1 ORDER BY id`, todoID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subtasks := []Subtask{}
+	for rows.Next() {
+		var subtask Subtask
+		if err := rows.Scan(&subtask.ID, &subtask.TodoID, &subtask.Title, &subtask.Done, &subtask.CreatedAt); err != nil {
+			return nil, err
+		}
+		subtask.CreatedAt = subtask.CreatedAt.UTC()
+
+		subtasks = append(subtasks, subtask)
+	}
+
+	return subtasks, rows.Err()
+}
+
+func (s *sqliteTodoStore) UpdateSubtask(ctx context.Context, todoID, id int64, patch SubtaskPatch) (Subtask, error) {
+	query := `
+		UPDATE subtasks
+		SET title = COALESCE(?3, title), done = COALESCE(?4, done)
+		WHERE todo_id = ?1 AND id = ?2 AND todo_id IN (SELECT id FROM todos WHERE ` + visibleTo("?5") + `)
+		RETURNING ` + subtaskColumns
+
+	var subtask Subtask
+	err := s.db.QueryRowContext(ctx, query, todoID, id, patch.Title, patch.Done, s.owner).
+		Scan(&subtask.ID, &subtask.TodoID, &subtask.Title, &subtask.Done, &subtask.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Subtask{}, s.missingSubtask(ctx, todoID)
+	}
+	subtask.CreatedAt = subtask.CreatedAt.UTC()
+
+	return subtask, err
+}
+
+func (s *sqliteTodoStore) DeleteSubtask(ctx context.Context, todoID, id int64) error {
+	query := `DELETE FROM subtasks WHERE todo_id = ?1 AND id = ?2 AND todo_id IN (SELECT id FROM todos WHERE ` + visibleTo("?3") + `)`
+
+	result, err := s.db.ExecContext(ctx, query, todoID, id, s.owner)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return s.missingSubtask(ctx, todoID)
+	}
+
+	return nil
+}
+
+func (s *sqliteTodoStore) todoExists(ctx context.Context, id int64) error {
+	var exists bool
+	if err := s.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM todos WHERE id = ?1 AND `+visibleTo("?2")+`)`, id, s.owner).Scan(&exists); err != nil {
+		return err
+	}
+
+	if !exists {
+		return ErrTodoNotFound
+	}
+
+	return nil
+}
+
+// missingSubtask tells apart a missing parent from a missing subtask after a
+// statement matched no rows.
+func (s *sqliteTodoStore) missingSubtask(ctx context.Context, todoID int64) error {
+	if err := s.todoExists(ctx, todoID); err != nil {
+		return err
+	}
+
+	return ErrSubtaskNotFound
+}
+
+func (s *sqliteTodoStore) CreateComment(ctx context.Context, comment *Comment) error {
+	// selecting the parent turns a missing todo into sql.ErrNoRows
+	query := `
+		INSERT INTO comments (todo_id, author_id, body, created_at)
+		SELECT id, ?2, ?3, ?5 FROM todos WHERE id = ?1 AND ` + visibleTo("?4") + `
+		RETURNING id, created_at
+	`
+
+	err := s.db.QueryRowContext(ctx, query, comment.TodoID, comment.AuthorID, comment.Body, s.owner, s.now().UTC()).Scan(&comment.ID, &comment.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrTodoNotFound
+	}
+	comment.CreatedAt = comment.CreatedAt.UTC()
+
+	return err
+}
+
+func (s *sqliteTodoStore) ListComments(ctx context.Context, todoID int64, q CommentQuery) ([]Comment, int, error) {
+	if err := s.todoExists(ctx, todoID); err != nil {
+		return nil, 0, err
+	}
+
+	var total int
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM comments WHERE todo_id = ?1`, todoID).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT `+commentColumns+` FROM comments WHERE todo_id = ?1 ORDER BY id LIMIT ?2 OFFSET ?3`, todoID, q.Limit, q.Offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	comments := []Comment{}
+	for rows.Next() {
+		var comment Comment
+		if err := rows.Scan(&comment.ID, &comment.TodoID, &comment.AuthorID, &comment.Body, &comment.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		comment.CreatedAt = comment.CreatedAt.UTC()
+
+		comments = append(comments, comment)
+	}
+
+	return comments, total, rows.Err()
+}
+
+func (s *sqliteTodoStore) DeleteComment(ctx context.Context, todoID, id int64, author string) error {
+	query := `
+		DELETE FROM comments
+		WHERE todo_id = ?1 AND id = ?2 AND (?4 = '' OR author_id = ?4)
+			AND todo_id IN (SELECT id FROM todos WHERE ` + visibleTo("?3") + `)`
+
+	result, err := s.db.ExecContext(ctx, query, todoID, id, s.owner, author)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return s.missingComment(ctx, todoID, id)
+	}
+
+	return nil
+}
+
+// missingComment tells apart a missing todo, a missing comment and someone
+// else's comment after DeleteComment matched no rows.
+func (s *sqliteTodoStore) missingComment(ctx context.Context, todoID, id int64) error {
+	if err := s.todoExists(ctx, todoID); err != nil {
+		return err
+	}
+
+	var exists bool
+	if err := s.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM comments WHERE todo_id = ?1 AND id = ?2)`, todoID, id).Scan(&exists); err != nil {
+		return err
+	}
+
+	if !exists {
+		return ErrCommentNotFound
+	}
+
+	return ErrNotCommentAuthor
+}
+
+// listExists returns ErrListNotFound unless the list is there and the
+// owner's.
+func (s *sqliteTodoStore) listExists(ctx context.Context, tx *sql.Tx, id int64) error {
+	var exists bool
+	if err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM lists WHERE id = ?1 AND `+ownedBy("?2")+`)`, id, s.owner).Scan(&exists); err != nil {
+		return err
+	}
+
+	if !exists {
+		return ErrListNotFound
+	}
+
+	return nil
+}
+
+func scanSQLiteList(row rowScanner, list *TodoList) error {
+	if err := scanList(row, list); err != nil {
+		return err
+	}
+	list.CreatedAt = list.CreatedAt.UTC()
+
+	return nil
+}
+
+func (s *sqliteTodoStore) CreateList(ctx context.Context, list *TodoList) error {
+	if s.owner != "" {
+		list.OwnerID = s.owner
+	}
+
+	query := `INSERT INTO lists (name, owner_id, created_at) VALUES (?1, ?2, ?3) RETURNING ` + listColumns
+
+	return scanSQLiteList(s.db.QueryRowContext(ctx, query, list.Name, list.OwnerID, s.now().UTC()), list)
+}
+
+func (s *sqliteTodoStore) GetList(ctx context.Context, id int64) (TodoList, error) {
+	var list TodoList
+	err := scanSQLiteList(s.db.QueryRowContext(ctx, `SELECT `+listColumns+` FROM lists WHERE id = ?1 AND `+ownedBy("?2"), id, s.owner), &list)
+	if errors.Is(err, sql.ErrNoRows) {
+		return TodoList{}, ErrListNotFound
+	}
+
+	return list, err
+}
+
+func (s *sqliteTodoStore) ListLists(ctx context.Context) ([]TodoList, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+listColumns+` FROM lists WHERE `+ownedBy("?1")+` ORDER BY id`, s.owner)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lists := []TodoList{}
+	for rows.Next() {
+		var list TodoList
+		if err := scanSQLiteList(rows, &list); err != nil {
+			return nil, err
+		}
+
+		lists = append(lists, list)
+	}
+
+	return lists, rows.Err()
+}
+
+func (s *sqliteTodoStore) RenameList(ctx context.Context, id int64, name string) (TodoList, error) {
+	query := `UPDATE lists SET name = ?3 WHERE id = ?1 AND ` + ownedBy("?2") + ` RETURNING ` + listColumns
+
+	var list TodoList
+	err := scanSQLiteList(s.db.QueryRowContext(ctx, query, id, s.owner, name), &list)
+	if errors.Is(err, sql.ErrNoRows) {
+		return TodoList{}, ErrListNotFound
+	}
+
+	return list, err
+}
+
+// DeleteList leaves the todos, and their subtasks and comments, to ON DELETE
+// CASCADE.
+func (s *sqliteTodoStore) DeleteList(ctx context.Context, id int64, force bool) ([]Todo, error) {
+	var deleted []Todo
+	err := inTx(ctx, s.db, func(tx *sql.Tx) error {
+		if err := s.listExists(ctx, tx, id); err != nil {
+			return err
+		}
+
+		rows, err := tx.QueryContext(ctx, `SELECT `+todoColumns+` FROM todos WHERE list_id = ?1 AND deleted_at IS NULL ORDER BY id`, id)
+		if err != nil {
+			return err
+		}
+		if deleted, err = scanSQLiteTodos(rows); err != nil {
+			return err
+		}
+		if len(deleted) > 0 && !force {
+			return ErrListNotEmpty
+		}
+
+		_, err = tx.ExecContext(ctx, `DELETE FROM lists WHERE id = ?1`, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return deleted, nil
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func newTestSQLiteDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := openSQLite(filepath.Join(t.TempDir(), "todos.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+func newTestSQLiteStore(t *testing.T) *sqliteTodoStore {
+	t.Helper()
+
+	store, err := newSQLiteTodoStore(context.Background(), newTestSQLiteDB(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return store
+}
+
+func TestSQLiteMigrations(t *testing.T) {
+	ctx := context.Background()
+	db := newTestSQLiteDB(t)
+
+	if err := migrateSQLite(ctx, db); err != nil {
+		t.Fatal(err)
+	}
+
+	store := &sqliteTodoStore{db: db, now: time.Now}
+	if err := store.Create(ctx, &Todo{Title: "survives"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// a second run finds every migration recorded and touches nothing
+	if err := migrateSQLite(ctx, db); err != nil {
+		t.Fatalf("migrations are not idempotent: %v", err)
+	}
+
+	rows, err := db.QueryContext(ctx, `SELECT version FROM schema_version ORDER BY version`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var versions []int
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			t.Fatal(err)
+		}
+		versions = append(versions, version)
+	}
+
+	if want := []int{1, 2, 3, 4, 5, 6, 7, 8}; !slices.Equal(versions, want) {
+		t.Errorf("expected versions %v to be recorded once each, got %v", want, versions)
+	}
+
+	if todo, err := store.Get(ctx, 1); err != nil || todo.Title != "survives" {
+		t.Errorf("expected the todo to survive, got %+v (%v)", todo, err)
+	}
+}
+
+func TestMigrationVersion(t *testing.T) {
+	if version, err := migrationVersion("migrations/sqlite/0012_add_things.sql"); err != nil || version != 12 {
+		t.Errorf("expected version 12, got %d (%v)", version, err)
+	}
+
+	for _, name := range []string{"add_things.sql", "0000_nothing.sql", "x1_bad.sql"} {
+		if _, err := migrationVersion(name); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestSQLiteTodoStore(t *testing.T) {
+	ctx := context.Background()
+	store := newTestSQLiteStore(t)
+
+	due := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	todo := &Todo{Title: "with fields", Priority: PriorityHigh, Tags: []string{"home", "work"}, DueDate: &due, Done: true}
+	if err := store.Create(ctx, todo); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := store.Get(ctx, todo.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Priority != PriorityHigh || !slices.Equal(got.Tags, []string{"home", "work"}) || !got.DueDate.Equal(due) || got.CompletedAt == nil {
+		t.Errorf("fields did not round trip: %+v", got)
+	}
+	if got.CreatedAt.Location() != time.UTC {
+		t.Errorf("expected UTC timestamps, got %v", got.CreatedAt.Location())
+	}
+
+	if _, err := store.Get(ctx, 99); !errors.Is(err, ErrTodoNotFound) {
+		t.Errorf("expected ErrTodoNotFound, got %v", err)
+	}
+
+	// trashing the todo keeps its subtasks and comments for a restore,
+	// purging it cascades to them
+	if err := store.CreateSubtask(ctx, &Subtask{TodoID: todo.ID, Title: "step"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.CreateComment(ctx, &Comment{TodoID: todo.ID, Body: "soon"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Delete(ctx, todo.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	count := func(table string) int {
+		t.Helper()
+
+		var n int
+		if err := store.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM `+table).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	if n := count("subtasks"); n != 1 {
+		t.Errorf("expected the trashed todo to keep its subtask, %d are left", n)
+	}
+	if n := count("comments"); n != 1 {
+		t.Errorf("expected the trashed todo to keep its comment, %d are left", n)
+	}
+
+	if purged, err := store.PurgeTrash(ctx, time.Now().Add(time.Minute)); err != nil || purged != 1 {
+		t.Fatalf("expected 1 todo purged, got %d (%v)", purged, err)
+	}
+	if n := count("subtasks"); n != 0 {
+		t.Errorf("expected the subtasks to be purged, %d are left", n)
+	}
+	if n := count("comments"); n != 0 {
+		t.Errorf("expected the comments to be purged, %d are left", n)
+	}
+
+	for _, todo := range []*Todo{{Title: "100% done"}, {Title: "f_s"}, {Title: "fxs"}} {
+		if err := store.Create(ctx, todo); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, total, _ := store.Search(ctx, SearchQuery{Text: "0%", Limit: 10}); total != 1 {
+		t.Errorf("expected %% to match literally, got %d todos", total)
+	}
+	if _, total, _ := store.Search(ctx, SearchQuery{Text: "f_s", Limit: 10}); total != 1 {
+		t.Errorf("expected _ to match literally, got %d todos", total)
+	}
+}
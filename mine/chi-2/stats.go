@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// TodoStats sums up the todos outside the trash, archived ones included.
+// Open is every todo not done, Overdue the open ones that are past due.
+type TodoStats struct {
+	Total   int `json:"total"`
+	Done    int `json:"done"`
+	Open    int `json:"open"`
+	Overdue int `json:"overdue"`
+}
+
+func (h *todoHandler) todoStats(w http.ResponseWriter, r *http.Request) {
+	stats, err := h.storeFor(r).Stats(r.Context(), h.clock().UTC())
+	if err != nil {
+		storeError(w, r, "sum up stats of", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, stats)
+}
+
+func (s *memoryTodoStore) Stats(ctx context.Context, now time.Time) (TodoStats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return TodoStats{}, err
+	}
+
+	var stats TodoStats
+	for i := range s.todos {
+		todo := &s.todos[i]
+		if !s.sees(todo) {
+			continue
+		}
+
+		stats.Total++
+		if todo.Done {
+			stats.Done++
+		}
+		if todo.IsOverdue(now) {
+			stats.Overdue++
+		}
+	}
+	stats.Open = stats.Total - stats.Done
+
+	return stats, nil
+}
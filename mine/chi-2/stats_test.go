@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTodoStats(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store TodoStore) {
+		now := time.Date(2030, 6, 1, 12, 0, 0, 0, time.UTC)
+		h := &todoHandler{store: store, now: func() time.Time { return now }}
+		ctx := context.Background()
+
+		stats := func() TodoStats {
+			t.Helper()
+
+			rr := httptest.NewRecorder()
+			h.todoStats(rr, httptest.NewRequest(http.MethodGet, "/todo/stats", nil))
+			if rr.Code != http.StatusOK {
+				t.Fatalf("expected status %d, got %d", http.StatusOK, rr.Code)
+			}
+
+			var stats TodoStats
+			if err := json.NewDecoder(rr.Body).Decode(&stats); err != nil {
+				t.Fatal(err)
+			}
+			return stats
+		}
+
+		if got := stats(); got != (TodoStats{}) {
+			t.Errorf("expected zeros for an empty store, got %+v", got)
+		}
+
+		past, future := now.Add(-time.Hour), now.Add(time.Hour)
+		todos := []*Todo{
+			{Title: "overdue", DueDate: &past},
+			{Title: "overdue and archived", DueDate: &past},
+			{Title: "due now", DueDate: &now},
+			{Title: "due later", DueDate: &future},
+			{Title: "no due date"},
+			{Title: "done late", Done: true, DueDate: &past},
+			{Title: "done", Done: true},
+			{Title: "trashed", DueDate: &past},
+		}
+		for _, todo := range todos {
+			if err := store.Create(ctx, todo); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err := store.SetArchived(ctx, todos[1].ID, true); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Delete(ctx, todos[7].ID); err != nil {
+			t.Fatal(err)
+		}
+
+		// the trash is left out, archived todos count, and a todo due right
+		// now isn't overdue yet
+		want := TodoStats{Total: 7, Done: 2, Open: 5, Overdue: 2}
+		if got := stats(); got != want {
+			t.Errorf("expected %+v, got %+v", want, got)
+		}
+
+		if got, err := store.ForOwner("bob").Stats(ctx, now); err != nil || got != (TodoStats{}) {
+			t.Errorf("expected zeros for someone without todos, got %+v (%v)", got, err)
+		}
+	})
+}
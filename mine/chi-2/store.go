@@ -0,0 +1,882 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+type Todo struct {
+	ID        int64      `json:"id"`
+	Title     string     `json:"title"`
+	Done      bool       `json:"done"`
+	Priority  Priority   `json:"priority"`
+	Tags      []string   `json:"tags,omitempty"`
+	DueDate   *time.Time `json:"due_date,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	// CompletedAt is set when the todo is marked done and cleared when it is
+	// reopened.
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	// OwnerID is the subject of the token the todo was created with.
+	OwnerID string `json:"owner_id,omitempty"`
+	// Archived todos are hidden from the default list and can't be updated
+	// until they are unarchived.
+	Archived   bool       `json:"archived"`
+	ArchivedAt *time.Time `json:"archived_at,omitempty"`
+	// Recurrence, when set, makes completing the todo create the next
+	// occurrence, due Recurrence.Next(DueDate). It requires a due date.
+	Recurrence *Recurrence `json:"recurrence,omitempty"`
+	// SeriesID links the occurrences of a recurring todo, it is the id of
+	// the first one. It stays 0 for a todo that never recurred.
+	SeriesID int64 `json:"series_id,omitempty"`
+	// DeletedAt is set while the todo is in the trash, where only the trash
+	// methods of the store can see it.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	// Version starts at 1 and goes up with every write to the todo, PUT and
+	// PATCH must send the one they were based on.
+	Version       int           `json:"version"`
+	SubtaskCounts SubtaskCounts `json:"subtask_counts"`
+	CommentCount  int           `json:"comment_count"`
+	// ClientID is the id an offline client gave the todo with PUT
+	// /todo/{clientID}, unique per owner. Todos created with POST have none.
+	ClientID string `json:"client_id,omitempty"`
+	// ListID is the list the todo was created in, 0 for a todo in none. It
+	// can't change after that.
+	ListID int64 `json:"list_id,omitempty"`
+
+	// next is the occurrence that completing this todo created, set on the
+	// todo Update, PutByClientID and SetDone return. It is never stored.
+	next *Todo
+}
+
+// sameContent reports whether t already holds everything a PUT of other
+// would write, so the PUT can leave it alone.
+func (t *Todo) sameContent(other *Todo) bool {
+	sameDue := t.DueDate == nil && other.DueDate == nil ||
+		t.DueDate != nil && other.DueDate != nil && t.DueDate.Equal(*other.DueDate)
+	sameRecurrence := t.Recurrence == nil && other.Recurrence == nil ||
+		t.Recurrence != nil && other.Recurrence != nil && *t.Recurrence == *other.Recurrence
+
+	return t.Title == other.Title && t.Done == other.Done && t.Priority == other.Priority &&
+		slices.Equal(t.Tags, other.Tags) && sameDue && sameRecurrence
+}
+
+// setDone flips Done, stamping CompletedAt with now. Setting the current
+// value again is a no-op so CompletedAt keeps the original completion time.
+func (t *Todo) setDone(done bool, now time.Time) {
+	if t.Done == done {
+		return
+	}
+
+	t.Done = done
+	t.CompletedAt = nil
+	if done {
+		t.CompletedAt = &now
+	}
+}
+
+// setArchived is setDone for Archived and ArchivedAt.
+func (t *Todo) setArchived(archived bool, now time.Time) {
+	if t.Archived == archived {
+		return
+	}
+
+	t.Archived = archived
+	t.ArchivedAt = nil
+	if archived {
+		t.ArchivedAt = &now
+	}
+}
+
+// IsOverdue reports whether the todo is still open and was due before now.
+func (t *Todo) IsOverdue(now time.Time) bool {
+	return !t.Done && t.DueDate != nil && t.DueDate.Before(now)
+}
+
+const (
+	sortCreated = "created"
+	sortDue     = "due"
+	// sortDueDate is accepted as an alias of sortDue.
+	sortDueDate  = "due_date"
+	sortPriority = "priority"
+
+	orderAsc  = "asc"
+	orderDesc = "desc"
+)
+
+type ListQuery struct {
+	Offset int
+	Limit  int
+	// Done, when set, keeps only the todos with that completion state.
+	Done *bool
+	// Priority, when set, keeps only the todos with that priority.
+	Priority Priority
+	// Tags keeps the todos carrying any of the given tags.
+	Tags []string
+	// Archived, when set, keeps only the archived or only the unarchived
+	// todos. The list handler defaults it to false.
+	Archived *bool
+	// Series, when not 0, keeps only the occurrences of that series.
+	Series int64
+	// ListID, when not 0, keeps only the todos of that list.
+	ListID int64
+	// Overdue keeps only the todos for which IsOverdue(Now) holds.
+	Overdue bool
+	Now     time.Time
+	// DueAfter and DueBefore, when set, keep only the todos due within them,
+	// both ends included. Todos without a due date are left out as soon as
+	// either is set.
+	DueAfter  *time.Time
+	DueBefore *time.Time
+	// due is ?due=, left for the handler to expand into DueAfter and
+	// DueBefore.
+	due string
+	// Sort is one of sortCreated or sortDue; empty keeps insertion order.
+	Sort  string
+	Order string
+
+	// page is the window as the request asked for it, for the Link header.
+	page pagination
+}
+
+func (q ListQuery) matches(todo *Todo) bool {
+	if q.Done != nil && todo.Done != *q.Done {
+		return false
+	}
+
+	if q.Archived != nil && todo.Archived != *q.Archived {
+		return false
+	}
+
+	if q.Priority != "" && todo.Priority != q.Priority {
+		return false
+	}
+
+	if q.Series != 0 && todo.SeriesID != q.Series {
+		return false
+	}
+
+	if q.ListID != 0 && todo.ListID != q.ListID {
+		return false
+	}
+
+	if len(q.Tags) > 0 && !slices.ContainsFunc(q.Tags, func(tag string) bool {
+		return slices.Contains(todo.Tags, tag)
+	}) {
+		return false
+	}
+
+	if q.Overdue && !todo.IsOverdue(q.Now) {
+		return false
+	}
+
+	if q.DueAfter != nil || q.DueBefore != nil {
+		if todo.DueDate == nil ||
+			q.DueAfter != nil && todo.DueDate.Before(*q.DueAfter) ||
+			q.DueBefore != nil && todo.DueDate.After(*q.DueBefore) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// sortTodos orders todos in place by the given field. Todos without a due date
+// always come last when sorting by due date, whatever the order. Todos of the
+// same priority stay ordered by creation time when sorting by priority.
+func sortTodos(todos []Todo, field, order string) {
+	desc := order == orderDesc
+
+	switch field {
+	case sortCreated:
+		sort.SliceStable(todos, func(i, j int) bool {
+			if desc {
+				return todos[i].CreatedAt.After(todos[j].CreatedAt)
+			}
+			return todos[i].CreatedAt.Before(todos[j].CreatedAt)
+		})
+	case sortDue:
+		sort.SliceStable(todos, func(i, j int) bool {
+			a, b := todos[i].DueDate, todos[j].DueDate
+			if a == nil || b == nil {
+				return a != nil && b == nil
+			}
+			if desc {
+				return a.After(*b)
+			}
+			return a.Before(*b)
+		})
+	case sortPriority:
+		sort.SliceStable(todos, func(i, j int) bool {
+			a, b := todos[i].Priority.rank(), todos[j].Priority.rank()
+			if a == b {
+				return todos[i].CreatedAt.Before(todos[j].CreatedAt)
+			}
+			if desc {
+				return a > b
+			}
+			return a < b
+		})
+	}
+}
+
+var (
+	ErrTodoNotFound = errors.New("todo not found")
+	ErrTodoArchived = errors.New("todo is archived")
+)
+
+// PutResult tells what PutByClientID did.
+type PutResult int
+
+const (
+	PutCreated PutResult = iota
+	PutUpdated
+	// PutUnchanged is a repeat of the PUT that is already stored.
+	PutUnchanged
+)
+
+type TodoStore interface {
+	// ForOwner returns a view of the store limited to the todos of owner,
+	// where todos of anyone else behave as if they didn't exist and new todos
+	// belong to owner. An empty owner sees every todo.
+	ForOwner(owner string) TodoStore
+
+	// Create returns ErrListNotFound when todo.ListID names a missing list.
+	Create(ctx context.Context, todo *Todo) error
+	Get(ctx context.Context, id int64) (Todo, error)
+	// Update replaces the title, done flag, due date and recurrence of
+	// todo.ID, filling the remaining fields of todo from the stored row. It
+	// returns ErrTodoArchived for an archived todo.
+	//
+	// todo.Version is the version the change was based on, when the stored
+	// todo is at another one nothing is written and the error is an
+	// *ErrVersionConflict. The check and the write are one atomic step. A
+	// version of 0 skips the check.
+	Update(ctx context.Context, todo *Todo) error
+	// PutByClientID creates or replaces the owner's todo named todo.ClientID,
+	// for clients that pick their own ids and retry freely. The first PUT
+	// creates the todo, a repeat with the content already stored changes
+	// nothing, not even the version, and one with other content updates it
+	// like Update without a version check. The todo keeps a regular id, so
+	// it lists and publishes events like any other. todo is filled from the
+	// stored row.
+	//
+	// It returns ErrTodoArchived for an archived todo and ErrTodoNotFound
+	// for one in the trash, which has to be restored first.
+	PutByClientID(ctx context.Context, todo *Todo) (PutResult, error)
+	// Delete moves the todo to the trash. From then on it is missing for
+	// every method but ListTrash, Restore and PurgeTrash.
+	Delete(ctx context.Context, id int64) error
+	// SetDone marks the todo as done or not done and returns it. version is
+	// checked like the one of Update.
+	//
+	// Completing a recurring todo, here, in Update or in CompleteMany, also
+	// creates its next occurrence unless the series already has one due
+	// later, so completing it again doesn't repeat that. The occurrence is
+	// returned in the todo's next field.
+	SetDone(ctx context.Context, id int64, done bool, version int) (Todo, error)
+	// SetArchived archives or unarchives the todo and returns it.
+	SetArchived(ctx context.Context, id int64, archived bool) (Todo, error)
+	// CompleteMany marks every todo in ids done in one atomic step and splits
+	// ids into the ones it found and the missing ones, keeping their order.
+	// Todos that were already done count as completed. spawned holds the
+	// occurrences of the recurring todos it completed.
+	CompleteMany(ctx context.Context, ids []int64) (completed, missing []int64, spawned []Todo, err error)
+	// List returns the todos matching q in the [q.Offset, q.Offset+q.Limit)
+	// window together with the total number of matching todos.
+	List(ctx context.Context, q ListQuery) ([]Todo, int, error)
+	// TagCounts returns every tag in use with the number of todos carrying it.
+	TagCounts(ctx context.Context) ([]TagCount, error)
+	// Stats counts the todos, now deciding which ones are overdue.
+	Stats(ctx context.Context, now time.Time) (TodoStats, error)
+	// Search returns the todos whose title or tags contain q.Text, ranked
+	// title prefix first, then title substring, then tag, and oldest first
+	// within a rank. The window and total work like List.
+	Search(ctx context.Context, q SearchQuery) ([]Todo, int, error)
+
+	// ListTrash returns the trashed todos, most recently deleted first, in a
+	// window that works like the one of List.
+	ListTrash(ctx context.Context, q TrashQuery) ([]Todo, int, error)
+	// Restore takes the todo out of the trash, its subtasks and tags come back
+	// with it. It returns ErrTodoNotFound when the todo isn't in the trash.
+	Restore(ctx context.Context, id int64) (Todo, error)
+	// PurgeTrash removes the todos that were trashed before cutoff for good,
+	// subtasks included, and returns how many there were.
+	PurgeTrash(ctx context.Context, cutoff time.Time) (int, error)
+
+	// The subtask methods return ErrTodoNotFound when the parent todo is
+	// missing and ErrSubtaskNotFound when the subtask is.
+	CreateSubtask(ctx context.Context, subtask *Subtask) error
+	ListSubtasks(ctx context.Context, todoID int64) ([]Subtask, error)
+	UpdateSubtask(ctx context.Context, todoID, id int64, patch SubtaskPatch) (Subtask, error)
+	DeleteSubtask(ctx context.Context, todoID, id int64) error
+	// The comment methods return errors the way the subtask methods do, with
+	// ErrCommentNotFound for a missing comment.
+	CreateComment(ctx context.Context, comment *Comment) error
+	// ListComments returns the comments on the todo oldest first, in the
+	// [q.Offset, q.Offset+q.Limit) window, together with their total number.
+	ListComments(ctx context.Context, todoID int64, q CommentQuery) ([]Comment, int, error)
+	// DeleteComment deletes the comment if author wrote it, an empty author
+	// deletes anyone's. It returns ErrNotCommentAuthor otherwise.
+	DeleteComment(ctx context.Context, todoID, id int64, author string) error
+	// Lists belong to an owner the way todos do. The list methods return
+	// ErrListNotFound for a missing list.
+	CreateList(ctx context.Context, list *TodoList) error
+	GetList(ctx context.Context, id int64) (TodoList, error)
+	// ListLists returns every list, oldest first.
+	ListLists(ctx context.Context) ([]TodoList, error)
+	RenameList(ctx context.Context, id int64, name string) (TodoList, error)
+	// DeleteList deletes the list with its todos, trashed ones included, and
+	// returns the todos that were not in the trash. Without force it returns
+	// ErrListNotEmpty instead when there are any.
+	DeleteList(ctx context.Context, id int64, force bool) ([]Todo, error)
+}
+
+// memoryTodoStore is a view of memoryTodos, ForOwner hands out views that
+// share the data but only see one owner's todos. Every method checks ctx once
+// it holds the lock, so a request that ran out of time waiting for it changes
+// nothing.
+type memoryTodoStore struct {
+	*memoryTodos
+	// owner, when set, hides the todos of everyone else and owns new ones.
+	owner string
+}
+
+type memoryTodos struct {
+	mu     sync.RWMutex
+	nextID int64
+	todos  []Todo
+	// tags counts the todos per tag, kept in step with todos under mu.
+	tags map[string]int
+	// subtasks are keyed by the id of their todo.
+	subtasks      map[int64][]Subtask
+	nextSubtaskID int64
+	// comments are keyed by the id of their todo, oldest first.
+	comments      map[int64][]Comment
+	nextCommentID int64
+	lists         []TodoList
+	nextListID    int64
+	// now stamps CreatedAt and CompletedAt, tests swap it for a fixed clock.
+	now func() time.Time
+}
+
+func newMemoryTodoStore() *memoryTodoStore {
+	return &memoryTodoStore{memoryTodos: &memoryTodos{
+		nextID:        1,
+		now:           time.Now,
+		tags:          make(map[string]int),
+		subtasks:      make(map[int64][]Subtask),
+		nextSubtaskID: 1,
+		comments:      make(map[int64][]Comment),
+		nextCommentID: 1,
+		nextListID:    1,
+	}}
+}
+
+func (s *memoryTodoStore) ForOwner(owner string) TodoStore {
+	return &memoryTodoStore{memoryTodos: s.memoryTodos, owner: owner}
+}
+
+func (s *memoryTodoStore) owns(todo *Todo) bool {
+	return s.owner == "" || todo.OwnerID == s.owner
+}
+
+// sees is owns without the trashed todos.
+func (s *memoryTodoStore) sees(todo *Todo) bool {
+	return s.owns(todo) && todo.DeletedAt == nil
+}
+
+func (s *memoryTodoStore) Create(ctx context.Context, todo *Todo) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if todo.ListID != 0 && s.indexOfList(todo.ListID) < 0 {
+		return ErrListNotFound
+	}
+
+	s.create(todo)
+	return nil
+}
+
+// create is Create for a caller holding the lock.
+func (s *memoryTodoStore) create(todo *Todo) {
+	now := s.now().UTC()
+
+	todo.ID = s.nextID
+	todo.CreatedAt = now
+	if s.owner != "" {
+		todo.OwnerID = s.owner
+	}
+	todo.SubtaskCounts = SubtaskCounts{}
+	todo.CommentCount = 0
+	todo.Version = 1
+	if todo.Priority == "" {
+		todo.Priority = defaultPriority
+	}
+	todo.CompletedAt = nil
+	if todo.Done {
+		todo.CompletedAt = &now
+	}
+	todo.SeriesID = 0
+	if todo.Recurrence != nil {
+		todo.SeriesID = todo.ID
+	}
+	s.nextID++
+
+	// the store keeps its own copy so callers can't change the index behind
+	// its back
+	todo.Tags = slices.Clone(todo.Tags)
+	s.todos = append(s.todos, *todo)
+	s.indexTags(todo.Tags, 1)
+}
+
+func (s *memoryTodoStore) List(ctx context.Context, q ListQuery) ([]Todo, int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	matched := []Todo{}
+	for i := range s.todos {
+		if s.sees(&s.todos[i]) && q.matches(&s.todos[i]) {
+			matched = append(matched, s.todos[i])
+		}
+	}
+
+	sortTodos(matched, q.Sort, q.Order)
+
+	total := len(matched)
+	offset := min(max(q.Offset, 0), total)
+	end := min(offset+q.Limit, total)
+
+	return matched[offset:end], total, nil
+}
+
+func (s *memoryTodoStore) Get(ctx context.Context, id int64) (Todo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return Todo{}, err
+	}
+
+	i := s.indexOf(id)
+	if i < 0 {
+		return Todo{}, ErrTodoNotFound
+	}
+
+	return s.todos[i], nil
+}
+
+func (s *memoryTodoStore) Update(ctx context.Context, todo *Todo) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	i := s.indexOf(todo.ID)
+	if i < 0 {
+		return ErrTodoNotFound
+	}
+
+	stored := &s.todos[i]
+	if todo.Version != 0 && stored.Version != todo.Version {
+		return &ErrVersionConflict{Current: *stored}
+	}
+
+	return s.update(i, todo)
+}
+
+// update writes todo over s.todos[i] for Update and PutByClientID, which hold
+// the lock and have checked the version.
+func (s *memoryTodoStore) update(i int, todo *Todo) error {
+	stored := &s.todos[i]
+	if stored.Archived {
+		return ErrTodoArchived
+	}
+
+	s.indexTags(stored.Tags, -1)
+	stored.Tags = slices.Clone(todo.Tags)
+	s.indexTags(stored.Tags, 1)
+	stored.Title = todo.Title
+	stored.Priority = todo.Priority
+	stored.DueDate = todo.DueDate
+	stored.Recurrence = todo.Recurrence
+	// a todo keeps its series once it had a rule, even if the rule goes
+	if stored.SeriesID == 0 && stored.Recurrence != nil {
+		stored.SeriesID = stored.ID
+	}
+	stored.setDone(todo.Done, s.now().UTC())
+	stored.Version++
+
+	*todo = s.todos[i]
+	todo.next = s.recur(i)
+
+	return nil
+}
+
+func (s *memoryTodoStore) PutByClientID(ctx context.Context, todo *Todo) (PutResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	// the trashed todos are looked at too, a client id stays taken until
+	// the todo is purged
+	i := slices.IndexFunc(s.todos, func(stored Todo) bool {
+		return stored.ClientID == todo.ClientID && stored.OwnerID == s.owner
+	})
+	if i < 0 {
+		todo.OwnerID = s.owner
+		s.create(todo)
+		return PutCreated, nil
+	}
+
+	stored := &s.todos[i]
+	if stored.DeletedAt != nil {
+		return 0, ErrTodoNotFound
+	}
+	if stored.sameContent(todo) {
+		*todo = *stored
+		return PutUnchanged, nil
+	}
+
+	todo.ID = stored.ID
+	if err := s.update(i, todo); err != nil {
+		return 0, err
+	}
+
+	return PutUpdated, nil
+}
+
+func (s *memoryTodoStore) Delete(ctx context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	i := s.indexOf(id)
+	if i < 0 {
+		return ErrTodoNotFound
+	}
+
+	// the tag index only counts todos outside the trash
+	s.indexTags(s.todos[i].Tags, -1)
+	now := s.now().UTC()
+	s.todos[i].DeletedAt = &now
+	s.todos[i].Version++
+
+	return nil
+}
+
+func (s *memoryTodoStore) SetDone(ctx context.Context, id int64, done bool, version int) (Todo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return Todo{}, err
+	}
+
+	i := s.indexOf(id)
+	if i < 0 {
+		return Todo{}, ErrTodoNotFound
+	}
+	if version != 0 && s.todos[i].Version != version {
+		return Todo{}, &ErrVersionConflict{Current: s.todos[i]}
+	}
+
+	s.todos[i].setDone(done, s.now().UTC())
+	s.todos[i].Version++
+	todo := s.todos[i]
+	todo.next = s.recur(i)
+
+	return todo, nil
+}
+
+func (s *memoryTodoStore) SetArchived(ctx context.Context, id int64, archived bool) (Todo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return Todo{}, err
+	}
+
+	i := s.indexOf(id)
+	if i < 0 {
+		return Todo{}, ErrTodoNotFound
+	}
+
+	s.todos[i].setArchived(archived, s.now().UTC())
+	s.todos[i].Version++
+
+	return s.todos[i], nil
+}
+
+func (s *memoryTodoStore) CompleteMany(ctx context.Context, ids []int64) ([]int64, []int64, []Todo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, nil, nil, err
+	}
+
+	now := s.now().UTC()
+	completed, missing, spawned := []int64{}, []int64{}, []Todo{}
+
+	for _, id := range ids {
+		i := s.indexOf(id)
+		if i < 0 {
+			missing = append(missing, id)
+			continue
+		}
+
+		s.todos[i].setDone(true, now)
+		s.todos[i].Version++
+		if next := s.recur(i); next != nil {
+			spawned = append(spawned, *next)
+		}
+		completed = append(completed, id)
+	}
+
+	return completed, missing, spawned, nil
+}
+
+func (s *memoryTodoStore) TagCounts(ctx context.Context) ([]TagCount, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// the index covers every owner, a scoped view counts its own todos
+	tags := s.tags
+	if s.owner != "" {
+		tags = make(map[string]int)
+		for i := range s.todos {
+			if s.sees(&s.todos[i]) {
+				for _, tag := range s.todos[i].Tags {
+					tags[tag]++
+				}
+			}
+		}
+	}
+
+	counts := make([]TagCount, 0, len(tags))
+	for tag, count := range tags {
+		counts = append(counts, TagCount{Tag: tag, Count: count})
+	}
+
+	sortTagCounts(counts)
+
+	return counts, nil
+}
+
+func (s *memoryTodoStore) CreateSubtask(ctx context.Context, subtask *Subtask) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	i := s.indexOf(subtask.TodoID)
+	if i < 0 {
+		return ErrTodoNotFound
+	}
+
+	subtask.ID = s.nextSubtaskID
+	subtask.CreatedAt = s.now().UTC()
+	s.nextSubtaskID++
+
+	s.subtasks[subtask.TodoID] = append(s.subtasks[subtask.TodoID], *subtask)
+	s.countSubtasks(i)
+
+	return nil
+}
+
+func (s *memoryTodoStore) ListSubtasks(ctx context.Context, todoID int64) ([]Subtask, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if s.indexOf(todoID) < 0 {
+		return nil, ErrTodoNotFound
+	}
+
+	subtasks := make([]Subtask, len(s.subtasks[todoID]))
+	copy(subtasks, s.subtasks[todoID])
+
+	return subtasks, nil
+}
+
+func (s *memoryTodoStore) UpdateSubtask(ctx context.Context, todoID, id int64, patch SubtaskPatch) (Subtask, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return Subtask{}, err
+	}
+
+	i := s.indexOf(todoID)
+	if i < 0 {
+		return Subtask{}, ErrTodoNotFound
+	}
+
+	subtasks := s.subtasks[todoID]
+	for j := range subtasks {
+		if subtasks[j].ID != id {
+			continue
+		}
+
+		if patch.Title != nil {
+			subtasks[j].Title = *patch.Title
+		}
+		if patch.Done != nil {
+			subtasks[j].Done = *patch.Done
+		}
+
+		s.countSubtasks(i)
+
+		return subtasks[j], nil
+	}
+
+	return Subtask{}, ErrSubtaskNotFound
+}
+
+func (s *memoryTodoStore) DeleteSubtask(ctx context.Context, todoID, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	i := s.indexOf(todoID)
+	if i < 0 {
+		return ErrTodoNotFound
+	}
+
+	subtasks := s.subtasks[todoID]
+	for j := range subtasks {
+		if subtasks[j].ID == id {
+			s.subtasks[todoID] = append(subtasks[:j], subtasks[j+1:]...)
+			s.countSubtasks(i)
+			return nil
+		}
+	}
+
+	return ErrSubtaskNotFound
+}
+
+// recur appends the next occurrence of the todo at index i when it is a
+// completed recurring todo and its series has nothing due after it yet, and
+// returns it. It must be called with s.mu held for writing and may move
+// s.todos.
+func (s *memoryTodoStore) recur(i int) *Todo {
+	current := s.todos[i]
+	if !current.Done || current.Recurrence == nil || current.DueDate == nil {
+		return nil
+	}
+
+	for j := range s.todos {
+		other := &s.todos[j]
+		if other.SeriesID == current.SeriesID && other.DueDate != nil && other.DueDate.After(*current.DueDate) {
+			return nil
+		}
+	}
+
+	due := current.Recurrence.Next(*current.DueDate)
+	next := Todo{
+		ID:            s.nextID,
+		Title:         current.Title,
+		Priority:      current.Priority,
+		Tags:          slices.Clone(current.Tags),
+		DueDate:       &due,
+		CreatedAt:     s.now().UTC(),
+		OwnerID:       current.OwnerID,
+		Recurrence:    current.Recurrence,
+		SeriesID:      current.SeriesID,
+		ListID:        current.ListID,
+		Version:       1,
+		SubtaskCounts: SubtaskCounts{},
+	}
+	s.nextID++
+
+	s.todos = append(s.todos, next)
+	s.indexTags(next.Tags, 1)
+
+	return &next
+}
+
+// countSubtasks refreshes the SubtaskCounts of the todo at index i. It must
+// be called with s.mu held for writing.
+func (s *memoryTodoStore) countSubtasks(i int) {
+	counts := SubtaskCounts{}
+	for _, subtask := range s.subtasks[s.todos[i].ID] {
+		counts.Total++
+		if subtask.Done {
+			counts.Done++
+		}
+	}
+
+	s.todos[i].SubtaskCounts = counts
+}
+
+// indexTags adds delta to the count of every tag. It must be called with
+// s.mu held for writing.
+func (s *memoryTodoStore) indexTags(tags []string, delta int) {
+	for _, tag := range tags {
+		s.tags[tag] += delta
+		if s.tags[tag] <= 0 {
+			delete(s.tags, tag)
+		}
+	}
+}
+
+// indexOf must be called with s.mu held. Todos of other owners and trashed
+// todos are not found, so every lookup by id is scoped.
+func (s *memoryTodoStore) indexOf(id int64) int {
+	for i := range s.todos {
+		if s.todos[i].ID == id {
+			if !s.sees(&s.todos[i]) {
+				return -1
+			}
+			return i
+		}
+	}
+
+	return -1
+}
+
+// indexOfTrashed is indexOf for the trash. It must be called with s.mu held.
+func (s *memoryTodoStore) indexOfTrashed(id int64) int {
+	for i := range s.todos {
+		if s.todos[i].ID == id {
+			if !s.owns(&s.todos[i]) || s.todos[i].DeletedAt == nil {
+				return -1
+			}
+			return i
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,23 @@
+package main
+
+import "testing"
+
+// todoStores lists every TodoStore implementation. Handler tests written with
+// forEachStore run against each of them, so the stores cannot drift apart.
+// Postgres is skipped unless DATABASE_URL is set.
+var todoStores = []struct {
+	name string
+	new  func(t *testing.T) TodoStore
+}{
+	{name: "memory", new: func(*testing.T) TodoStore { return newMemoryTodoStore() }},
+	{name: "sqlite", new: func(t *testing.T) TodoStore { return newTestSQLiteStore(t) }},
+	{name: "postgres", new: func(t *testing.T) TodoStore { return newTestPostgresStore(t) }},
+}
+
+func forEachStore(t *testing.T, test func(t *testing.T, store TodoStore)) {
+	for _, s := range todoStores {
+		t.Run(s.name, func(t *testing.T) {
+			test(t, s.new(t))
+		})
+	}
+}
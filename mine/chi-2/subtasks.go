@@ -0,0 +1,164 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+)
+
+var ErrSubtaskNotFound = errors.New("subtask not found")
+
+type Subtask struct {
+	ID        int64     `json:"id"`
+	TodoID    int64     `json:"todo_id"`
+	Title     string    `json:"title"`
+	Done      bool      `json:"done"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type SubtaskCounts struct {
+	Total int `json:"total"`
+	Done  int `json:"done"`
+}
+
+// SubtaskPatch holds the fields a PATCH changes; nil fields are left as is.
+type SubtaskPatch struct {
+	Title *string `json:"title"`
+	Done  *bool   `json:"done"`
+}
+
+type subtaskPayload struct {
+	Title string `json:"title"`
+	Done  bool   `json:"done"`
+}
+
+func validateSubtaskTitle(title string) ValidationErrors {
+	switch {
+	case title == "":
+		return ValidationErrors{{Field: "title", Message: "is required"}}
+	case utf8.RuneCountInString(title) > maxTitleLength:
+		return ValidationErrors{{Field: "title", Message: fmt.Sprintf("must be at most %d characters", maxTitleLength)}}
+	}
+
+	return nil
+}
+
+func (h *todoHandler) subtaskRoutes(r chi.Router) {
+	r.Method(http.MethodGet, "/", documented(routeDoc{Summary: "List the subtasks of a todo", Response: []Subtask{}}, h.listSubtasks))
+	r.Method(http.MethodPost, "/", documented(routeDoc{Summary: "Add a subtask", Request: subtaskPayload{}, Response: Subtask{}, Status: http.StatusCreated}, h.createSubtask))
+	r.Method(http.MethodPatch, "/{subtaskID}", documented(routeDoc{Summary: "Update a subtask", Request: SubtaskPatch{}, Response: Subtask{}}, h.updateSubtask))
+	r.Method(http.MethodDelete, "/{subtaskID}", documented(routeDoc{Summary: "Delete a subtask", Status: http.StatusNoContent}, h.deleteSubtask))
+}
+
+func (h *todoHandler) listSubtasks(w http.ResponseWriter, r *http.Request) {
+	todoID := TodoIDFromContext(r.Context())
+
+	subtasks, err := h.storeFor(r).ListSubtasks(r.Context(), todoID)
+	if err != nil {
+		subtaskStoreError(w, r, todoID, "list", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, subtasks)
+}
+
+func (h *todoHandler) createSubtask(w http.ResponseWriter, r *http.Request) {
+	todoID := TodoIDFromContext(r.Context())
+
+	var payload subtaskPayload
+	if err := decodeJSON(r, &payload); err != nil {
+		respondDecodeError(w, r, err)
+		return
+	}
+
+	subtask := &Subtask{TodoID: todoID, Title: strings.TrimSpace(payload.Title), Done: payload.Done}
+	if errs := validateSubtaskTitle(subtask.Title); errs != nil {
+		writeValidationErrors(w, r, errs)
+		return
+	}
+
+	if err := h.storeFor(r).CreateSubtask(r.Context(), subtask); err != nil {
+		subtaskStoreError(w, r, todoID, "create", err)
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/"+apiVersion+"/todo/%d/subtasks/%d", todoID, subtask.ID))
+	respondJSON(w, http.StatusCreated, subtask)
+}
+
+func (h *todoHandler) updateSubtask(w http.ResponseWriter, r *http.Request) {
+	todoID := TodoIDFromContext(r.Context())
+
+	id, ok := subtaskID(w, r)
+	if !ok {
+		return
+	}
+
+	var patch SubtaskPatch
+	if err := decodeJSON(r, &patch); err != nil {
+		respondDecodeError(w, r, err)
+		return
+	}
+
+	if patch.Title != nil {
+		title := strings.TrimSpace(*patch.Title)
+		if errs := validateSubtaskTitle(title); errs != nil {
+			writeValidationErrors(w, r, errs)
+			return
+		}
+		patch.Title = &title
+	}
+
+	subtask, err := h.storeFor(r).UpdateSubtask(r.Context(), todoID, id, patch)
+	if err != nil {
+		subtaskStoreError(w, r, todoID, "update", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, subtask)
+}
+
+func (h *todoHandler) deleteSubtask(w http.ResponseWriter, r *http.Request) {
+	todoID := TodoIDFromContext(r.Context())
+
+	id, ok := subtaskID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.storeFor(r).DeleteSubtask(r.Context(), todoID, id); err != nil {
+		subtaskStoreError(w, r, todoID, "delete", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func subtaskID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "subtaskID"), 10, 64)
+	if err != nil || id < 1 {
+		respondError(w, r, http.StatusBadRequest, codeBadRequest, "subtask id must be a positive integer")
+		return 0, false
+	}
+
+	return id, true
+}
+
+// subtaskStoreError names the missing parent so a 404 on a subtask route says
+// whether the todo or the subtask is gone.
+func subtaskStoreError(w http.ResponseWriter, r *http.Request, todoID int64, action string, err error) {
+	switch {
+	case errors.Is(err, ErrTodoNotFound):
+		respondError(w, r, http.StatusNotFound, codeNotFound, fmt.Sprintf("todo %d not found", todoID))
+	case errors.Is(err, ErrSubtaskNotFound):
+		respondError(w, r, http.StatusNotFound, codeNotFound, "subtask not found")
+	default:
+		storeError(w, r, action+" subtask of", err)
+	}
+}
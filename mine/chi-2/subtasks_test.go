@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSubtasks(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store TodoStore) {
+		r := newTodoRouter(t, store)
+
+		do := func(method, target, body string, out any) int {
+			t.Helper()
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, newJSONRequest(method, target, body))
+
+			if out != nil {
+				if err := json.NewDecoder(rr.Body).Decode(out); err != nil {
+					t.Fatalf("%s %s: %v", method, target, err)
+				}
+			}
+
+			return rr.Code
+		}
+
+		counts := func(todoID string) SubtaskCounts {
+			t.Helper()
+
+			var todo Todo
+			if status := do(http.MethodGet, "/todo/"+todoID, "", &todo); status != http.StatusOK {
+				t.Fatalf("expected status %d, got %d", http.StatusOK, status)
+			}
+			return todo.SubtaskCounts
+		}
+
+		do(http.MethodPost, "/todo", `{"title":"move house"}`, nil)
+		do(http.MethodPost, "/todo", `{"title":"other"}`, nil)
+
+		for _, title := range []string{"pack", "hire van", "clean"} {
+			var subtask Subtask
+			if status := do(http.MethodPost, "/todo/1/subtasks", `{"title":"`+title+`"}`, &subtask); status != http.StatusCreated {
+				t.Fatalf("expected status %d, got %d", http.StatusCreated, status)
+			}
+			if subtask.TodoID != 1 || subtask.Title != title {
+				t.Errorf("unexpected subtask %+v", subtask)
+			}
+		}
+		do(http.MethodPost, "/todo/2/subtasks", `{"title":"unrelated"}`, nil)
+
+		if got := counts("1"); got != (SubtaskCounts{Total: 3}) {
+			t.Errorf("expected 3 open subtasks, got %+v", got)
+		}
+
+		var updated Subtask
+		if status := do(http.MethodPatch, "/todo/1/subtasks/1", `{"done":true}`, &updated); status != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, status)
+		}
+		if !updated.Done || updated.Title != "pack" {
+			t.Errorf("toggling done changed other fields: %+v", updated)
+		}
+
+		do(http.MethodPatch, "/todo/1/subtasks/2", `{"title":"hire a bigger van"}`, &updated)
+		if updated.Title != "hire a bigger van" || updated.Done {
+			t.Errorf("renaming changed other fields: %+v", updated)
+		}
+
+		if got := counts("1"); got != (SubtaskCounts{Total: 3, Done: 1}) {
+			t.Errorf("expected 1 of 3 done, got %+v", got)
+		}
+
+		if status := do(http.MethodDelete, "/todo/1/subtasks/3", "", nil); status != http.StatusNoContent {
+			t.Fatalf("expected status %d, got %d", http.StatusNoContent, status)
+		}
+		if got := counts("1"); got != (SubtaskCounts{Total: 2, Done: 1}) {
+			t.Errorf("expected 1 of 2 done, got %+v", got)
+		}
+
+		// a subtask is only reachable through its own todo
+		if status := do(http.MethodPatch, "/todo/2/subtasks/1", `{"done":false}`, nil); status != http.StatusNotFound {
+			t.Errorf("expected status %d, got %d", http.StatusNotFound, status)
+		}
+
+		var subtasks []Subtask
+		do(http.MethodGet, "/todo/1/subtasks", "", &subtasks)
+		if len(subtasks) != 2 {
+			t.Errorf("expected 2 subtasks, got %+v", subtasks)
+		}
+
+		// deleting the todo takes its subtasks with it
+		do(http.MethodDelete, "/todo/1", "", nil)
+
+		var errBody map[string]string
+		if status := do(http.MethodGet, "/todo/1/subtasks", "", &errBody); status != http.StatusNotFound {
+			t.Fatalf("expected status %d, got %d", http.StatusNotFound, status)
+		}
+		if errBody["error"] != "todo 1 not found" {
+			t.Errorf("expected the error to name the parent, got %q", errBody["error"])
+		}
+
+		for _, tt := range []struct{ method, target, body string }{
+			{http.MethodPost, "/todo/1/subtasks", `{"title":"late"}`},
+			{http.MethodPatch, "/todo/1/subtasks/1", `{"done":true}`},
+			{http.MethodDelete, "/todo/1/subtasks/1", ""},
+		} {
+			if status := do(tt.method, tt.target, tt.body, nil); status != http.StatusNotFound {
+				t.Errorf("%s %s: expected status %d, got %d", tt.method, tt.target, http.StatusNotFound, status)
+			}
+		}
+
+		if got := counts("2"); got != (SubtaskCounts{Total: 1}) {
+			t.Errorf("other todo lost its subtasks: %+v", got)
+		}
+	})
+}
+
+func TestSubtaskValidation(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store TodoStore) {
+		r := newTodoRouter(t, store)
+		r.ServeHTTP(httptest.NewRecorder(), newJSONRequest(http.MethodPost, "/todo", `{"title":"a"}`))
+
+		tests := []struct {
+			method, target, body string
+			status               int
+		}{
+			{http.MethodPost, "/todo/1/subtasks", `{"title":" "}`, http.StatusUnprocessableEntity},
+			{http.MethodPost, "/todo/1/subtasks", `{`, http.StatusBadRequest},
+			{http.MethodPatch, "/todo/1/subtasks/abc", `{}`, http.StatusBadRequest},
+			{http.MethodPatch, "/todo/1/subtasks/1", `{"title":""}`, http.StatusUnprocessableEntity},
+			{http.MethodPatch, "/todo/1/subtasks/1", `{"done":true}`, http.StatusNotFound},
+		}
+
+		for _, tt := range tests {
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, newJSONRequest(tt.method, tt.target, tt.body))
+
+			if rr.Code != tt.status {
+				t.Errorf("%s %s %s: expected status %d, got %d", tt.method, tt.target, tt.body, tt.status, rr.Code)
+			}
+		}
+	})
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+const (
+	maxTagLength   = 30
+	maxTagsPerTodo = 10
+)
+
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+// normalizeTags lowercases and trims every tag and drops duplicates, keeping
+// the first occurrence order. Length limits are left to Todo.Validate.
+func normalizeTags(tags []string) []string {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	seen := make(map[string]bool, len(tags))
+	normalized := make([]string, 0, len(tags))
+
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if seen[tag] {
+			continue
+		}
+
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+
+	return normalized
+}
+
+// sortTagCounts orders the most used tags first, then alphabetically.
+func sortTagCounts(counts []TagCount) {
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		return counts[i].Tag < counts[j].Tag
+	})
+}
+
+func (h *todoHandler) listTags(w http.ResponseWriter, r *http.Request) {
+	counts, err := h.storeFor(r).TagCounts(r.Context())
+	if err != nil {
+		storeError(w, r, "count tags of", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, counts)
+}
+
+// tagsFromQuery reads ?tag=, which may be repeated or comma separated.
+func tagsFromQuery(q url.Values) []string {
+	var tags []string
+	for _, value := range q["tag"] {
+		for _, tag := range strings.Split(value, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				tags = append(tags, tag)
+			}
+		}
+	}
+
+	return normalizeTags(tags)
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTodoTags(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store TodoStore) {
+		r := newTodoRouter(t, store)
+
+		do := func(method, target, body string) *httptest.ResponseRecorder {
+			t.Helper()
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, newJSONRequest(method, target, body))
+
+			return rr
+		}
+
+		counts := func() []TagCount {
+			t.Helper()
+
+			rr := do(http.MethodGet, "/todo/tags", "")
+			if rr.Code != http.StatusOK {
+				t.Fatalf("expected status %d, got %d", http.StatusOK, rr.Code)
+			}
+
+			var counts []TagCount
+			if err := json.NewDecoder(rr.Body).Decode(&counts); err != nil {
+				t.Fatal(err)
+			}
+			return counts
+		}
+
+		assertCounts := func(want []TagCount) {
+			t.Helper()
+
+			got := counts()
+			if len(got) != len(want) {
+				t.Fatalf("expected counts %+v, got %+v", want, got)
+			}
+			for i := range want {
+				if got[i] != want[i] {
+					t.Fatalf("expected counts %+v, got %+v", want, got)
+				}
+			}
+		}
+
+		assertCounts([]TagCount{})
+
+		rr := do(http.MethodPost, "/todo", `{"title":"a","tags":["Work"," work ","urgent"]}`)
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("expected status %d, got %d", http.StatusCreated, rr.Code)
+		}
+
+		var created Todo
+		if err := json.NewDecoder(rr.Body).Decode(&created); err != nil {
+			t.Fatal(err)
+		}
+		if strings.Join(created.Tags, ",") != "work,urgent" {
+			t.Errorf("expected normalized tags [work urgent], got %v", created.Tags)
+		}
+
+		do(http.MethodPost, "/todo", `{"title":"b","tags":["work","home"]}`)
+		do(http.MethodPost, "/todo", `{"title":"c"}`)
+
+		assertCounts([]TagCount{{Tag: "work", Count: 2}, {Tag: "home", Count: 1}, {Tag: "urgent", Count: 1}})
+
+		// updating swaps the tags of the todo in the index
+		do(http.MethodPut, "/todo/1", `{"title":"a","tags":["home"],"version":1}`)
+		assertCounts([]TagCount{{Tag: "home", Count: 2}, {Tag: "work", Count: 1}})
+
+		// completing keeps the tags untouched
+		do(http.MethodPatch, "/todo/2/complete", `{"version":1}`)
+		assertCounts([]TagCount{{Tag: "home", Count: 2}, {Tag: "work", Count: 1}})
+
+		do(http.MethodDelete, "/todo/2", "")
+		assertCounts([]TagCount{{Tag: "home", Count: 1}})
+
+		do(http.MethodPut, "/todo/1", `{"title":"a","version":2}`)
+		assertCounts([]TagCount{})
+	})
+}
+
+func TestListTodosTagFilter(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store TodoStore) {
+		r := newTodoRouter(t, store)
+
+		for _, body := range []string{
+			`{"title":"a","tags":["work"]}`,
+			`{"title":"b","tags":["home"]}`,
+			`{"title":"c","tags":["work","home"]}`,
+			`{"title":"d"}`,
+		} {
+			r.ServeHTTP(httptest.NewRecorder(), newJSONRequest(http.MethodPost, "/todo", body))
+		}
+
+		tests := []struct {
+			query string
+			want  string
+		}{
+			{query: "tag=work", want: "ac"},
+			{query: "tag=WORK", want: "ac"},
+			{query: "tag=home&tag=work", want: "abc"},
+			{query: "tag=home,missing", want: "bc"},
+			{query: "tag=missing", want: ""},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.query, func(t *testing.T) {
+				rr := httptest.NewRecorder()
+				r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/todo?"+tt.query, nil))
+
+				var todos []Todo
+				if err := json.NewDecoder(rr.Body).Decode(&todos); err != nil {
+					t.Fatal(err)
+				}
+
+				var got string
+				for _, todo := range todos {
+					got += todo.Title
+				}
+
+				if got != tt.want {
+					t.Errorf("expected %q, got %q", tt.want, got)
+				}
+			})
+		}
+	})
+}
+
+func TestTodoTagsValidation(t *testing.T) {
+	r := newTodoRouter(t, newMemoryTodoStore())
+
+	tests := []struct {
+		name string
+		tags string
+	}{
+		{name: "empty tag", tags: `["work",""]`},
+		{name: "blank tag", tags: `["   "]`},
+		{name: "tag too long", tags: `["` + strings.Repeat("x", maxTagLength+1) + `"]`},
+		{name: "too many tags", tags: `["a","b","c","d","e","f","g","h","i","j","k"]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, newJSONRequest(http.MethodPost, "/todo", `{"title":"a","tags":`+tt.tags+`}`))
+
+			if rr.Code != http.StatusUnprocessableEntity {
+				t.Fatalf("expected status %d, got %d", http.StatusUnprocessableEntity, rr.Code)
+			}
+
+			if !strings.Contains(rr.Body.String(), `"field":"tags"`) {
+				t.Errorf("expected a tags field error, got %s", rr.Body.String())
+			}
+		})
+	}
+
+	// duplicates collapse before the limit is checked
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, newJSONRequest(http.MethodPost, "/todo", `{"title":"a","tags":["a","a","a","a","a","a","a","a","a","a","a"]}`))
+
+	if rr.Code != http.StatusCreated {
+		t.Errorf("expected status %d, got %d", http.StatusCreated, rr.Code)
+	}
+}
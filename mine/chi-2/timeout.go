@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const defaultRequestTimeout = 10 * time.Second
+
+// timeoutMiddleware gives each request a deadline of timeout. A handler that
+// hasn't finished by then is answered with a 503 JSON error, and the store
+// calls it makes see the expired context and stop.
+//
+// It works like http.TimeoutHandler: the handler writes into a buffer that is
+// only copied to w once it returns, so the 503 and a handler finishing at the
+// same moment can't both end up in the response. Anything the handler writes
+// after the deadline fails with http.ErrHandlerTimeout. That buffering rules
+// out streams like /todo/events, which are mounted without it.
+func timeoutMiddleware(timeout time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+			r = r.WithContext(ctx)
+
+			tw := &timeoutWriter{header: make(http.Header)}
+			done := make(chan struct{})
+			panicked := make(chan any, 1)
+
+			go func() {
+				defer func() {
+					if p := recover(); p != nil {
+						panicked <- p
+					}
+				}()
+
+				next.ServeHTTP(tw, r)
+				close(done)
+			}()
+
+			select {
+			case p := <-panicked:
+				// re-raised on the serving goroutine, where net/http's
+				// per-connection recover logs it and drops the connection;
+				// left on the handler goroutine it would crash the process
+				panic(p)
+			case <-done:
+				tw.mu.Lock()
+				defer tw.mu.Unlock()
+
+				for key, values := range tw.header {
+					w.Header()[key] = values
+				}
+				if !tw.wroteHeader {
+					tw.status = http.StatusOK
+				}
+				w.WriteHeader(tw.status)
+				w.Write(tw.body.Bytes())
+			case <-ctx.Done():
+				tw.mu.Lock()
+				defer tw.mu.Unlock()
+
+				tw.timedOut = true
+				if ctx.Err() == context.DeadlineExceeded {
+					respondError(w, r, http.StatusServiceUnavailable, codeTimeout, "the request took too long, try again later")
+				}
+			}
+		})
+	}
+}
+
+// timeoutWriter holds a handler's response until timeoutMiddleware knows
+// whether it made the deadline.
+type timeoutWriter struct {
+	mu          sync.Mutex
+	header      http.Header
+	body        bytes.Buffer
+	status      int
+	wroteHeader bool
+	timedOut    bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) Write(p []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if !tw.wroteHeader {
+		tw.writeHeaderLocked(http.StatusOK)
+	}
+
+	return tw.body.Write(p)
+}
+
+func (tw *timeoutWriter) WriteHeader(status int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.timedOut {
+		return
+	}
+	tw.writeHeaderLocked(status)
+}
+
+func (tw *timeoutWriter) writeHeaderLocked(status int) {
+	if tw.wroteHeader {
+		return
+	}
+
+	tw.wroteHeader = true
+	tw.status = status
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// slowStore makes List take until the request gives up on it.
+type slowStore struct {
+	TodoStore
+	stopped chan error
+}
+
+func (s *slowStore) ForOwner(owner string) TodoStore {
+	return &slowStore{TodoStore: s.TodoStore.ForOwner(owner), stopped: s.stopped}
+}
+
+func (s *slowStore) List(ctx context.Context, q ListQuery) ([]Todo, int, error) {
+	<-ctx.Done()
+	s.stopped <- ctx.Err()
+
+	return nil, 0, ctx.Err()
+}
+
+func TestRequestTimeout(t *testing.T) {
+	store := &slowStore{TodoStore: newMemoryTodoStore(), stopped: make(chan error, 1)}
+	r := chi.NewRouter()
+	r.Mount("/todo", (&todoHandler{store: store, timeout: 20 * time.Millisecond}).routes())
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/todo", nil))
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusServiceUnavailable, rr.Code, rr.Body)
+	}
+
+	var body errorResponse
+	if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Code != codeTimeout {
+		t.Errorf("expected code %q, got %q", codeTimeout, body.Code)
+	}
+
+	select {
+	case err := <-store.stopped:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected the store to see the deadline, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the slow List to stop")
+	}
+
+	// the timeout is per request, the next ones go through
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, newJSONRequest(http.MethodPost, "/todo", `{"title":"buy milk"}`))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body)
+	}
+
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/todo/1", nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+	}
+}
+
+// TestTimeoutRace finishes the handler right around the deadline, every
+// response must be either all of the handler's or all of the 503.
+func TestTimeoutRace(t *testing.T) {
+	const timeout = time.Millisecond
+
+	handler := timeoutMiddleware(timeout)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(timeout)
+		w.Header().Set("X-Handler", "done")
+		respondJSON(w, http.StatusOK, map[string]string{"status": "done"})
+	}))
+
+	for range 200 {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		var body map[string]string
+		if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+			t.Fatalf("expected a single JSON body, got %v", err)
+		}
+		if rr.Body.Len() != 0 {
+			t.Fatalf("expected nothing after the JSON body, got %q", rr.Body)
+		}
+
+		switch rr.Code {
+		case http.StatusOK:
+			if body["status"] != "done" || rr.Header().Get("X-Handler") != "done" {
+				t.Fatalf("expected the handler's response, got %v", body)
+			}
+		case http.StatusServiceUnavailable:
+			if body["code"] != codeTimeout || rr.Header().Get("X-Handler") != "" {
+				t.Fatalf("expected only the timeout response, got %v", body)
+			}
+		default:
+			t.Fatalf("unexpected status %d", rr.Code)
+		}
+	}
+}
+
+func TestStoreHonoursContext(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store TodoStore) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		if err := store.Create(ctx, &Todo{Title: "too late"}); !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled, got %v", err)
+		}
+
+		_, total, err := store.List(context.Background(), ListQuery{Limit: pageSizes.Default})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if total != 0 {
+			t.Errorf("expected nothing written, got %d todos", total)
+		}
+	})
+}
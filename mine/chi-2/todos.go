@@ -0,0 +1,418 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type todoHandler struct {
+	store TodoStore
+	// events feeds GET /todo/events; store must be a notifyingStore
+	// publishing to it for the stream to see any changes.
+	events *todoEvents
+	// now is the clock used for time dependent queries; nil means time.Now.
+	now func() time.Time
+	// location is where ?due=today starts and ends; nil means UTC.
+	location *time.Location
+	// trashRetention is how long DELETE /todo/trash keeps trashed todos; 0
+	// means defaultTrashRetention.
+	trashRetention time.Duration
+	// timeout bounds every request but the streams; 0 means
+	// defaultRequestTimeout.
+	timeout time.Duration
+}
+
+func (h *todoHandler) clock() time.Time {
+	if h.now != nil {
+		return h.now()
+	}
+
+	return time.Now()
+}
+
+func (h *todoHandler) timezone() *time.Location {
+	if h.location != nil {
+		return h.location
+	}
+
+	return time.UTC
+}
+
+func (h *todoHandler) requestTimeout() time.Duration {
+	if h.timeout > 0 {
+		return h.timeout
+	}
+
+	return defaultRequestTimeout
+}
+
+// routes returns the /todo route tree, ready to be mounted.
+func (h *todoHandler) routes() chi.Router {
+	r := chi.NewRouter()
+	r.Use(scopeMiddleware)
+
+	// the streams stay open for as long as the client listens
+	r.Method(http.MethodGet, "/events", documented(routeDoc{Summary: "Stream todo changes as server-sent events", ContentType: "text/event-stream"}, h.streamEvents))
+	r.Method(http.MethodGet, "/ws", documented(routeDoc{Summary: "Stream todo changes over a WebSocket", Status: http.StatusSwitchingProtocols}, h.todoSocket))
+
+	r.Group(func(r chi.Router) {
+		r.Use(timeoutMiddleware(h.requestTimeout()))
+
+		r.Method(http.MethodGet, "/", documented(routeDoc{Summary: "List todos", Response: []Todo{}}, h.listTodos))
+		r.Method(http.MethodPost, "/", documented(routeDoc{Summary: "Create a todo", Request: todoPayload{}, Response: Todo{}, Status: http.StatusCreated}, h.createTodo))
+		r.Method(http.MethodGet, "/tags", documented(routeDoc{Summary: "Count todos by tag", Response: []TagCount{}}, h.listTags))
+		r.Method(http.MethodGet, "/stats", documented(routeDoc{Summary: "Count todos by state", Response: TodoStats{}}, h.todoStats))
+		r.Method(http.MethodGet, "/search", documented(routeDoc{Summary: "Search todos", Response: []Todo{}}, h.searchTodos))
+		r.Method(http.MethodGet, "/trash", documented(routeDoc{Summary: "List deleted todos", Response: []Todo{}}, h.listTrash))
+		r.Method(http.MethodDelete, "/trash", documented(routeDoc{Summary: "Purge the trash", Response: purgeResult{}}, h.purgeTrash))
+		r.Method(http.MethodGet, "/export.ics", documented(routeDoc{Summary: "Export todos as iCalendar", ContentType: "text/calendar"}, h.exportICal))
+		r.Method(http.MethodPost, "/bulk/complete", documented(routeDoc{Summary: "Complete several todos", Request: bulkPayload{}, Response: bulkResult{}}, h.bulkComplete))
+		r.Method(http.MethodPost, "/complete", documented(routeDoc{Summary: "Complete several todos", Request: bulkPayload{}, Response: bulkResult{}}, h.bulkComplete))
+		r.Method(http.MethodPost, "/import", documented(routeDoc{Summary: "Import todos", Request: []todoPayload{}, Response: importReport{}}, h.importTodos))
+
+		r.Method(http.MethodPut, "/{clientID:"+clientIDPattern+"}", documented(routeDoc{Summary: "Create or replace a todo under a client chosen UUID", Request: todoPayload{}, Response: Todo{}}, h.putTodo))
+
+		r.Route("/{todoID}", func(r chi.Router) {
+			r.Use(todoIDMiddleware)
+
+			r.Method(http.MethodGet, "/", documented(routeDoc{Summary: "Get a todo", Response: Todo{}}, h.getTodo))
+			r.Method(http.MethodPut, "/", documented(routeDoc{Summary: "Replace a todo", Request: todoPayload{}, Response: Todo{}}, h.updateTodo))
+			r.Method(http.MethodDelete, "/", documented(routeDoc{Summary: "Delete a todo", Status: http.StatusNoContent}, h.deleteTodo))
+			r.Method(http.MethodPatch, "/complete", documented(routeDoc{Summary: "Mark a todo done", Response: Todo{}}, h.completeTodo))
+			r.Method(http.MethodPatch, "/uncomplete", documented(routeDoc{Summary: "Reopen a todo", Response: Todo{}}, h.uncompleteTodo))
+			r.Method(http.MethodPost, "/archive", documented(routeDoc{Summary: "Archive a todo", Response: Todo{}}, h.archiveTodo))
+			r.Method(http.MethodPost, "/unarchive", documented(routeDoc{Summary: "Unarchive a todo", Response: Todo{}}, h.unarchiveTodo))
+			r.Method(http.MethodPost, "/restore", documented(routeDoc{Summary: "Restore a deleted todo", Response: Todo{}}, h.restoreTodo))
+			r.Route("/subtasks", h.subtaskRoutes)
+			r.Route("/comments", h.commentRoutes)
+		})
+	})
+
+	return r
+}
+
+func (h *todoHandler) listTodos(w http.ResponseWriter, r *http.Request) {
+	query, err := ParseListQuery(r.URL.Query())
+	if err != nil {
+		respondParamErrors(w, r, err)
+		return
+	}
+	query.Now = h.clock().UTC()
+	query.expandDue(query.Now, h.timezone())
+
+	// under /lists/{listID}/todos the path picks the list
+	if listID := ListIDFromContext(r.Context()); listID != 0 {
+		if _, err := h.storeFor(r).GetList(r.Context(), listID); err != nil {
+			listStoreError(w, r, "get", err)
+			return
+		}
+		query.ListID = listID
+	}
+
+	todos, total, err := h.storeFor(r).List(r.Context(), query)
+	if err != nil {
+		logError(r, "failed to list todos: %v", err)
+		respondError(w, r, http.StatusInternalServerError, codeInternal, "the server encountered a problem")
+		return
+	}
+
+	query.page.setHeaders(w, r.URL, total)
+
+	respondJSON(w, http.StatusOK, todos)
+}
+
+type todoPayload struct {
+	Title    string    `json:"title"`
+	Done     bool      `json:"done"`
+	Priority *Priority `json:"priority"`
+	Tags     []string  `json:"tags"`
+	// DueDate is kept as a string so a malformed timestamp can be reported
+	// as a field error rather than as an undecodable body.
+	DueDate *string `json:"due_date"`
+	// Recurrence is a string for the same reason, see ParseRecurrence.
+	Recurrence *string `json:"recurrence"`
+	// Version is the version a PUT was based on, see expectedVersion.
+	// Creating a todo ignores it.
+	Version int `json:"version"`
+}
+
+// decodeTodo reads a todoPayload from the request body, writing a 4xx for a
+// body decodeJSON rejects and a 422 for invalid fields. It returns false when a
+// response has already been written.
+func decodeTodo(w http.ResponseWriter, r *http.Request) (*Todo, bool) {
+	var payload todoPayload
+	if err := decodeJSON(r, &payload); err != nil {
+		var priorityErr *ErrInvalidPriority
+		if errors.As(err, &priorityErr) {
+			writeValidationErrors(w, r, ValidationErrors{{Field: "priority", Message: "must be one of low, medium, high"}})
+			return nil, false
+		}
+
+		respondDecodeError(w, r, err)
+		return nil, false
+	}
+
+	todo, errs := payload.todo()
+	if errs != nil {
+		writeValidationErrors(w, r, errs)
+		return nil, false
+	}
+
+	return todo, true
+}
+
+// todo turns the payload into a todo and validates it. A due date or
+// recurrence that can't be parsed is reported on its own, before the other
+// rules run.
+func (p *todoPayload) todo() (*Todo, ValidationErrors) {
+	todo := &Todo{
+		Title:    strings.TrimSpace(p.Title),
+		Done:     p.Done,
+		Priority: defaultPriority,
+		Tags:     normalizeTags(p.Tags),
+		Version:  p.Version,
+	}
+	if p.Priority != nil {
+		todo.Priority = *p.Priority
+	}
+
+	if p.DueDate != nil {
+		dueDate, err := time.Parse(time.RFC3339, *p.DueDate)
+		if err != nil {
+			return nil, ValidationErrors{{Field: "due_date", Message: "must be an RFC 3339 timestamp"}}
+		}
+
+		todo.DueDate = utcTime(&dueDate)
+	}
+
+	if p.Recurrence != nil && *p.Recurrence != "" {
+		rule, err := ParseRecurrence(*p.Recurrence)
+		var ruleErr *ErrInvalidRecurrence
+		if errors.As(err, &ruleErr) {
+			return nil, ValidationErrors{{Field: "recurrence", Message: ruleErr.Reason}}
+		}
+
+		todo.Recurrence = &rule
+	}
+
+	if err := todo.Validate(); err != nil {
+		errs, ok := err.(ValidationErrors)
+		if !ok {
+			errs = ValidationErrors{{Message: err.Error()}}
+		}
+		return nil, errs
+	}
+
+	return todo, nil
+}
+
+func (h *todoHandler) createTodo(w http.ResponseWriter, r *http.Request) {
+	todo, ok := decodeTodo(w, r)
+	if !ok {
+		return
+	}
+
+	todo.ListID = ListIDFromContext(r.Context())
+
+	if err := h.storeFor(r).Create(r.Context(), todo); err != nil {
+		if errors.Is(err, ErrListNotFound) {
+			listStoreError(w, r, "create todo in", err)
+			return
+		}
+		logError(r, "failed to create todo: %v", err)
+		respondError(w, r, http.StatusInternalServerError, codeInternal, "the server encountered a problem")
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/"+apiVersion+"/todo/%d", todo.ID))
+	respondTodo(w, http.StatusCreated, *todo)
+}
+
+func (h *todoHandler) getTodo(w http.ResponseWriter, r *http.Request) {
+	id := TodoIDFromContext(r.Context())
+
+	todo, err := h.storeFor(r).Get(r.Context(), id)
+	if err != nil {
+		storeError(w, r, "get", err)
+		return
+	}
+
+	respondTodo(w, http.StatusOK, todo)
+}
+
+// updateTodo replaces the todo with the request body; omitted fields are reset
+// to their zero value.
+func (h *todoHandler) updateTodo(w http.ResponseWriter, r *http.Request) {
+	id := TodoIDFromContext(r.Context())
+
+	todo, ok := decodeTodo(w, r)
+	if !ok {
+		return
+	}
+	todo.ID = id
+
+	var bodyVersion *int
+	if todo.Version != 0 {
+		bodyVersion = &todo.Version
+	}
+	version, ok := expectedVersion(w, r, bodyVersion)
+	if !ok {
+		return
+	}
+	todo.Version = version
+
+	if err := h.storeFor(r).Update(r.Context(), todo); err != nil {
+		storeError(w, r, "update", err)
+		return
+	}
+
+	respondTodo(w, http.StatusOK, *todo)
+}
+
+// putTodo creates or replaces the todo a client named with its own UUID, so a
+// client that lost the response can send the same request again. Unlike the
+// PUT by id it needs no version, the last write wins.
+func (h *todoHandler) putTodo(w http.ResponseWriter, r *http.Request) {
+	todo, ok := decodeTodo(w, r)
+	if !ok {
+		return
+	}
+	todo.ClientID = strings.ToLower(chi.URLParam(r, "clientID"))
+
+	result, err := h.storeFor(r).PutByClientID(r.Context(), todo)
+	if err != nil {
+		storeError(w, r, "put", err)
+		return
+	}
+
+	if result != PutCreated {
+		respondTodo(w, http.StatusOK, *todo)
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/"+apiVersion+"/todo/%d", todo.ID))
+	respondTodo(w, http.StatusCreated, *todo)
+}
+
+func (h *todoHandler) deleteTodo(w http.ResponseWriter, r *http.Request) {
+	id := TodoIDFromContext(r.Context())
+
+	if err := h.storeFor(r).Delete(r.Context(), id); err != nil {
+		storeError(w, r, "delete", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// completeTodo and uncompleteTodo are idempotent, repeating them leaves
+// CompletedAt untouched. Like PUT they need the version the change is based
+// on, in If-Match or as {"version": 3} in an optional body.
+func (h *todoHandler) completeTodo(w http.ResponseWriter, r *http.Request) {
+	h.setDone(w, r, true)
+}
+
+func (h *todoHandler) uncompleteTodo(w http.ResponseWriter, r *http.Request) {
+	h.setDone(w, r, false)
+}
+
+func (h *todoHandler) setDone(w http.ResponseWriter, r *http.Request, done bool) {
+	id := TodoIDFromContext(r.Context())
+
+	var payload versionPayload
+	if r.ContentLength != 0 {
+		if err := decodeJSON(r, &payload); err != nil {
+			respondDecodeError(w, r, err)
+			return
+		}
+	}
+
+	version, ok := expectedVersion(w, r, payload.Version)
+	if !ok {
+		return
+	}
+
+	todo, err := h.storeFor(r).SetDone(r.Context(), id, done, version)
+	if err != nil {
+		storeError(w, r, "update", err)
+		return
+	}
+
+	respondTodo(w, http.StatusOK, todo)
+}
+
+const todoIDCtx contextKey = "todoID"
+
+// clientIDPattern matches the UUIDs of PUT /todo/{clientID}, in either case.
+// Numeric ids never match, so PUT /todo/{todoID} still gets those.
+const clientIDPattern = `[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`
+
+// todoIDMiddleware parses the {todoID} URL parameter once for every route
+// below it and stores it in the request context, rejecting anything that is
+// not a positive integer before the handler runs.
+func todoIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "todoID"), 10, 64)
+		if err != nil || id < 1 {
+			respondError(w, r, http.StatusBadRequest, codeBadRequest, "todo id must be a positive integer")
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), todoIDCtx, id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// TodoIDFromContext returns the id todoIDMiddleware parsed, or 0 outside of
+// the /{todoID} routes.
+func TodoIDFromContext(ctx context.Context) int64 {
+	id, _ := ctx.Value(todoIDCtx).(int64)
+	return id
+}
+
+// storeError writes 404 for ErrTodoNotFound, 409 for ErrTodoArchived and
+// *ErrVersionConflict, and logs anything else as a 500.
+func storeError(w http.ResponseWriter, r *http.Request, action string, err error) {
+	var conflict *ErrVersionConflict
+
+	switch {
+	case errors.Is(err, ErrTodoNotFound):
+		respondError(w, r, http.StatusNotFound, codeNotFound, "todo not found")
+		return
+	case errors.Is(err, ErrTodoArchived):
+		respondError(w, r, http.StatusConflict, codeTodoArchived, "todo is archived, unarchive it before updating")
+		return
+	case errors.As(err, &conflict):
+		respondVersionConflict(w, r, conflict.Current)
+		return
+	}
+
+	logError(r, "failed to %s todo: %v", action, err)
+	respondError(w, r, http.StatusInternalServerError, codeInternal, "the server encountered a problem")
+}
+
+func utcTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+
+	utc := t.UTC()
+
+	return &utc
+}
+
+func queryBool(r *http.Request, key string) (bool, error) {
+	value := r.URL.Query().Get(key)
+	if value == "" {
+		return false, nil
+	}
+
+	return strconv.ParseBool(value)
+}
@@ -0,0 +1,403 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestListTodosPagination(t *testing.T) {
+	h := &todoHandler{store: newMemoryTodoStore()}
+
+	for i := 0; i < 50; i++ {
+		if err := h.store.Create(context.Background(), &Todo{Title: fmt.Sprintf("todo %d", i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("default page", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		h.listTodos(rr, httptest.NewRequest(http.MethodGet, "/todo", nil))
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		var todos []Todo
+		if err := json.NewDecoder(rr.Body).Decode(&todos); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(todos) != pageSizes.Default {
+			t.Errorf("expected %d todos, got %d", pageSizes.Default, len(todos))
+		}
+
+		if got := rr.Header().Get("X-Total-Count"); got != "50" {
+			t.Errorf("expected X-Total-Count 50, got %q", got)
+		}
+
+		link := rr.Header().Get("Link")
+		if !strings.Contains(link, `</todo?page=2&per_page=20>; rel="next"`) {
+			t.Errorf("expected next link, got %q", link)
+		}
+		if strings.Contains(link, `rel="prev"`) {
+			t.Errorf("expected no prev link on first page, got %q", link)
+		}
+	})
+
+	t.Run("limit is capped", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		h.listTodos(rr, httptest.NewRequest(http.MethodGet, "/todo?limit=500&offset=10", nil))
+
+		var todos []Todo
+		if err := json.NewDecoder(rr.Body).Decode(&todos); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(todos) != 40 {
+			t.Errorf("expected 40 todos, got %d", len(todos))
+		}
+
+		want := `</todo?limit=100&offset=0>; rel="first", </todo?limit=100&offset=0>; rel="prev", </todo?limit=100&offset=0>; rel="last"`
+		if link := rr.Header().Get("Link"); link != want {
+			t.Errorf("unexpected Link header %q", link)
+		}
+	})
+
+	t.Run("invalid limit", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		h.listTodos(rr, httptest.NewRequest(http.MethodGet, "/todo?limit=abc", nil))
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+}
+
+func TestListTodosOverdue(t *testing.T) {
+	h := &todoHandler{store: newMemoryTodoStore()}
+	ctx := context.Background()
+
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+
+	for _, todo := range []*Todo{
+		{Title: "overdue", DueDate: &past},
+		{Title: "future", DueDate: &future},
+		{Title: "done overdue", Done: true, DueDate: &past},
+		{Title: "no due date"},
+	} {
+		if err := h.store.Create(ctx, todo); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	h.listTodos(rr, httptest.NewRequest(http.MethodGet, "/todo?overdue=true", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	var todos []Todo
+	if err := json.NewDecoder(rr.Body).Decode(&todos); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(todos) != 1 || todos[0].Title != "overdue" {
+		t.Errorf("expected only the overdue todo, got %+v", todos)
+	}
+
+	if got := rr.Header().Get("X-Total-Count"); got != "1" {
+		t.Errorf("expected X-Total-Count 1, got %q", got)
+	}
+}
+
+func TestListTodosOverdueBoundary(t *testing.T) {
+	now := time.Date(2030, 6, 1, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	store := newMemoryTodoStore()
+	store.now = clock
+	h := &todoHandler{store: store, now: clock}
+
+	before := now.Add(-time.Second)
+	after := now.Add(time.Second)
+
+	for _, todo := range []*Todo{
+		{Title: "a second ago", DueDate: &before},
+		{Title: "exactly now", DueDate: &now},
+		{Title: "in a second", DueDate: &after},
+		{Title: "no due date"},
+	} {
+		if err := store.Create(context.Background(), todo); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	h.listTodos(rr, httptest.NewRequest(http.MethodGet, "/todo?overdue=true", nil))
+
+	var todos []Todo
+	if err := json.NewDecoder(rr.Body).Decode(&todos); err != nil {
+		t.Fatal(err)
+	}
+
+	// a todo due exactly now is not overdue yet, and one without a due date
+	// never is
+	if len(todos) != 1 || todos[0].Title != "a second ago" {
+		t.Errorf("expected only the todo due a second ago, got %+v", todos)
+	}
+}
+
+func TestListTodosDueWindow(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store TodoStore) {
+		// 06:00 on June 2nd where the server is, ten hours ahead of UTC
+		now := time.Date(2030, 6, 1, 20, 0, 0, 0, time.UTC)
+		midnight := time.Date(2030, 6, 1, 14, 0, 0, 0, time.UTC)
+		h := &todoHandler{store: store, now: func() time.Time { return now }, location: time.FixedZone("UTC+10", 10*60*60)}
+
+		at := func(d time.Duration) *time.Time {
+			due := midnight.Add(d)
+			return &due
+		}
+		for _, todo := range []*Todo{
+			{Title: "yesterday", DueDate: at(-time.Second)},
+			{Title: "midnight", DueDate: at(0)},
+			{Title: "tonight", DueDate: at(24*time.Hour - time.Second), Priority: PriorityHigh},
+			{Title: "tomorrow", DueDate: at(24 * time.Hour), Done: true},
+			{Title: "in six days", DueDate: at(7*24*time.Hour - time.Second)},
+			{Title: "next week", DueDate: at(7 * 24 * time.Hour)},
+			{Title: "whenever"},
+		} {
+			if err := store.Create(context.Background(), todo); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		tests := []struct {
+			query string
+			want  []string
+		}{
+			{query: "due=today", want: []string{"midnight", "tonight"}},
+			{query: "due=week", want: []string{"midnight", "tonight", "tomorrow", "in six days"}},
+			// both bounds are included
+			{query: "due_after=2030-06-01T14:00:00Z&due_before=2030-06-02T14:00:00Z", want: []string{"midnight", "tonight", "tomorrow"}},
+			{query: "due_after=2030-06-08T14:00:00Z", want: []string{"next week"}},
+			{query: "due_before=2030-06-01T13:59:59Z", want: []string{"yesterday"}},
+			{query: "due=week&done=false&priority=high", want: []string{"tonight"}},
+			{query: "due=week&done=false", want: []string{"midnight", "tonight", "in six days"}},
+		}
+
+		for _, tt := range tests {
+			rr := httptest.NewRecorder()
+			h.listTodos(rr, httptest.NewRequest(http.MethodGet, "/todo?sort=due&"+tt.query, nil))
+			if rr.Code != http.StatusOK {
+				t.Fatalf("%s: expected status %d, got %d", tt.query, http.StatusOK, rr.Code)
+			}
+
+			var todos []Todo
+			if err := json.NewDecoder(rr.Body).Decode(&todos); err != nil {
+				t.Fatal(err)
+			}
+			titles := []string{}
+			for _, todo := range todos {
+				titles = append(titles, todo.Title)
+			}
+			if !slices.Equal(titles, tt.want) {
+				t.Errorf("%s: expected %v, got %v", tt.query, tt.want, titles)
+			}
+		}
+	})
+}
+
+func TestCreateTodoInvalidDueDate(t *testing.T) {
+	h := &todoHandler{store: newMemoryTodoStore()}
+
+	for _, dueDate := range []string{`"tomorrow"`, `"2030-01-02"`, `""`} {
+		t.Run(dueDate, func(t *testing.T) {
+			body := `{"title":"pay rent","due_date":` + dueDate + `}`
+			rr := httptest.NewRecorder()
+			h.createTodo(rr, newJSONRequest(http.MethodPost, "/todo", body))
+
+			if rr.Code != http.StatusUnprocessableEntity {
+				t.Fatalf("expected status %d, got %d", http.StatusUnprocessableEntity, rr.Code)
+			}
+
+			if !strings.Contains(rr.Body.String(), `"field":"due_date"`) {
+				t.Errorf("expected a due_date field error, got %s", rr.Body.String())
+			}
+		})
+	}
+
+	rr := httptest.NewRecorder()
+	h.createTodo(rr, newJSONRequest(http.MethodPost, "/todo", `{"title":"pay rent","due_date":null}`))
+
+	if rr.Code != http.StatusCreated {
+		t.Errorf("expected a null due date to be accepted, got status %d", rr.Code)
+	}
+}
+
+func TestCreateTodoDueDate(t *testing.T) {
+	h := &todoHandler{store: newMemoryTodoStore()}
+
+	body := `{"title":"pay rent","due_date":"2030-01-02T15:04:05+02:00"}`
+	rr := httptest.NewRecorder()
+	h.createTodo(rr, newJSONRequest(http.MethodPost, "/todo", body))
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d", http.StatusCreated, rr.Code)
+	}
+
+	if !strings.Contains(rr.Body.String(), `"due_date":"2030-01-02T13:04:05Z"`) {
+		t.Errorf("expected RFC3339 UTC due date, got %s", rr.Body.String())
+	}
+}
+
+func TestListTodosSorting(t *testing.T) {
+	store := newMemoryTodoStore()
+	h := &todoHandler{store: store}
+	ctx := context.Background()
+
+	base := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	day := func(n int) *time.Time {
+		d := base.AddDate(0, 0, n)
+		return &d
+	}
+
+	for _, todo := range []*Todo{
+		{Title: "a", DueDate: day(3)},
+		{Title: "b"},
+		{Title: "c", DueDate: day(1)},
+		{Title: "d", DueDate: day(2)},
+	} {
+		if err := store.Create(ctx, todo); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// spread the creation times so the created sort is deterministic
+	for i := range store.todos {
+		store.todos[i].CreatedAt = base.Add(time.Duration(i) * time.Minute)
+	}
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{query: "sort=created", want: "abcd"},
+		{query: "sort=created&order=desc", want: "dcba"},
+		{query: "sort=due", want: "cdab"},
+		{query: "sort=due&order=desc", want: "adcb"},
+		{query: "sort=due_date", want: "cdab"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			h.listTodos(rr, httptest.NewRequest(http.MethodGet, "/todo?"+tt.query, nil))
+
+			var todos []Todo
+			if err := json.NewDecoder(rr.Body).Decode(&todos); err != nil {
+				t.Fatal(err)
+			}
+
+			var got string
+			for _, todo := range todos {
+				got += todo.Title
+			}
+
+			if got != tt.want {
+				t.Errorf("expected order %q, got %q", tt.want, got)
+			}
+		})
+	}
+
+	for _, query := range []string{"sort=title", "sort=due&order=up"} {
+		t.Run(query, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			h.listTodos(rr, httptest.NewRequest(http.MethodGet, "/todo?"+query, nil))
+
+			if rr.Code != http.StatusBadRequest {
+				t.Errorf("expected status %d, got %d", http.StatusBadRequest, rr.Code)
+			}
+		})
+	}
+}
+
+func TestTodoIDMiddleware(t *testing.T) {
+	var called bool
+	var got int64
+
+	r := chi.NewRouter()
+	r.Route("/{todoID}", func(r chi.Router) {
+		r.Use(todoIDMiddleware)
+		r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+			called = true
+			got = TodoIDFromContext(r.Context())
+		})
+	})
+
+	for _, id := range []string{"abc", "-1", "0", "1.5", "99999999999999999999"} {
+		t.Run(id, func(t *testing.T) {
+			called = false
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/"+id, nil))
+
+			if rr.Code != http.StatusBadRequest {
+				t.Errorf("expected status %d, got %d", http.StatusBadRequest, rr.Code)
+			}
+
+			if called {
+				t.Error("handler ran for an invalid id")
+			}
+		})
+	}
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/42", nil))
+
+	if !called || got != 42 {
+		t.Errorf("expected the handler to see id 42, got called=%v id=%d", called, got)
+	}
+}
+
+// untouchedStore panics on any call, its nil TodoStore has no methods to run.
+type untouchedStore struct {
+	TodoStore
+}
+
+func TestInvalidTodoIDSkipsHandlers(t *testing.T) {
+	r := newTodoRouter(t, untouchedStore{})
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/todo/abc", nil),
+		newJSONRequest(http.MethodPut, "/todo/0", `{"title":"a"}`),
+		httptest.NewRequest(http.MethodDelete, "/todo/-5", nil),
+		httptest.NewRequest(http.MethodPatch, "/todo/abc/complete", nil),
+		httptest.NewRequest(http.MethodGet, "/todo/abc/subtasks", nil),
+		newJSONRequest(http.MethodPost, "/todo/x/subtasks", `{"title":"a"}`),
+	} {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s %s: expected status %d, got %d", req.Method, req.URL.Path, http.StatusBadRequest, rr.Code)
+		}
+	}
+}
+
+func TestTodoIDFromContextOutsideRoute(t *testing.T) {
+	if id := TodoIDFromContext(context.Background()); id != 0 {
+		t.Errorf("expected 0 without the middleware, got %d", id)
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"sort"
+	"time"
+)
+
+// defaultTrashRetention is how long a trashed todo survives DELETE
+// /todo/trash, -trash-retention changes it.
+const defaultTrashRetention = 30 * 24 * time.Hour
+
+type TrashQuery struct {
+	Offset int
+	Limit  int
+}
+
+type purgeResult struct {
+	Purged int `json:"purged"`
+}
+
+func (h *todoHandler) listTrash(w http.ResponseWriter, r *http.Request) {
+	page, err := parsePagination(r)
+	if err != nil {
+		respondParamErrors(w, r, err)
+		return
+	}
+
+	todos, total, err := h.storeFor(r).ListTrash(r.Context(), TrashQuery{Offset: page.offset, Limit: page.limit})
+	if err != nil {
+		logError(r, "failed to list trashed todos: %v", err)
+		respondError(w, r, http.StatusInternalServerError, codeInternal, "the server encountered a problem")
+		return
+	}
+
+	page.setHeaders(w, r.URL, total)
+
+	respondJSON(w, http.StatusOK, todos)
+}
+
+func (h *todoHandler) restoreTodo(w http.ResponseWriter, r *http.Request) {
+	id := TodoIDFromContext(r.Context())
+
+	todo, err := h.storeFor(r).Restore(r.Context(), id)
+	if err != nil {
+		storeError(w, r, "restore", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, todo)
+}
+
+// purgeTrash empties the trash of the todos deleted more than the retention
+// ago; the ones deleted since stay restorable.
+func (h *todoHandler) purgeTrash(w http.ResponseWriter, r *http.Request) {
+	retention := h.trashRetention
+	if retention == 0 {
+		retention = defaultTrashRetention
+	}
+
+	purged, err := h.storeFor(r).PurgeTrash(r.Context(), h.clock().UTC().Add(-retention))
+	if err != nil {
+		logError(r, "failed to purge trashed todos: %v", err)
+		respondError(w, r, http.StatusInternalServerError, codeInternal, "the server encountered a problem")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, purgeResult{Purged: purged})
+}
+
+func (s *memoryTodoStore) ListTrash(ctx context.Context, q TrashQuery) ([]Todo, int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	trashed := []Todo{}
+	for i := range s.todos {
+		if s.owns(&s.todos[i]) && s.todos[i].DeletedAt != nil {
+			trashed = append(trashed, s.todos[i])
+		}
+	}
+
+	// newest first, ties broken by id like the SQL stores
+	sort.SliceStable(trashed, func(i, j int) bool {
+		a, b := trashed[i].DeletedAt, trashed[j].DeletedAt
+		if a.Equal(*b) {
+			return trashed[i].ID > trashed[j].ID
+		}
+		return a.After(*b)
+	})
+
+	total := len(trashed)
+	offset := min(q.Offset, total)
+	end := min(offset+q.Limit, total)
+
+	return trashed[offset:end], total, nil
+}
+
+func (s *memoryTodoStore) Restore(ctx context.Context, id int64) (Todo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return Todo{}, err
+	}
+
+	i := s.indexOfTrashed(id)
+	if i < 0 {
+		return Todo{}, ErrTodoNotFound
+	}
+
+	s.todos[i].DeletedAt = nil
+	s.todos[i].Version++
+	s.indexTags(s.todos[i].Tags, 1)
+
+	return s.todos[i], nil
+}
+
+func (s *memoryTodoStore) PurgeTrash(ctx context.Context, cutoff time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	before := len(s.todos)
+	s.todos = slices.DeleteFunc(s.todos, func(todo Todo) bool {
+		purge := s.owns(&todo) && todo.DeletedAt != nil && todo.DeletedAt.Before(cutoff)
+		if purge {
+			delete(s.subtasks, todo.ID)
+			delete(s.comments, todo.ID)
+		}
+		return purge
+	})
+
+	return before - len(s.todos), nil
+}
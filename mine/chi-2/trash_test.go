@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestTrashAndRestore(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store TodoStore) {
+		r := newTodoRouter(t, store)
+
+		do := func(method, target, body string, want int, out any) *httptest.ResponseRecorder {
+			t.Helper()
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, newJSONRequest(method, target, body))
+			if rr.Code != want {
+				t.Fatalf("%s %s: expected status %d, got %d: %s", method, target, want, rr.Code, rr.Body)
+			}
+			if out != nil {
+				if err := json.NewDecoder(rr.Body).Decode(out); err != nil {
+					t.Fatal(err)
+				}
+			}
+			return rr
+		}
+
+		var trashed, kept Todo
+		do(http.MethodPost, "/todo", `{"title":"Paint the fence","tags":["garden"]}`, http.StatusCreated, &trashed)
+		do(http.MethodPost, "/todo", `{"title":"Water the plants"}`, http.StatusCreated, &kept)
+		do(http.MethodPost, fmt.Sprintf("/todo/%d/subtasks", trashed.ID), `{"title":"Buy paint"}`, http.StatusCreated, nil)
+
+		target := fmt.Sprintf("/todo/%d", trashed.ID)
+		do(http.MethodDelete, target, "", http.StatusNoContent, nil)
+
+		// a trashed todo is gone from everything but the trash
+		do(http.MethodGet, target, "", http.StatusNotFound, nil)
+		do(http.MethodPut, target, `{"title":"Paint it again","version":2}`, http.StatusNotFound, nil)
+		do(http.MethodDelete, target, "", http.StatusNotFound, nil)
+		do(http.MethodGet, target+"/subtasks", "", http.StatusNotFound, nil)
+
+		var todos []Todo
+		do(http.MethodGet, "/todo", "", http.StatusOK, &todos)
+		if len(todos) != 1 || todos[0].ID != kept.ID {
+			t.Errorf("expected only the kept todo, got %+v", todos)
+		}
+
+		do(http.MethodGet, "/todo/search?q=fence", "", http.StatusOK, &todos)
+		if len(todos) != 0 {
+			t.Errorf("expected search to skip the trash, got %+v", todos)
+		}
+
+		var tags []TagCount
+		do(http.MethodGet, "/todo/tags", "", http.StatusOK, &tags)
+		if len(tags) != 0 {
+			t.Errorf("expected no tags left, got %+v", tags)
+		}
+
+		rr := do(http.MethodGet, "/todo/trash", "", http.StatusOK, &todos)
+		if len(todos) != 1 || todos[0].ID != trashed.ID || todos[0].DeletedAt == nil {
+			t.Fatalf("expected the deleted todo in the trash, got %+v", todos)
+		}
+		if got := rr.Header().Get("X-Total-Count"); got != "1" {
+			t.Errorf("expected X-Total-Count 1, got %q", got)
+		}
+
+		var restored Todo
+		do(http.MethodPost, target+"/restore", "", http.StatusOK, &restored)
+		if restored.DeletedAt != nil || restored.Title != trashed.Title || !slices.Equal(restored.Tags, trashed.Tags) {
+			t.Errorf("unexpected restored todo %+v", restored)
+		}
+		if restored.SubtaskCounts.Total != 1 {
+			t.Errorf("expected the subtask to survive the trash, got %+v", restored.SubtaskCounts)
+		}
+
+		do(http.MethodGet, "/todo", "", http.StatusOK, &todos)
+		if len(todos) != 2 {
+			t.Errorf("expected both todos back, got %+v", todos)
+		}
+
+		do(http.MethodGet, "/todo/tags", "", http.StatusOK, &tags)
+		if len(tags) != 1 || tags[0].Tag != "garden" || tags[0].Count != 1 {
+			t.Errorf("expected the garden tag back, got %+v", tags)
+		}
+
+		do(http.MethodGet, "/todo/trash", "", http.StatusOK, &todos)
+		if len(todos) != 0 {
+			t.Errorf("expected an empty trash, got %+v", todos)
+		}
+
+		// only trashed todos can be restored
+		do(http.MethodPost, target+"/restore", "", http.StatusNotFound, nil)
+		do(http.MethodPost, "/todo/9999/restore", "", http.StatusNotFound, nil)
+	})
+}
+
+func TestPurgeTrash(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store TodoStore) {
+		h := &todoHandler{store: store}
+		r := chi.NewRouter()
+		r.Mount("/todo", h.routes())
+
+		ctx := context.Background()
+		todo := &Todo{Title: "Old"}
+		if err := store.Create(ctx, todo); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Delete(ctx, todo.ID); err != nil {
+			t.Fatal(err)
+		}
+
+		purge := func() int {
+			t.Helper()
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, httptest.NewRequest(http.MethodDelete, "/todo/trash", nil))
+			if rr.Code != http.StatusOK {
+				t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+			}
+
+			var result purgeResult
+			if err := json.NewDecoder(rr.Body).Decode(&result); err != nil {
+				t.Fatal(err)
+			}
+			return result.Purged
+		}
+
+		// just deleted, well inside the default retention
+		if purged := purge(); purged != 0 {
+			t.Errorf("expected nothing purged, got %d", purged)
+		}
+
+		h.now = func() time.Time { return time.Now().Add(defaultTrashRetention + time.Hour) }
+		if purged := purge(); purged != 1 {
+			t.Errorf("expected the todo purged, got %d", purged)
+		}
+
+		if _, err := store.Restore(ctx, todo.ID); err != ErrTodoNotFound {
+			t.Errorf("expected a purged todo to be gone for good, got %v", err)
+		}
+	})
+}
+
+func TestPurgeTrashRetention(t *testing.T) {
+	start := time.Date(2030, 6, 1, 12, 0, 0, 0, time.UTC)
+	now := start
+
+	store := newMemoryTodoStore()
+	store.now = func() time.Time { return now }
+	h := &todoHandler{store: store, now: func() time.Time { return now }, trashRetention: 7 * 24 * time.Hour}
+
+	ctx := context.Background()
+	var ids []int64
+	for _, offset := range []time.Duration{0, 5 * 24 * time.Hour, 10 * 24 * time.Hour} {
+		now = start.Add(offset)
+
+		todo := &Todo{Title: fmt.Sprintf("deleted after %s", offset)}
+		if err := store.Create(ctx, todo); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.CreateSubtask(ctx, &Subtask{TodoID: todo.ID, Title: "step"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Delete(ctx, todo.ID); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, todo.ID)
+	}
+
+	// the cutoff lands on the second todo, which stays
+	now = start.Add(12 * 24 * time.Hour)
+
+	rr := httptest.NewRecorder()
+	h.purgeTrash(rr, httptest.NewRequest(http.MethodDelete, "/todo/trash", nil))
+
+	var result purgeResult
+	if err := json.NewDecoder(rr.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Purged != 1 {
+		t.Errorf("expected 1 todo purged, got %d", result.Purged)
+	}
+
+	if _, ok := store.subtasks[ids[0]]; ok {
+		t.Error("expected the purged todo's subtasks to go with it")
+	}
+
+	trash, total, err := store.ListTrash(ctx, TrashQuery{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 2 || len(trash) != 2 || trash[0].ID != ids[2] || trash[1].ID != ids[1] {
+		t.Errorf("expected the two newer todos, newest first, got %+v", trash)
+	}
+}
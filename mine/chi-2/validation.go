@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+const maxTitleLength = 200
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+type ValidationErrors []FieldError
+
+func (v ValidationErrors) Error() string {
+	messages := make([]string, len(v))
+	for i, fe := range v {
+		messages[i] = fmt.Sprintf("%s: %s", fe.Field, fe.Message)
+	}
+
+	return strings.Join(messages, "; ")
+}
+
+func (t *Todo) Validate() error {
+	var errs ValidationErrors
+
+	title := strings.TrimSpace(t.Title)
+	switch {
+	case title == "":
+		errs = append(errs, FieldError{Field: "title", Message: "is required"})
+	case utf8.RuneCountInString(title) > maxTitleLength:
+		errs = append(errs, FieldError{Field: "title", Message: fmt.Sprintf("must be at most %d characters", maxTitleLength)})
+	}
+
+	if len(t.Tags) > maxTagsPerTodo {
+		errs = append(errs, FieldError{Field: "tags", Message: fmt.Sprintf("must have at most %d tags", maxTagsPerTodo)})
+	}
+
+	for _, tag := range t.Tags {
+		if n := utf8.RuneCountInString(tag); n < 1 || n > maxTagLength {
+			errs = append(errs, FieldError{Field: "tags", Message: fmt.Sprintf("each tag must be 1 to %d characters", maxTagLength)})
+			break
+		}
+	}
+
+	if t.Priority != "" && !t.Priority.Valid() {
+		errs = append(errs, FieldError{Field: "priority", Message: "must be one of low, medium, high"})
+	}
+
+	// the next occurrence is due one interval after this one
+	if t.Recurrence != nil && t.DueDate == nil {
+		errs = append(errs, FieldError{Field: "recurrence", Message: "requires a due_date"})
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+func writeValidationErrors(w http.ResponseWriter, r *http.Request, errs ValidationErrors) {
+	respondJSON(w, http.StatusUnprocessableEntity, struct {
+		Errors    ValidationErrors `json:"errors"`
+		Code      string           `json:"code"`
+		RequestID string           `json:"request_id,omitempty"`
+	}{errs, codeValidationFailed, middleware.GetReqID(r.Context())})
+}
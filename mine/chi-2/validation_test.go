@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCreateTodoValidation(t *testing.T) {
+	h := &todoHandler{store: newMemoryTodoStore()}
+
+	tests := []struct {
+		name    string
+		title   string
+		message string
+	}{
+		{name: "empty title", title: "", message: "is required"},
+		{name: "whitespace title", title: "   ", message: "is required"},
+		{name: "too long title", title: strings.Repeat("a", maxTitleLength+1), message: "must be at most 200 characters"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, _ := json.Marshal(todoPayload{Title: tt.title})
+
+			rr := httptest.NewRecorder()
+			h.createTodo(rr, newJSONRequest(http.MethodPost, "/todo", string(body)))
+
+			if rr.Code != http.StatusUnprocessableEntity {
+				t.Fatalf("expected status %d, got %d", http.StatusUnprocessableEntity, rr.Code)
+			}
+
+			var resp struct {
+				Errors []FieldError `json:"errors"`
+			}
+			if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+
+			want := []FieldError{{Field: "title", Message: tt.message}}
+			if len(resp.Errors) != 1 || resp.Errors[0] != want[0] {
+				t.Errorf("expected errors %+v, got %+v", want, resp.Errors)
+			}
+		})
+	}
+
+	t.Run("valid title", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		h.createTodo(rr, newJSONRequest(http.MethodPost, "/todo", `{"title":"  buy milk "}`))
+
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("expected status %d, got %d", http.StatusCreated, rr.Code)
+		}
+
+		var todo Todo
+		if err := json.NewDecoder(rr.Body).Decode(&todo); err != nil {
+			t.Fatal(err)
+		}
+
+		if todo.ID != 1 || todo.Title != "buy milk" {
+			t.Errorf("unexpected todo %+v", todo)
+		}
+	})
+}
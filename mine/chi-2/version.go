@@ -0,0 +1,80 @@
+package main
+
+import (
+	"net/http"
+	"runtime"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// apiVersion prefixes every route. Bump it, and keep the old prefix mounted,
+// when the todo payload changes in a way clients would notice.
+const apiVersion = "v1"
+
+// Build info, set with the linker:
+//
+//	go build -ldflags "-X main.version=1.2.0 -X main.commit=$(git rev-parse --short HEAD) -X main.buildTime=$(date -u +%FT%TZ)"
+var (
+	version   = "dev"
+	commit    = "unknown"
+	buildTime = "unknown"
+)
+
+type versionResponse struct {
+	API       string `json:"api"`
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildTime string `json:"build_time"`
+	Go        string `json:"go"`
+}
+
+func versionHandler(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, versionResponse{
+		API:       apiVersion,
+		Version:   version,
+		Commit:    commit,
+		BuildTime: buildTime,
+		Go:        runtime.Version(),
+	})
+}
+
+// mountAPI serves the API under /v1 and GET /version next to it. The old
+// unversioned paths redirect to /v1 for one more release. middlewares wrap
+// the /v1 routes only, like the rate limiter, which must leave the health
+// checks, /metrics and the drain alone.
+func mountAPI(r chi.Router, todos *todoHandler, webhooks *webhookHandler, tokens *refreshTokens, middlewares ...func(http.Handler) http.Handler) {
+	r.Method(http.MethodGet, "/version", documented(routeDoc{Summary: "Show the API and build version", Response: versionResponse{}}, versionHandler))
+
+	r.Route("/"+apiVersion, func(r chi.Router) {
+		r.Use(middlewares...)
+
+		r.Group(func(r chi.Router) {
+			r.Method(http.MethodGet, "/", documented(routeDoc{Summary: "Say hello", ContentType: "text/plain"}, helloWorldHandler))
+			tokens.mount(r)
+		})
+		r.Group(func(r chi.Router) {
+			r.Use(AuthMiddleware)
+			r.Mount("/todo", todos.routes())
+			r.Mount("/lists", todos.listRoutes())
+			r.Mount("/admin", todos.adminRoutes())
+			r.With(timeoutMiddleware(todos.requestTimeout())).Mount("/webhooks", webhooks.routes())
+		})
+	})
+
+	redirect := documented(routeDoc{Summary: "Redirect to the /" + apiVersion + " path", Status: http.StatusPermanentRedirect}, redirectToAPIVersion)
+	r.Handle("/", redirect)
+	r.Handle("/todo", redirect)
+	r.Handle("/todo/*", redirect)
+}
+
+// redirectToAPIVersion sends an unversioned path to its /v1 equivalent. It is
+// a 308 and not a 301 so clients repeat a POST or PUT with the same method
+// and body instead of turning it into a GET.
+func redirectToAPIVersion(w http.ResponseWriter, r *http.Request) {
+	target := "/" + apiVersion + r.URL.Path
+	if r.URL.RawQuery != "" {
+		target += "?" + r.URL.RawQuery
+	}
+
+	http.Redirect(w, r, target, http.StatusPermanentRedirect)
+}
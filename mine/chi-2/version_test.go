@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func newAPIServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(newAPIRouter(t, &todoHandler{store: newMemoryTodoStore()}, nil, nil))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestLegacyRedirect(t *testing.T) {
+	server := newAPIServer(t)
+
+	token := testToken(t, "ana")
+
+	noFollow := &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+
+	tests := []struct {
+		method, path, location string
+	}{
+		{http.MethodGet, "/", "/v1/"},
+		{http.MethodGet, "/todo?done=true&limit=5", "/v1/todo?done=true&limit=5"},
+		{http.MethodPost, "/todo", "/v1/todo"},
+		{http.MethodPut, "/todo/3", "/v1/todo/3"},
+		{http.MethodDelete, "/todo/3/subtasks/1", "/v1/todo/3/subtasks/1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, server.URL+tt.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res, err := noFollow.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res.Body.Close()
+
+			if res.StatusCode != http.StatusPermanentRedirect {
+				t.Fatalf("expected status %d, got %d", http.StatusPermanentRedirect, res.StatusCode)
+			}
+			if location := res.Header.Get("Location"); location != tt.location {
+				t.Errorf("expected Location %q, got %q", tt.location, location)
+			}
+		})
+	}
+
+	t.Run("POST keeps method and body", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, server.URL+"/todo", strings.NewReader(`{"title":"Write docs"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusCreated {
+			t.Fatalf("expected status %d, got %d", http.StatusCreated, res.StatusCode)
+		}
+		if res.Request.Method != http.MethodPost || res.Request.URL.Path != "/v1/todo" {
+			t.Errorf("expected the POST to be repeated on /v1/todo, got %s %s", res.Request.Method, res.Request.URL.Path)
+		}
+
+		var created Todo
+		if err := json.NewDecoder(res.Body).Decode(&created); err != nil {
+			t.Fatal(err)
+		}
+		if created.Title != "Write docs" {
+			t.Errorf("expected the body to survive the redirect, got %+v", created)
+		}
+	})
+}
+
+func TestVersionedRoutes(t *testing.T) {
+	server := newAPIServer(t)
+
+	for path, status := range map[string]int{
+		"/v1":      http.StatusOK,
+		"/v1/":     http.StatusOK,
+		"/v1/todo": http.StatusUnauthorized,
+		"/v2/todo": http.StatusNotFound,
+	} {
+		res, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+
+		if res.StatusCode != status {
+			t.Errorf("GET %s: expected status %d, got %d", path, status, res.StatusCode)
+		}
+	}
+}
+
+func TestVersionHandler(t *testing.T) {
+	oldVersion, oldCommit, oldBuildTime := version, commit, buildTime
+	version, commit, buildTime = "1.2.0", "abc1234", "2024-11-02T10:00:00Z"
+	t.Cleanup(func() { version, commit, buildTime = oldVersion, oldCommit, oldBuildTime })
+
+	rr := httptest.NewRecorder()
+	versionHandler(rr, httptest.NewRequest(http.MethodGet, "/version", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	var got versionResponse
+	if err := json.NewDecoder(rr.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+
+	want := versionResponse{API: "v1", Version: "1.2.0", Commit: "abc1234", BuildTime: "2024-11-02T10:00:00Z", Go: runtime.Version()}
+	if got != want {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+}
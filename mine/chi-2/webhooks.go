@@ -0,0 +1,578 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	// webhookSignatureHeader carries "sha256=" and the hex HMAC-SHA256 of the
+	// body, keyed with the webhook's secret.
+	webhookSignatureHeader = "X-Webhook-Signature"
+
+	defaultWebhookAttempts = 5
+	firstWebhookBackoff    = time.Second
+	maxWebhookBackoff      = time.Minute
+	webhookTimeout         = 10 * time.Second
+
+	// maxWebhookQueueDepth is how many events may wait for one webhook, the
+	// ones beyond it are dropped. /healthz reports the dispatcher as failing
+	// once more than that are pending across the webhooks.
+	maxWebhookQueueDepth = 100
+)
+
+// nonPublicPrefixes are the ranges publicAddr turns down on top of the
+// loopback, private, link-local and multicast ones netip knows about. The
+// cloud metadata addresses fall in link-local, 100.64.0.0/10 or fc00::/7.
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+}
+
+// errNonPublicWebhook is what dialing a webhook that resolves to a
+// non-public address fails with.
+var errNonPublicWebhook = errors.New("webhook address is not public")
+
+// publicAddr reports whether addr is on the public internet, the only place
+// webhooks may point to unless private ones are allowed.
+func publicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// newWebhookClient returns the client deliveries go out with. Unless
+// allowPrivate is set it checks every address it connects to, so a name that
+// resolves, or rebinds, to an internal one is refused, redirects included.
+func newWebhookClient(allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: webhookTimeout}
+	if !allowPrivate {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			addr, err := netip.ParseAddr(host)
+			if err != nil || !publicAddr(addr) {
+				return errNonPublicWebhook
+			}
+			return nil
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// a proxy would be dialed instead of the receiver
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{Timeout: webhookTimeout, Transport: transport}
+}
+
+// webhookStatusError is a delivery the receiver answered with a non-2xx
+// status.
+type webhookStatusError struct {
+	status int
+}
+
+func (e *webhookStatusError) Error() string {
+	return fmt.Sprintf("receiver answered %d %s", e.status, http.StatusText(e.status))
+}
+
+// deliveryError is the err of a failed attempt as last_delivery shows it. The
+// raw error stays in the server log, it can tell internal addresses and
+// network details the webhook's owner has no business seeing.
+func deliveryError(err error) string {
+	var statusErr *webhookStatusError
+	var netErr net.Error
+
+	switch {
+	case err == nil:
+		return ""
+	case errors.As(err, &statusErr):
+		return statusErr.Error()
+	case errors.Is(err, errNonPublicWebhook):
+		return "the receiver's address is not public"
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return "the receiver timed out"
+	default:
+		return "the receiver could not be reached"
+	}
+}
+
+// webhookEvents are the event types a webhook can subscribe to.
+var webhookEvents = []string{eventCreated, eventUpdated, eventCompleted, eventDeleted}
+
+type webhook struct {
+	ID     int64    `json:"id"`
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	// Secret is only shown in the response that created the webhook.
+	Secret       string           `json:"secret,omitempty"`
+	OwnerID      string           `json:"owner_id,omitempty"`
+	CreatedAt    time.Time        `json:"created_at"`
+	LastDelivery *webhookDelivery `json:"last_delivery,omitempty"`
+}
+
+// webhookDelivery is the outcome of the latest event sent to a webhook.
+// Status is the receiver's last answer, 0 when it couldn't be reached.
+type webhookDelivery struct {
+	EventID   uint64    `json:"event_id"`
+	Event     string    `json:"event"`
+	Status    int       `json:"status"`
+	Attempts  int       `json:"attempts"`
+	Delivered bool      `json:"delivered"`
+	Error     string    `json:"error,omitempty"`
+	At        time.Time `json:"at"`
+}
+
+func (hook *webhook) wants(event todoEvent) bool {
+	return slices.Contains(hook.Events, event.Type) && event.visibleTo(hook.OwnerID)
+}
+
+// webhookRegistry keeps the registered webhooks in memory, they are gone
+// after a restart.
+type webhookRegistry struct {
+	mu     sync.Mutex
+	nextID int64
+	hooks  []*webhook
+}
+
+func newWebhookRegistry() *webhookRegistry {
+	return &webhookRegistry{nextID: 1}
+}
+
+func (reg *webhookRegistry) add(hook webhook) webhook {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	hook.ID = reg.nextID
+	reg.nextID++
+	reg.hooks = append(reg.hooks, &hook)
+
+	return hook
+}
+
+// get returns a copy of the webhook, limited to owner's like ForOwner.
+func (reg *webhookRegistry) get(id int64, owner string) (webhook, bool) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	for _, hook := range reg.hooks {
+		if hook.ID == id && (owner == "" || hook.OwnerID == owner) {
+			return hook.view(), true
+		}
+	}
+
+	return webhook{}, false
+}
+
+// matching returns copies of the webhooks that want event.
+func (reg *webhookRegistry) matching(event todoEvent) []webhook {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	var hooks []webhook
+	for _, hook := range reg.hooks {
+		if hook.wants(event) {
+			hooks = append(hooks, *hook)
+		}
+	}
+
+	return hooks
+}
+
+func (reg *webhookRegistry) recordDelivery(id int64, delivery webhookDelivery) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	for _, hook := range reg.hooks {
+		if hook.ID == id {
+			hook.LastDelivery = &delivery
+			return
+		}
+	}
+}
+
+// view is the webhook as GET shows it, without the secret. It must be called
+// with reg.mu held.
+func (hook *webhook) view() webhook {
+	view := *hook
+	view.Secret = ""
+	view.Events = slices.Clone(hook.Events)
+	if hook.LastDelivery != nil {
+		delivery := *hook.LastDelivery
+		view.LastDelivery = &delivery
+	}
+
+	return view
+}
+
+func signWebhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookDispatcher delivers todo events to the webhooks that want them. It
+// is an ordinary subscriber of the events notifyingStore publishes, so the
+// handlers never wait for a delivery.
+type webhookDispatcher struct {
+	events   *todoEvents
+	registry *webhookRegistry
+	client   *http.Client
+	// attempts is how often a delivery is tried, backoff the wait after the
+	// first failure, doubled after every further one up to
+	// maxWebhookBackoff.
+	attempts int
+	backoff  time.Duration
+	// queues holds a queue per webhook, each drained in order by its own
+	// worker. Only run touches the map.
+	queues map[int64]chan webhookJob
+	// workers tracks the queue workers so run can wait for them, pending
+	// counts the queued and running deliveries for queueDepth and dropped
+	// the events a full queue turned away.
+	workers sync.WaitGroup
+	pending atomic.Int64
+	dropped atomic.Int64
+}
+
+// webhookJob is one event waiting in a webhook's queue, with the webhook as
+// it was when the event was dispatched.
+type webhookJob struct {
+	hook  webhook
+	event todoEvent
+}
+
+// newWebhookDispatcher returns a dispatcher that only delivers to public
+// addresses, unless allowPrivate is set.
+func newWebhookDispatcher(events *todoEvents, registry *webhookRegistry, attempts int, allowPrivate bool) *webhookDispatcher {
+	return &webhookDispatcher{
+		events:   events,
+		registry: registry,
+		client:   newWebhookClient(allowPrivate),
+		attempts: attempts,
+		backoff:  firstWebhookBackoff,
+		queues:   make(map[int64]chan webhookJob),
+	}
+}
+
+// run hands every event to the matching webhooks until ctx is done, then
+// waits for the queue workers to give up on the deliveries in flight. Should the dispatcher fall
+// behind and be dropped by publish it subscribes again, replaying what it
+// missed from the event history.
+func (d *webhookDispatcher) run(ctx context.Context) {
+	defer d.workers.Wait()
+
+	var lastID uint64
+	replay := false
+
+	for {
+		events, missed := d.events.subscribe(lastID, replay)
+		for _, event := range missed {
+			d.dispatch(ctx, event)
+			lastID = event.ID
+		}
+
+		if !d.drain(ctx, events, &lastID) {
+			d.events.unsubscribe(events)
+			return
+		}
+
+		log.Printf("webhook dispatcher fell behind, resubscribing after event %d", lastID)
+		replay = true
+	}
+}
+
+// drain dispatches events until ctx is done, when it returns false, or until
+// publish closes the channel.
+func (d *webhookDispatcher) drain(ctx context.Context, events chan todoEvent, lastID *uint64) bool {
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case event, ok := <-events:
+			if !ok {
+				return true
+			}
+
+			d.dispatch(ctx, event)
+			*lastID = event.ID
+		}
+	}
+}
+
+// dispatch queues event for every webhook that wants it. A webhook whose
+// queue is full misses the event rather than hold up the others.
+func (d *webhookDispatcher) dispatch(ctx context.Context, event todoEvent) {
+	for _, hook := range d.registry.matching(event) {
+		jobs := d.queue(ctx, hook.ID)
+
+		d.pending.Add(1)
+		select {
+		case jobs <- webhookJob{hook: hook, event: event}:
+		default:
+			d.pending.Add(-1)
+			dropped := d.dropped.Add(1)
+			log.Printf("webhook %d has %d deliveries queued, dropping event %d (%d dropped so far)", hook.ID, maxWebhookQueueDepth, event.ID, dropped)
+		}
+	}
+}
+
+// queue returns the queue of webhook id, starting its worker on first use.
+// The worker delivers one event at a time, so a receiver gets them in order.
+func (d *webhookDispatcher) queue(ctx context.Context, id int64) chan webhookJob {
+	if jobs, ok := d.queues[id]; ok {
+		return jobs
+	}
+
+	jobs := make(chan webhookJob, maxWebhookQueueDepth)
+	d.queues[id] = jobs
+
+	d.workers.Add(1)
+	go func() {
+		defer d.workers.Done()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case job := <-jobs:
+				d.deliver(ctx, job.hook, job.event)
+				d.pending.Add(-1)
+			}
+		}
+	}()
+
+	return jobs
+}
+
+// queueDepth is the number of deliveries not finished yet, retries included.
+func (d *webhookDispatcher) queueDepth() int {
+	return int(d.pending.Load())
+}
+
+// checkQueue is the webhooks check of /healthz, a growing queue means the
+// receivers can't keep up.
+func (d *webhookDispatcher) checkQueue(ctx context.Context) error {
+	if depth := d.queueDepth(); depth > maxWebhookQueueDepth {
+		return fmt.Errorf("%d webhook deliveries pending, more than %d", depth, maxWebhookQueueDepth)
+	}
+
+	return nil
+}
+
+// deliver posts event to hook until it answers 2xx or the attempts run out,
+// recording the outcome after every attempt.
+func (d *webhookDispatcher) deliver(ctx context.Context, hook webhook, event todoEvent) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("failed to encode %s event %d for webhook %d: %v", event.Type, event.ID, hook.ID, err)
+		return
+	}
+
+	delivery := webhookDelivery{EventID: event.ID, Event: event.Type}
+	backoff := d.backoff
+
+	for attempt := 1; attempt <= d.attempts; attempt++ {
+		delivery.Attempts = attempt
+		delivery.Status, err = d.post(ctx, hook, event, body)
+		delivery.Delivered = err == nil
+		delivery.Error = deliveryError(err)
+		delivery.At = time.Now().UTC()
+		d.registry.recordDelivery(hook.ID, delivery)
+
+		if err == nil {
+			return
+		}
+
+		if attempt == d.attempts {
+			log.Printf("giving up on webhook %d for event %d after %d attempts: %v", hook.ID, event.ID, attempt, err)
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+
+		backoff = min(backoff*2, maxWebhookBackoff)
+	}
+}
+
+func (d *webhookDispatcher) post(ctx context.Context, hook webhook, event todoEvent, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Event", event.Type)
+	req.Header.Set("X-Webhook-Delivery", strconv.FormatUint(event.ID, 10))
+	req.Header.Set(webhookSignatureHeader, signWebhook(hook.Secret, body))
+
+	res, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, &webhookStatusError{status: res.StatusCode}
+	}
+
+	return res.StatusCode, nil
+}
+
+type webhookHandler struct {
+	registry *webhookRegistry
+	// allowPrivate lets webhooks point to loopback and private addresses,
+	// for receivers on the same machine or network.
+	allowPrivate bool
+}
+
+// routes returns the /webhooks route tree. Like /todo it is meant to be
+// mounted behind AuthMiddleware, each caller sees only their own webhooks.
+func (h *webhookHandler) routes() chi.Router {
+	r := chi.NewRouter()
+
+	r.Method(http.MethodPost, "/", documented(routeDoc{Summary: "Register a webhook", Request: webhookPayload{}, Response: webhook{}, Status: http.StatusCreated}, h.createWebhook))
+	r.Method(http.MethodGet, "/{webhookID}", documented(routeDoc{Summary: "Get a webhook", Response: webhook{}}, h.getWebhook))
+
+	return r
+}
+
+type webhookPayload struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	// Secret is generated when left empty.
+	Secret string `json:"secret"`
+}
+
+// validate checks the payload. Unless allowPrivate is set it turns down the
+// URLs naming a non-public address outright; the ones with a host name are
+// checked when a delivery connects, see newWebhookClient.
+func (p *webhookPayload) validate(allowPrivate bool) ValidationErrors {
+	var errs ValidationErrors
+
+	target, err := url.Parse(p.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		errs = append(errs, FieldError{Field: "url", Message: "must be an absolute http or https URL"})
+	} else if !allowPrivate && !publicHost(target.Hostname()) {
+		errs = append(errs, FieldError{Field: "url", Message: "must point to a public address"})
+	}
+
+	allowed := "must list one or more of " + strings.Join(webhookEvents, ", ")
+	if len(p.Events) == 0 {
+		errs = append(errs, FieldError{Field: "events", Message: allowed})
+	}
+	for _, event := range p.Events {
+		if !slices.Contains(webhookEvents, event) {
+			errs = append(errs, FieldError{Field: "events", Message: allowed})
+			break
+		}
+	}
+
+	return errs
+}
+
+// publicHost reports whether host may be public: an address has to be, a
+// name other than localhost is taken at its word until it is dialed.
+func publicHost(host string) bool {
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return publicAddr(addr)
+	}
+
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	return host != "localhost" && !strings.HasSuffix(host, ".localhost")
+}
+
+func (h *webhookHandler) createWebhook(w http.ResponseWriter, r *http.Request) {
+	var payload webhookPayload
+	if err := decodeJSON(r, &payload); err != nil {
+		respondDecodeError(w, r, err)
+		return
+	}
+
+	payload.URL = strings.TrimSpace(payload.URL)
+	if errs := payload.validate(h.allowPrivate); len(errs) > 0 {
+		writeValidationErrors(w, r, errs)
+		return
+	}
+
+	if payload.Secret == "" {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			logError(r, "failed to generate webhook secret: %v", err)
+			respondError(w, r, http.StatusInternalServerError, codeInternal, "the server encountered a problem")
+			return
+		}
+		payload.Secret = hex.EncodeToString(secret)
+	}
+
+	subject, _ := subjectFromContext(r.Context())
+
+	slices.Sort(payload.Events)
+	hook := h.registry.add(webhook{
+		URL:       payload.URL,
+		Events:    slices.Compact(payload.Events),
+		Secret:    payload.Secret,
+		OwnerID:   subject,
+		CreatedAt: time.Now().UTC(),
+	})
+
+	w.Header().Set("Location", fmt.Sprintf("/"+apiVersion+"/webhooks/%d", hook.ID))
+	respondJSON(w, http.StatusCreated, hook)
+}
+
+func (h *webhookHandler) getWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "webhookID"), 10, 64)
+	if err != nil || id < 1 {
+		respondError(w, r, http.StatusBadRequest, codeBadRequest, "webhook id must be a positive integer")
+		return
+	}
+
+	subject, _ := subjectFromContext(r.Context())
+
+	hook, ok := h.registry.get(id, subject)
+	if !ok {
+		respondError(w, r, http.StatusNotFound, codeNotFound, "webhook not found")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, hook)
+}
@@ -0,0 +1,528 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type webhookAPI struct {
+	router     http.Handler
+	registry   *webhookRegistry
+	events     *todoEvents
+	dispatcher *webhookDispatcher
+}
+
+// startWebhookAPI serves the /v1 routes with a dispatcher that retries after
+// a millisecond. Private addresses are allowed, the receivers are httptest
+// servers on loopback.
+func startWebhookAPI(t *testing.T, attempts int) *webhookAPI {
+	t.Helper()
+
+	events := newTodoEvents()
+	registry := newWebhookRegistry()
+
+	dispatcher := newWebhookDispatcher(events, registry, attempts, true)
+	dispatcher.backoff = time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		dispatcher.run(ctx)
+		close(stopped)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-stopped
+	})
+
+	waitFor(t, "the dispatcher to subscribe", func() bool { return events.subscriberCount() == 1 })
+
+	todos := &todoHandler{store: &notifyingStore{TodoStore: newMemoryTodoStore(), events: events}, events: events}
+	r := newAPIRouter(t, todos, &webhookHandler{registry: registry, allowPrivate: true}, nil)
+
+	return &webhookAPI{
+		router:     r,
+		registry:   registry,
+		events:     events,
+		dispatcher: dispatcher,
+	}
+}
+
+func (api *webhookAPI) do(t *testing.T, subject, method, target, body string, out any) int {
+	t.Helper()
+
+	req := newJSONRequest(method, target, body)
+	req.Header.Set("Authorization", "Bearer "+testToken(t, subject))
+
+	rr := httptest.NewRecorder()
+	api.router.ServeHTTP(rr, req)
+
+	if out != nil && rr.Code < 300 {
+		if err := json.NewDecoder(rr.Body).Decode(out); err != nil {
+			t.Fatalf("%s %s: %v", method, target, err)
+		}
+	}
+
+	return rr.Code
+}
+
+func waitFor(t *testing.T, what string, done func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !done() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+type receivedWebhook struct {
+	header http.Header
+	body   []byte
+}
+
+func TestWebhookDelivery(t *testing.T) {
+	api := startWebhookAPI(t, 5)
+
+	// the receiver fails twice before it takes the delivery
+	var calls atomic.Int32
+	received := make(chan receivedWebhook, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		if calls.Add(1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		received <- receivedWebhook{header: r.Header.Clone(), body: body}
+	}))
+	defer receiver.Close()
+
+	var hook webhook
+	body := fmt.Sprintf(`{"url":%q,"events":["completed"],"secret":"s3cret"}`, receiver.URL)
+	if status := api.do(t, "ana", http.MethodPost, "/v1/webhooks", body, &hook); status != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d", http.StatusCreated, status)
+	}
+	if hook.Secret != "s3cret" || hook.OwnerID != "ana" {
+		t.Errorf("unexpected webhook %+v", hook)
+	}
+
+	var todo Todo
+	api.do(t, "ana", http.MethodPost, "/v1/todo", `{"title":"Water the plants"}`, &todo)
+	api.do(t, "ana", http.MethodPatch, fmt.Sprintf("/v1/todo/%d/complete", todo.ID), fmt.Sprintf(`{"version":%d}`, todo.Version), nil)
+
+	var got receivedWebhook
+	select {
+	case got = <-received:
+	case <-time.After(2 * time.Second):
+		t.Fatal("the webhook was never delivered")
+	}
+
+	if calls.Load() != 3 {
+		t.Errorf("expected 3 attempts, got %d", calls.Load())
+	}
+	if signature := got.header.Get(webhookSignatureHeader); signature != signWebhook("s3cret", got.body) {
+		t.Errorf("unexpected signature %q", signature)
+	}
+	if event := got.header.Get("X-Webhook-Event"); event != eventCompleted {
+		t.Errorf("expected X-Webhook-Event %q, got %q", eventCompleted, event)
+	}
+
+	var event todoEvent
+	if err := json.Unmarshal(got.body, &event); err != nil {
+		t.Fatal(err)
+	}
+	if event.Type != eventCompleted || event.Todo.ID != todo.ID || !event.Todo.Done {
+		t.Errorf("unexpected payload %+v", event)
+	}
+
+	var fetched webhook
+	waitFor(t, "the delivery to be recorded", func() bool {
+		fetched = webhook{}
+		api.do(t, "ana", http.MethodGet, fmt.Sprintf("/v1/webhooks/%d", hook.ID), "", &fetched)
+		return fetched.LastDelivery != nil && fetched.LastDelivery.Delivered
+	})
+
+	delivery := fetched.LastDelivery
+	if delivery.Status != http.StatusOK || delivery.Attempts != 3 || delivery.Event != eventCompleted || delivery.Error != "" {
+		t.Errorf("unexpected last delivery %+v", delivery)
+	}
+	if fetched.Secret != "" {
+		t.Error("expected GET to hide the secret")
+	}
+}
+
+func TestWebhookGivesUp(t *testing.T) {
+	api := startWebhookAPI(t, 3)
+
+	var calls atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer receiver.Close()
+
+	var hook webhook
+	api.do(t, "ana", http.MethodPost, "/v1/webhooks", fmt.Sprintf(`{"url":%q,"events":["created"]}`, receiver.URL), &hook)
+	if len(hook.Secret) != 64 {
+		t.Errorf("expected a generated 32 byte secret, got %q", hook.Secret)
+	}
+
+	api.do(t, "ana", http.MethodPost, "/v1/todo", `{"title":"Water the plants"}`, nil)
+
+	var fetched webhook
+	waitFor(t, "the last attempt", func() bool {
+		fetched = webhook{}
+		api.do(t, "ana", http.MethodGet, fmt.Sprintf("/v1/webhooks/%d", hook.ID), "", &fetched)
+		return fetched.LastDelivery != nil && fetched.LastDelivery.Attempts == 3
+	})
+
+	delivery := fetched.LastDelivery
+	if delivery.Delivered || delivery.Status != http.StatusInternalServerError || delivery.Error != "receiver answered 500 Internal Server Error" {
+		t.Errorf("unexpected last delivery %+v", delivery)
+	}
+
+	// nothing is left to retry
+	time.Sleep(20 * time.Millisecond)
+	if calls.Load() != 3 {
+		t.Errorf("expected 3 attempts, got %d", calls.Load())
+	}
+}
+
+func TestWebhookFilters(t *testing.T) {
+	api := startWebhookAPI(t, 1)
+
+	received := make(chan string, 10)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Get("X-Webhook-Event")
+	}))
+	defer receiver.Close()
+
+	api.do(t, "ana", http.MethodPost, "/v1/webhooks", fmt.Sprintf(`{"url":%q,"events":["deleted"]}`, receiver.URL), nil)
+
+	// bob's todos and other event types don't reach ana's webhook
+	var bobs, anas Todo
+	api.do(t, "bob", http.MethodPost, "/v1/todo", `{"title":"Bob's todo"}`, &bobs)
+	api.do(t, "bob", http.MethodDelete, fmt.Sprintf("/v1/todo/%d", bobs.ID), "", nil)
+	api.do(t, "ana", http.MethodPost, "/v1/todo", `{"title":"Ana's todo"}`, &anas)
+	api.do(t, "ana", http.MethodPatch, fmt.Sprintf("/v1/todo/%d/complete", anas.ID), fmt.Sprintf(`{"version":%d}`, anas.Version), nil)
+	api.do(t, "ana", http.MethodDelete, fmt.Sprintf("/v1/todo/%d", anas.ID), "", nil)
+
+	select {
+	case event := <-received:
+		if event != eventDeleted {
+			t.Errorf("expected only a deleted event, got %q", event)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("the webhook was never delivered")
+	}
+
+	select {
+	case event := <-received:
+		t.Errorf("expected a single delivery, also got %q", event)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestWebhookDoesNotBlockHandlers(t *testing.T) {
+	api := startWebhookAPI(t, 1)
+
+	release := make(chan struct{})
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer receiver.Close()
+	defer close(release)
+
+	api.do(t, "ana", http.MethodPost, "/v1/webhooks", fmt.Sprintf(`{"url":%q,"events":["created"]}`, receiver.URL), nil)
+
+	start := time.Now()
+	for i := range 5 {
+		if status := api.do(t, "ana", http.MethodPost, "/v1/todo", fmt.Sprintf(`{"title":"todo %d"}`, i), nil); status != http.StatusCreated {
+			t.Fatalf("expected status %d, got %d", http.StatusCreated, status)
+		}
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the handlers not to wait for the receiver, took %s", elapsed)
+	}
+}
+
+func TestWebhookRoutes(t *testing.T) {
+	api := startWebhookAPI(t, 1)
+
+	tests := []struct {
+		name, body string
+		fields     []string
+	}{
+		{"missing url", `{"events":["created"]}`, []string{"url"}},
+		{"relative url", `{"url":"/hook","events":["created"]}`, []string{"url"}},
+		{"other scheme", `{"url":"ftp://example.com/hook","events":["created"]}`, []string{"url"}},
+		{"no events", `{"url":"https://example.com/hook"}`, []string{"events"}},
+		{"unknown event", `{"url":"https://example.com/hook","events":["created","archived"]}`, []string{"events"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := newJSONRequest(http.MethodPost, "/v1/webhooks", tt.body)
+			req.Header.Set("Authorization", "Bearer "+testToken(t, "ana"))
+
+			rr := httptest.NewRecorder()
+			api.router.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusUnprocessableEntity {
+				t.Fatalf("expected status %d, got %d", http.StatusUnprocessableEntity, rr.Code)
+			}
+
+			var body struct{ Errors ValidationErrors }
+			if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			if len(body.Errors) != len(tt.fields) || body.Errors[0].Field != tt.fields[0] {
+				t.Errorf("expected errors on %v, got %+v", tt.fields, body.Errors)
+			}
+		})
+	}
+
+	var hook webhook
+	api.do(t, "ana", http.MethodPost, "/v1/webhooks", `{"url":"https://example.com/hook","events":["completed","created","completed"]}`, &hook)
+	if strings.Join(hook.Events, ",") != "completed,created" {
+		t.Errorf("expected sorted unique events, got %v", hook.Events)
+	}
+
+	target := fmt.Sprintf("/v1/webhooks/%d", hook.ID)
+	if status := api.do(t, "bob", http.MethodGet, target, "", nil); status != http.StatusNotFound {
+		t.Errorf("expected another user to get %d, got %d", http.StatusNotFound, status)
+	}
+	if status := api.do(t, "ana", http.MethodGet, "/v1/webhooks/abc", "", nil); status != http.StatusBadRequest {
+		t.Errorf("expected status %d for a bad id, got %d", http.StatusBadRequest, status)
+	}
+
+	rr := httptest.NewRecorder()
+	api.router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, target, nil))
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected status %d without a token, got %d", http.StatusUnauthorized, rr.Code)
+	}
+}
+
+func TestWebhookDispatcherResubscribes(t *testing.T) {
+	api := startWebhookAPI(t, 1)
+
+	received := make(chan string, 2*subscriberBuffer)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Get("X-Webhook-Delivery")
+	}))
+	defer receiver.Close()
+
+	api.do(t, "ana", http.MethodPost, "/v1/webhooks", fmt.Sprintf(`{"url":%q,"events":["created"]}`, receiver.URL), nil)
+
+	// a burst bigger than the subscriber buffer gets the dispatcher dropped,
+	// it has to catch up from the history
+	count := subscriberBuffer + 8
+	for i := range count {
+		api.events.publish(eventCreated, Todo{ID: int64(i + 1), OwnerID: "ana"})
+	}
+
+	seen := map[string]bool{}
+	for len(seen) < count {
+		select {
+		case id := <-received:
+			seen[id] = true
+		case <-time.After(2 * time.Second):
+			t.Fatalf("expected %d deliveries, got %d", count, len(seen))
+		}
+	}
+}
+
+func TestWebhookDeliversInOrder(t *testing.T) {
+	api := startWebhookAPI(t, 1)
+
+	received := make(chan string, 20)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Get("X-Webhook-Delivery")
+	}))
+	defer receiver.Close()
+
+	api.do(t, "ana", http.MethodPost, "/v1/webhooks", fmt.Sprintf(`{"url":%q,"events":["created"]}`, receiver.URL), nil)
+
+	for i := range 20 {
+		api.events.publish(eventCreated, Todo{ID: int64(i + 1), OwnerID: "ana"})
+	}
+
+	for i := range 20 {
+		select {
+		case id := <-received:
+			if want := strconv.Itoa(i + 1); id != want {
+				t.Fatalf("expected delivery %s next, got %s", want, id)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("expected 20 deliveries, got %d", i)
+		}
+	}
+}
+
+func TestWebhookQueueIsBounded(t *testing.T) {
+	api := startWebhookAPI(t, 1)
+
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-release
+	}))
+	defer receiver.Close()
+	defer close(release)
+
+	api.do(t, "ana", http.MethodPost, "/v1/webhooks", fmt.Sprintf(`{"url":%q,"events":["created"]}`, receiver.URL), nil)
+
+	// the first delivery holds the worker, the queue fills up behind it
+	api.events.publish(eventCreated, Todo{ID: 1, OwnerID: "ana"})
+	<-started
+	for i := range maxWebhookQueueDepth + 3 {
+		api.events.publish(eventCreated, Todo{ID: int64(i + 2), OwnerID: "ana"})
+	}
+
+	waitFor(t, "the full queue to drop events", func() bool { return api.dispatcher.dropped.Load() == 3 })
+	if depth := api.dispatcher.queueDepth(); depth != maxWebhookQueueDepth+1 {
+		t.Errorf("expected %d pending deliveries, got %d", maxWebhookQueueDepth+1, depth)
+	}
+	if err := api.dispatcher.checkQueue(context.Background()); err == nil {
+		t.Error("expected the health check to report the full queue")
+	}
+}
+
+func TestPublicAddr(t *testing.T) {
+	tests := []struct {
+		addr   string
+		public bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"100.100.100.200", false},
+		{"fd00:ec2::254", false},
+		{"fe80::1", false},
+		{"0.0.0.0", false},
+		{"::ffff:127.0.0.1", false},
+		{"224.0.0.1", false},
+	}
+
+	for _, tt := range tests {
+		if got := publicAddr(netip.MustParseAddr(tt.addr)); got != tt.public {
+			t.Errorf("publicAddr(%s): expected %t, got %t", tt.addr, tt.public, got)
+		}
+	}
+}
+
+func TestWebhookRejectsPrivateTargets(t *testing.T) {
+	r := chi.NewRouter()
+	r.Mount("/webhooks", (&webhookHandler{registry: newWebhookRegistry()}).routes())
+
+	for _, target := range []string{
+		"http://127.0.0.1:8080/hook",
+		"http://[::1]/hook",
+		"http://localhost/hook",
+		"http://api.localhost./hook",
+		"http://10.0.0.5/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://[::ffff:192.168.0.1]/hook",
+	} {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, newJSONRequest(http.MethodPost, "/webhooks", fmt.Sprintf(`{"url":%q,"events":["created"]}`, target)))
+		if rr.Code != http.StatusUnprocessableEntity {
+			t.Errorf("%s: expected status %d, got %d", target, http.StatusUnprocessableEntity, rr.Code)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, newJSONRequest(http.MethodPost, "/webhooks", `{"url":"https://example.com/hook","events":["created"]}`))
+	if rr.Code != http.StatusCreated {
+		t.Errorf("expected status %d for a public host, got %d", http.StatusCreated, rr.Code)
+	}
+}
+
+// A name that passed validation can still resolve to an internal address
+// later on, the dial is refused then.
+func TestWebhookRefusesPrivateDial(t *testing.T) {
+	var calls atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+	}))
+	defer receiver.Close()
+
+	events := newTodoEvents()
+	registry := newWebhookRegistry()
+	hook := registry.add(webhook{URL: receiver.URL, Events: []string{eventCreated}})
+
+	dispatcher := newWebhookDispatcher(events, registry, 1, false)
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		dispatcher.run(ctx)
+		close(stopped)
+	}()
+	defer func() {
+		cancel()
+		<-stopped
+	}()
+	waitFor(t, "the dispatcher to subscribe", func() bool { return events.subscriberCount() == 1 })
+
+	events.publish(eventCreated, Todo{ID: 1})
+
+	var fetched webhook
+	waitFor(t, "the delivery to be recorded", func() bool {
+		fetched, _ = registry.get(hook.ID, "")
+		return fetched.LastDelivery != nil
+	})
+
+	if calls.Load() != 0 {
+		t.Errorf("expected the receiver never to be called, got %d calls", calls.Load())
+	}
+	if delivery := fetched.LastDelivery; delivery.Delivered || delivery.Error != "the receiver's address is not public" {
+		t.Errorf("unexpected last delivery %+v", delivery)
+	}
+}
+
+func TestWebhookLocation(t *testing.T) {
+	s := newRouterServer(t)
+
+	req := newJSONRequest(http.MethodPost, "/v1/webhooks", `{"url":"https://example.com/hook","events":["created"]}`)
+	req.Header.Set("Authorization", "Bearer "+s.tokens["ana"])
+	rr := httptest.NewRecorder()
+	s.app.router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d", http.StatusCreated, rr.Code)
+	}
+
+	// the seeded webhook is 1
+	location := rr.Header().Get("Location")
+	if location != "/v1/webhooks/2" {
+		t.Errorf("expected Location /v1/webhooks/2, got %q", location)
+	}
+	if status := s.do(t, http.MethodGet, routeRequest{as: "ana", target: location}); status != http.StatusOK {
+		t.Errorf("following the Location: expected status %d, got %d", http.StatusOK, status)
+	}
+}
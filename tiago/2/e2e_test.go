@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func newTestServer(t *testing.T, cfg config) *httptest.Server {
+	t.Helper()
+
+	ts := httptest.NewServer(newTestAPI(t, cfg).mount())
+	t.Cleanup(ts.Close)
+
+	return ts
+}
+
+// doRequest sends body as JSON (unless it is already a string) and decodes
+// the response into out when out is non-nil.
+func doRequest(t *testing.T, method, url string, body any, out any) *http.Response {
+	t.Helper()
+
+	var reader io.Reader
+	switch b := body.(type) {
+	case nil:
+	case string:
+		reader = strings.NewReader(b)
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reader != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("%s %s: could not decode response: %v", method, url, err)
+		}
+	}
+
+	return resp
+}
+
+type errorBody struct {
+	Error string `json:"error"`
+}
+
+func TestUserLifecycle(t *testing.T) {
+	ts := newTestServer(t, config{})
+	usersURL := ts.URL + "/users"
+
+	var created User
+	resp := doRequest(t, http.MethodPost, usersURL, createUserPayload{Username: "Tiago_S", FirstName: "Tiago", LastName: "Silva"}, &created)
+	checkResponseCode(t, http.StatusCreated, resp.StatusCode)
+
+	want := User{ID: 1, Username: "tiago_s", Status: statusActive, FirstName: "Tiago", LastName: "Silva"}
+	if diff := cmp.Diff(want, created); diff != "" {
+		t.Fatalf("created user mismatch (-want +got):\n%s", diff)
+	}
+
+	var list []User
+	resp = doRequest(t, http.MethodGet, usersURL, nil, &list)
+	checkResponseCode(t, http.StatusOK, resp.StatusCode)
+
+	if diff := cmp.Diff([]User{want}, list); diff != "" {
+		t.Fatalf("list mismatch (-want +got):\n%s", diff)
+	}
+
+	var fetched User
+	resp = doRequest(t, http.MethodGet, usersURL+"/1", nil, &fetched)
+	checkResponseCode(t, http.StatusOK, resp.StatusCode)
+
+	if diff := cmp.Diff(want, fetched); diff != "" {
+		t.Fatalf("fetched user mismatch (-want +got):\n%s", diff)
+	}
+
+	var updated User
+	resp = doRequest(t, http.MethodPatch, usersURL+"/1", map[string]string{"last_name": "Santos"}, &updated)
+	checkResponseCode(t, http.StatusOK, resp.StatusCode)
+
+	want.LastName = "Santos"
+	if diff := cmp.Diff(want, updated); diff != "" {
+		t.Fatalf("updated user mismatch (-want +got):\n%s", diff)
+	}
+
+	resp = doRequest(t, http.MethodDelete, usersURL+"/1", nil, nil)
+	checkResponseCode(t, http.StatusNoContent, resp.StatusCode)
+
+	var notFound errorBody
+	resp = doRequest(t, http.MethodGet, usersURL+"/1", nil, &notFound)
+	checkResponseCode(t, http.StatusNotFound, resp.StatusCode)
+
+	if diff := cmp.Diff(errorBody{Error: "not found"}, notFound); diff != "" {
+		t.Errorf("error body mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestUserErrorPaths(t *testing.T) {
+	ts := newTestServer(t, config{})
+
+	t.Run("bad json", func(t *testing.T) {
+		var body errorBody
+		resp := doRequest(t, http.MethodPost, ts.URL+"/users", `{"first_name":`, &body)
+		checkResponseCode(t, http.StatusBadRequest, resp.StatusCode)
+
+		if !strings.HasPrefix(body.Error, "error decoding payload") {
+			t.Errorf("unexpected error %q", body.Error)
+		}
+	})
+
+	t.Run("invalid id", func(t *testing.T) {
+		resp := doRequest(t, http.MethodGet, ts.URL+"/users/abc", nil, nil)
+		checkResponseCode(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("wrong method", func(t *testing.T) {
+		resp := doRequest(t, http.MethodPut, ts.URL+"/users", nil, nil)
+		checkResponseCode(t, http.StatusMethodNotAllowed, resp.StatusCode)
+	})
+
+	t.Run("unknown route", func(t *testing.T) {
+		resp := doRequest(t, http.MethodGet, ts.URL+"/nope", nil, nil)
+		checkResponseCode(t, http.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("each test gets a fresh store", func(t *testing.T) {
+		var list []User
+		doRequest(t, http.MethodGet, ts.URL+"/users", nil, &list)
+
+		if diff := cmp.Diff([]User{}, list); diff != "" {
+			t.Errorf("expected an empty store (-want +got):\n%s", diff)
+		}
+	})
+}
+
+func TestUsernames(t *testing.T) {
+	ts := newTestServer(t, config{})
+	usersURL := ts.URL + "/users"
+
+	for _, username := range []string{"alice", "bob"} {
+		resp := doRequest(t, http.MethodPost, usersURL, createUserPayload{Username: username}, nil)
+		checkResponseCode(t, http.StatusCreated, resp.StatusCode)
+	}
+
+	t.Run("conflict on create", func(t *testing.T) {
+		resp := doRequest(t, http.MethodPost, usersURL, createUserPayload{Username: "ALICE"}, nil)
+		checkResponseCode(t, http.StatusConflict, resp.StatusCode)
+	})
+
+	t.Run("invalid username", func(t *testing.T) {
+		for _, username := range []string{"al", "alice!", strings.Repeat("a", 31)} {
+			var body struct {
+				Fields map[string]string `json:"fields"`
+			}
+			resp := doRequest(t, http.MethodPost, usersURL, createUserPayload{Username: username}, &body)
+			checkResponseCode(t, http.StatusUnprocessableEntity, resp.StatusCode)
+
+			if body.Fields["username"] == "" {
+				t.Errorf("expected a username field error for %q", username)
+			}
+		}
+	})
+
+	t.Run("conflict on rename", func(t *testing.T) {
+		resp := doRequest(t, http.MethodPatch, usersURL+"/2", map[string]string{"username": "alice"}, nil)
+		checkResponseCode(t, http.StatusConflict, resp.StatusCode)
+	})
+
+	t.Run("rename frees the old username", func(t *testing.T) {
+		resp := doRequest(t, http.MethodPatch, usersURL+"/2", map[string]string{"username": "robert"}, nil)
+		checkResponseCode(t, http.StatusOK, resp.StatusCode)
+
+		resp = doRequest(t, http.MethodGet, usersURL+"/by-username/bob", nil, nil)
+		checkResponseCode(t, http.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("lookup by username", func(t *testing.T) {
+		var user User
+		resp := doRequest(t, http.MethodGet, usersURL+"/by-username/Robert", nil, &user)
+		checkResponseCode(t, http.StatusOK, resp.StatusCode)
+
+		if diff := cmp.Diff(User{ID: 2, Username: "robert", Status: statusActive}, user); diff != "" {
+			t.Errorf("lookup mismatch (-want +got):\n%s", diff)
+		}
+	})
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/yowger/golang-api-study/internal/apperror"
+)
+
+func (a *api) internalServerError(w http.ResponseWriter, r *http.Request, err error) {
+	log.Printf("internal error: method=%s path=%s error=%s", r.Method, r.URL.Path, err)
+
+	apperror.WriteError(w, apperror.Internal(err))
+}
+
+func (a *api) badRequestResponse(w http.ResponseWriter, r *http.Request, err error) {
+	log.Printf("bad request: method=%s path=%s error=%s", r.Method, r.URL.Path, err)
+
+	apperror.WriteError(w, apperror.BadRequest(err.Error()))
+}
+
+func (a *api) forbiddenResponse(w http.ResponseWriter, r *http.Request, err error) {
+	log.Printf("forbidden: method=%s path=%s error=%s", r.Method, r.URL.Path, err)
+
+	apperror.WriteError(w, apperror.Forbidden("forbidden"))
+}
+
+func (a *api) accountDeactivatedResponse(w http.ResponseWriter, r *http.Request) {
+	log.Printf("account deactivated: method=%s path=%s user=%s", r.Method, r.URL.Path, getUsernameFromContext(r))
+
+	apperror.WriteError(w, apperror.New(http.StatusForbidden, "account_deactivated", "this account has been deactivated"))
+}
+
+func (a *api) notFoundResponse(w http.ResponseWriter, r *http.Request, err error) {
+	log.Printf("not found: method=%s path=%s error=%s", r.Method, r.URL.Path, err)
+
+	apperror.WriteError(w, apperror.NotFound("not found"))
+}
+
+func (a *api) routeNotFoundResponse(w http.ResponseWriter, r *http.Request) {
+	log.Printf("route not found: method=%s path=%s", r.Method, r.URL.Path)
+
+	apperror.WriteError(w, apperror.NotFound("the requested resource could not be found"))
+}
+
+func (a *api) methodNotAllowedResponse(w http.ResponseWriter, r *http.Request) {
+	log.Printf("method not allowed: method=%s path=%s", r.Method, r.URL.Path)
+
+	apperror.WriteError(w, apperror.MethodNotAllowed(fmt.Sprintf("the %s method is not supported for this resource", r.Method)))
+}
+
+func (a *api) conflictResponse(w http.ResponseWriter, r *http.Request, err error) {
+	log.Printf("conflict: method=%s path=%s error=%s", r.Method, r.URL.Path, err)
+
+	apperror.WriteError(w, apperror.Conflict(err.Error()))
+}
+
+func (a *api) failedValidationResponse(w http.ResponseWriter, r *http.Request, errs validationErrors) {
+	log.Printf("failed validation: method=%s path=%s fields=%v", r.Method, r.URL.Path, errs)
+
+	apperror.WriteError(w, apperror.Validation(errs))
+}
+
+func (a *api) unsupportedMediaTypeResponse(w http.ResponseWriter, r *http.Request, contentType string) {
+	log.Printf("unsupported media type: method=%s path=%s content_type=%q", r.Method, r.URL.Path, contentType)
+
+	message := "missing content type, expected application/json"
+	if contentType != "" {
+		message = fmt.Sprintf("unsupported content type %s, expected application/json", contentType)
+	}
+
+	apperror.WriteError(w, apperror.New(http.StatusUnsupportedMediaType, "unsupported_media_type", message))
+}
+
+func (a *api) requestTooLargeResponse(w http.ResponseWriter, r *http.Request, err *http.MaxBytesError) {
+	log.Printf("request too large: method=%s path=%s limit=%d", r.Method, r.URL.Path, err.Limit)
+
+	message := fmt.Sprintf("request body must not be larger than %d bytes", err.Limit)
+	apperror.WriteError(w, apperror.New(http.StatusRequestEntityTooLarge, "request_too_large", message))
+}
+
+// decodeErrorResponse maps a readJSON failure to 413 when the body limit was
+// hit and to 400 for anything else the client sent.
+func (a *api) decodeErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		a.requestTooLargeResponse(w, r, maxBytesErr)
+		return
+	}
+
+	a.badRequestResponse(w, r, fmt.Errorf("error decoding payload: %w", err))
+}
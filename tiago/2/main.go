@@ -1,80 +1,187 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
 )
 
 type api struct {
-	addr string
+	addr   string
+	config config
+	store  *userStore
 }
 
-type User struct {
-	FirstName string `json:"first_name"`
-	LastName  string `json:"last_name"`
+type config struct {
+	auth    authConfig
+	tenancy tenancyConfig
+	// maxBodyBytes caps request bodies unless a route sets its own limit.
+	maxBodyBytes int64
 }
 
-var users = []User{}
+type tenancyConfig struct {
+	// enabled requires every request to carry an X-Tenant-ID from tenants.
+	enabled bool
+	tenants map[string]bool
+}
 
-func (a *api) getUserHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
+const defaultMaxBodyBytes = 256 << 10 // 256 KiB
 
-	newUsers := []User{{FirstName: "Tiago", LastName: "Silva"} 
-	users = append(users, newUsers...)
+const (
+	authModeNone  = "none"
+	authModeBasic = "basic"
+)
 
-	fmt.Println("Users: ", users)
+type authConfig struct {
+	// mode selects the middleware guarding the API: "none" or "basic".
+	mode  string
+	basic basicConfig
+}
 
-	if err := json.NewEncoder(w).Encode(users); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+type basicConfig struct {
+	user  string
+	pass  string
+	realm string
+}
 
-		return
+// validate refuses an unknown mode, which would otherwise leave the API
+// unguarded, and basic auth without credentials.
+func (c authConfig) validate() error {
+	switch c.mode {
+	case authModeNone:
+	case authModeBasic:
+		if c.basic.user == "" || c.basic.pass == "" {
+			return fmt.Errorf("AUTH_BASIC_USER and AUTH_BASIC_PASS must be set when AUTH_MODE is %q", authModeBasic)
+		}
+	default:
+		return fmt.Errorf("unknown AUTH_MODE %q, must be %q or %q", c.mode, authModeNone, authModeBasic)
 	}
 
-	w.WriteHeader(http.StatusOK)
+	return nil
 }
 
-/*
-	curl -X POST http://localhost:8080/users \
-     -H "Content-Type: application/json" \
-     -d '{"name": "John Doe"}'
-*/
+// validate checks the auth config, and the body limit: one of zero or less
+// would answer every request that has a body with a 413.
+func (c config) validate() error {
+	if err := c.auth.validate(); err != nil {
+		return err
+	}
 
-func (a *api) createUserHandler(w http.ResponseWriter, r *http.Request) {
-	var payloadUser User
+	if c.maxBodyBytes <= 0 {
+		return fmt.Errorf("MAX_BODY_BYTES must be positive, got %d", c.maxBodyBytes)
+	}
 
-	if err := json.NewDecoder(r.Body).Decode(&payloadUser); err != nil {
-		http.Error(w, fmt.Sprintf("error decoding payload: %s", err), http.StatusInternalServerError)
+	return nil
+}
 
-		return
+func (a *api) mount() http.Handler {
+	mux := http.NewServeMux()
+
+	// routes that accept larger uploads should wrap themselves with their own
+	// maxBytesMiddleware instead of relying on the default limit
+	limitBody := maxBytesMiddleware(a.config.maxBodyBytes)
+
+	mux.HandleFunc("GET /users", a.listUsersHandler)
+	mux.Handle("POST /users", limitBody(http.HandlerFunc(a.createUserHandler)))
+	mux.HandleFunc("GET /users/{id}", a.getUserHandler)
+	mux.HandleFunc("GET /users/by-username/{username}", a.getUserByUsernameHandler)
+	mux.Handle("PATCH /users/{id}", limitBody(http.HandlerFunc(a.updateUserHandler)))
+	mux.HandleFunc("DELETE /users/{id}", a.deleteUserHandler)
+	mux.HandleFunc("POST /users/{id}/deactivate", a.deactivateUserHandler)
+	mux.HandleFunc("POST /users/{id}/activate", a.activateUserHandler)
+	// "GET /users/{id}/preferences" would overlap with the by-username route
+	// without either being more specific, so GET sub-resources share one
+	// pattern and are dispatched by name
+	mux.HandleFunc("GET /users/{id}/{resource}", a.getUserResourceHandler)
+	mux.Handle("PUT /users/{id}/preferences", limitBody(http.HandlerFunc(a.updatePreferencesHandler)))
+
+	var handler http.Handler = a.requireJSONContentType(a.jsonFallbackMiddleware(mux))
+
+	if a.config.auth.mode != authModeNone {
+		handler = a.activeAccountMiddleware(handler)
 	}
 
-	u := User{
-		FirstName: payloadUser.FirstName,
-		LastName:  payloadUser.LastName,
+	if a.config.tenancy.enabled {
+		handler = a.tenantMiddleware(handler)
 	}
 
-	users = append(users, u)
+	switch a.config.auth.mode {
+	case authModeBasic:
+		handler = a.basicAuthMiddleware(handler)
+	}
 
-	w.WriteHeader(http.StatusCreated)
+	return handler
 }
 
 func main() {
-	api := &api{addr: ":8080"}
+	api := &api{
+		addr: ":8080",
+		config: config{
+			auth: authConfig{
+				mode: getEnv("AUTH_MODE", authModeNone),
+				basic: basicConfig{
+					user:  getEnv("AUTH_BASIC_USER", ""),
+					pass:  getEnv("AUTH_BASIC_PASS", ""),
+					realm: getEnv("AUTH_BASIC_REALM", "users"),
+				},
+			},
+			tenancy:      tenancyFromEnv(getEnv("TENANTS", "")),
+			maxBodyBytes: getEnvInt64("MAX_BODY_BYTES", defaultMaxBodyBytes),
+		},
+		store: newUserStore(),
+	}
 
-	mux := http.NewServeMux()
+	if err := api.config.validate(); err != nil {
+		log.Fatal(err)
+	}
 
 	srv := &http.Server{
 		Addr:    api.addr,
-		Handler: mux,
+		Handler: api.mount(),
 	}
 
-	mux.HandleFunc("GET /users", api.getUserHandler)
-	mux.HandleFunc("POST /users", api.createUserHandler)
-
 	if err := srv.ListenAndServe(); err != nil {
 		log.Fatal("Error starting server: ", err)
 	}
 
 }
+
+// tenancyFromEnv enables tenancy when the comma separated list is non-empty.
+func tenancyFromEnv(list string) tenancyConfig {
+	cfg := tenancyConfig{tenants: make(map[string]bool)}
+
+	for _, tenant := range strings.Split(list, ",") {
+		if tenant = strings.TrimSpace(tenant); tenant != "" {
+			cfg.tenants[tenant] = true
+		}
+	}
+
+	cfg.enabled = len(cfg.tenants) > 0
+
+	return cfg
+}
+
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+
+	return fallback
+}
+
+func getEnvInt64(key string, fallback int64) int64 {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return fallback
+	}
+
+	return n
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/yowger/golang-api-study/internal/apperror"
+)
+
+type contextKey string
+
+const (
+	usernameCtx contextKey = "username"
+	tenantCtx   contextKey = "tenant"
+)
+
+// basicAuthMiddleware checks the Authorization: Basic header against the
+// configured credentials and stores the username in the request context.
+func (a *api) basicAuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authHeader := r.Header.Get("Authorization")
+		if authHeader == "" {
+			a.unauthorizedBasicResponse(w, r, fmt.Errorf("authorization header is missing"))
+			return
+		}
+
+		scheme, encoded, ok := strings.Cut(authHeader, " ")
+		if !ok || !strings.EqualFold(scheme, "Basic") {
+			a.unauthorizedBasicResponse(w, r, fmt.Errorf("authorization header is malformed"))
+			return
+		}
+
+		decoded, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			a.unauthorizedBasicResponse(w, r, err)
+			return
+		}
+
+		username, password, ok := strings.Cut(string(decoded), ":")
+		if !ok {
+			a.unauthorizedBasicResponse(w, r, fmt.Errorf("credentials are malformed"))
+			return
+		}
+
+		// compare both values every time so the response time doesn't reveal
+		// which of the two was wrong
+		userMatch := subtle.ConstantTimeCompare([]byte(username), []byte(a.config.auth.basic.user))
+		passMatch := subtle.ConstantTimeCompare([]byte(password), []byte(a.config.auth.basic.pass))
+		if userMatch&passMatch != 1 {
+			a.unauthorizedBasicResponse(w, r, fmt.Errorf("invalid credentials"))
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), usernameCtx, username)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func (a *api) unauthorizedBasicResponse(w http.ResponseWriter, r *http.Request, err error) {
+	log.Printf("unauthorized basic error: method=%s path=%s error=%s", r.Method, r.URL.Path, err)
+
+	w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Basic realm=%q, charset="UTF-8"`, a.config.auth.basic.realm))
+
+	apperror.WriteError(w, apperror.New(http.StatusUnauthorized, "unauthorized", "unauthorized"))
+}
+
+func getUsernameFromContext(r *http.Request) string {
+	username, _ := r.Context().Value(usernameCtx).(string)
+	return username
+}
+
+// requireJSONContentType rejects requests that carry a body with anything
+// other than application/json. Safe methods and empty bodies are exempt.
+func (a *api) requireJSONContentType(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if r.ContentLength == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		contentType := r.Header.Get("Content-Type")
+
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err != nil || mediaType != "application/json" {
+			a.unsupportedMediaTypeResponse(w, r, contentType)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// maxBytesMiddleware caps the request body at limit bytes before the handler
+// starts decoding it.
+func maxBytesMiddleware(limit int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// tenantMiddleware requires a known X-Tenant-ID header and stores the tenant
+// in the request context so every store call is scoped to it.
+func (a *api) tenantMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenant := r.Header.Get("X-Tenant-ID")
+		if tenant == "" {
+			a.badRequestResponse(w, r, fmt.Errorf("X-Tenant-ID header is missing"))
+			return
+		}
+
+		if !a.config.tenancy.tenants[tenant] {
+			a.forbiddenResponse(w, r, fmt.Errorf("unknown tenant %q", tenant))
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), tenantCtx, tenant)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// getTenantFromContext returns the caller's tenant, or "" when tenancy is
+// disabled and every user lives in the same partition.
+func getTenantFromContext(r *http.Request) string {
+	tenant, _ := r.Context().Value(tenantCtx).(string)
+	return tenant
+}
+
+// activeAccountMiddleware refuses logins of users that have been deactivated.
+// Authenticated names without a matching user are let through untouched.
+func (a *api) activeAccountMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username := getUsernameFromContext(r)
+		if username == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		user, err := a.store.GetByUsername(getTenantFromContext(r), normalizeUsername(username))
+		if err == nil && user.Status == statusDeactivated {
+			a.accountDeactivatedResponse(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// jsonFallbackMiddleware serves the mux, replacing its plain text 404 and 405
+// pages with the JSON error envelope. Requests that match a pattern are passed
+// through untouched so handlers keep full control over their own responses.
+func (a *api) jsonFallbackMiddleware(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, pattern := mux.Handler(r); pattern != "" {
+			mux.ServeHTTP(w, r)
+			return
+		}
+
+		fw := &fallbackWriter{ResponseWriter: w}
+		mux.ServeHTTP(fw, r)
+
+		switch fw.status {
+		case http.StatusNotFound:
+			a.routeNotFoundResponse(w, r)
+		case http.StatusMethodNotAllowed:
+			// the mux has already set the Allow header on w
+			a.methodNotAllowedResponse(w, r)
+		}
+	})
+}
+
+// fallbackWriter swallows the 404 and 405 responses written by the mux so
+// they can be replaced, forwarding anything else (like redirects) as is.
+type fallbackWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (fw *fallbackWriter) WriteHeader(status int) {
+	if status == http.StatusNotFound || status == http.StatusMethodNotAllowed {
+		fw.status = status
+		return
+	}
+
+	fw.ResponseWriter.WriteHeader(status)
+}
+
+func (fw *fallbackWriter) Write(b []byte) (int, error) {
+	if fw.status != 0 {
+		return len(b), nil
+	}
+
+	return fw.ResponseWriter.Write(b)
+}
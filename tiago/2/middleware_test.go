@@ -0,0 +1,202 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBasicAuthMiddleware(t *testing.T) {
+	a := &api{
+		config: config{
+			auth: authConfig{
+				mode:  authModeBasic,
+				basic: basicConfig{user: "admin", pass: "secret", realm: "users"},
+			},
+		},
+	}
+
+	handler := a.basicAuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(getUsernameFromContext(r)))
+	}))
+
+	basic := func(creds string) string {
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(creds))
+	}
+
+	tests := []struct {
+		name   string
+		header string
+		status int
+	}{
+		{name: "missing header", header: "", status: http.StatusUnauthorized},
+		{name: "bad base64", header: "Basic not-base64!", status: http.StatusUnauthorized},
+		{name: "wrong password", header: basic("admin:wrong"), status: http.StatusUnauthorized},
+		{name: "success", header: basic("admin:secret"), status: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/users", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.status {
+				t.Fatalf("expected status %d, got %d", tt.status, rr.Code)
+			}
+
+			if tt.status == http.StatusUnauthorized {
+				if got := rr.Header().Get("WWW-Authenticate"); got != `Basic realm="users", charset="UTF-8"` {
+					t.Errorf("unexpected WWW-Authenticate header %q", got)
+				}
+				return
+			}
+
+			if got := rr.Body.String(); got != "admin" {
+				t.Errorf("expected username in context, got %q", got)
+			}
+		})
+	}
+}
+
+func TestAuthConfigValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		auth  authConfig
+		valid bool
+	}{
+		{name: "none", auth: authConfig{mode: authModeNone}, valid: true},
+		{name: "basic", auth: authConfig{mode: authModeBasic, basic: basicConfig{user: "admin", pass: "secret"}}, valid: true},
+		{name: "basic without user", auth: authConfig{mode: authModeBasic, basic: basicConfig{pass: "secret"}}},
+		{name: "basic without password", auth: authConfig{mode: authModeBasic, basic: basicConfig{user: "admin"}}},
+		{name: "unknown mode", auth: authConfig{mode: "bearer"}},
+		{name: "empty mode", auth: authConfig{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.auth.validate(); (err == nil) != tt.valid {
+				t.Errorf("expected valid %t, got %v", tt.valid, err)
+			}
+		})
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	auth := authConfig{mode: authModeNone}
+
+	tests := []struct {
+		name   string
+		config config
+		valid  bool
+	}{
+		{name: "default body limit", config: config{auth: auth, maxBodyBytes: defaultMaxBodyBytes}, valid: true},
+		{name: "zero body limit", config: config{auth: auth}},
+		{name: "negative body limit", config: config{auth: auth, maxBodyBytes: -1}},
+		{name: "bad auth", config: config{auth: authConfig{mode: "bearer"}, maxBodyBytes: defaultMaxBodyBytes}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.config.validate(); (err == nil) != tt.valid {
+				t.Errorf("expected valid %t, got %v", tt.valid, err)
+			}
+		})
+	}
+}
+
+func TestRequireJSONContentType(t *testing.T) {
+	handler := newTestAPI(t, config{}).requireJSONContentType(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	tests := []struct {
+		name        string
+		method      string
+		contentType string
+		body        string
+		status      int
+	}{
+		{name: "json with charset", method: http.MethodPost, contentType: "application/json; charset=utf-8", body: `{"first_name":"Ada"}`, status: http.StatusCreated},
+		{name: "text/plain", method: http.MethodPost, contentType: "text/plain", body: `{"first_name":"Ada"}`, status: http.StatusUnsupportedMediaType},
+		{name: "missing header with body", method: http.MethodPost, body: `{"first_name":"Ada"}`, status: http.StatusUnsupportedMediaType},
+		{name: "empty body", method: http.MethodPost, status: http.StatusCreated},
+		{name: "get is exempt", method: http.MethodGet, contentType: "text/plain", body: "ignored", status: http.StatusCreated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/users", strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.status {
+				t.Fatalf("expected status %d, got %d", tt.status, rr.Code)
+			}
+
+			if tt.status == http.StatusUnsupportedMediaType && tt.contentType != "" && !strings.Contains(rr.Body.String(), tt.contentType) {
+				t.Errorf("expected error to name the received type, got %s", rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestJSONFallback(t *testing.T) {
+	mux := newTestAPI(t, config{}).mount()
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		status int
+		allow  string
+	}{
+		{name: "unknown path", method: http.MethodGet, path: "/nonexistent", status: http.StatusNotFound},
+		{name: "nested unknown path", method: http.MethodGet, path: "/users/1/preferences/extra", status: http.StatusNotFound},
+		{name: "method not allowed", method: http.MethodPut, path: "/users", status: http.StatusMethodNotAllowed, allow: "GET, HEAD, POST"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			mux.ServeHTTP(rr, httptest.NewRequest(tt.method, tt.path, nil))
+
+			checkResponseCode(t, tt.status, rr.Code)
+
+			if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("expected application/json, got %q", ct)
+			}
+
+			var body struct {
+				Error string `json:"error"`
+			}
+			if err := json.NewDecoder(rr.Body).Decode(&body); err != nil || body.Error == "" {
+				t.Errorf("expected a JSON error envelope, got err=%v body=%+v", err, body)
+			}
+
+			if got := rr.Header().Get("Allow"); got != tt.allow {
+				t.Errorf("expected Allow %q, got %q", tt.allow, got)
+			}
+		})
+	}
+
+	// handler 404s keep their own message
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/users/42", nil))
+	checkResponseCode(t, http.StatusNotFound, rr.Code)
+
+	if got := rr.Body.String(); got != `{"error":"not found","code":"not_found"}`+"\n" {
+		t.Errorf("unexpected body %s", got)
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestUserStatusListFiltering(t *testing.T) {
+	ts := newTestServer(t, config{})
+	usersURL := ts.URL + "/users"
+
+	for _, username := range []string{"ana", "bia", "caio"} {
+		doRequest(t, http.MethodPost, usersURL, createUserPayload{Username: username}, nil)
+	}
+
+	// deactivating twice is idempotent
+	for i := 0; i < 2; i++ {
+		var user User
+		resp := doRequest(t, http.MethodPost, usersURL+"/2/deactivate", nil, &user)
+		checkResponseCode(t, http.StatusOK, resp.StatusCode)
+
+		if user.Status != statusDeactivated {
+			t.Fatalf("expected deactivated, got %q", user.Status)
+		}
+	}
+
+	usernames := func(query string) []string {
+		var users []User
+		doRequest(t, http.MethodGet, usersURL+query, nil, &users)
+
+		names := []string{}
+		for _, u := range users {
+			names = append(names, u.Username)
+		}
+		return names
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{query: "", want: []string{"ana", "caio"}},
+		{query: "?status=deactivated", want: []string{"bia"}},
+		{query: "?status=all", want: []string{"ana", "bia", "caio"}},
+	}
+
+	for _, tt := range tests {
+		if got := usernames(tt.query); !equalStrings(got, tt.want) {
+			t.Errorf("GET /users%s: expected %v, got %v", tt.query, tt.want, got)
+		}
+	}
+
+	resp := doRequest(t, http.MethodGet, usersURL+"?status=banned", nil, nil)
+	checkResponseCode(t, http.StatusBadRequest, resp.StatusCode)
+
+	var user User
+	resp = doRequest(t, http.MethodPost, usersURL+"/2/activate", nil, &user)
+	checkResponseCode(t, http.StatusOK, resp.StatusCode)
+
+	if got := usernames(""); !equalStrings(got, []string{"ana", "bia", "caio"}) {
+		t.Errorf("expected bia back in the default list, got %v", got)
+	}
+}
+
+func TestDeactivatedUserLogin(t *testing.T) {
+	a := newTestAPI(t, config{
+		auth: authConfig{
+			mode:  authModeBasic,
+			basic: basicConfig{user: "wile", pass: "acme", realm: "users"},
+		},
+	})
+	mux := a.mount()
+
+	if err := a.store.Create("", &User{Username: "wile", Status: statusActive}); err != nil {
+		t.Fatal(err)
+	}
+
+	login := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/users", nil)
+		req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte("wile:acme")))
+
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+
+		return rr
+	}
+
+	checkResponseCode(t, http.StatusOK, login().Code)
+
+	if _, err := a.store.SetStatus("", 1, statusDeactivated); err != nil {
+		t.Fatal(err)
+	}
+
+	rr := login()
+	checkResponseCode(t, http.StatusForbidden, rr.Code)
+
+	if body := rr.Body.String(); body != `{"error":"this account has been deactivated","code":"account_deactivated"}`+"\n" {
+		t.Errorf("unexpected body %s", body)
+	}
+}
+
+// Update patches the user as stored when it takes the lock, a deactivation
+// that lands after the handler read the user isn't written back.
+func TestUpdateKeepsStatus(t *testing.T) {
+	store := newUserStore()
+	if err := store.Create("", &User{Username: "wile", Status: statusActive}); err != nil {
+		t.Fatal(err)
+	}
+
+	stale, _ := store.GetByID("", 1)
+	if _, err := store.SetStatus("", 1, statusDeactivated); err != nil {
+		t.Fatal(err)
+	}
+
+	user, err := store.Update("", stale.ID, func(user *User) error {
+		user.FirstName = "Wile"
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Status != statusDeactivated || user.FirstName != "Wile" {
+		t.Errorf("expected the deactivated user renamed, got %+v", user)
+	}
+
+	// a patch that fails leaves the user alone
+	_, err = store.Update("", 1, func(user *User) error {
+		user.FirstName = "Coyote"
+		return validationErrors{"first_name": "is taken"}
+	})
+	if _, ok := err.(validationErrors); !ok {
+		t.Fatalf("expected the patch error back, got %v", err)
+	}
+	if user, _ := store.GetByID("", 1); user.FirstName != "Wile" {
+		t.Errorf("expected the failed patch discarded, got %+v", user)
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"errors"
+	"sort"
+	"sync"
+)
+
+var (
+	ErrNotFound = errors.New("resource not found")
+	ErrConflict = errors.New("resource already exists")
+)
+
+// userPartition holds the users of a single tenant.
+type userPartition struct {
+	users map[int64]User
+	// byUsername is a secondary index kept in sync with users under the
+	// store lock.
+	byUsername map[string]int64
+}
+
+// userStore partitions users per tenant. Every method is scoped to one tenant
+// and never sees the users of another, so a cross-tenant lookup is simply
+// ErrNotFound. IDs are unique across tenants.
+type userStore struct {
+	mu         sync.RWMutex
+	nextID     int64
+	partitions map[string]*userPartition
+}
+
+type userFilter struct {
+	// status is a stored status or statusAll; empty matches everyone.
+	status string
+	// hasPhone keeps only users with (true) or without (false) a phone
+	// number when set.
+	hasPhone *bool
+}
+
+func (f userFilter) matches(u *User) bool {
+	if f.status != "" && f.status != statusAll && u.Status != f.status {
+		return false
+	}
+
+	if f.hasPhone != nil && (u.Phone != "") != *f.hasPhone {
+		return false
+	}
+
+	return true
+}
+
+func newUserStore() *userStore {
+	return &userStore{
+		nextID:     1,
+		partitions: make(map[string]*userPartition),
+	}
+}
+
+// partition returns the tenant's partition, creating it when create is true.
+// Callers must hold the lock matching create.
+func (s *userStore) partition(tenant string, create bool) *userPartition {
+	p, ok := s.partitions[tenant]
+	if !ok && create {
+		p = &userPartition{
+			users:      make(map[int64]User),
+			byUsername: make(map[string]int64),
+		}
+		s.partitions[tenant] = p
+	}
+
+	return p
+}
+
+func (s *userStore) Create(tenant string, user *User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := s.partition(tenant, true)
+
+	if _, taken := p.byUsername[user.Username]; taken {
+		return ErrConflict
+	}
+
+	user.ID = s.nextID
+	s.nextID++
+
+	p.users[user.ID] = *user
+	p.byUsername[user.Username] = user.ID
+
+	return nil
+}
+
+func (s *userStore) List(tenant string, filter userFilter) []User {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	users := []User{}
+
+	p := s.partition(tenant, false)
+	if p == nil {
+		return users
+	}
+
+	for _, u := range p.users {
+		if filter.matches(&u) {
+			users = append(users, u)
+		}
+	}
+
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+
+	return users
+}
+
+func (s *userStore) GetByID(tenant string, id int64) (User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	p := s.partition(tenant, false)
+	if p == nil {
+		return User{}, ErrNotFound
+	}
+
+	user, ok := p.users[id]
+	if !ok {
+		return User{}, ErrNotFound
+	}
+
+	return user, nil
+}
+
+func (s *userStore) GetByUsername(tenant, username string) (User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	p := s.partition(tenant, false)
+	if p == nil {
+		return User{}, ErrNotFound
+	}
+
+	id, ok := p.byUsername[username]
+	if !ok {
+		return User{}, ErrNotFound
+	}
+
+	return p.users[id], nil
+}
+
+// Update applies patch to the stored user and saves the result, all under the
+// store lock, so a change made in between, like SetStatus, isn't lost. An
+// error from patch is returned as is and leaves the user untouched.
+func (s *userStore) Update(tenant string, id int64, patch func(*User) error) (User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := s.partition(tenant, false)
+	if p == nil {
+		return User{}, ErrNotFound
+	}
+
+	current, ok := p.users[id]
+	if !ok {
+		return User{}, ErrNotFound
+	}
+
+	user := current
+	if err := patch(&user); err != nil {
+		return User{}, err
+	}
+	user.ID = id
+
+	if user.Username != current.Username {
+		if _, taken := p.byUsername[user.Username]; taken {
+			return User{}, ErrConflict
+		}
+
+		delete(p.byUsername, current.Username)
+		p.byUsername[user.Username] = id
+	}
+
+	p.users[id] = user
+
+	return user, nil
+}
+
+func (s *userStore) SetStatus(tenant string, id int64, status string) (User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := s.partition(tenant, false)
+	if p == nil {
+		return User{}, ErrNotFound
+	}
+
+	user, ok := p.users[id]
+	if !ok {
+		return User{}, ErrNotFound
+	}
+
+	user.Status = status
+	p.users[id] = user
+
+	return user, nil
+}
+
+func (s *userStore) Delete(tenant string, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := s.partition(tenant, false)
+	if p == nil {
+		return ErrNotFound
+	}
+
+	user, ok := p.users[id]
+	if !ok {
+		return ErrNotFound
+	}
+
+	delete(p.users, id)
+	delete(p.byUsername, user.Username)
+
+	return nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTenantIsolation(t *testing.T) {
+	a := newTestAPI(t, config{tenancy: tenancyFromEnv("acme,globex")})
+	mux := a.mount()
+
+	request := func(tenant, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if tenant != "" {
+			req.Header.Set("X-Tenant-ID", tenant)
+		}
+
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+
+		return rr
+	}
+
+	checkResponseCode(t, http.StatusCreated, request("acme", http.MethodPost, "/users", `{"username":"wile"}`).Code)
+	checkResponseCode(t, http.StatusCreated, request("globex", http.MethodPost, "/users", `{"username":"hank"}`).Code)
+	// usernames are only unique within a tenant
+	checkResponseCode(t, http.StatusCreated, request("globex", http.MethodPost, "/users", `{"username":"wile"}`).Code)
+
+	list := func(tenant string) []User {
+		var users []User
+		if err := json.NewDecoder(request(tenant, http.MethodGet, "/users", "").Body).Decode(&users); err != nil {
+			t.Fatal(err)
+		}
+		return users
+	}
+
+	if users := list("acme"); len(users) != 1 || users[0].ID != 1 {
+		t.Errorf("acme should only see user 1, got %+v", users)
+	}
+
+	if users := list("globex"); len(users) != 2 || users[0].ID != 2 || users[1].ID != 3 {
+		t.Errorf("globex should only see users 2 and 3, got %+v", users)
+	}
+
+	t.Run("cross-tenant lookups 404", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNotFound, request("acme", http.MethodGet, "/users/2", "").Code)
+		checkResponseCode(t, http.StatusNotFound, request("globex", http.MethodGet, "/users/1", "").Code)
+		checkResponseCode(t, http.StatusNotFound, request("globex", http.MethodPatch, "/users/1", `{"first_name":"x"}`).Code)
+		checkResponseCode(t, http.StatusNotFound, request("acme", http.MethodDelete, "/users/3", "").Code)
+		checkResponseCode(t, http.StatusNotFound, request("acme", http.MethodGet, "/users/by-username/hank", "").Code)
+	})
+
+	t.Run("missing tenant", func(t *testing.T) {
+		checkResponseCode(t, http.StatusBadRequest, request("", http.MethodGet, "/users", "").Code)
+	})
+
+	t.Run("unknown tenant", func(t *testing.T) {
+		checkResponseCode(t, http.StatusForbidden, request("initech", http.MethodGet, "/users", "").Code)
+	})
+}
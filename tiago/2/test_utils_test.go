@@ -0,0 +1,26 @@
+package main
+
+import (
+	"testing"
+)
+
+func newTestAPI(t *testing.T, cfg config) *api {
+	t.Helper()
+
+	if cfg.maxBodyBytes == 0 {
+		cfg.maxBodyBytes = defaultMaxBodyBytes
+	}
+
+	return &api{
+		config: cfg,
+		store:  newUserStore(),
+	}
+}
+
+func checkResponseCode(t *testing.T, expected, actual int) {
+	t.Helper()
+
+	if expected != actual {
+		t.Errorf("Expected response code %d. Got %d", expected, actual)
+	}
+}
@@ -0,0 +1,337 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+const (
+	statusActive      = "active"
+	statusDeactivated = "deactivated"
+	// statusAll is only a list filter, never a stored status.
+	statusAll = "all"
+)
+
+type User struct {
+	ID        int64  `json:"id"`
+	Username  string `json:"username"`
+	Status    string `json:"status"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	// Phone is stored in E.164 format, e.g. +5511987654321.
+	Phone string `json:"phone,omitempty"`
+	// Preferences is nil until the user saves them; readers should fall back
+	// to defaultPreferences.
+	Preferences *Preferences `json:"preferences,omitempty"`
+}
+
+type Preferences struct {
+	Locale     string `json:"locale"`
+	Theme      string `json:"theme"`
+	Newsletter bool   `json:"newsletter"`
+}
+
+func defaultPreferences() Preferences {
+	return Preferences{
+		Locale:     "en",
+		Theme:      "system",
+		Newsletter: false,
+	}
+}
+
+func (a *api) listUsersHandler(w http.ResponseWriter, r *http.Request) {
+	filter := userFilter{status: statusActive}
+
+	switch status := r.URL.Query().Get("status"); status {
+	case "":
+	case statusActive, statusDeactivated, statusAll:
+		filter.status = status
+	default:
+		a.badRequestResponse(w, r, fmt.Errorf("status must be one of active, deactivated, all"))
+		return
+	}
+
+	if value := r.URL.Query().Get("has_phone"); value != "" {
+		hasPhone, err := strconv.ParseBool(value)
+		if err != nil {
+			a.badRequestResponse(w, r, fmt.Errorf("has_phone must be true or false"))
+			return
+		}
+
+		filter.hasPhone = &hasPhone
+	}
+
+	if err := writeJSON(w, http.StatusOK, a.store.List(getTenantFromContext(r), filter)); err != nil {
+		a.internalServerError(w, r, err)
+	}
+}
+
+/*
+	curl -X POST http://localhost:8080/users \
+     -H "Content-Type: application/json" \
+     -d '{"username": "john_doe", "first_name": "John", "last_name": "Doe"}'
+*/
+
+type createUserPayload struct {
+	Username  string `json:"username"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Phone     string `json:"phone"`
+}
+
+func (a *api) createUserHandler(w http.ResponseWriter, r *http.Request) {
+	var payload createUserPayload
+	if err := readJSON(r, &payload); err != nil {
+		a.decodeErrorResponse(w, r, err)
+		return
+	}
+
+	user := &User{
+		Username:  normalizeUsername(payload.Username),
+		Status:    statusActive,
+		FirstName: payload.FirstName,
+		LastName:  payload.LastName,
+		Phone:     normalizePhone(payload.Phone),
+	}
+
+	if errs := user.validate(); len(errs) > 0 {
+		a.failedValidationResponse(w, r, errs)
+		return
+	}
+
+	if err := a.store.Create(getTenantFromContext(r), user); err != nil {
+		a.storeErrorResponse(w, r, err)
+		return
+	}
+
+	if err := writeJSON(w, http.StatusCreated, user); err != nil {
+		a.internalServerError(w, r, err)
+	}
+}
+
+func (a *api) getUserHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := parseUserID(r)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	user, err := a.store.GetByID(getTenantFromContext(r), id)
+	if err != nil {
+		a.storeErrorResponse(w, r, err)
+		return
+	}
+
+	if err := writeJSON(w, http.StatusOK, user); err != nil {
+		a.internalServerError(w, r, err)
+	}
+}
+
+func (a *api) getUserByUsernameHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := a.store.GetByUsername(getTenantFromContext(r), normalizeUsername(r.PathValue("username")))
+	if err != nil {
+		a.storeErrorResponse(w, r, err)
+		return
+	}
+
+	if err := writeJSON(w, http.StatusOK, user); err != nil {
+		a.internalServerError(w, r, err)
+	}
+}
+
+type updateUserPayload struct {
+	Username  *string `json:"username"`
+	FirstName *string `json:"first_name"`
+	LastName  *string `json:"last_name"`
+	// Phone clears the stored number when set to an empty string.
+	Phone *string `json:"phone"`
+}
+
+func (a *api) updateUserHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := parseUserID(r)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	var payload updateUserPayload
+	if err := readJSON(r, &payload); err != nil {
+		a.decodeErrorResponse(w, r, err)
+		return
+	}
+
+	user, err := a.store.Update(getTenantFromContext(r), id, func(user *User) error {
+		if payload.Username != nil {
+			user.Username = normalizeUsername(*payload.Username)
+		}
+
+		if payload.FirstName != nil {
+			user.FirstName = *payload.FirstName
+		}
+
+		if payload.LastName != nil {
+			user.LastName = *payload.LastName
+		}
+
+		if payload.Phone != nil {
+			user.Phone = normalizePhone(*payload.Phone)
+		}
+
+		if errs := user.validate(); len(errs) > 0 {
+			return errs
+		}
+
+		return nil
+	})
+	if err != nil {
+		a.updateErrorResponse(w, r, err)
+		return
+	}
+
+	if err := writeJSON(w, http.StatusOK, user); err != nil {
+		a.internalServerError(w, r, err)
+	}
+}
+
+func (a *api) getUserResourceHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.PathValue("resource") {
+	case "preferences":
+		a.getPreferencesHandler(w, r)
+	default:
+		a.notFoundResponse(w, r, fmt.Errorf("unknown user resource %q", r.PathValue("resource")))
+	}
+}
+
+func (a *api) getPreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := parseUserID(r)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	user, err := a.store.GetByID(getTenantFromContext(r), id)
+	if err != nil {
+		a.storeErrorResponse(w, r, err)
+		return
+	}
+
+	prefs := defaultPreferences()
+	if user.Preferences != nil {
+		prefs = *user.Preferences
+	}
+
+	if err := writeJSON(w, http.StatusOK, prefs); err != nil {
+		a.internalServerError(w, r, err)
+	}
+}
+
+func (a *api) updatePreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := parseUserID(r)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	var prefs Preferences
+	if err := readJSON(r, &prefs); err != nil {
+		a.decodeErrorResponse(w, r, err)
+		return
+	}
+
+	if errs := prefs.validate(); len(errs) > 0 {
+		a.failedValidationResponse(w, r, errs)
+		return
+	}
+
+	_, err = a.store.Update(getTenantFromContext(r), id, func(user *User) error {
+		user.Preferences = &prefs
+		return nil
+	})
+	if err != nil {
+		a.storeErrorResponse(w, r, err)
+		return
+	}
+
+	if err := writeJSON(w, http.StatusOK, prefs); err != nil {
+		a.internalServerError(w, r, err)
+	}
+}
+
+// deactivateUserHandler and activateUserHandler are idempotent: repeating
+// them returns 200 with the current state.
+//
+// TODO: restrict both to admins once the API has roles.
+func (a *api) deactivateUserHandler(w http.ResponseWriter, r *http.Request) {
+	a.setUserStatus(w, r, statusDeactivated)
+}
+
+func (a *api) activateUserHandler(w http.ResponseWriter, r *http.Request) {
+	a.setUserStatus(w, r, statusActive)
+}
+
+func (a *api) setUserStatus(w http.ResponseWriter, r *http.Request, status string) {
+	id, err := parseUserID(r)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	user, err := a.store.SetStatus(getTenantFromContext(r), id, status)
+	if err != nil {
+		a.storeErrorResponse(w, r, err)
+		return
+	}
+
+	if err := writeJSON(w, http.StatusOK, user); err != nil {
+		a.internalServerError(w, r, err)
+	}
+}
+
+func (a *api) deleteUserHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := parseUserID(r)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := a.store.Delete(getTenantFromContext(r), id); err != nil {
+		a.storeErrorResponse(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func parseUserID(r *http.Request) (int64, error) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id < 1 {
+		return 0, fmt.Errorf("invalid user id %q", r.PathValue("id"))
+	}
+
+	return id, nil
+}
+
+// updateErrorResponse is storeErrorResponse for the errors of a store Update
+// whose patch validates the user.
+func (a *api) updateErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	var errs validationErrors
+	if errors.As(err, &errs) {
+		a.failedValidationResponse(w, r, errs)
+		return
+	}
+
+	a.storeErrorResponse(w, r, err)
+}
+
+func (a *api) storeErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		a.notFoundResponse(w, r, err)
+	case errors.Is(err, ErrConflict):
+		a.conflictResponse(w, r, err)
+	default:
+		a.internalServerError(w, r, err)
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCreateUserBodyTooLarge(t *testing.T) {
+	a := newTestAPI(t, config{maxBodyBytes: 64})
+
+	body := `{"username":"tiago","first_name":"` + strings.Repeat("a", 128) + `","last_name":"Silva"}`
+	req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+	a.mount().ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected status %d, got %d", http.StatusRequestEntityTooLarge, rr.Code)
+	}
+
+	if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("expected a JSON error, got Content-Type %q", ct)
+	}
+
+	if users := a.store.List("", userFilter{}); len(users) != 0 {
+		t.Errorf("expected no user to be created, got %d", len(users))
+	}
+}
+
+func TestUserPreferences(t *testing.T) {
+	a := newTestAPI(t, config{})
+	mux := a.mount()
+
+	if err := a.store.Create("", &User{Username: "tiago"}); err != nil {
+		t.Fatal(err)
+	}
+
+	request := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+
+		return rr
+	}
+
+	decode := func(t *testing.T, rr *httptest.ResponseRecorder) Preferences {
+		t.Helper()
+
+		var prefs Preferences
+		if err := json.NewDecoder(rr.Body).Decode(&prefs); err != nil {
+			t.Fatal(err)
+		}
+
+		return prefs
+	}
+
+	t.Run("defaults", func(t *testing.T) {
+		rr := request(http.MethodGet, "/users/1/preferences", "")
+		checkResponseCode(t, http.StatusOK, rr.Code)
+
+		if got := decode(t, rr); got != defaultPreferences() {
+			t.Errorf("expected defaults, got %+v", got)
+		}
+	})
+
+	t.Run("replace", func(t *testing.T) {
+		rr := request(http.MethodPut, "/users/1/preferences", `{"locale":"pt-BR","theme":"dark","newsletter":true}`)
+		checkResponseCode(t, http.StatusOK, rr.Code)
+
+		want := Preferences{Locale: "pt-BR", Theme: "dark", Newsletter: true}
+
+		rr = request(http.MethodGet, "/users/1/preferences", "")
+		if got := decode(t, rr); got != want {
+			t.Errorf("expected %+v, got %+v", want, got)
+		}
+	})
+
+	t.Run("validation", func(t *testing.T) {
+		rr := request(http.MethodPut, "/users/1/preferences", `{"locale":"klingon","theme":"neon"}`)
+		checkResponseCode(t, http.StatusUnprocessableEntity, rr.Code)
+
+		var body struct {
+			Fields map[string]string `json:"fields"`
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+
+		if body.Fields["locale"] == "" || body.Fields["theme"] == "" {
+			t.Errorf("expected locale and theme errors, got %v", body.Fields)
+		}
+	})
+
+	t.Run("unknown user", func(t *testing.T) {
+		rr := request(http.MethodPut, "/users/42/preferences", `{"locale":"en","theme":"light"}`)
+		checkResponseCode(t, http.StatusNotFound, rr.Code)
+	})
+}
+
+func TestUserPhone(t *testing.T) {
+	ts := newTestServer(t, config{})
+	usersURL := ts.URL + "/users"
+
+	var created User
+	resp := doRequest(t, http.MethodPost, usersURL, createUserPayload{Username: "ana", Phone: "+55 11 98765-4321"}, &created)
+	checkResponseCode(t, http.StatusCreated, resp.StatusCode)
+
+	if created.Phone != "+5511987654321" {
+		t.Errorf("expected normalized phone, got %q", created.Phone)
+	}
+
+	resp = doRequest(t, http.MethodPost, usersURL, createUserPayload{Username: "bia"}, nil)
+	checkResponseCode(t, http.StatusCreated, resp.StatusCode)
+
+	t.Run("invalid phone", func(t *testing.T) {
+		var body struct {
+			Fields map[string]string `json:"fields"`
+		}
+		resp := doRequest(t, http.MethodPatch, usersURL+"/2", map[string]string{"phone": "12345"}, &body)
+		checkResponseCode(t, http.StatusUnprocessableEntity, resp.StatusCode)
+
+		if body.Fields["phone"] == "" {
+			t.Errorf("expected a phone field error, got %v", body.Fields)
+		}
+	})
+
+	t.Run("has_phone filter", func(t *testing.T) {
+		var users []User
+		doRequest(t, http.MethodGet, usersURL+"?has_phone=true", nil, &users)
+
+		if len(users) != 1 || users[0].Username != "ana" {
+			t.Errorf("expected only ana, got %+v", users)
+		}
+
+		doRequest(t, http.MethodGet, usersURL+"?has_phone=false", nil, &users)
+
+		if len(users) != 1 || users[0].Username != "bia" {
+			t.Errorf("expected only bia, got %+v", users)
+		}
+	})
+
+	t.Run("empty string clears", func(t *testing.T) {
+		var updated User
+		resp := doRequest(t, http.MethodPatch, usersURL+"/1", map[string]string{"phone": ""}, &updated)
+		checkResponseCode(t, http.StatusOK, resp.StatusCode)
+
+		if updated.Phone != "" {
+			t.Errorf("expected phone to be cleared, got %q", updated.Phone)
+		}
+	})
+}
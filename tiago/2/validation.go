@@ -0,0 +1,78 @@
+package main
+
+import (
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// validationErrors maps a JSON field name to what is wrong with it. It is an
+// error so a store Update patch can turn the change down with it.
+type validationErrors map[string]string
+
+func (e validationErrors) Error() string {
+	fields := slices.Sorted(maps.Keys(e))
+	return "invalid " + strings.Join(fields, ", ")
+}
+
+var (
+	usernameRegex = regexp.MustCompile(`^[a-z0-9_]{3,30}$`)
+	// E.164: a leading +, no leading zero and 8 to 15 digits in total.
+	phoneRegex = regexp.MustCompile(`^\+[1-9][0-9]{7,14}$`)
+)
+
+// normalizePhone strips the spaces and dashes people commonly type so that
+// "+55 11 98765-4321" validates as +5511987654321.
+func normalizePhone(phone string) string {
+	return strings.NewReplacer(" ", "", "-", "").Replace(phone)
+}
+
+func validatePhone(phone string) bool {
+	return phoneRegex.MatchString(phone)
+}
+
+func normalizeUsername(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
+
+func (u *User) validate() validationErrors {
+	errs := validationErrors{}
+
+	if !usernameRegex.MatchString(u.Username) {
+		errs["username"] = "must be 3-30 characters of lowercase letters, digits or underscores"
+	}
+
+	if u.Phone != "" && !validatePhone(u.Phone) {
+		errs["phone"] = "must be in E.164 format, e.g. +14155552671"
+	}
+
+	return errs
+}
+
+var allowedLocales = map[string]bool{
+	"en": true, "en-US": true, "en-GB": true,
+	"pt": true, "pt-PT": true, "pt-BR": true,
+	"es": true, "es-ES": true, "fr": true, "fr-FR": true,
+	"de": true, "de-DE": true,
+}
+
+var allowedThemes = map[string]bool{
+	"light":  true,
+	"dark":   true,
+	"system": true,
+}
+
+func (p *Preferences) validate() validationErrors {
+	errs := validationErrors{}
+
+	if !allowedLocales[p.Locale] {
+		errs["locale"] = "must be a supported locale such as en or pt-BR"
+	}
+
+	if !allowedThemes[p.Theme] {
+		errs["theme"] = "must be one of light, dark, system"
+	}
+
+	return errs
+}
@@ -0,0 +1,34 @@
+package main
+
+import "testing"
+
+func TestValidatePhone(t *testing.T) {
+	tests := []struct {
+		input string
+		valid bool
+	}{
+		{input: "+14155552671", valid: true},       // US, 1-digit country code
+		{input: "+1 415-555-2671", valid: true},    // spaces and dashes are stripped
+		{input: "+442071838750", valid: true},      // UK, 2-digit country code
+		{input: "+351 912 345 678", valid: true},   // Portugal, 3-digit country code
+		{input: "+55 11 98765-4321", valid: true},  // Brazil mobile
+		{input: "+49301234", valid: true},          // 8 digits, the shortest allowed
+		{input: "+123456789012345", valid: true},   // 15 digits, the longest allowed
+		{input: "14155552671", valid: false},       // missing +
+		{input: "+04155552671", valid: false},      // country codes never start with 0
+		{input: "+1234567", valid: false},          // 7 digits
+		{input: "+1234567890123456", valid: false}, // 16 digits
+		{input: "+1 (415) 555-2671", valid: false}, // parentheses are not stripped
+		{input: "+1415555267a", valid: false},      // letters
+		{input: "++14155552671", valid: false},     // double +
+		{input: "+", valid: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if got := validatePhone(normalizePhone(tt.input)); got != tt.valid {
+				t.Errorf("validatePhone(%q) = %v, want %v", tt.input, got, tt.valid)
+			}
+		})
+	}
+}